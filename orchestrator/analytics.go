@@ -0,0 +1,243 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// AnalyticsOptions controls how usage statistics are anonymized
+type AnalyticsOptions struct {
+	// Salt keys the HMAC used to pseudonymize session identifiers.
+	// When empty, per-session rows are omitted entirely.
+	Salt string
+	// MinGroupSize suppresses aggregate groups with fewer sessions (k-anonymity)
+	MinGroupSize int
+	// Epsilon adds Laplace noise to counts when > 0 (differential privacy)
+	Epsilon float64
+}
+
+// AnalyticsExport is an anonymized summary of orchestrator usage
+type AnalyticsExport struct {
+	GeneratedAt time.Time                `json:"generated_at"`
+	Epsilon     float64                  `json:"epsilon,omitempty"`
+	Languages   []LanguageStats          `json:"languages"`
+	Daily       []DailyStats             `json:"daily"`
+	Sessions    []AnonymizedSessionStats `json:"sessions,omitempty"`
+	Suppressed  int                      `json:"suppressed_groups"`
+}
+
+// LanguageStats aggregates executions for one language
+type LanguageStats struct {
+	Language      string  `json:"language"`
+	Sessions      float64 `json:"sessions"`
+	Executions    float64 `json:"executions"`
+	Failures      float64 `json:"failures"`
+	AvgDurationMs float64 `json:"avg_duration_ms"`
+	P95DurationMs float64 `json:"p95_duration_ms"`
+}
+
+// DailyStats aggregates executions for one day
+type DailyStats struct {
+	Date       string  `json:"date"`
+	Sessions   float64 `json:"sessions"`
+	Executions float64 `json:"executions"`
+	Failures   float64 `json:"failures"`
+}
+
+// AnonymizedSessionStats is a per-session row keyed by a pseudonym
+type AnonymizedSessionStats struct {
+	Pseudonym  string `json:"pseudonym"`
+	Language   string `json:"language"`
+	Date       string `json:"date"`
+	Executions int    `json:"executions"`
+	Failures   int    `json:"failures"`
+}
+
+// BuildAnalytics aggregates session history with identifying fields removed
+func BuildAnalytics(sessions []*Session, opts AnalyticsOptions) *AnalyticsExport {
+	type group struct {
+		sessions   map[string]bool
+		executions int
+		failures   int
+		durations  []float64
+	}
+	newGroup := func() *group { return &group{sessions: make(map[string]bool)} }
+
+	byLanguage := make(map[string]*group)
+	byDay := make(map[string]*group)
+	export := &AnalyticsExport{GeneratedAt: time.Now(), Epsilon: opts.Epsilon}
+
+	for _, s := range sessions {
+		lang := byLanguage[s.Language]
+		if lang == nil {
+			lang = newGroup()
+			byLanguage[s.Language] = lang
+		}
+		lang.sessions[s.ID] = true
+
+		failures := 0
+		for _, exec := range s.State.History {
+			day := exec.Time.UTC().Format("2006-01-02")
+			d := byDay[day]
+			if d == nil {
+				d = newGroup()
+				byDay[day] = d
+			}
+			d.sessions[s.ID] = true
+			d.executions++
+			lang.executions++
+			lang.durations = append(lang.durations, exec.Duration)
+			if exec.ExitCode != 0 {
+				d.failures++
+				lang.failures++
+				failures++
+			}
+		}
+
+		if opts.Salt != "" {
+			mac := hmac.New(sha256.New, []byte(opts.Salt))
+			mac.Write([]byte(s.ID))
+			export.Sessions = append(export.Sessions, AnonymizedSessionStats{
+				Pseudonym:  hex.EncodeToString(mac.Sum(nil))[:16],
+				Language:   s.Language,
+				Date:       s.CreatedAt.UTC().Format("2006-01-02"),
+				Executions: len(s.State.History),
+				Failures:   failures,
+			})
+		}
+	}
+
+	for name, g := range byLanguage {
+		if len(g.sessions) < opts.MinGroupSize {
+			export.Suppressed++
+			continue
+		}
+		stats := LanguageStats{
+			Language:   name,
+			Sessions:   opts.noisy(float64(len(g.sessions))),
+			Executions: opts.noisy(float64(g.executions)),
+			Failures:   opts.noisy(float64(g.failures)),
+		}
+		if len(g.durations) > 0 {
+			sort.Float64s(g.durations)
+			total := 0.0
+			for _, d := range g.durations {
+				total += d
+			}
+			stats.AvgDurationMs = total / float64(len(g.durations))
+			stats.P95DurationMs = g.durations[int(float64(len(g.durations)-1)*0.95)]
+		}
+		export.Languages = append(export.Languages, stats)
+	}
+	sort.Slice(export.Languages, func(i, j int) bool {
+		return export.Languages[i].Language < export.Languages[j].Language
+	})
+
+	for day, g := range byDay {
+		if len(g.sessions) < opts.MinGroupSize {
+			export.Suppressed++
+			continue
+		}
+		export.Daily = append(export.Daily, DailyStats{
+			Date:       day,
+			Sessions:   opts.noisy(float64(len(g.sessions))),
+			Executions: opts.noisy(float64(g.executions)),
+			Failures:   opts.noisy(float64(g.failures)),
+		})
+	}
+	sort.Slice(export.Daily, func(i, j int) bool {
+		return export.Daily[i].Date < export.Daily[j].Date
+	})
+
+	return export
+}
+
+// noisy adds Laplace(1/epsilon) noise to a count when differential privacy is enabled
+func (o AnalyticsOptions) noisy(count float64) float64 {
+	if o.Epsilon <= 0 {
+		return count
+	}
+
+	var b [8]byte
+	rand.Read(b[:])
+	u := float64(binary.BigEndian.Uint64(b[:])>>11)/(1<<53) - 0.5
+	noise := -(1 / o.Epsilon) * math.Copysign(1, u) * math.Log(1-2*math.Abs(u))
+
+	return math.Max(0, math.Round(count+noise))
+}
+
+// handleAnalyticsExport serves GET /analytics/export?salt=&min_group=&epsilon=
+func handleAnalyticsExport(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	opts := AnalyticsOptions{
+		Salt:         query.Get("salt"),
+		MinGroupSize: 1,
+	}
+	if v := query.Get("min_group"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "invalid min_group", http.StatusBadRequest)
+			return
+		}
+		opts.MinGroupSize = n
+	}
+	if v := query.Get("epsilon"); v != "" {
+		eps, err := strconv.ParseFloat(v, 64)
+		if err != nil || eps < 0 {
+			http.Error(w, "invalid epsilon", http.StatusBadRequest)
+			return
+		}
+		opts.Epsilon = eps
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(BuildAnalytics(sessionsFor(r.Context()).ListSessions(), opts))
+}
+
+var analyticsCmd = &cobra.Command{
+	Use:   "analytics",
+	Short: "Export anonymized usage statistics",
+	Long: `Export aggregate execution statistics with identifying fields removed.
+
+Session IDs, names and metadata are never included. With --salt, per-session
+rows are emitted under an HMAC pseudonym. --min-group suppresses groups with
+fewer sessions than the threshold, and --epsilon adds Laplace noise to counts
+for differential privacy.
+
+Examples:
+  j0 analytics
+  j0 analytics --min-group 5 --epsilon 0.5`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		salt, _ := cmd.Flags().GetString("salt")
+		minGroup, _ := cmd.Flags().GetInt("min-group")
+		epsilon, _ := cmd.Flags().GetFloat64("epsilon")
+
+		export := BuildAnalytics(sessionManager.ListSessions(), AnalyticsOptions{
+			Salt:         salt,
+			MinGroupSize: minGroup,
+			Epsilon:      epsilon,
+		})
+
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(export)
+	},
+}
+
+func init() {
+	analyticsCmd.Flags().String("salt", "", "Secret used to pseudonymize per-session rows (omitted if empty)")
+	analyticsCmd.Flags().Int("min-group", 1, "Suppress groups with fewer sessions than this")
+	analyticsCmd.Flags().Float64("epsilon", 0, "Differential privacy budget for Laplace noise (0 disables)")
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Headers for API version negotiation
+const (
+	// acceptVersionHeader lets clients pin a version on unprefixed routes
+	acceptVersionHeader = "Accept-Version"
+	// apiVersionHeader reports the version that served the request
+	apiVersionHeader = "API-Version"
+)
+
+// currentAPIVersion is served when a request doesn't ask for a version
+const currentAPIVersion = "1"
+
+// APIVersion describes a supported API version. A version whose response
+// shapes changed incompatibly gets a new entry; old ones stay until their
+// sunset so pinned clients keep working.
+type APIVersion struct {
+	// Deprecated versions are announced in Deprecation and Sunset headers
+	Deprecated bool
+	Sunset     time.Time
+}
+
+// apiVersions lists the versions this server speaks
+var apiVersions = map[string]APIVersion{
+	"1": {},
+}
+
+// legacySunset is announced on unprefixed routes, kept as aliases of /v1;
+// zero omits the Sunset header
+var legacySunset time.Time
+
+// unversionedPrefixes are not part of the versioned API: probes, docs and
+// URLs handed to browsers or other systems
+var unversionedPrefixes = []string{"/health", "/openapi.json", "/docs", "/s/", "/lti/", callbackPath}
+
+type apiVersionKey struct{}
+
+// apiVersionFromContext returns the API version negotiated for the request,
+// for handlers whose response shape differs between versions
+func apiVersionFromContext(ctx context.Context) string {
+	if v, ok := ctx.Value(apiVersionKey{}).(string); ok {
+		return v
+	}
+	return currentAPIVersion
+}
+
+// supportedAPIVersions lists version names for error messages
+func supportedAPIVersions() string {
+	names := make([]string, 0, len(apiVersions))
+	for v := range apiVersions {
+		names = append(names, "v"+v)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// withAPIVersion serves /v<N>/... by routing the rest of the path to the
+// handlers, and marks the legacy unprefixed routes as deprecated aliases
+// of /v1. Unknown versions are rejected rather than served with a
+// different response shape.
+func withAPIVersion(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		version, rest, prefixed := splitVersionPrefix(r.URL.Path)
+		if !prefixed {
+			for _, p := range unversionedPrefixes {
+				if strings.HasPrefix(r.URL.Path, p) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			version = currentAPIVersion
+			if v := r.Header.Get(acceptVersionHeader); v != "" {
+				version = strings.TrimPrefix(v, "v")
+			}
+		}
+
+		info, ok := apiVersions[version]
+		if !ok {
+			http.Error(w, fmt.Sprintf("unsupported API version v%s (supported: %s)", version, supportedAPIVersions()), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set(apiVersionHeader, version)
+		if info.Deprecated {
+			w.Header().Set("Deprecation", "true")
+			if !info.Sunset.IsZero() {
+				w.Header().Set("Sunset", info.Sunset.UTC().Format(http.TimeFormat))
+			}
+		}
+
+		if !prefixed {
+			// The unprefixed alias keeps working but points at its successor
+			w.Header().Set("Deprecation", "true")
+			w.Header().Add("Link", fmt.Sprintf("</v%s%s>; rel=\"successor-version\"", version, r.URL.Path))
+			if !legacySunset.IsZero() {
+				w.Header().Set("Sunset", legacySunset.UTC().Format(http.TimeFormat))
+			}
+		}
+
+		ctx := context.WithValue(r.Context(), apiVersionKey{}, version)
+		r = r.Clone(ctx)
+		if prefixed {
+			r.URL.Path = rest
+			r.URL.RawPath = strings.TrimPrefix(r.URL.RawPath, "/v"+version)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// splitVersionPrefix splits "/v1/sessions" into "1" and "/sessions"
+func splitVersionPrefix(path string) (string, string, bool) {
+	if !strings.HasPrefix(path, "/v") {
+		return "", path, false
+	}
+	seg, rest, _ := strings.Cut(path[2:], "/")
+	if seg == "" || strings.Trim(seg, "0123456789") != "" {
+		return "", path, false
+	}
+	return seg, "/" + rest, true
+}
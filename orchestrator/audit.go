@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// auditLog records state-changing operations, nil until initialized
+var auditLog *AuditLog
+
+// AuditEntry is one state-changing operation
+type AuditEntry struct {
+	Time      time.Time              `json:"time"`
+	Actor     string                 `json:"actor"`
+	Tenant    string                 `json:"tenant,omitempty"`
+	Action    string                 `json:"action"`
+	SessionID string                 `json:"session_id,omitempty"`
+	RequestID string                 `json:"request_id,omitempty"`
+	Params    map[string]interface{} `json:"params,omitempty"`
+}
+
+// AuditQuery filters audit entries; zero values match everything
+type AuditQuery struct {
+	Actor     string
+	Tenant    string
+	Action    string
+	SessionID string
+	Since     time.Time
+	Until     time.Time
+	Limit     int
+}
+
+func (q AuditQuery) matches(e *AuditEntry) bool {
+	return (q.Actor == "" || e.Actor == q.Actor) &&
+		(q.Tenant == "" || e.Tenant == q.Tenant) &&
+		(q.Action == "" || e.Action == q.Action) &&
+		(q.SessionID == "" || e.SessionID == q.SessionID) &&
+		(q.Since.IsZero() || !e.Time.Before(q.Since)) &&
+		(q.Until.IsZero() || e.Time.Before(q.Until))
+}
+
+// AuditLog is an append-only JSONL file of AuditEntry records
+type AuditLog struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewAuditLog opens (creating if needed) <data-dir>/audit.jsonl
+func NewAuditLog(dataDir string) (*AuditLog, error) {
+	path := filepath.Join(dataDir, "audit.jsonl")
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	f.Close()
+	return &AuditLog{path: path}, nil
+}
+
+// Record appends an entry attributed to the actor in ctx
+func (a *AuditLog) Record(ctx context.Context, action, sessionID string, params map[string]interface{}) {
+	entry := AuditEntry{
+		Time:      time.Now().UTC(),
+		Actor:     actorFromContext(ctx),
+		Tenant:    tenantFromContext(ctx),
+		Action:    action,
+		SessionID: sessionID,
+		RequestID: requestIDFromContext(ctx),
+		Params:    params,
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := appendJSONLine(a.path, entry); err != nil {
+		log.Printf("Warning: failed to write audit entry %s: %v", action, err)
+	}
+}
+
+// Query returns matching entries, most recent last, keeping the newest
+// q.Limit entries when a limit is set
+func (a *AuditLog) Query(q AuditQuery) ([]AuditEntry, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	f, err := os.Open(a.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entries := []AuditEntry{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var e AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil || !q.matches(&e) {
+			continue
+		}
+		entries = append(entries, e)
+		if q.Limit > 0 && len(entries) > q.Limit {
+			entries = entries[1:]
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// audit records an operation when the audit log is enabled
+func audit(ctx context.Context, action, sessionID string, params map[string]interface{}) {
+	if auditLog != nil {
+		auditLog.Record(ctx, action, sessionID, params)
+	}
+}
+
+type actorKey struct{}
+
+// withActor returns ctx attributed to actor
+func withActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// actorFromContext returns the actor in ctx, defaulting to the local CLI user
+func actorFromContext(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	return cliActor()
+}
+
+// cliActor identifies operations run from the command line
+func cliActor() string {
+	if u, err := user.Current(); err == nil {
+		return "cli:" + u.Username
+	}
+	return "cli"
+}
+
+// systemContext attributes background operations (reapers, watchdog)
+var systemContext = withActor(context.Background(), "system")
+
+// anonymousActor identifies unauthenticated HTTP clients by address
+func anonymousActor(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "anonymous@" + host
+}
+
+// handleAuditQuery serves GET /audit?actor=&tenant=&action=&session=&since=&until=&limit=
+// Requests scoped to a tenant only see that tenant's entries.
+func handleAuditQuery(w http.ResponseWriter, r *http.Request) {
+	if !hasRole(r.Context(), RoleAdmin) {
+		http.Error(w, "only admins can read the audit log", http.StatusForbidden)
+		return
+	}
+	query := r.URL.Query()
+	q := AuditQuery{
+		Actor:     query.Get("actor"),
+		Tenant:    query.Get("tenant"),
+		Action:    query.Get("action"),
+		SessionID: query.Get("session"),
+		Limit:     1000,
+	}
+
+	for name, dst := range map[string]*time.Time{"since": &q.Since, "until": &q.Until} {
+		if v := query.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				http.Error(w, "invalid "+name+" (want RFC 3339)", http.StatusBadRequest)
+				return
+			}
+			*dst = t
+		}
+	}
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		q.Limit = n
+	}
+
+	if tenant := tenantFromContext(r.Context()); tenant != "" {
+		q.Tenant = tenant
+	}
+
+	entries, err := auditLog.Query(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
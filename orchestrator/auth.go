@@ -0,0 +1,240 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// apiKeysEnv holds additional keys as comma-separated name=key pairs
+const apiKeysEnv = "J0_API_KEYS"
+
+// apiKeys authenticates HTTP requests; nil leaves the API open
+var apiKeys *APIKeyStore
+
+// APIKey is a named credential accepted in the Authorization header
+type APIKey struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
+	// RateLimit is requests per minute; 0 uses the store default
+	RateLimit int `json:"rate_limit,omitempty"`
+	// Tenant, when set, confines the key to that tenant's data
+	Tenant string `json:"tenant,omitempty"`
+	// Role is admin, operator or read-only (default operator)
+	Role string `json:"role,omitempty"`
+}
+
+// keyLimiter is a token bucket refilled at limit tokens per minute
+type keyLimiter struct {
+	mu     sync.Mutex
+	limit  int
+	tokens float64
+	last   time.Time
+}
+
+// allow takes a token, or reports how long until one is available
+func (l *keyLimiter) allow() (bool, time.Duration) {
+	if l.limit <= 0 {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	perSecond := float64(l.limit) / 60
+	l.tokens = math.Min(float64(l.limit), l.tokens+now.Sub(l.last).Seconds()*perSecond)
+	l.last = now
+
+	if l.tokens >= 1 {
+		l.tokens--
+		return true, 0
+	}
+	wait := (1 - l.tokens) / perSecond
+	return false, time.Duration(wait * float64(time.Second))
+}
+
+// available reports the tokens left without taking one
+func (l *keyLimiter) available() int {
+	if l.limit <= 0 {
+		return -1
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	refilled := l.tokens + time.Since(l.last).Seconds()*float64(l.limit)/60
+	return int(math.Min(float64(l.limit), refilled))
+}
+
+// APIKeyStore holds configured keys indexed by their SHA-256 digest so the
+// plaintext is not kept around for comparisons
+type APIKeyStore struct {
+	keys     map[[sha256.Size]byte]*APIKey
+	limiters map[string]*keyLimiter
+}
+
+// LoadAPIKeys reads keys from a JSON file (array of APIKey) and the
+// J0_API_KEYS environment variable. It returns nil when neither has keys.
+func LoadAPIKeys(path string, defaultRateLimit int) (*APIKeyStore, error) {
+	var keys []APIKey
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read API keys: %w", err)
+		}
+		if err := json.Unmarshal(data, &keys); err != nil {
+			return nil, fmt.Errorf("failed to parse API keys: %w", err)
+		}
+	}
+
+	if env := os.Getenv(apiKeysEnv); env != "" {
+		for _, pair := range strings.Split(env, ",") {
+			name, key, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if !ok {
+				return nil, fmt.Errorf("%s: expected name=key, got %q", apiKeysEnv, pair)
+			}
+			keys = append(keys, APIKey{Name: name, Key: key})
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	store := &APIKeyStore{
+		keys:     make(map[[sha256.Size]byte]*APIKey),
+		limiters: make(map[string]*keyLimiter),
+	}
+	for i := range keys {
+		k := &keys[i]
+		if k.Name == "" || k.Key == "" {
+			return nil, fmt.Errorf("API key %d: name and key are required", i+1)
+		}
+		if _, dup := store.limiters[k.Name]; dup {
+			return nil, fmt.Errorf("duplicate API key name: %s", k.Name)
+		}
+		if err := validateRole(k.Role); err != nil {
+			return nil, fmt.Errorf("API key %s: %w", k.Name, err)
+		}
+		limit := k.RateLimit
+		if limit == 0 {
+			limit = defaultRateLimit
+		}
+		store.keys[sha256.Sum256([]byte(k.Key))] = k
+		store.limiters[k.Name] = &keyLimiter{limit: limit, tokens: float64(limit), last: time.Now()}
+	}
+	return store, nil
+}
+
+// Lookup returns the key matching secret
+func (s *APIKeyStore) Lookup(secret string) (*APIKey, bool) {
+	digest := sha256.Sum256([]byte(secret))
+	for d, k := range s.keys {
+		if subtle.ConstantTimeCompare(d[:], digest[:]) == 1 {
+			return k, true
+		}
+	}
+	return nil, false
+}
+
+// publicPaths are served without credentials; anything else needs them
+// once API keys or OIDC are configured. LTI endpoints check the platform's
+// signed messages and Judge0 callbacks carry their own HMAC signature.
+var publicPaths = []string{"/health", "/openapi.json", "/docs", "/lti/login", "/lti/launch", "/lti/jwks", callbackPath}
+
+// requiresAuth reports whether a request needs an API key or token.
+// Published snapshots are public to read.
+func requiresAuth(method, path string) bool {
+	if (method == http.MethodGet || method == http.MethodHead) && strings.HasPrefix(path, "/s/") {
+		return false
+	}
+	for _, p := range publicPaths {
+		if path == p || strings.HasPrefix(path, p+"/") {
+			return false
+		}
+	}
+	return true
+}
+
+// bearerToken extracts the credential from "Authorization: Bearer <token>"
+func bearerToken(r *http.Request) string {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// withAuth requires credentials on protected paths once API keys or OIDC
+// are configured. OIDC tokens authenticate "user:<sub>", scoped to the
+// user's own sessions unless they hold the admin role; API keys
+// authenticate "key:<name>" and are rate limited per key. Both record the
+// caller's role for withRBAC.
+func withAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if apiKeys == nil && oidcAuth == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !requiresAuth(r.Method, r.URL.Path) {
+			// Public routes run anonymously, never with the admin default
+			next.ServeHTTP(w, r.WithContext(withRole(r.Context(), roleAnonymous)))
+			return
+		}
+
+		token := bearerToken(r)
+		if oidcAuth != nil && looksLikeJWT(token) {
+			sub, roles, err := oidcAuth.Authenticate(token)
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="j0", error="invalid_token"`)
+				http.Error(w, "invalid token: "+err.Error(), http.StatusUnauthorized)
+				return
+			}
+			role := oidcAuth.Role(roles)
+			ctx := withRole(authenticatedAs(r.Context(), "user:"+sub), role)
+			if role != RoleAdmin {
+				ctx = withOwnerScope(ctx)
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
+		var key *APIKey
+		ok := false
+		if apiKeys != nil {
+			key, ok = apiKeys.Lookup(token)
+		}
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="j0"`)
+			http.Error(w, "missing or invalid credentials", http.StatusUnauthorized)
+			return
+		}
+
+		r = r.WithContext(withRole(authenticatedAs(r.Context(), "key:"+key.Name), key.Role))
+
+		if allowed, wait := apiKeys.limiters[key.Name].allow(); !allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, "rate limit exceeded for API key "+key.Name, http.StatusTooManyRequests)
+			return
+		}
+
+		if key.Tenant != "" {
+			if t := r.Header.Get(tenantHeader); t != "" && t != key.Tenant {
+				http.Error(w, "API key is not valid for tenant "+t, http.StatusForbidden)
+				return
+			}
+			r = r.WithContext(withCredentialTenant(r.Context(), key.Tenant))
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
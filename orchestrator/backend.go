@@ -0,0 +1,320 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// defaultJudge0Version is the Judge0 release j0 backend up pins by default;
+// "latest" is avoided so a restart never silently upgrades the backend
+const defaultJudge0Version = "1.13.1"
+
+// backendProject is the docker compose project name for the local backend
+const backendProject = "j0-backend"
+
+// BackendState records a local Judge0 started by j0 backend up. While it
+// exists, commands use its URL unless --judge0-url is given.
+type BackendState struct {
+	URL       string    `json:"url"`
+	Version   string    `json:"version"`
+	Port      int       `json:"port"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// backendDir holds the generated compose file, config and state
+func backendDir() string {
+	return filepath.Join(dataDir, "backend")
+}
+
+func backendStatePath() string {
+	return filepath.Join(backendDir(), "backend.json")
+}
+
+// loadBackendState returns the local backend's state, or nil if none is running
+func loadBackendState() (*BackendState, error) {
+	data, err := os.ReadFile(backendStatePath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var state BackendState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("invalid backend state %s: %w", backendStatePath(), err)
+	}
+	return &state, nil
+}
+
+var backendComposeTemplate = template.Must(template.New("compose").Parse(`# Generated by j0 backend up; changes are overwritten
+x-logging: &default-logging
+  logging:
+    driver: json-file
+    options:
+      max-size: 100M
+
+services:
+  server:
+    image: judge0/judge0:{{.Version}}
+    volumes:
+      - ./judge0.conf:/judge0.conf:ro
+    ports:
+      - "127.0.0.1:{{.Port}}:2358"
+    privileged: true
+    <<: *default-logging
+    restart: unless-stopped
+
+  worker:
+    image: judge0/judge0:{{.Version}}
+    command: ["./scripts/workers"]
+    volumes:
+      - ./judge0.conf:/judge0.conf:ro
+    privileged: true
+    <<: *default-logging
+    restart: unless-stopped
+
+  db:
+    image: postgres:16.2
+    env_file: judge0.conf
+    volumes:
+      - data:/var/lib/postgresql/data/
+    <<: *default-logging
+    restart: unless-stopped
+
+  redis:
+    image: redis:7.2.4
+    command: [
+      "bash", "-c",
+      'docker-entrypoint.sh --appendonly no --requirepass "$$REDIS_PASSWORD"'
+    ]
+    env_file: judge0.conf
+    <<: *default-logging
+    restart: unless-stopped
+
+volumes:
+  data:
+`))
+
+// writeBackendConfig generates judge0.conf once, with random database and
+// Redis passwords, and rewrites the compose file for version and port
+func writeBackendConfig(version string, port int) error {
+	dir := backendDir()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	confPath := filepath.Join(dir, "judge0.conf")
+	if _, err := os.Stat(confPath); os.IsNotExist(err) {
+		conf := fmt.Sprintf(`# Generated by j0 backend up; kept across restarts so the database stays readable
+REDIS_HOST=redis
+REDIS_PASSWORD=%s
+POSTGRES_HOST=db
+POSTGRES_DB=judge0
+POSTGRES_USER=judge0
+POSTGRES_PASSWORD=%s
+ENABLE_ADDITIONAL_FILES=true
+ENABLE_COMPILER_OPTIONS=true
+CPU_TIME_LIMIT=10
+WALL_TIME_LIMIT=20
+MAX_WALL_TIME_LIMIT=60
+MEMORY_LIMIT=256000
+MAX_MEMORY_LIMIT=512000
+`, randomSecret(), randomSecret())
+		if err := os.WriteFile(confPath, []byte(conf), 0600); err != nil {
+			return err
+		}
+	}
+
+	var compose strings.Builder
+	if err := backendComposeTemplate.Execute(&compose, map[string]interface{}{"Version": version, "Port": port}); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "docker-compose.yml"), []byte(compose.String()), 0644)
+}
+
+func randomSecret() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// dockerCompose runs a docker compose subcommand on the generated project,
+// using the compose plugin or the standalone docker-compose binary
+func dockerCompose(args ...string) error {
+	base := []string{"-f", filepath.Join(backendDir(), "docker-compose.yml"), "-p", backendProject}
+
+	var cmd *exec.Cmd
+	if exec.Command("docker", "compose", "version").Run() == nil {
+		cmd = exec.Command("docker", append(append([]string{"compose"}, base...), args...)...)
+	} else if path, err := exec.LookPath("docker-compose"); err == nil {
+		cmd = exec.Command(path, append(base, args...)...)
+	} else {
+		return fmt.Errorf("docker compose not found: install Docker with the compose plugin")
+	}
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// waitForBackend polls Judge0 until it answers /about and has a worker
+// available, or timeout passes or ctx is cancelled
+func waitForBackend(ctx context.Context, url string, timeout time.Duration) error {
+	client := NewJudge0Client(url)
+	deadline := time.Now().Add(timeout)
+	var lastErr error
+	for time.Now().Before(deadline) {
+		if _, err := client.About(ctx); err != nil {
+			lastErr = err
+		} else if queues, err := client.Workers(ctx); err != nil {
+			lastErr = err
+		} else if workersAvailable(queues) {
+			return nil
+		} else {
+			lastErr = fmt.Errorf("no workers available yet")
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(2 * time.Second):
+		}
+	}
+	return fmt.Errorf("Judge0 at %s not ready after %s: %v", url, timeout, lastErr)
+}
+
+// workersAvailable reports whether any Judge0 queue has an available worker
+func workersAvailable(queues []map[string]interface{}) bool {
+	for _, q := range queues {
+		if n, _ := q["available"].(float64); n > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+var backendCmd = &cobra.Command{
+	Use:   "backend",
+	Short: "Run a local Judge0 with docker compose",
+	Long: `Provision and manage a local Judge0 backend with docker compose.
+
+j0 backend up generates a compose file and judge0.conf under the data
+directory, starts Judge0 pinned to a version, and waits until it is healthy.
+While it runs, other j0 commands use it automatically unless --judge0-url
+is given.
+
+Examples:
+  j0 backend up
+  j0 backend up --version 1.13.1 --port 2358
+  j0 backend status
+  j0 backend down`,
+}
+
+var backendUpCmd = &cobra.Command{
+	Use:   "up",
+	Short: "Start a local Judge0 and wait until it is ready",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		version, _ := cmd.Flags().GetString("version")
+		port, _ := cmd.Flags().GetInt("port")
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+
+		if err := writeBackendConfig(version, port); err != nil {
+			return fmt.Errorf("failed to write backend config: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "Starting Judge0 %s (config in %s)\n", version, backendDir())
+		if err := dockerCompose("up", "-d"); err != nil {
+			return fmt.Errorf("docker compose up failed: %w", err)
+		}
+
+		url := fmt.Sprintf("http://localhost:%d", port)
+		fmt.Fprintf(os.Stderr, "Waiting for Judge0 at %s...\n", url)
+		if err := waitForBackend(cmd.Context(), url, timeout); err != nil {
+			return err
+		}
+
+		state := BackendState{URL: url, Version: version, Port: port, StartedAt: time.Now()}
+		data, _ := json.MarshalIndent(state, "", "  ")
+		if err := os.WriteFile(backendStatePath(), data, 0644); err != nil {
+			return err
+		}
+		fmt.Printf("Judge0 %s is ready at %s\n", version, url)
+		return nil
+	},
+}
+
+var backendDownCmd = &cobra.Command{
+	Use:   "down",
+	Short: "Stop the local Judge0",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		volumes, _ := cmd.Flags().GetBool("volumes")
+
+		if _, err := os.Stat(filepath.Join(backendDir(), "docker-compose.yml")); os.IsNotExist(err) {
+			return fmt.Errorf("no local backend in %s", backendDir())
+		}
+		composeArgs := []string{"down"}
+		if volumes {
+			composeArgs = append(composeArgs, "--volumes")
+		}
+		if err := dockerCompose(composeArgs...); err != nil {
+			return fmt.Errorf("docker compose down failed: %w", err)
+		}
+		if err := os.Remove(backendStatePath()); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		fmt.Println("Judge0 stopped")
+		return nil
+	},
+}
+
+var backendStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the local Judge0's containers and health",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		state, err := loadBackendState()
+		if err != nil {
+			return err
+		}
+		if state == nil {
+			fmt.Printf("No local backend running (using %s)\n", judge0URL)
+			return nil
+		}
+
+		fmt.Printf("Judge0 %s at %s, started %s\n", state.Version, state.URL, state.StartedAt.Format(time.RFC3339))
+		if err := dockerCompose("ps"); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: docker compose ps failed: %v\n", err)
+		}
+
+		client := NewJudge0Client(state.URL)
+		if _, err := client.About(cmd.Context()); err != nil {
+			fmt.Printf("Health: unreachable (%v)\n", err)
+			return nil
+		}
+		queues, err := client.Workers(cmd.Context())
+		if err != nil || !workersAvailable(queues) {
+			fmt.Println("Health: API up, no workers available")
+			return nil
+		}
+		fmt.Println("Health: ready")
+		return nil
+	},
+}
+
+func init() {
+	backendUpCmd.Flags().String("version", defaultJudge0Version, "Judge0 image version to run")
+	backendUpCmd.Flags().Int("port", 2358, "Local port for the Judge0 API")
+	backendUpCmd.Flags().Duration("timeout", 5*time.Minute, "How long to wait for Judge0 to become ready")
+	backendDownCmd.Flags().Bool("volumes", false, "Also delete the backend's database volume")
+
+	backendCmd.AddCommand(backendUpCmd)
+	backendCmd.AddCommand(backendDownCmd)
+	backendCmd.AddCommand(backendStatusCmd)
+}
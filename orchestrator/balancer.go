@@ -0,0 +1,328 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Judge0 load balancing strategies for --judge0-balance
+const (
+	BalanceRoundRobin       = "round-robin"
+	BalanceLeastOutstanding = "least-outstanding"
+)
+
+// Multiple backend settings. With --judge0-backends, submissions are
+// spread over several Judge0 instances instead of --judge0-url.
+var (
+	judge0Backends []string
+	judge0Balance  = BalanceRoundRobin
+)
+
+// Circuit breaker states of a pooled backend
+const (
+	CircuitClosed   = "closed"    // submissions are routed to the backend
+	CircuitOpen     = "open"      // the backend is skipped until its cooldown passes
+	CircuitHalfOpen = "half-open" // one trial request decides whether it closes again
+)
+
+// A backend's circuit opens for backendCooldown after
+// backendFailureThreshold consecutive failures
+const (
+	backendFailureThreshold = 3
+	backendCooldown         = 30 * time.Second
+	backendProbeInterval    = 15 * time.Second
+)
+
+// judge0Backend is one Judge0 instance in a pool and its circuit breaker
+type judge0Backend struct {
+	client *Judge0Client
+
+	circuit             string
+	retryAt             time.Time // when an open circuit goes half-open
+	trial               bool      // a half-open trial request is in flight
+	trips               int64
+	outstanding         int
+	submissions         int64
+	failures            int64
+	consecutiveFailures int
+	lastError           string
+	lastChecked         time.Time
+}
+
+// BackendStatus reports one pooled backend, served by the dashboard and
+// /health/ready
+type BackendStatus struct {
+	URL         string     `json:"url"`
+	Healthy     bool       `json:"healthy"`
+	Circuit     string     `json:"circuit"`
+	RetryAt     *time.Time `json:"retry_at,omitempty"`
+	Trips       int64      `json:"trips"`
+	Outstanding int        `json:"outstanding"`
+	Submissions int64      `json:"submissions"`
+	Failures    int64      `json:"failures"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastChecked time.Time  `json:"last_checked,omitempty"`
+}
+
+// BackendsUnavailableError is returned when every backend's circuit is
+// open, instead of sending work to backends known to be failing
+type BackendsUnavailableError struct {
+	RetryAt time.Time
+}
+
+func (e *BackendsUnavailableError) Error() string {
+	return fmt.Sprintf("all Judge0 backends are failing; retry after %s", time.Until(e.RetryAt).Round(time.Second))
+}
+
+// backendPool distributes submissions over several Judge0 instances.
+// Each submission is polled on the backend that created it.
+type backendPool struct {
+	mu       sync.Mutex
+	strategy string
+	backends []*judge0Backend
+	next     int
+}
+
+func newBackendPool(urls []string, strategy string) (*backendPool, error) {
+	if strategy != BalanceRoundRobin && strategy != BalanceLeastOutstanding {
+		return nil, fmt.Errorf("invalid --judge0-balance: %s (want %s or %s)", strategy, BalanceRoundRobin, BalanceLeastOutstanding)
+	}
+	p := &backendPool{strategy: strategy}
+	for _, u := range urls {
+		if _, err := url.ParseRequestURI(u); err != nil {
+			return nil, fmt.Errorf("invalid Judge0 backend URL %q", u)
+		}
+		p.backends = append(p.backends, &judge0Backend{client: NewJudge0Client(u), circuit: CircuitClosed})
+	}
+	if len(p.backends) == 0 {
+		return nil, fmt.Errorf("no Judge0 backends configured")
+	}
+	return p, nil
+}
+
+// state returns the backend's circuit, moving an open circuit whose
+// cooldown has passed to half-open; p.mu must be held
+func (b *judge0Backend) state(now time.Time) string {
+	if b.circuit == CircuitOpen && !now.Before(b.retryAt) {
+		b.circuit = CircuitHalfOpen
+		b.trial = false
+	}
+	return b.circuit
+}
+
+// admit reports whether a request may be sent to the backend now, and
+// claims the trial of a half-open circuit; p.mu must be held
+func (b *judge0Backend) admit(now time.Time) bool {
+	switch b.state(now) {
+	case CircuitClosed:
+		return true
+	case CircuitHalfOpen:
+		if !b.trial {
+			b.trial = true
+			return true
+		}
+	}
+	return false
+}
+
+// pick reserves a backend not in tried whose circuit admits a request.
+// It returns nil when there is none.
+func (p *backendPool) pick(tried map[*judge0Backend]bool) *judge0Backend {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	var best *judge0Backend
+	for i := range p.backends {
+		b := p.backends[(p.next+i)%len(p.backends)]
+		if tried[b] || b.state(now) == CircuitOpen || (b.circuit == CircuitHalfOpen && b.trial) {
+			continue
+		}
+		if best == nil || (p.strategy == BalanceLeastOutstanding && b.outstanding < best.outstanding) {
+			best = b
+		}
+		if p.strategy == BalanceRoundRobin {
+			break
+		}
+	}
+	if best == nil || !best.admit(now) {
+		return nil
+	}
+	p.next = (p.next + 1) % len(p.backends)
+	best.outstanding++
+	best.submissions++
+	return best
+}
+
+// release returns a backend reserved by pick, recording whether it failed
+func (p *backendPool) release(b *judge0Backend, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	b.outstanding--
+	p.record(b, backendFault(err))
+}
+
+// record updates a backend's circuit after a request; p.mu must be held.
+// A failed half-open trial reopens the circuit straight away.
+func (p *backendPool) record(b *judge0Backend, fault error) {
+	now := time.Now()
+	b.lastChecked = now
+	halfOpen := b.state(now) == CircuitHalfOpen
+	b.trial = false
+	if fault == nil {
+		b.circuit = CircuitClosed
+		b.consecutiveFailures = 0
+		b.lastError = ""
+		return
+	}
+	b.failures++
+	b.consecutiveFailures++
+	b.lastError = fault.Error()
+	if halfOpen || (b.circuit == CircuitClosed && b.consecutiveFailures >= backendFailureThreshold) {
+		b.circuit = CircuitOpen
+		b.retryAt = now.Add(backendCooldown)
+		b.trips++
+	}
+}
+
+// unavailable returns the error for a pool with every circuit open
+func (p *backendPool) unavailable() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	retryAt := time.Now().Add(backendCooldown)
+	for _, b := range p.backends {
+		if b.circuit == CircuitOpen && b.retryAt.Before(retryAt) {
+			retryAt = b.retryAt
+		}
+	}
+	return &BackendsUnavailableError{RetryAt: retryAt}
+}
+
+// backendFault returns err if it means the backend itself is failing
+// (unreachable, timing out, answering 5xx or not starting submissions)
+// rather than the submission. Requests cancelled by the caller are not
+// the backend's fault.
+func backendFault(err error) error {
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	var transport *url.Error
+	var server *Judge0ServerError
+	var deadline *DeadlineError
+	switch {
+	case errors.As(err, &transport), errors.As(err, &server):
+		return err
+	case errors.As(err, &deadline) && deadline.Kind == DeadlineQueue && deadline.Token != "":
+		return err
+	}
+	return nil
+}
+
+// submit sends a submission to a backend picked by the pool's strategy.
+// If it can't be created there (the backend is failing or rate
+// limiting), nothing ran and it fails over to the next backend.
+func (p *backendPool) submit(ctx context.Context, callbacks *CallbackReceiver, submission Judge0Submission, opts WaitOptions) (*Judge0Result, error) {
+	span := spanFromContext(ctx)
+	tried := make(map[*judge0Backend]bool)
+	var lastErr error
+	for {
+		b := p.pick(tried)
+		if b == nil && lastErr == nil {
+			return nil, p.unavailable()
+		}
+		if b == nil {
+			return nil, lastErr
+		}
+		tried[b] = true
+		span.SetAttr("judge0.backend", b.client.baseURL)
+
+		client := *b.client
+		client.callbacks = callbacks
+		result, err := client.Submit(ctx, submission, opts)
+		p.release(b, err)
+
+		var notCreated *CreateSubmissionError
+		var limited *RateLimitError
+		if errors.As(err, &notCreated) && (backendFault(err) != nil || errors.As(err, &limited)) {
+			lastErr = err
+			continue
+		}
+		return result, err
+	}
+}
+
+// baseURL returns a closed backend's URL for metadata requests (about,
+// languages, workers), which pooled backends are expected to agree on
+func (p *backendPool) baseURL() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	for _, b := range p.backends {
+		if b.state(now) == CircuitClosed {
+			return b.client.baseURL
+		}
+	}
+	return p.backends[0].client.baseURL
+}
+
+// Status reports every backend in the pool
+func (p *backendPool) Status() []BackendStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	statuses := make([]BackendStatus, 0, len(p.backends))
+	for _, b := range p.backends {
+		status := BackendStatus{
+			URL:         b.client.baseURL,
+			Circuit:     b.state(now),
+			Trips:       b.trips,
+			Outstanding: b.outstanding,
+			Submissions: b.submissions,
+			Failures:    b.failures,
+			LastError:   b.lastError,
+			LastChecked: b.lastChecked,
+		}
+		status.Healthy = status.Circuit == CircuitClosed
+		if status.Circuit == CircuitOpen {
+			retryAt := b.retryAt
+			status.RetryAt = &retryAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// RunHealthChecks probes backends' /about each interval until ctx is
+// done. Closed circuits are probed to catch failures between submissions;
+// a half-open circuit is probed as its trial request, so failed backends
+// come back as soon as they recover even without traffic.
+func (p *backendPool) RunHealthChecks(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for _, b := range p.backends {
+			p.mu.Lock()
+			admitted := b.admit(time.Now())
+			p.mu.Unlock()
+			if !admitted {
+				continue
+			}
+			_, err := b.client.About(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+			p.mu.Lock()
+			p.record(b, err)
+			p.mu.Unlock()
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	pool, err := newBackendPool([]string{"http://judge0-a:2358", "http://judge0-b:2358"}, BalanceRoundRobin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b := pool.backends[0], pool.backends[1]
+	fault := &Judge0ServerError{StatusCode: http.StatusBadGateway}
+
+	// The circuit opens after backendFailureThreshold consecutive failures
+	for i := 0; i < backendFailureThreshold; i++ {
+		if a.circuit != CircuitClosed {
+			t.Fatalf("circuit opened after %d failures", i)
+		}
+		pool.record(a, fault)
+	}
+	if a.circuit != CircuitOpen || a.trips != 1 {
+		t.Fatalf("after %d failures: circuit %s, %d trips", backendFailureThreshold, a.circuit, a.trips)
+	}
+	for i := 0; i < 3; i++ {
+		if got := pool.pick(nil); got != b {
+			t.Fatalf("pick %d chose %s with its circuit open", i, got.client.baseURL)
+		}
+		pool.release(b, nil)
+	}
+
+	// After the cooldown one trial request is let through
+	a.retryAt = time.Now().Add(-time.Second)
+	if got := pool.pick(map[*judge0Backend]bool{b: true}); got != a {
+		t.Fatalf("half-open backend not tried: %v", got)
+	}
+	if got := pool.pick(map[*judge0Backend]bool{b: true}); got != nil {
+		t.Fatal("second request admitted during the half-open trial")
+	}
+	// A failed trial reopens the circuit at once
+	pool.release(a, fault)
+	if a.circuit != CircuitOpen || a.trips != 2 {
+		t.Fatalf("after failed trial: circuit %s, %d trips", a.circuit, a.trips)
+	}
+
+	// A successful trial closes it
+	a.retryAt = time.Now().Add(-time.Second)
+	if got := pool.pick(map[*judge0Backend]bool{b: true}); got != a {
+		t.Fatal("half-open backend not tried again")
+	}
+	pool.release(a, nil)
+	if a.circuit != CircuitClosed || a.consecutiveFailures != 0 {
+		t.Fatalf("after successful trial: circuit %s, %d consecutive failures", a.circuit, a.consecutiveFailures)
+	}
+
+	// Client errors don't count against the backend
+	for i := 0; i < backendFailureThreshold; i++ {
+		pool.record(b, backendFault(fmt.Errorf("poll failed: 404 Not Found")))
+	}
+	if b.circuit != CircuitClosed {
+		t.Fatal("circuit opened on client errors")
+	}
+}
+
+func TestBackendFault(t *testing.T) {
+	tests := []struct {
+		name  string
+		err   error
+		fault bool
+	}{
+		{name: "unreachable", err: &url.Error{Op: "Post", URL: "http://judge0", Err: errors.New("connection refused")}, fault: true},
+		{name: "server error", err: &CreateSubmissionError{Err: &Judge0ServerError{StatusCode: 503}}, fault: true},
+		{name: "queue timeout", err: &DeadlineError{Kind: DeadlineQueue, Token: "tok"}, fault: true},
+		{name: "no concurrency slot", err: &DeadlineError{Kind: DeadlineQueue}, fault: false},
+		{name: "slow code", err: &DeadlineError{Kind: DeadlineTotal, Token: "tok"}, fault: false},
+		{name: "cancelled", err: &url.Error{Op: "Post", URL: "http://judge0", Err: context.Canceled}, fault: false},
+		{name: "rate limited", err: &RateLimitError{}, fault: false},
+		{name: "success", err: nil, fault: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := backendFault(tt.err) != nil; got != tt.fault {
+				t.Fatalf("backendFault = %v, want %v", got, tt.fault)
+			}
+		})
+	}
+}
+
+func TestPoolFailsOverWhenCreateFails(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down for maintenance", http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusCreated)
+		}
+		w.Write([]byte(`{"token":"tok","stdout":"aGkK","status":{"id":3,"description":"Accepted"}}`))
+	}))
+	defer healthy.Close()
+
+	pool, err := newBackendPool([]string{failing.URL, healthy.URL}, BalanceRoundRobin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < backendFailureThreshold; i++ {
+		result, err := pool.submit(context.Background(), nil, NewSubmission("echo hi", LanguageBash, ""), WaitOptions{Timeout: 5 * time.Second})
+		if err != nil || result.Status.ID != 3 {
+			t.Fatalf("submission %d: %+v, %v", i, result, err)
+		}
+	}
+	// Round robin tried the failing backend on every other submission
+	if status := pool.Status(); status[0].Failures == 0 || status[1].Submissions != backendFailureThreshold {
+		t.Fatalf("status = %+v", status)
+	}
+
+	// With every circuit open, nothing is sent
+	for _, b := range pool.backends {
+		b.circuit, b.retryAt = CircuitOpen, time.Now().Add(time.Minute)
+	}
+	_, err = pool.submit(context.Background(), nil, NewSubmission("echo hi", LanguageBash, ""), WaitOptions{})
+	var unavailable *BackendsUnavailableError
+	if !errors.As(err, &unavailable) {
+		t.Fatalf("error = %v, want BackendsUnavailableError", err)
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Deployment banner settings. The banner comes from --banner-file, or
+// banner.txt in the data directory.
+var (
+	bannerFile       string
+	requirePolicyAck bool
+	acceptPolicy     bool
+)
+
+// deploymentBanner is the loaded banner text; empty when none is configured
+var deploymentBanner string
+
+// policyAckFile records, in each data directory's state files, that the
+// banner was shown and whether its terms were accepted
+const policyAckFile = "policy-ack.json"
+
+// codeExecutingCommands need an accepted policy when --require-policy-ack
+// is set
+var codeExecutingCommands = map[string]bool{
+	"j0 sessions create": true,
+	"j0 exec":            true,
+	"j0 replay":          true,
+}
+
+// PolicyAck is the acknowledgment record for one banner version
+type PolicyAck struct {
+	BannerSHA256   string    `json:"banner_sha256"`
+	ShownAt        time.Time `json:"shown_at"`
+	AcknowledgedAt time.Time `json:"acknowledged_at,omitempty"`
+	AcknowledgedBy string    `json:"acknowledged_by,omitempty"`
+}
+
+// loadBanner reads the deployment banner
+func loadBanner(dataDir string) (string, error) {
+	path := bannerFile
+	if path == "" {
+		path = filepath.Join(dataDir, "banner.txt")
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) && bannerFile == "" {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read banner: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// bannerHash identifies the banner version an acknowledgment applies to
+func bannerHash() string {
+	return sha256Hex([]byte(deploymentBanner))
+}
+
+func loadPolicyAck(dataDir string) *PolicyAck {
+	data, err := os.ReadFile(statePath(dataDir, policyAckFile))
+	if err != nil {
+		return nil
+	}
+	var ack PolicyAck
+	if json.Unmarshal(data, &ack) != nil || ack.BannerSHA256 != bannerHash() {
+		// A changed banner must be shown (and accepted) again
+		return nil
+	}
+	return &ack
+}
+
+func savePolicyAck(dataDir string, ack *PolicyAck) error {
+	data, err := json.MarshalIndent(ack, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeStateFile(dataDir, policyAckFile, data)
+}
+
+// presentBanner shows the banner the first time the CLI uses a data
+// directory, records --accept-policy, and blocks code execution until the
+// policy is accepted when that is required. readOnly commands show the
+// banner without recording anything.
+func presentBanner(commandPath string, readOnly bool) error {
+	if deploymentBanner == "" {
+		return nil
+	}
+
+	ack := loadPolicyAck(dataDir)
+	if ack == nil {
+		fmt.Fprintf(os.Stderr, "%s\n\n", deploymentBanner)
+		if requirePolicyAck && !acceptPolicy {
+			fmt.Fprintln(os.Stderr, "Re-run with --accept-policy to accept these terms.")
+		}
+		ack = &PolicyAck{BannerSHA256: bannerHash(), ShownAt: time.Now()}
+	}
+	if readOnly {
+		return nil
+	}
+
+	if acceptPolicy && ack.AcknowledgedAt.IsZero() {
+		ack.AcknowledgedAt = time.Now()
+		ack.AcknowledgedBy = cliActor()
+	}
+	if err := savePolicyAck(dataDir, ack); err != nil {
+		return err
+	}
+
+	if requirePolicyAck && ack.AcknowledgedAt.IsZero() && codeExecutingCommands[commandPath] {
+		return fmt.Errorf("this deployment requires accepting its usage policy before running code; re-run with --accept-policy")
+	}
+	return nil
+}
+
+// checkPolicyAccepted enforces --require-policy-ack for API clients, which
+// accept the terms shown by /capabilities when creating a session
+func checkPolicyAccepted(accepted bool) error {
+	if !requirePolicyAck || deploymentBanner == "" || accepted {
+		return nil
+	}
+	return fmt.Errorf("this deployment requires accepting its usage policy (see /capabilities); set accept_policy")
+}
+
+// handleCapabilities serves GET /capabilities: the deployment banner and
+// what clients need to know before running code
+func handleCapabilities(w http.ResponseWriter, r *http.Request) {
+	resp := map[string]interface{}{
+		"languages":           canonicalLanguages,
+		"policy_ack_required": requirePolicyAck && deploymentBanner != "",
+	}
+	if deploymentBanner != "" {
+		resp["banner"] = deploymentBanner
+		resp["banner_sha256"] = bannerHash()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPolicyAckIsNotASession(t *testing.T) {
+	dir := withDataDir(t)
+	defer func(saved string) { deploymentBanner = saved }(deploymentBanner)
+	deploymentBanner = "Authorized use only."
+
+	if err := savePolicyAck(dir, &PolicyAck{BannerSHA256: bannerHash()}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, stateDirName, policyAckFile)); err != nil {
+		t.Fatalf("policy acknowledgment not under %s/: %v", stateDirName, err)
+	}
+
+	sm, err := NewSessionManager(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sessions := sm.ListSessions(); len(sessions) != 0 {
+		t.Fatalf("policy acknowledgment loaded as %d session(s)", len(sessions))
+	}
+	if loadPolicyAck(dir) == nil {
+		t.Fatal("saved acknowledgment not loaded")
+	}
+}
+
+func TestPolicyAckResetByNewBanner(t *testing.T) {
+	dir := withDataDir(t)
+	defer func(saved string) { deploymentBanner = saved }(deploymentBanner)
+
+	deploymentBanner = "v1 terms"
+	if err := savePolicyAck(dir, &PolicyAck{BannerSHA256: bannerHash()}); err != nil {
+		t.Fatal(err)
+	}
+	deploymentBanner = "v2 terms"
+	if loadPolicyAck(dir) != nil {
+		t.Fatal("acknowledgment of an older banner still applies")
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// exportedEnvSentinel marks where bash programs dump their exported
+// variables at the end of stdout
+const exportedEnvSentinel = "__J0_EXPORTED_ENV_7f3c9a__"
+
+// bashExportPrelude records the environment the user's code starts with
+// and, on exit, prints every exported variable that was added or changed
+// ("+NAME base64(value)") or unexported ("-NAME") since. Values are base64
+// encoded so newlines survive.
+var bashExportPrelude = `declare -A __j0_env0=()
+for __j0_n in $(compgen -e); do __j0_env0[$__j0_n]=${!__j0_n}; done
+__j0_export_env() {
+	local n
+	local -A seen=()
+	printf '\n%s\n' '` + exportedEnvSentinel + `'
+	for n in $(compgen -e); do
+		seen[$n]=1
+		case $n in _|PWD|OLDPWD|SHLVL) continue ;; esac
+		if [[ ! -v "__j0_env0[$n]" || "${__j0_env0[$n]}" != "${!n}" ]]; then
+			printf '+%s %s\n' "$n" "$(printf '%s' "${!n}" | base64 -w0)"
+		fi
+	done
+	for n in "${!__j0_env0[@]}"; do
+		[[ -v "seen[$n]" ]] || printf -- '-%s\n' "$n"
+	done
+}
+`
+
+// bashPrelude sets up the dumps a bash execution prints on exit: its
+// working directory (see bashfs.go), then its exports. A trap runs them
+// even when the code calls exit, with the user's errexit and nounset off.
+func bashPrelude(exports, workdir bool) string {
+	var prelude strings.Builder
+	handlers := []string{"set +eu +o pipefail"}
+	if workdir {
+		prelude.WriteString(bashWorkdirPrelude)
+		handlers = append(handlers, "__j0_save_workdir")
+	}
+	if exports {
+		prelude.WriteString(bashExportPrelude)
+		handlers = append(handlers, "__j0_export_env")
+	}
+	if len(handlers) == 1 {
+		return ""
+	}
+	fmt.Fprintf(&prelude, "trap '%s' EXIT\n", strings.Join(handlers, "; "))
+	return prelude.String()
+}
+
+// capturesBashExports reports whether a session's bash executions report
+// their exports back; exam sessions can't change their environment
+func capturesBashExports(session *Session, langID int) bool {
+	return langID == LanguageBash && session.Exam == nil
+}
+
+// extractExportedEnv splits the export dump off stdout, returning the
+// user's output, the variables exported or changed and those unexported.
+// Output without a dump (the program was killed, or redirected stdout) is
+// returned unchanged.
+func extractExportedEnv(stdout string) (string, map[string]string, []string) {
+	marker := "\n" + exportedEnvSentinel + "\n"
+	i := strings.LastIndex(stdout, marker)
+	if i < 0 {
+		return stdout, nil, nil
+	}
+
+	set := map[string]string{}
+	var unset []string
+	for _, line := range strings.Split(stdout[i+len(marker):], "\n") {
+		if line == "" {
+			continue
+		}
+		op, rest := line[0], line[1:]
+		switch op {
+		case '+':
+			name, encoded, _ := strings.Cut(rest, " ")
+			value, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil || !validEnvName.MatchString(name) {
+				continue
+			}
+			set[name] = string(value)
+		case '-':
+			if validEnvName.MatchString(rest) {
+				unset = append(unset, rest)
+			}
+		}
+	}
+	sort.Strings(unset)
+	return stdout[:i], set, unset
+}
+
+// mergeExportedEnv applies a bash execution's exports to the session env.
+// Only variables the session set are removed, so unexporting the sandbox's
+// own variables doesn't matter. It returns the names removed.
+func (sm *SessionManager) mergeExportedEnv(sessionID string, set map[string]string, unset []string) ([]string, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	session, ok := sm.sessions[sessionID]
+	if !ok {
+		return nil, fmt.Errorf("session not found: %s", sessionID)
+	}
+	if session.Exam != nil {
+		return nil, fmt.Errorf("environment changes are not allowed in exam sessions")
+	}
+
+	var removed []string
+	for _, name := range unset {
+		_, plain := session.State.Env[name]
+		_, secret := session.State.Secrets[name]
+		if plain || secret {
+			delete(session.State.Env, name)
+			delete(session.State.Secrets, name)
+			removed = append(removed, name)
+		}
+	}
+	if session.State.Env == nil {
+		session.State.Env = map[string]string{}
+	}
+	for name, value := range set {
+		// Secrets stay secret when the code changes them
+		if _, ok := session.State.Secrets[name]; ok {
+			sealed, err := encryptSecret(sm.sealingKey(), sessionID, name, value)
+			if err != nil {
+				return nil, err
+			}
+			session.State.Secrets[name] = sealed
+			continue
+		}
+		session.State.Env[name] = value
+	}
+	if len(set) == 0 && len(removed) == 0 {
+		return nil, nil
+	}
+	session.UpdatedAt = time.Now()
+	return removed, sm.saveSession(session)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os/exec"
+	"reflect"
+	"testing"
+)
+
+// runBash runs script with bash in dir, as Judge0 would, and returns its
+// stdout. The test is skipped where bash isn't installed.
+func runBash(t *testing.T, dir string, env []string, script string) string {
+	t.Helper()
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not installed")
+	}
+	cmd := exec.Command(bash, "-c", script)
+	cmd.Dir = dir
+	cmd.Env = env
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Run()
+	return stdout.String()
+}
+
+func TestBashExportCapture(t *testing.T) {
+	tests := []struct {
+		name  string
+		code  string
+		out   string
+		set   map[string]string
+		unset []string
+	}{
+		{
+			name:  "export and unset",
+			code:  "export NEW=$'two\\nlines'\nexport KEPT=changed\nunset GONE\necho done\n",
+			out:   "done\n",
+			set:   map[string]string{"NEW": "two\nlines", "KEPT": "changed"},
+			unset: []string{"GONE"},
+		},
+		{
+			name: "unchanged",
+			code: "echo -n quiet",
+			out:  "quiet",
+			set:  map[string]string{},
+		},
+		{
+			name: "exit under errexit",
+			code: "set -euo pipefail\nexport CODE=3\nexit 3\n",
+			set:  map[string]string{"CODE": "3"},
+		},
+		{
+			name:  "plain assignment isn't exported",
+			code:  "LOCAL=1\nexport -n KEPT\n",
+			set:   map[string]string{},
+			unset: []string{"KEPT"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout := runBash(t, t.TempDir(), []string{"KEPT=original", "GONE=x"}, bashPrelude(true, false)+tt.code)
+			out, set, unset := extractExportedEnv(stdout)
+			if out != tt.out || !reflect.DeepEqual(set, tt.set) || !reflect.DeepEqual(unset, tt.unset) {
+				t.Fatalf("got %q, set %v, unset %v\nwant %q, set %v, unset %v", out, set, unset, tt.out, tt.set, tt.unset)
+			}
+		})
+	}
+}
+
+func TestExtractExportedEnv(t *testing.T) {
+	encoded := base64.StdEncoding.EncodeToString([]byte("v"))
+	tests := []struct {
+		name   string
+		stdout string
+		out    string
+		set    map[string]string
+		unset  []string
+	}{
+		{name: "no dump", stdout: "killed before exit\n", out: "killed before exit\n"},
+		{
+			name:   "sentinel printed by the user first",
+			stdout: "a\n" + exportedEnvSentinel + "\nb\n" + exportedEnvSentinel + "\n+X " + encoded + "\n",
+			out:    "a\n" + exportedEnvSentinel + "\nb",
+			set:    map[string]string{"X": "v"},
+		},
+		{
+			name:   "invalid entries",
+			stdout: "\n" + exportedEnvSentinel + "\n+BAD-NAME " + encoded + "\n+Y !!!\n-1X\n-Z\n",
+			set:    map[string]string{},
+			unset:  []string{"Z"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, set, unset := extractExportedEnv(tt.stdout)
+			if out != tt.out || !reflect.DeepEqual(set, tt.set) || !reflect.DeepEqual(unset, tt.unset) {
+				t.Fatalf("got %q, set %v, unset %v", out, set, unset)
+			}
+		})
+	}
+}
+
+func TestMergeExportedEnv(t *testing.T) {
+	sm, session := newTestSession(t, "bash", SessionOptions{})
+	sm.secretKey = bytes.Repeat([]byte{5}, 32)
+	sm.SetEnv(session.ID, "PLAIN", "1")
+	sm.SetEnv(session.ID, "DROPPED", "1")
+	if err := sm.SetSecretEnv(session.ID, "TOKEN", "old-token"); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := sm.mergeExportedEnv(session.ID,
+		map[string]string{"PLAIN": "2", "TOKEN": "new-token", "NEW": "3"},
+		[]string{"DROPPED", "HOSTNAME"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only variables the session set are reported removed
+	if !reflect.DeepEqual(removed, []string{"DROPPED"}) {
+		t.Fatalf("removed = %v", removed)
+	}
+
+	saved, _ := sm.GetSession(session.ID)
+	if want := map[string]string{"PLAIN": "2", "NEW": "3"}; !reflect.DeepEqual(saved.State.Env, want) {
+		t.Fatalf("env = %v, want %v", saved.State.Env, want)
+	}
+	token, err := decryptSecret(sm.sealingKey(), session.ID, "TOKEN", saved.State.Secrets["TOKEN"])
+	if err != nil || token != "new-token" {
+		t.Fatalf("secret = %q, %v; want it changed and still sealed", token, err)
+	}
+
+	exam, examSession := newTestSession(t, "bash", SessionOptions{})
+	examSession.Exam = &ExamSettings{}
+	if _, err := exam.mergeExportedEnv(examSession.ID, map[string]string{"X": "1"}, nil); err == nil {
+		t.Fatal("exam session environment changed")
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// workdirSentinel marks where bash programs dump their working directory
+// at the end of stdout
+const workdirSentinel = "__J0_WORKDIR_e81b05__"
+
+// maxWorkdirArchive caps the compressed working directory a bash execution
+// prints; Judge0 kills programs whose output exceeds max_file_size, so it
+// stays well under the backend's default
+const maxWorkdirArchive = 512 << 10
+
+// judge0BoxFiles are the files Judge0 itself writes next to the program;
+// they are never kept in the workspace
+var judge0BoxFiles = []string{"script.sh", "run", "compile"}
+
+// bashWorkdirPrelude records the directory the code starts in, which holds
+// the unpacked workspace, and defines __j0_save_workdir to print it as a
+// base64 tar.gz. Archives over the cap are cut short, so the orchestrator
+// can tell them apart.
+var bashWorkdirPrelude = func() string {
+	excludes := ""
+	for _, name := range judge0BoxFiles {
+		excludes += " --exclude=./" + name
+	}
+	return fmt.Sprintf(`__j0_workdir=$PWD
+__j0_save_workdir() {
+	printf '\n%%s\n' '%s'
+	(cd "$__j0_workdir" && tar -czf -%s . 2>/dev/null | head -c %d | base64 -w0)
+	printf '\n'
+}
+`, workdirSentinel, excludes, maxWorkdirArchive+1)
+}()
+
+// extractWorkdir splits the working directory dump off stdout, returning
+// the user's output and the tar.gz archive. The archive is nil when the
+// program didn't get as far as printing it.
+func extractWorkdir(stdout string) (string, []byte, error) {
+	marker := "\n" + workdirSentinel + "\n"
+	i := strings.LastIndex(stdout, marker)
+	if i < 0 {
+		return stdout, nil, nil
+	}
+
+	out := stdout[:i]
+	archive, err := base64.StdEncoding.DecodeString(strings.TrimSpace(stdout[i+len(marker):]))
+	if err != nil {
+		return out, nil, fmt.Errorf("workspace not kept: invalid archive: %w", err)
+	}
+	if len(archive) > maxWorkdirArchive {
+		return out, nil, fmt.Errorf("workspace not kept: compressed files exceed %d bytes", maxWorkdirArchive)
+	}
+	return out, archive, nil
+}
+
+// lockWorkspace serializes changes to a session's workspace. Bash
+// executions hold it from packaging the workspace until their files are
+// restored and the execution saved, so concurrent runs and file edits
+// aren't lost when the workspace is swapped.
+func (sm *SessionManager) lockWorkspace(sessionID string) (unlock func()) {
+	sm.mu.Lock()
+	if sm.workspaceLocks == nil {
+		sm.workspaceLocks = make(map[string]*sync.Mutex)
+	}
+	l := sm.workspaceLocks[sessionID]
+	if l == nil {
+		l = &sync.Mutex{}
+		sm.workspaceLocks[sessionID] = l
+	}
+	sm.mu.Unlock()
+
+	l.Lock()
+	return l.Unlock
+}
+
+// restoreWorkdir replaces the session workspace with the files in a
+// working directory archive. Only regular files are kept; the archive is
+// unpacked beside the workspace and swapped in, so a bad archive leaves
+// the workspace as it was. The caller holds lockWorkspace.
+func (sm *SessionManager) restoreWorkdir(sessionID string, archive []byte) error {
+	if _, err := sm.GetSession(sessionID); err != nil {
+		return err
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return fmt.Errorf("invalid archive: %w", err)
+	}
+	defer gz.Close()
+
+	dir := sm.WorkspaceDir(sessionID)
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return err
+	}
+	staging, err := os.MkdirTemp(filepath.Dir(dir), sessionID+".new-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
+
+	var total int64
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("invalid archive: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		// Entries are relative to the working directory; anything
+		// escaping it is dropped
+		name := path.Clean("/" + hdr.Name)[1:]
+		if name == "" {
+			continue
+		}
+		total += hdr.Size
+		if total > maxWorkspaceBytes {
+			return fmt.Errorf("workspace exceeds %d bytes", maxWorkspaceBytes)
+		}
+
+		dst := filepath.Join(staging, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(f, tr)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+		os.Chtimes(dst, hdr.ModTime, hdr.ModTime)
+	}
+
+	old := staging + ".old"
+	if err := os.Rename(dir, old); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Rename(staging, dir); err != nil {
+		os.Rename(old, dir)
+		return err
+	}
+	return os.RemoveAll(old)
+}
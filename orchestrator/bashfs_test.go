@@ -0,0 +1,141 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// tarGz archives files, keyed by entry name, as a bash execution would
+func tarGz(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+// workspaceFiles returns the paths in a session workspace
+func workspaceFiles(t *testing.T, sm *SessionManager, sessionID string) string {
+	t.Helper()
+	files, err := sm.ListFiles(sessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, f := range files {
+		paths = append(paths, f.Path)
+	}
+	return strings.Join(paths, ",")
+}
+
+func TestBashWorkdirPersistence(t *testing.T) {
+	sm, session := newTestSession(t, "bash", SessionOptions{})
+	if err := sm.WriteFile(session.ID, "old.txt", []byte("old")); err != nil {
+		t.Fatal(err)
+	}
+	if err := sm.WriteFile(session.ID, "kept.txt", []byte("kept")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Judge0 runs the program in a box holding the workspace and its own files
+	box := t.TempDir()
+	for _, name := range []string{"old.txt", "kept.txt", "script.sh"} {
+		os.WriteFile(filepath.Join(box, name), []byte(name), 0644)
+	}
+	code := "rm old.txt\nmkdir -p data/sub\necho -n results > data/sub/out.csv\nln -s /etc/passwd link\ncd /tmp\necho ran\n"
+	stdout := runBash(t, box, nil, bashPrelude(false, true)+code)
+
+	out, archive, err := extractWorkdir(stdout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "ran\n" {
+		t.Fatalf("output = %q", out)
+	}
+	if err := sm.restoreWorkdir(session.ID, archive); err != nil {
+		t.Fatal(err)
+	}
+
+	// Changes are kept; Judge0's files, links and removed files are not,
+	// and the code changing directory doesn't matter
+	if got := workspaceFiles(t, sm, session.ID); got != "data/sub/out.csv,kept.txt" {
+		t.Fatalf("workspace = %s", got)
+	}
+	if data, _ := sm.ReadFile(session.ID, "data/sub/out.csv"); string(data) != "results" {
+		t.Fatalf("out.csv = %q", data)
+	}
+}
+
+func TestExtractWorkdir(t *testing.T) {
+	archive := tarGz(t, map[string]string{"a.txt": "a"})
+	tests := []struct {
+		name    string
+		stdout  string
+		out     string
+		archive bool
+		err     string
+	}{
+		{name: "no dump", stdout: "killed\n", out: "killed\n"},
+		{name: "archive", stdout: "hi\n\n" + workdirSentinel + "\n" + base64.StdEncoding.EncodeToString(archive) + "\n", out: "hi\n", archive: true},
+		{name: "corrupt", stdout: "\n" + workdirSentinel + "\n!!!\n", err: "invalid archive"},
+		{
+			name:   "cut at the cap",
+			stdout: "\n" + workdirSentinel + "\n" + base64.StdEncoding.EncodeToString(make([]byte, maxWorkdirArchive+1)) + "\n",
+			err:    "exceed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, got, err := extractWorkdir(tt.stdout)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil || out != tt.out || (got != nil) != tt.archive {
+				t.Fatalf("got %q, archive %v, %v", out, got != nil, err)
+			}
+		})
+	}
+}
+
+func TestRestoreWorkdirStaysInWorkspace(t *testing.T) {
+	sm, session := newTestSession(t, "bash", SessionOptions{})
+	if err := sm.WriteFile(session.ID, "kept.txt", []byte("kept")); err != nil {
+		t.Fatal(err)
+	}
+
+	// A bad archive leaves the workspace as it was
+	if err := sm.restoreWorkdir(session.ID, []byte("not gzip")); err == nil {
+		t.Fatal("corrupt archive restored")
+	}
+	if got := workspaceFiles(t, sm, session.ID); got != "kept.txt" {
+		t.Fatalf("workspace after a bad archive = %s", got)
+	}
+
+	archive := tarGz(t, map[string]string{"../../escape.txt": "x", "/abs.txt": "y", "./ok.txt": "z"})
+	if err := sm.restoreWorkdir(session.ID, archive); err != nil {
+		t.Fatal(err)
+	}
+	if got := workspaceFiles(t, sm, session.ID); got != "abs.txt,escape.txt,ok.txt" {
+		t.Fatalf("workspace = %s", got)
+	}
+	if _, err := os.Stat(filepath.Join(sm.dataDir, "escape.txt")); !os.IsNotExist(err) {
+		t.Fatal("archive entry escaped the workspace")
+	}
+}
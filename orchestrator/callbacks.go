@@ -0,0 +1,139 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// callbackSecretEnv overrides the per-process callback signing secret, so
+// callbacks survive a restart behind a load balancer
+const callbackSecretEnv = "J0_CALLBACK_SECRET"
+
+// callbackPath receives Judge0 submission callbacks
+const callbackPath = "/judge0/callback"
+
+// callbackClockSkew tolerates timestamps slightly in the future
+const callbackClockSkew = 30 * time.Second
+
+// CallbackReceiver accepts Judge0's result callbacks so executions finish
+// without waiting for the next poll. Judge0 can't sign its requests, so
+// each submission gets a callback URL carrying a single-use nonce, a
+// timestamp and an HMAC over both; callbacks that fail any check are
+// rejected and polling still delivers the real result.
+type CallbackReceiver struct {
+	baseURL string
+	secret  []byte
+	maxAge  time.Duration
+
+	mu      sync.Mutex
+	pending map[string]chan *Judge0Result
+}
+
+// NewCallbackReceiver issues callback URLs under baseURL, the orchestrator's
+// address as reachable from Judge0 workers
+func NewCallbackReceiver(baseURL, secret string, maxAge time.Duration) *CallbackReceiver {
+	if secret == "" {
+		secret = randomHex(32)
+	}
+	return &CallbackReceiver{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		secret:  []byte(secret),
+		maxAge:  maxAge,
+		pending: make(map[string]chan *Judge0Result),
+	}
+}
+
+// sign computes the signature for a nonce and timestamp
+func (c *CallbackReceiver) sign(nonce, ts string) string {
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write([]byte(nonce + "." + ts))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Register returns a signed callback URL, its nonce and the channel the
+// verified result is delivered on. Callers must Release the nonce.
+func (c *CallbackReceiver) Register() (string, string, <-chan *Judge0Result) {
+	nonce := randomHex(16)
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	ch := make(chan *Judge0Result, 1)
+
+	c.mu.Lock()
+	c.pending[nonce] = ch
+	c.mu.Unlock()
+
+	q := url.Values{"nonce": {nonce}, "ts": {ts}, "sig": {c.sign(nonce, ts)}}
+	return c.baseURL + callbackPath + "?" + q.Encode(), nonce, ch
+}
+
+// Release forgets a nonce once its submission is done
+func (c *CallbackReceiver) Release(nonce string) {
+	c.mu.Lock()
+	delete(c.pending, nonce)
+	c.mu.Unlock()
+}
+
+// verify checks a callback's signature and age and consumes its nonce, so
+// a replayed callback is rejected
+func (c *CallbackReceiver) verify(q url.Values) (chan *Judge0Result, error) {
+	nonce, ts, sig := q.Get("nonce"), q.Get("ts"), q.Get("sig")
+	if !hmac.Equal([]byte(sig), []byte(c.sign(nonce, ts))) {
+		return nil, fmt.Errorf("invalid callback signature")
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid callback timestamp")
+	}
+	age := time.Since(time.Unix(unix, 0))
+	if age > c.maxAge || age < -callbackClockSkew {
+		return nil, fmt.Errorf("callback expired")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch, ok := c.pending[nonce]
+	if !ok {
+		return nil, fmt.Errorf("unknown or already used callback nonce")
+	}
+	delete(c.pending, nonce)
+	return ch, nil
+}
+
+// handleCallback serves PUT /judge0/callback
+func (c *CallbackReceiver) handleCallback(w http.ResponseWriter, r *http.Request) {
+	ch, err := c.verify(r.URL.Query())
+	if err != nil {
+		log.Printf("Warning: rejected Judge0 callback from %s: %v", r.RemoteAddr, err)
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	var result Judge0Result
+	if err := json.NewDecoder(r.Body).Decode(&result); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := result.decodeBase64(); err != nil {
+		http.Error(w, "invalid result encoding: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ch <- &result
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RegisterRoutes adds the callback endpoint
+func (c *CallbackReceiver) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("PUT "+callbackPath, c.handleCallback)
+	mux.HandleFunc("POST "+callbackPath, c.handleCallback)
+}
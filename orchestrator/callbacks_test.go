@@ -0,0 +1,168 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// signedQuery returns the callback query Register would issue for nonce at t
+func signedQuery(c *CallbackReceiver, nonce string, t time.Time) url.Values {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return url.Values{"nonce": {nonce}, "ts": {ts}, "sig": {c.sign(nonce, ts)}}
+}
+
+func TestCallbackVerify(t *testing.T) {
+	tests := []struct {
+		name  string
+		query func(c *CallbackReceiver, nonce string) url.Values
+		want  string // error substring, "" for success
+	}{
+		{
+			name:  "valid",
+			query: func(c *CallbackReceiver, nonce string) url.Values { return signedQuery(c, nonce, time.Now()) },
+		},
+		{
+			name: "bad signature",
+			query: func(c *CallbackReceiver, nonce string) url.Values {
+				q := signedQuery(c, nonce, time.Now())
+				q.Set("sig", strings.Repeat("0", 64))
+				return q
+			},
+			want: "invalid callback signature",
+		},
+		{
+			name: "signature from another secret",
+			query: func(c *CallbackReceiver, nonce string) url.Values {
+				other := NewCallbackReceiver("http://j0", "other-secret", time.Minute)
+				return signedQuery(other, nonce, time.Now())
+			},
+			want: "invalid callback signature",
+		},
+		{
+			name: "tampered timestamp",
+			query: func(c *CallbackReceiver, nonce string) url.Values {
+				q := signedQuery(c, nonce, time.Now().Add(-time.Hour))
+				q.Set("ts", strconv.FormatInt(time.Now().Unix(), 10))
+				return q
+			},
+			want: "invalid callback signature",
+		},
+		{
+			name: "missing signature",
+			query: func(c *CallbackReceiver, nonce string) url.Values {
+				q := signedQuery(c, nonce, time.Now())
+				q.Del("sig")
+				return q
+			},
+			want: "invalid callback signature",
+		},
+		{
+			name: "expired",
+			query: func(c *CallbackReceiver, nonce string) url.Values {
+				return signedQuery(c, nonce, time.Now().Add(-2*time.Minute))
+			},
+			want: "callback expired",
+		},
+		{
+			name: "too far in the future",
+			query: func(c *CallbackReceiver, nonce string) url.Values {
+				return signedQuery(c, nonce, time.Now().Add(time.Hour))
+			},
+			want: "callback expired",
+		},
+		{
+			name: "non-numeric timestamp",
+			query: func(c *CallbackReceiver, nonce string) url.Values {
+				return url.Values{"nonce": {nonce}, "ts": {"soon"}, "sig": {c.sign(nonce, "soon")}}
+			},
+			want: "invalid callback timestamp",
+		},
+		{
+			name:  "unknown nonce",
+			query: func(c *CallbackReceiver, nonce string) url.Values { return signedQuery(c, "not-"+nonce, time.Now()) },
+			want:  "unknown or already used callback nonce",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCallbackReceiver("http://j0", "secret", time.Minute)
+			_, nonce, _ := c.Register()
+
+			ch, err := c.verify(tt.query(c, nonce))
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("verify: %v", err)
+				}
+				if ch == nil {
+					t.Fatal("verify returned no result channel")
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("verify error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCallbackVerifyRejectsReplay(t *testing.T) {
+	c := NewCallbackReceiver("http://j0", "secret", time.Minute)
+	_, nonce, _ := c.Register()
+	q := signedQuery(c, nonce, time.Now())
+
+	if _, err := c.verify(q); err != nil {
+		t.Fatalf("first callback: %v", err)
+	}
+	if _, err := c.verify(q); err == nil || !strings.Contains(err.Error(), "already used") {
+		t.Fatalf("replayed callback error = %v, want nonce rejection", err)
+	}
+}
+
+func TestCallbackVerifyRejectsReleasedNonce(t *testing.T) {
+	c := NewCallbackReceiver("http://j0", "secret", time.Minute)
+	_, nonce, _ := c.Register()
+	c.Release(nonce)
+
+	if _, err := c.verify(signedQuery(c, nonce, time.Now())); err == nil {
+		t.Fatal("callback for a released nonce was accepted")
+	}
+}
+
+func TestHandleCallback(t *testing.T) {
+	c := NewCallbackReceiver("http://j0", "secret", time.Minute)
+	callbackURL, _, results := c.Register()
+	u, err := url.Parse(callbackURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	put := func(rawQuery, body string) int {
+		r := httptest.NewRequest(http.MethodPut, callbackPath+"?"+rawQuery, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		c.handleCallback(w, r)
+		return w.Code
+	}
+
+	forged := u.Query()
+	forged.Set("sig", strings.Repeat("f", 64))
+	if code := put(forged.Encode(), `{"stdout":null}`); code != http.StatusForbidden {
+		t.Fatalf("forged callback: status %d, want %d", code, http.StatusForbidden)
+	}
+	if code := put(u.RawQuery, `{"stdout":null}`); code != http.StatusNoContent {
+		t.Fatalf("signed callback: status %d, want %d", code, http.StatusNoContent)
+	}
+	select {
+	case <-results:
+	default:
+		t.Fatal("signed callback didn't deliver its result")
+	}
+	if code := put(u.RawQuery, `{"stdout":null}`); code != http.StatusForbidden {
+		t.Fatalf("replayed callback: status %d, want %d", code, http.StatusForbidden)
+	}
+}
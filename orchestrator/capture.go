@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// validEnvName limits variables to names every language can read; names
+// are interpolated into generated shell and source code
+var validEnvName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// checkEnvName rejects a variable name that validEnvName doesn't accept
+func checkEnvName(name string) error {
+	if !validEnvName.MatchString(name) {
+		return fmt.Errorf("invalid variable name %q", name)
+	}
+	return nil
+}
+
+// captureRule extracts a value from an execution's stdout. Specs are
+// "last_line", "stdout", "regex:<pattern>" (first group, or the whole
+// match) or "json:<path>" (dotted keys and array indexes, e.g. items.0.id).
+type captureRule struct {
+	kind string
+	re   *regexp.Regexp
+	path []string
+}
+
+// parseCaptureSpec validates a capture_env entry
+func parseCaptureSpec(spec string) (captureRule, error) {
+	kind, arg, _ := strings.Cut(spec, ":")
+	switch kind {
+	case "last_line", "stdout":
+		if arg != "" {
+			return captureRule{}, fmt.Errorf("%s takes no argument", kind)
+		}
+		return captureRule{kind: kind}, nil
+	case "regex":
+		re, err := regexp.Compile(arg)
+		if err != nil {
+			return captureRule{}, fmt.Errorf("invalid regex: %w", err)
+		}
+		return captureRule{kind: kind, re: re}, nil
+	case "json":
+		if arg == "" {
+			return captureRule{kind: kind}, nil
+		}
+		return captureRule{kind: kind, path: strings.Split(arg, ".")}, nil
+	}
+	return captureRule{}, fmt.Errorf("unknown capture %q (want last_line, stdout, regex:<pattern> or json:<path>)", spec)
+}
+
+// parseCaptureEnv validates every capture_env entry before the code runs
+func parseCaptureEnv(capture map[string]string) (map[string]captureRule, error) {
+	rules := make(map[string]captureRule, len(capture))
+	for name, spec := range capture {
+		if !validEnvName.MatchString(name) {
+			return nil, &InvalidSubmissionError{Param: "capture_env", Reason: fmt.Sprintf("invalid variable name %q", name)}
+		}
+		rule, err := parseCaptureSpec(spec)
+		if err != nil {
+			return nil, &InvalidSubmissionError{Param: "capture_env", Reason: fmt.Sprintf("%s: %v", name, err)}
+		}
+		rules[name] = rule
+	}
+	return rules, nil
+}
+
+// extract applies the rule to stdout
+func (c captureRule) extract(stdout string) (string, error) {
+	switch c.kind {
+	case "stdout":
+		return strings.TrimRight(stdout, "\n"), nil
+	case "last_line":
+		lines := strings.Split(strings.TrimRight(stdout, "\n"), "\n")
+		return lines[len(lines)-1], nil
+	case "regex":
+		m := c.re.FindStringSubmatch(stdout)
+		if m == nil {
+			return "", fmt.Errorf("no match for %s", c.re)
+		}
+		if len(m) > 1 {
+			return m[1], nil
+		}
+		return m[0], nil
+	}
+
+	var v interface{}
+	if err := json.Unmarshal([]byte(stdout), &v); err != nil {
+		return "", fmt.Errorf("output is not JSON: %w", err)
+	}
+	for _, key := range c.path {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if v, ok = node[key]; !ok {
+				return "", fmt.Errorf("no %q in JSON output", key)
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return "", fmt.Errorf("invalid index %q in JSON output", key)
+			}
+			v = node[i]
+		default:
+			return "", fmt.Errorf("can't descend into %q in JSON output", key)
+		}
+	}
+
+	// Strings are stored as-is, anything else as JSON
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	data, _ := json.Marshal(v)
+	return string(data), nil
+}
+
+// captureEnv stores values extracted from stdout as session env vars. A
+// value that is or contains a secret, matches a redaction pattern or
+// replaces a secret is stored as a secret. It returns the captured values
+// and a message for each capture that failed.
+func (sm *SessionManager) captureEnv(sessionID string, rules map[string]captureRule, stdout string) (map[string]string, []string) {
+	session, err := sm.GetSession(sessionID)
+	if err != nil {
+		return nil, []string{"capture_env: " + err.Error()}
+	}
+	r := sm.redactor(session)
+
+	names := make([]string, 0, len(rules))
+	for name := range rules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	captured := map[string]string{}
+	var failures []string
+	for _, name := range names {
+		value, err := rules[name].extract(stdout)
+		if err == nil {
+			if r.secrets[name] || r.redact(value) != value {
+				err = sm.SetSecretEnv(sessionID, name, value)
+			} else {
+				err = sm.SetEnv(sessionID, name, value)
+			}
+		}
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("capture_env %s: %v", name, err))
+			continue
+		}
+		captured[name] = value
+	}
+	return captured, failures
+}
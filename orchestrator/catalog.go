@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// catalogFile caches the backend's /languages list in the data directory's
+// state files, so CLI invocations resolve catalog names without asking Judge0
+const catalogFile = "languages.json"
+
+// languageCatalog resolves names of every language the backend offers, on
+// top of the fixed LanguageMap aliases. It is rebuilt whenever /languages
+// is fetched: "Kotlin (1.3.70)" is reachable as "kotlin", "kotlin-1.3.70"
+// and "kotlin (1.3.70)"; when several versions share a name, the plain
+// name resolves to the highest ID, normally the newest.
+var languageCatalog struct {
+	mu     sync.RWMutex
+	loaded bool
+	names  map[string]int
+}
+
+// languageSyncInterval is how often serve refreshes the catalog
+// (--language-sync-interval)
+var languageSyncInterval = 10 * time.Minute
+
+// splitLanguageName splits a Judge0 name like "Python (3.8.1)" into the
+// language and its version
+func splitLanguageName(name string) (string, string) {
+	if open := strings.LastIndex(name, " ("); open > 0 && strings.HasSuffix(name, ")") {
+		return name[:open], name[open+2 : len(name)-1]
+	}
+	return name, ""
+}
+
+// catalogSlug lowercases a name and joins its words with dashes
+func catalogSlug(name string) string {
+	return strings.Join(strings.Fields(strings.ToLower(name)), "-")
+}
+
+// setLanguageCatalog rebuilds the catalog from a /languages list
+func setLanguageCatalog(languages []map[string]interface{}) {
+	names := make(map[string]int)
+	for _, l := range languages {
+		v, _ := l["id"].(float64)
+		full, _ := l["name"].(string)
+		id := int(v)
+		if id == 0 || full == "" {
+			continue
+		}
+		name, version := splitLanguageName(full)
+		names[strings.ToLower(full)] = id
+		if version != "" {
+			names[catalogSlug(name+" "+version)] = id
+		}
+		if slug := catalogSlug(name); names[slug] < id {
+			names[slug] = id
+		}
+	}
+
+	languageCatalog.mu.Lock()
+	languageCatalog.names = names
+	languageCatalog.loaded = true
+	languageCatalog.mu.Unlock()
+}
+
+// saveLanguageCatalog caches the /languages list in the data directory
+func saveLanguageCatalog(languages []map[string]interface{}) error {
+	data, err := json.Marshal(languages)
+	if err != nil {
+		return err
+	}
+	return writeStateFile(dataDir, catalogFile, data)
+}
+
+// loadLanguageCatalog builds the catalog from the cached /languages list,
+// once; with no cache the catalog stays empty until the backend is asked
+func loadLanguageCatalog() {
+	languageCatalog.mu.RLock()
+	loaded := languageCatalog.loaded
+	languageCatalog.mu.RUnlock()
+	if loaded {
+		return
+	}
+
+	var languages []map[string]interface{}
+	if data, err := os.ReadFile(statePath(dataDir, catalogFile)); err == nil {
+		json.Unmarshal(data, &languages)
+	}
+	setLanguageCatalog(languages)
+}
+
+// catalogLanguageID resolves a language name through the catalog,
+// ignoring case and surrounding space
+func catalogLanguageID(language string) (int, bool) {
+	key := strings.ToLower(strings.TrimSpace(language))
+	if id, ok := LanguageMap[key]; ok {
+		return id, true
+	}
+
+	loadLanguageCatalog()
+	languageCatalog.mu.RLock()
+	defer languageCatalog.mu.RUnlock()
+	if id, ok := languageCatalog.names[key]; ok {
+		return id, true
+	}
+	id, ok := languageCatalog.names[catalogSlug(key)]
+	return id, ok
+}
+
+// catalogNames returns every name the catalog resolves
+func catalogNames() []string {
+	loadLanguageCatalog()
+	languageCatalog.mu.RLock()
+	defer languageCatalog.mu.RUnlock()
+	names := make([]string, 0, len(languageCatalog.names))
+	for name := range languageCatalog.names {
+		names = append(names, name)
+	}
+	return names
+}
+
+// RunLanguageSync refreshes the catalog from the backend now and then each
+// interval until ctx is cancelled
+func RunLanguageSync(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		languageCache.mu.Lock()
+		languageCache.fetched = time.Time{}
+		languageCache.mu.Unlock()
+		if _, err := backendLanguages(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Warning: language catalog sync failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
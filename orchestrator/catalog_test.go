@@ -0,0 +1,65 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// resetLanguageCatalog forgets the loaded catalog, so the next lookup
+// reads the data directory's cache
+func resetLanguageCatalog(t *testing.T) {
+	t.Helper()
+	reset := func() {
+		languageCatalog.mu.Lock()
+		languageCatalog.loaded = false
+		languageCatalog.names = nil
+		languageCatalog.mu.Unlock()
+	}
+	reset()
+	t.Cleanup(reset)
+}
+
+func TestLanguageCatalogCache(t *testing.T) {
+	dir := withDataDir(t)
+	resetLanguageCatalog(t)
+
+	languages := []map[string]interface{}{
+		{"id": float64(78), "name": "Kotlin (1.3.70)"},
+		{"id": float64(111), "name": "Kotlin (1.9.0)"},
+		{"id": float64(48), "name": "C (GCC 7.4.0)"},
+	}
+	if err := saveLanguageCatalog(languages); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, stateDirName, catalogFile)); err != nil {
+		t.Fatalf("catalog cache not under %s/: %v", stateDirName, err)
+	}
+	resetLanguageCatalog(t)
+
+	tests := []struct {
+		name string
+		want int
+	}{
+		{"kotlin", 111},
+		{"Kotlin (1.3.70)", 78},
+		{"kotlin-1.3.70", 78},
+		{" KOTLIN-1.9.0 ", 111},
+		{"c-gcc-7.4.0", 48},
+		{"cobol-9000", 0},
+		{"c (gcc 7.4.0)", 48},
+		{"python", LanguageMap["python"]},
+	}
+	for _, tt := range tests {
+		id, ok := catalogLanguageID(tt.name)
+		if tt.want == 0 {
+			if ok {
+				t.Errorf("%q resolved to %d, want no match", tt.name, id)
+			}
+			continue
+		}
+		if !ok || id != tt.want {
+			t.Errorf("%q resolved to %d (%v), want %d", tt.name, id, ok, tt.want)
+		}
+	}
+}
@@ -5,9 +5,9 @@ import (
 	"fmt"
 	"os"
 	"strings"
-	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 // sessionsCmd manages sessions
@@ -28,25 +28,77 @@ var sessionsCreateCmd = &cobra.Command{
 	Short: "Create a new session",
 	Long: `Create a new execution session for the specified language.
 
-Supported languages: bash, python, go, javascript, ruby, rust, c, cpp
+Supported languages: bash, python, go, javascript, ruby, rust, c, cpp,
+and any other language the backend offers. Qualify the language with a
+version (python:3.11) or a compiler and version (gcc:12, clang:7) to pin
+a specific backend language.
 
 Examples:
   j0 sessions create bash
-  j0 sessions create python --name "data-analysis"`,
+  j0 sessions create python --name "data-analysis"
+  j0 sessions create python --version 3.11
+  j0 sessions create python:3.11
+  j0 sessions create gcc:9
+  j0 sessions create python --init-script motd.py
+  j0 sessions create python --stateful`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		language := args[0]
 		name, _ := cmd.Flags().GetString("name")
-
-		// Validate language
-		if _, err := GetLanguageID(language); err != nil {
+		strict, _ := cmd.Flags().GetBool("strict")
+		stateful, _ := cmd.Flags().GetBool("stateful")
+		examDuration, _ := cmd.Flags().GetDuration("exam-duration")
+		problemID, _ := cmd.Flags().GetString("problem")
+		version, _ := cmd.Flags().GetString("version")
+		initFile, _ := cmd.Flags().GetString("init-script")
+
+		// Validate language and resolve a pinned version
+		language, pinned, err := resolveLanguageSpec(cmd.Context(), language, version)
+		if err != nil {
 			return err
 		}
+		if err := checkStateful(language, stateful); err != nil {
+			return err
+		}
+
+		opts := SessionOptions{
+			Strict:       strict,
+			Stateful:     stateful,
+			ExamDuration: examDuration,
+			Language:     pinned,
+		}
+		if problemID != "" {
+			if _, err := problemStore.Get(problemID); err != nil {
+				return err
+			}
+			opts.Metadata = map[string]string{"problem_id": problemID}
+		}
+		var initScript []byte
+		if initFile != "" {
+			var err error
+			if initScript, err = os.ReadFile(initFile); err != nil {
+				return err
+			}
+		}
 
-		session, err := sessionManager.CreateSession(language, name)
+		session, err := sessionManager.CreateSession(language, name, opts)
 		if err != nil {
 			return err
 		}
+		audit(cmd.Context(), "session.create", session.ID, map[string]interface{}{
+			"language": language,
+			"version":  session.Version,
+			"name":     name,
+			"strict":   strict,
+			"stateful": stateful,
+			"exam":     examDuration > 0,
+		})
+
+		if len(initScript) > 0 {
+			if _, err := runSessionInit(cmd.Context(), session.ID, string(initScript)); err != nil {
+				return err
+			}
+		}
 
 		if verbose {
 			enc := json.NewEncoder(os.Stdout)
@@ -54,14 +106,28 @@ Examples:
 			return enc.Encode(session)
 		}
 
-		fmt.Printf("Created session: %s (%s)\n", session.ID, session.Language)
+		if session.Version != "" {
+			fmt.Printf("Created session: %s (%s %s)\n", session.ID, session.Language, session.Version)
+		} else {
+			fmt.Printf("Created session: %s (%s)\n", session.ID, session.Language)
+		}
 		fmt.Printf("Log file: %s\n", session.LogFile)
+		if session.Init != nil {
+			fmt.Println()
+			printMOTD(os.Stdout, session.Init)
+		}
 		return nil
 	},
 }
 
 func init() {
 	sessionsCreateCmd.Flags().String("name", "", "Optional session name")
+	sessionsCreateCmd.Flags().Bool("strict", false, "Reject executions while another is in flight")
+	sessionsCreateCmd.Flags().Bool("stateful", false, "Keep interpreter state (Python and JavaScript globals) between executions")
+	sessionsCreateCmd.Flags().Duration("exam-duration", 0, "Create a time-boxed exam session closed after this duration")
+	sessionsCreateCmd.Flags().String("problem", "", "Link the session to a problem for its stdin templates")
+	sessionsCreateCmd.Flags().String("version", "", "Pin a backend version of the language, e.g. 3.11")
+	sessionsCreateCmd.Flags().String("init-script", "", "File with code run once at creation; its output is shown on attach")
 }
 
 var sessionsListCmd = &cobra.Command{
@@ -76,6 +142,25 @@ var sessionsListCmd = &cobra.Command{
 		}
 
 		jsonOut, _ := cmd.Flags().GetBool("json")
+		usage, _ := cmd.Flags().GetBool("usage")
+		if usage {
+			withUsage := withDiskUsage(sessionManager, sessions)
+			if jsonOut {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(withUsage)
+			}
+
+			fmt.Printf("%-15s %-10s %-10s %-10s %-10s %-10s %s\n", "ID", "LANGUAGE", "STATUS", "LOGS", "WORKSPACE", "ARTIFACTS", "TOTAL")
+			fmt.Println(strings.Repeat("-", 80))
+			for _, s := range withUsage {
+				fmt.Printf("%-15s %-10s %-10s %-10s %-10s %-10s %s\n",
+					s.ID, s.Language, s.Status,
+					formatBytes(s.Disk.LogBytes), formatBytes(s.Disk.WorkspaceBytes), formatBytes(s.Disk.ArtifactBytes), formatBytes(s.Disk.TotalBytes))
+			}
+			return nil
+		}
+
 		if jsonOut {
 			enc := json.NewEncoder(os.Stdout)
 			enc.SetIndent("", "  ")
@@ -105,6 +190,7 @@ var sessionsListCmd = &cobra.Command{
 
 func init() {
 	sessionsListCmd.Flags().Bool("json", false, "Output as JSON")
+	sessionsListCmd.Flags().Bool("usage", false, "Show disk usage per session, largest first")
 }
 
 var sessionsShowCmd = &cobra.Command{
@@ -119,7 +205,7 @@ var sessionsShowCmd = &cobra.Command{
 
 		enc := json.NewEncoder(os.Stdout)
 		enc.SetIndent("", "  ")
-		return enc.Encode(session)
+		return enc.Encode(SessionWithDisk{Session: session, Disk: sessionManager.DiskUsage(session)})
 	},
 }
 
@@ -131,6 +217,7 @@ var sessionsCloseCmd = &cobra.Command{
 		if err := sessionManager.CloseSession(args[0]); err != nil {
 			return err
 		}
+		audit(cmd.Context(), "session.close", args[0], nil)
 		fmt.Printf("Session %s closed.\n", args[0])
 		return nil
 	},
@@ -163,70 +250,184 @@ Examples:
 			return fmt.Errorf("session is not active: %s", session.Status)
 		}
 
-		// Get language ID
-		langID, err := GetLanguageID(session.Language)
-		if err != nil {
-			return err
-		}
-
-		// Prepare code with environment
-		fullCode := prepareCodeWithEnv(code, session.State.Env, session.Language)
-
 		stdin, _ := cmd.Flags().GetString("stdin")
-
-		// Execute
-		startTime := time.Now()
-		result, err := judge0Client.Execute(fullCode, langID, stdin)
-		if err != nil {
-			return fmt.Errorf("execution failed: %w", err)
+		stdinTemplate, _ := cmd.Flags().GetString("stdin-template")
+		stdinParams, _ := cmd.Flags().GetStringToString("stdin-param")
+		trace, _ := cmd.Flags().GetBool("trace")
+		queueTimeout, _ := cmd.Flags().GetDuration("queue-timeout")
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+
+		var expectedExit *int
+		if cmd.Flags().Changed("expect-exit") {
+			code, _ := cmd.Flags().GetInt("expect-exit")
+			expectedExit = &code
 		}
-		duration := time.Since(startTime).Seconds() * 1000
 
-		// Record execution
-		exec := Execution{
-			Code:     code,
-			Output:   result.Stdout,
-			Stderr:   result.Stderr,
-			ExitCode: result.ExitCode,
-			Time:     startTime,
-			Duration: duration,
+		var formatOverride *bool
+		if cmd.Flags().Changed("format") {
+			f, _ := cmd.Flags().GetBool("format")
+			formatOverride = &f
 		}
 
-		if err := sessionManager.AddExecution(sessionID, exec); err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: failed to record execution: %v\n", err)
+		captureEnv, _ := cmd.Flags().GetStringToString("capture-env")
+		preview, _ := cmd.Flags().GetBool("preview")
+		params := submissionParamsFromFlags(cmd.Flags())
+
+		exec, err := runExecution(cmd.Context(), sessionID, ExecRequest{
+			Code:          code,
+			Stdin:         stdin,
+			StdinTemplate: stdinTemplate,
+			StdinParams:   stdinParams,
+			Trace:         trace,
+			QueueTimeout:  queueTimeout,
+			Timeout:       timeout,
+
+			ExpectedExitCode: expectedExit,
+			Format:           formatOverride,
+			CaptureEnv:       captureEnv,
+			Preview:          preview,
+			Params:           params,
+		})
+		if err != nil {
+			return fmt.Errorf("execution failed: %w", err)
 		}
 
 		jsonOut, _ := cmd.Flags().GetBool("json")
 		if jsonOut {
 			enc := json.NewEncoder(os.Stdout)
 			enc.SetIndent("", "  ")
-			return enc.Encode(map[string]interface{}{
-				"stdout":    result.Stdout,
-				"stderr":    result.Stderr,
-				"exit_code": result.ExitCode,
-				"time_ms":   duration,
-			})
+			if err := enc.Encode(executionResponse(exec, defaultLocale)); err != nil {
+				return err
+			}
+			return executionFailure(exec)
+		}
+
+		if exec.Phase == PhaseCompile {
+			fmt.Fprintln(os.Stderr, strings.TrimRight(exec.CompileOutput, "\n"))
+			return executionFailure(exec)
+		}
+
+		if exec.Preview != nil {
+			printPreview(os.Stderr, exec.Preview)
 		}
 
 		// Print output
-		if result.Stdout != "" {
-			fmt.Print(result.Stdout)
+		if exec.Output != "" {
+			fmt.Print(exec.Output)
 		}
-		if result.Stderr != "" {
-			fmt.Fprintf(os.Stderr, "%s", result.Stderr)
+		if exec.Stderr != "" {
+			fmt.Fprintf(os.Stderr, "%s", exec.Stderr)
 		}
 
-		if result.ExitCode != 0 {
-			return fmt.Errorf("exit code: %d", result.ExitCode)
+		if exec.Status != "" && exec.Status != "ACCEPTED" {
+			fmt.Fprintf(os.Stderr, "[status] %s: %s\n", exec.Status, statusSummary(exec.Status, defaultLocale))
+		}
+		if exec.Fallback != nil {
+			fmt.Fprintf(os.Stderr, "[warning] %s\n", exec.Fallback.Message)
+		}
+		for _, w := range exec.Warnings {
+			fmt.Fprintf(os.Stderr, "[warning] %s\n", w.Message)
+		}
+		for name, value := range exec.ExportedEnv {
+			fmt.Fprintf(os.Stderr, "[env] export %s=%s\n", name, value)
+		}
+		for _, name := range exec.UnsetEnv {
+			fmt.Fprintf(os.Stderr, "[env] unset %s\n", name)
+		}
+		for name, value := range exec.CapturedEnv {
+			fmt.Fprintf(os.Stderr, "[env] %s=%s\n", name, value)
+		}
+		for _, msg := range exec.StateErrors {
+			fmt.Fprintf(os.Stderr, "[warning] %s\n", msg)
+		}
+		for _, msg := range exec.CaptureErrors {
+			fmt.Fprintf(os.Stderr, "[warning] %s\n", msg)
 		}
 
-		return nil
+		for _, step := range exec.Trace {
+			fmt.Fprintf(os.Stderr, "[trace] line %d", step.Line)
+			if step.Function != "" {
+				fmt.Fprintf(os.Stderr, " in %s", step.Function)
+			}
+			for k, v := range step.Locals {
+				fmt.Fprintf(os.Stderr, " %s=%s", k, v)
+			}
+			fmt.Fprintln(os.Stderr)
+		}
+
+		return executionFailure(exec)
 	},
 }
 
+// submissionParamsFromFlags reads the Judge0 submission settings given to j0 exec
+func submissionParamsFromFlags(flags *pflag.FlagSet) SubmissionParams {
+	var p SubmissionParams
+	if flags.Changed("wall-time-limit") {
+		v, _ := flags.GetFloat64("wall-time-limit")
+		p.WallTimeLimit = &v
+	}
+	for name, dst := range map[string]**int{
+		"stack-limit":   &p.StackLimit,
+		"max-processes": &p.MaxProcessesAndOrThreads,
+		"max-file-size": &p.MaxFileSize,
+		"runs":          &p.NumberOfRuns,
+	} {
+		if flags.Changed(name) {
+			v, _ := flags.GetInt(name)
+			*dst = &v
+		}
+	}
+	for name, dst := range map[string]**bool{
+		"per-process-time-limit": &p.EnablePerProcessAndThreadTimeLimit,
+		"redirect-stderr":        &p.RedirectStderrToStdout,
+	} {
+		if flags.Changed(name) {
+			v, _ := flags.GetBool(name)
+			*dst = &v
+		}
+	}
+	return p
+}
+
+// executionFailure returns an ExecutionFailedError when exec compiled but
+// failed, didn't compile, or exited with an unexpected code
+func executionFailure(exec *Execution) error {
+	failed := &ExecutionFailedError{ExecutionID: exec.ID, ExitCode: exec.ExitCode}
+	switch {
+	case exec.Phase == PhaseCompile:
+		failed.Reason = "compilation failed"
+	case exec.Passed != nil:
+		if *exec.Passed {
+			return nil
+		}
+		failed.Reason = fmt.Sprintf("expected exit code %d, got %d", *exec.ExpectedExitCode, exec.ExitCode)
+	case exec.ExitCode != 0:
+		failed.Reason = fmt.Sprintf("exit code: %d", exec.ExitCode)
+	default:
+		return nil
+	}
+	return failed
+}
+
 func init() {
 	execCmd.Flags().String("stdin", "", "Standard input for the code")
+	execCmd.Flags().String("stdin-template", "", "Use a named session or problem stdin template as input")
+	execCmd.Flags().StringToString("stdin-param", nil, "Template parameter as name=value (repeatable)")
 	execCmd.Flags().Bool("json", false, "Output as JSON")
+	execCmd.Flags().Bool("trace", false, "Print a line-by-line execution trace (Python only)")
+	execCmd.Flags().Duration("queue-timeout", 0, "Fail if the backend hasn't started the code within this duration")
+	execCmd.Flags().Duration("timeout", 0, "Fail if the code hasn't finished within this duration (default 15s)")
+	execCmd.Flags().Int("expect-exit", 0, "Expected exit code; the command succeeds only if it matches")
+	execCmd.Flags().Bool("format", false, "Format the code before running it (overrides --format-code)")
+	execCmd.Flags().Bool("preview", false, "Print a static analysis of the code (imports, network, file writes, processes) with the result")
+	execCmd.Flags().Float64("wall-time-limit", 0, "Judge0 wall clock limit for the program, in seconds")
+	execCmd.Flags().Int("stack-limit", 0, "Judge0 stack limit, in KB")
+	execCmd.Flags().Int("max-processes", 0, "Maximum processes and/or threads the program may create")
+	execCmd.Flags().Bool("per-process-time-limit", false, "Apply the CPU time limit to each process and thread")
+	execCmd.Flags().Int("max-file-size", 0, "Largest file the program may create, in KB")
+	execCmd.Flags().Bool("redirect-stderr", false, "Merge stderr into stdout")
+	execCmd.Flags().Int("runs", 0, "Run the program this many times; time and memory are averaged")
+	execCmd.Flags().StringToString("capture-env", nil, "Store output in a session env var as VAR=last_line|stdout|regex:<pattern>|json:<path> (repeatable)")
 }
 
 // logCmd shows session logs
@@ -247,16 +448,39 @@ Examples:
 		follow, _ := cmd.Flags().GetBool("follow")
 		lines, _ := cmd.Flags().GetInt("lines")
 
+		session, err := sessionManager.GetSession(sessionID)
+		if err != nil {
+			return err
+		}
+
+		if cmd.Flags().Changed("offset") {
+			offset, _ := cmd.Flags().GetInt64("offset")
+			limit, _ := cmd.Flags().GetInt64("limit")
+
+			page, err := sessionManager.GetLogPage(sessionID, offset, limit)
+			if err != nil {
+				return err
+			}
+			fmt.Print(page.Data)
+			if page.NextOffset < page.Size {
+				fmt.Fprintf(os.Stderr, "\n[more log available, continue with --offset %d]\n", page.NextOffset)
+			}
+			return nil
+		}
+
 		content, err := sessionManager.GetLog(sessionID, lines)
 		if err != nil {
 			return err
 		}
+		offset := logSize(session.LogFile)
 
 		fmt.Print(content)
 
 		if follow {
-			// TODO: Implement tail -f functionality
-			fmt.Println("\n[--follow not yet implemented, showing current log]")
+			return followLog(cmd.Context(), session.LogFile, offset, func(chunk []byte) error {
+				_, err := os.Stdout.Write(chunk)
+				return err
+			})
 		}
 
 		return nil
@@ -265,5 +489,7 @@ Examples:
 
 func init() {
 	logCmd.Flags().BoolP("follow", "f", false, "Follow log output (like tail -f)")
-	logCmd.Flags().IntP("lines", "n", 100, "Number of lines to show")
+	logCmd.Flags().IntP("lines", "n", 100, "Number of lines to show (0 for the whole log)")
+	logCmd.Flags().Int64("offset", 0, "Byte offset to start reading from (pagination)")
+	logCmd.Flags().Int64("limit", 64*1024, "Maximum bytes to read with --offset")
 }
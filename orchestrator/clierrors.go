@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"strings"
+)
+
+// CLI exit codes. Wrappers can branch on these instead of parsing error
+// text; they are listed in j0 --help.
+const (
+	ExitFailure            = 1 // an error that fits none of the classes below
+	ExitConfigError        = 2 // invalid flags, arguments, configuration or credentials
+	ExitNotFound           = 3 // the session, execution, problem, tenant or file doesn't exist
+	ExitBackendUnavailable = 4 // Judge0 is unreachable, failing, busy or closed for maintenance
+	ExitExecutionFailed    = 5 // the code ran but failed: non-zero exit, compile error or timeout
+	ExitQuotaExceeded      = 6 // a tenant quota or the backend's rate limit was exceeded
+)
+
+// exitCodeTypes names the exit codes in --json-errors output
+var exitCodeTypes = map[int]string{
+	ExitFailure:            "error",
+	ExitConfigError:        "config_error",
+	ExitNotFound:           "not_found",
+	ExitBackendUnavailable: "backend_unavailable",
+	ExitExecutionFailed:    "execution_failed",
+	ExitQuotaExceeded:      "quota_exceeded",
+}
+
+// exitCodesHelp documents the exit codes in the root command's help
+const exitCodesHelp = `Exit codes:
+  0  success
+  1  other error
+  2  invalid flags, arguments, configuration or credentials
+  3  session, execution, problem, tenant or file not found
+  4  Judge0 unreachable, failing, busy or closed for maintenance
+  5  execution failed (non-zero exit, compile error or timeout)
+  6  tenant quota or backend rate limit exceeded
+
+With --json-errors, errors are written to stderr as one JSON object.`
+
+// jsonErrors writes CLI errors as JSON (--json-errors)
+var jsonErrors bool
+
+// commandStarted is set once flags, arguments and configuration were
+// accepted; errors before that are configuration errors
+var commandStarted bool
+
+// ExecutionFailedError is returned by j0 exec when the code ran but failed
+type ExecutionFailedError struct {
+	ExecutionID string
+	ExitCode    int
+	Reason      string
+}
+
+func (e *ExecutionFailedError) Error() string {
+	return e.Reason
+}
+
+// exitCodeFor classifies err into the CLI exit code taxonomy
+func exitCodeFor(err error) int {
+	if !commandStarted {
+		return ExitConfigError
+	}
+
+	var failed *ExecutionFailedError
+	var deadline *DeadlineError
+	var quota *QuotaError
+	var limited *RateLimitError
+	var unavailable *BackendsUnavailableError
+	var server *Judge0ServerError
+	var closed *ExecutionsClosedError
+	var transport *url.Error
+	var backendAuth *BackendAuthError
+	var unsupported *UnsupportedLanguageError
+	var invalid *InvalidSubmissionError
+	switch {
+	case errors.As(err, &failed):
+		return ExitExecutionFailed
+	case errors.As(err, &deadline):
+		if deadline.Kind == DeadlineQueue {
+			return ExitBackendUnavailable
+		}
+		return ExitExecutionFailed
+	case errors.As(err, &quota), errors.As(err, &limited):
+		return ExitQuotaExceeded
+	case errors.As(err, &backendAuth), errors.Is(err, errJudge0CredentialsRequired), errors.As(err, &unsupported), errors.As(err, &invalid):
+		return ExitConfigError
+	case errors.As(err, &unavailable), errors.As(err, &server), errors.As(err, &closed), errors.As(err, &transport):
+		return ExitBackendUnavailable
+	case errors.Is(err, fs.ErrNotExist), errors.Is(err, ErrUnknownTenant), strings.Contains(err.Error(), "not found"):
+		return ExitNotFound
+	}
+	return ExitFailure
+}
+
+// reportError writes err for the user, or as JSON with --json-errors, and
+// returns the process exit code
+func reportError(w io.Writer, command string, err error) int {
+	code := exitCodeFor(err)
+	if !jsonErrors {
+		fmt.Fprintln(w, "Error:", err)
+		return code
+	}
+
+	report := map[string]interface{}{
+		"error":     err.Error(),
+		"type":      exitCodeTypes[code],
+		"exit_code": code,
+		"command":   command,
+	}
+	if detail := errorCode(err); detail != "" {
+		report["code"] = detail
+	}
+	var failed *ExecutionFailedError
+	if errors.As(err, &failed) {
+		report["execution_id"] = failed.ExecutionID
+		report["program_exit_code"] = failed.ExitCode
+	}
+	json.NewEncoder(w).Encode(report)
+	return code
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// languageLimiter caps how many submissions of each language are at the
+// backend at once, so heavy compiles (Rust, C++) can't crowd out cheap
+// interpreters sharing the same Judge0. Languages without a cap are only
+// counted.
+type languageLimiter struct {
+	mu    sync.Mutex
+	slots map[int]chan struct{}
+	stats map[int]*languageQueueCounters
+}
+
+type languageQueueCounters struct {
+	running    int
+	waiting    int
+	executions int64
+	totalWait  time.Duration
+	maxWait    time.Duration
+}
+
+// LanguageQueueStats reports one language's slots and local queue time,
+// served by the dashboard
+type LanguageQueueStats struct {
+	Limit      int     `json:"limit,omitempty"`
+	Running    int     `json:"running"`
+	Waiting    int     `json:"waiting"`
+	Executions int64   `json:"executions"`
+	AvgWaitMs  float64 `json:"avg_wait_ms"`
+	MaxWaitMs  float64 `json:"max_wait_ms"`
+}
+
+var submissionLimiter = &languageLimiter{
+	slots: make(map[int]chan struct{}),
+	stats: make(map[int]*languageQueueCounters),
+}
+
+// configureLanguageConcurrency sets the per-language caps from
+// --language-concurrency; aliases of a language share its cap
+func configureLanguageConcurrency(caps map[string]int) error {
+	for language, n := range caps {
+		id, err := GetLanguageID(language)
+		if err != nil {
+			return fmt.Errorf("language concurrency: %w", err)
+		}
+		if n < 1 {
+			return fmt.Errorf("language concurrency for %s must be at least 1, got %d", language, n)
+		}
+		submissionLimiter.slots[id] = make(chan struct{}, n)
+	}
+	return nil
+}
+
+// acquire waits for a slot for languageID. maxWait, if set, bounds the wait
+// and fails with a queue DeadlineError like a busy backend would. onWait,
+// if set, is called with the number of executions ahead when all slots are
+// taken. The returned release must be called once the submission has finished.
+func (l *languageLimiter) acquire(ctx context.Context, languageID int, maxWait time.Duration, onWait func(ahead int)) (release func(), waited time.Duration, err error) {
+	l.mu.Lock()
+	slots := l.slots[languageID]
+	c := l.stats[languageID]
+	if c == nil {
+		c = &languageQueueCounters{}
+		l.stats[languageID] = c
+	}
+	ahead := c.waiting
+	c.waiting++
+	l.mu.Unlock()
+
+	start := time.Now()
+	if slots != nil {
+		select {
+		case slots <- struct{}{}:
+		default:
+			if onWait != nil {
+				onWait(ahead)
+			}
+			err = waitForSlot(ctx, slots, maxWait)
+		}
+	}
+	waited = time.Since(start)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	c.waiting--
+	if err != nil {
+		return nil, waited, err
+	}
+	c.running++
+	c.executions++
+	c.totalWait += waited
+	if waited > c.maxWait {
+		c.maxWait = waited
+	}
+
+	return func() {
+		if slots != nil {
+			<-slots
+		}
+		l.mu.Lock()
+		c.running--
+		l.mu.Unlock()
+	}, waited, nil
+}
+
+// waitForSlot blocks until slots has room, ctx ends or maxWait passes
+func waitForSlot(ctx context.Context, slots chan struct{}, maxWait time.Duration) error {
+	start := time.Now()
+	var timeout <-chan time.Time
+	if maxWait > 0 {
+		timer := time.NewTimer(maxWait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timeout:
+		return &DeadlineError{Kind: DeadlineQueue, Elapsed: time.Since(start)}
+	}
+}
+
+// Stats reports every language that has a cap or has run, keyed by its
+// canonical name
+func (l *languageLimiter) Stats() map[string]LanguageQueueStats {
+	aliases := languageAliases()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ids := make(map[int]bool)
+	for id := range l.slots {
+		ids[id] = true
+	}
+	for id := range l.stats {
+		ids[id] = true
+	}
+
+	stats := make(map[string]LanguageQueueStats, len(ids))
+	for id := range ids {
+		name := fmt.Sprint(id)
+		if names := aliases[id]; len(names) > 0 {
+			name = names[0]
+		}
+		s := LanguageQueueStats{Limit: cap(l.slots[id])}
+		if c := l.stats[id]; c != nil {
+			s.Running = c.running
+			s.Waiting = c.waiting
+			s.Executions = c.executions
+			s.MaxWaitMs = float64(c.maxWait.Microseconds()) / 1000
+			if c.executions > 0 {
+				s.AvgWaitMs = float64(c.totalWait.Microseconds()) / 1000 / float64(c.executions)
+			}
+		}
+		stats[name] = s
+	}
+	return stats
+}
+
+// Limits returns the configured caps by canonical language name
+func (l *languageLimiter) Limits() map[string]int {
+	aliases := languageAliases()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.slots) == 0 {
+		return nil
+	}
+	limits := make(map[string]int, len(l.slots))
+	for id, slots := range l.slots {
+		limits[aliases[id][0]] = cap(slots)
+	}
+	return limits
+}
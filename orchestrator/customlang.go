@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// languagesConfig is the file registering custom language aliases
+// (--languages-config)
+var languagesConfig string
+
+// LanguagesConfig is the --languages-config file:
+//
+//	{"languages": {"kotlin": {"id": 78, "compiled": true},
+//	               "py-ml": {"id": 10, "wrapper": "import os\n{{range $k, $v := .Env}}os.environ[{{quote $k}}] = {{quote $v}}\n{{end}}{{.Code}}"}}}
+type LanguagesConfig struct {
+	Languages map[string]CustomLanguage `json:"languages"`
+}
+
+// CustomLanguage maps an alias to a Judge0 language ID, such as one only an
+// Extra-CE instance offers
+type CustomLanguage struct {
+	ID int `json:"id"`
+	// Wrapper is a text/template producing the submitted source from a
+	// WrapperData; it injects the session's environment and any prelude.
+	// Without one, code gets the built-in injection for the language ID,
+	// if there is one.
+	Wrapper string `json:"wrapper,omitempty"`
+	// Compiled reports the language compiles before it runs
+	Compiled bool `json:"compiled,omitempty"`
+
+	wrapper *template.Template
+}
+
+// WrapperData is what a custom language's wrapper template renders
+type WrapperData struct {
+	Code     string
+	Env      map[string]string
+	Language string
+}
+
+// customLanguages holds the aliases registered by --languages-config
+var customLanguages = make(map[string]*CustomLanguage)
+
+// wrapperFuncs are available to wrapper templates: quote renders a
+// double-quoted string literal valid in most languages, json a JSON value
+var wrapperFuncs = template.FuncMap{
+	"quote": strconv.Quote,
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// configureCustomLanguages loads --languages-config and registers its
+// aliases next to the built-in ones
+func configureCustomLanguages(path string) error {
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read languages config: %w", err)
+	}
+	var config LanguagesConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return fmt.Errorf("failed to parse languages config: %w", err)
+	}
+
+	for name, lang := range config.Languages {
+		alias := strings.ToLower(strings.TrimSpace(name))
+		if alias == "" || strings.ContainsAny(alias, " \t") {
+			return fmt.Errorf("languages config: invalid alias %q", name)
+		}
+		if _, builtin := LanguageMap[alias]; builtin {
+			return fmt.Errorf("languages config: %q is a built-in language", alias)
+		}
+		if lang.ID < 1 {
+			return fmt.Errorf("languages config: %s needs a Judge0 language id", alias)
+		}
+		if lang.Wrapper != "" {
+			tmpl, err := template.New(alias).Funcs(wrapperFuncs).Option("missingkey=zero").Parse(lang.Wrapper)
+			if err != nil {
+				return fmt.Errorf("languages config: %s wrapper: %w", alias, err)
+			}
+			if err := tmpl.Execute(new(bytes.Buffer), WrapperData{Env: map[string]string{"J0": "1"}, Language: alias}); err != nil {
+				return fmt.Errorf("languages config: %s wrapper: %w", alias, err)
+			}
+			lang.wrapper = tmpl
+		}
+
+		customLanguages[alias] = &lang
+		LanguageMap[alias] = lang.ID
+		if lang.Compiled {
+			compiledLanguages[lang.ID] = true
+		}
+	}
+	return nil
+}
+
+// wrapCustomLanguage renders code through its custom language's wrapper.
+// ok is false when language has no wrapper.
+func wrapCustomLanguage(code string, env map[string]string, language string) (string, bool) {
+	lang, found := customLanguages[strings.ToLower(language)]
+	if !found || lang.wrapper == nil {
+		return "", false
+	}
+	if env == nil {
+		env = map[string]string{}
+	}
+
+	var buf bytes.Buffer
+	if err := lang.wrapper.Execute(&buf, WrapperData{Code: code, Env: env, Language: language}); err != nil {
+		log.Printf("Warning: %s wrapper failed, submitting code unwrapped: %v", language, err)
+		return "", false
+	}
+	return buf.String(), true
+}
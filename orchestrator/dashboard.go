@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"path/filepath"
+	"time"
+)
+
+// Dashboard aggregates orchestrator state for status screens
+type Dashboard struct {
+	GeneratedAt time.Time        `json:"generated_at"`
+	Sessions    DashboardSession `json:"sessions"`
+	Executions  DashboardExecs   `json:"executions"`
+	Backend     DashboardBackend `json:"backend"`
+	Queue       DashboardQueue   `json:"queue"`
+	DiskBytes   int64            `json:"disk_bytes"`
+}
+
+// DashboardSession counts sessions by status and active sessions by language
+type DashboardSession struct {
+	Total            int            `json:"total"`
+	ByStatus         map[string]int `json:"by_status"`
+	ActiveByLanguage map[string]int `json:"active_by_language"`
+}
+
+// DashboardExecs summarizes recent execution throughput and failures
+type DashboardExecs struct {
+	LastMinute    int     `json:"last_minute"`
+	LastHour      int     `json:"last_hour"`
+	PerMinute     float64 `json:"per_minute"` // averaged over the last hour
+	ErrorRate     float64 `json:"error_rate"` // non-zero exit codes over the last hour
+	AvgDurationMs float64 `json:"avg_duration_ms"`
+}
+
+// DashboardBackend reports Judge0 reachability
+type DashboardBackend struct {
+	URL       string `json:"url"`
+	Healthy   bool   `json:"healthy"`
+	LatencyMs int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+	// Backends reports each Judge0 instance when --judge0-backends is set
+	Backends []BackendStatus `json:"backends,omitempty"`
+}
+
+// DashboardQueue reports pending work on both sides
+type DashboardQueue struct {
+	InFlight int                      `json:"in_flight"`
+	Judge0   []map[string]interface{} `json:"judge0,omitempty"`
+	// Languages reports per-language concurrency slots and time spent
+	// waiting for them since startup
+	Languages map[string]LanguageQueueStats `json:"languages,omitempty"`
+}
+
+// BuildDashboard collects the dashboard; backend calls are best-effort
+func BuildDashboard(ctx context.Context) *Dashboard {
+	now := time.Now()
+	d := &Dashboard{
+		GeneratedAt: now,
+		Sessions: DashboardSession{
+			ByStatus:         make(map[string]int),
+			ActiveByLanguage: make(map[string]int),
+		},
+	}
+
+	var failures int
+	var totalDuration float64
+	for _, s := range sessionManager.ListSessions() {
+		d.Sessions.Total++
+		d.Sessions.ByStatus[s.Status]++
+		if s.Status == "active" {
+			d.Sessions.ActiveByLanguage[s.Language]++
+		}
+
+		for _, exec := range s.State.History {
+			age := now.Sub(exec.Time)
+			if age > time.Hour {
+				continue
+			}
+			d.Executions.LastHour++
+			totalDuration += exec.Duration
+			if exec.ExitCode != 0 {
+				failures++
+			}
+			if age <= time.Minute {
+				d.Executions.LastMinute++
+			}
+		}
+	}
+	if d.Executions.LastHour > 0 {
+		d.Executions.PerMinute = float64(d.Executions.LastHour) / 60
+		d.Executions.ErrorRate = float64(failures) / float64(d.Executions.LastHour)
+		d.Executions.AvgDurationMs = totalDuration / float64(d.Executions.LastHour)
+	}
+
+	d.Backend.URL = judge0URL
+	start := time.Now()
+	if _, err := judge0Client.About(ctx); err != nil {
+		d.Backend.Error = err.Error()
+	} else {
+		d.Backend.Healthy = true
+	}
+	d.Backend.LatencyMs = time.Since(start).Milliseconds()
+	if judge0Client.pool != nil {
+		d.Backend.URL = judge0Client.pool.baseURL()
+		d.Backend.Backends = judge0Client.pool.Status()
+	}
+
+	d.Queue.InFlight = sessionManager.InFlightCount()
+	d.Queue.Languages = submissionLimiter.Stats()
+	if d.Backend.Healthy {
+		d.Queue.Judge0, _ = judge0Client.Workers(ctx)
+	}
+
+	d.DiskBytes = dirSize(dataDir)
+	return d
+}
+
+// dirSize sums the sizes of regular files under root
+func dirSize(root string) int64 {
+	var total int64
+	filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if entry.Type().IsRegular() {
+			if info, err := entry.Info(); err == nil {
+				total += info.Size()
+			}
+		}
+		return nil
+	})
+	return total
+}
+
+func handleDashboard(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(BuildDashboard(r.Context()))
+}
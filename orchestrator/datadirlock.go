@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// dataDirLockName is the single-writer lock file inside the data directory
+const dataDirLockName = "j0.lock"
+
+// readOnlyCommands never modify the data directory and run without taking
+// the lock, so they work alongside a running server
+var readOnlyCommands = map[string]bool{
+	"j0 sessions list":      true,
+	"j0 sessions show":      true,
+	"j0 log":                true,
+	"j0 log verify":         true,
+	"j0 log keygen":         true,
+	"j0 env list":           true,
+	"j0 env keygen":         true,
+	"j0 fs ls":              true,
+	"j0 about":              true,
+	"j0 analytics":          true,
+	"j0 export":             true,
+	"j0 backend status":     true,
+	"j0 maintenance status": true,
+	"j0 problems list":      true,
+	"j0 tenants list":       true,
+	"j0 limits":             true,
+}
+
+// serverForwardedCommands change state a running server also manages over
+// its API; while j0 serve owns the data directory they are sent to it
+// instead of failing on the lock
+var serverForwardedCommands = map[string]bool{
+	"j0 maintenance on":  true,
+	"j0 maintenance off": true,
+}
+
+// runningServer is the j0 serve owning the data directory when a
+// serverForwardedCommands command runs alongside it
+var runningServer *DataDirLock
+
+// DataDirLock records the process that owns a data directory
+type DataDirLock struct {
+	PID       int       `json:"pid"`
+	Command   string    `json:"command"`
+	Addr      string    `json:"addr,omitempty"`
+	TLS       bool      `json:"tls,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+
+	path string
+}
+
+// heldLock is released when the process exits
+var heldLock *DataDirLock
+
+// acquireDataDirLock takes the data directory's lock for this process;
+// servers record their address and whether it serves TLS. Locks left
+// behind by processes that are no longer running are replaced.
+func acquireDataDirLock(dir, command, addr string, tls bool) (*DataDirLock, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	lock := &DataDirLock{
+		PID:       os.Getpid(),
+		Command:   command,
+		Addr:      addr,
+		TLS:       tls,
+		StartedAt: time.Now(),
+		path:      filepath.Join(dir, dataDirLockName),
+	}
+	data, err := json.Marshal(lock)
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 0; attempt < 2; attempt++ {
+		f, err := os.OpenFile(lock.path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			_, err = f.Write(data)
+			f.Close()
+			return lock, err
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("failed to create lock file: %w", err)
+		}
+
+		owner, err := readDataDirLock(lock.path)
+		// A lock with our own PID was left by a previous container run
+		if err == nil && owner.PID != lock.PID && processAlive(owner.PID) {
+			return nil, lockHeldError(dir, owner)
+		}
+		// Stale or unreadable lock from a process that exited uncleanly
+		os.Remove(lock.path)
+	}
+	return nil, fmt.Errorf("failed to acquire lock on %s", dir)
+}
+
+// Release removes the lock file if this process still owns it
+func (l *DataDirLock) Release() {
+	if l == nil {
+		return
+	}
+	if owner, err := readDataDirLock(l.path); err == nil && owner.PID == l.PID {
+		os.Remove(l.path)
+	}
+}
+
+func readDataDirLock(path string) (*DataDirLock, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var lock DataDirLock
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, err
+	}
+	return &lock, nil
+}
+
+// DataDirLockedError reports a data directory owned by another process
+type DataDirLockedError struct {
+	Dir   string
+	Owner *DataDirLock
+}
+
+// Error explains who owns the directory and what to do instead
+func (e *DataDirLockedError) Error() string {
+	owner := e.Owner
+	if owner.Command == "j0 serve" {
+		return fmt.Sprintf("data directory %s is owned by a running j0 serve (pid %d, listening on %s); "+
+			"send requests to its HTTP API instead (e.g. curl %s/sessions), "+
+			"use a different --data-dir, or stop the server first",
+			e.Dir, owner.PID, owner.Addr, owner.baseURL())
+	}
+	return fmt.Sprintf("data directory %s is in use by %q (pid %d, since %s); wait for it to finish or use a different --data-dir",
+		e.Dir, owner.Command, owner.PID, owner.StartedAt.Format(time.RFC3339))
+}
+
+func lockHeldError(dir string, owner *DataDirLock) error {
+	return &DataDirLockedError{Dir: dir, Owner: owner}
+}
+
+// baseURL is a server owner's address as reachable from this host
+func (l *DataDirLock) baseURL() string {
+	scheme := "http"
+	if l.TLS {
+		scheme = "https"
+	}
+	return scheme + "://localhost" + l.Addr
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// runCLI runs j0 in-process with args and returns what it printed and the
+// error main would report
+func runCLI(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	rootCmd.SetErr(&out)
+	rootCmd.SetArgs(args)
+	if cmd, _, err := rootCmd.Find(args); err == nil {
+		cmd.SilenceUsage = false
+	}
+	commandStarted = false
+	defer func() {
+		rootCmd.SetOut(nil)
+		rootCmd.SetErr(nil)
+		rootCmd.SetArgs(nil)
+		heldLock.Release()
+		heldLock = nil
+	}()
+
+	err := rootCmd.ExecuteContext(context.Background())
+	return out.String(), err
+}
+
+// holdLockAs writes a lock owned by another live process, as if command
+// were running on dir
+func holdLockAs(t *testing.T, dir, command, addr string) {
+	t.Helper()
+	owner := DataDirLock{PID: os.Getppid(), Command: command, Addr: addr, StartedAt: time.Now()}
+	data, err := json.Marshal(owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, dataDirLockName), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAcquireDataDirLock(t *testing.T) {
+	dir := t.TempDir()
+	lock, err := acquireDataDirLock(dir, "j0 exec", "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Our own lock, left by an earlier run with the same PID, is replaced
+	again, err := acquireDataDirLock(dir, "j0 exec", "", false)
+	if err != nil {
+		t.Fatalf("relocking own lock: %v", err)
+	}
+	again.Release()
+	lock.Release()
+	if _, err := os.Stat(filepath.Join(dir, dataDirLockName)); !os.IsNotExist(err) {
+		t.Fatalf("lock file not released: %v", err)
+	}
+
+	// Locks of exited processes are stale
+	stale := DataDirLock{PID: 1 << 30, Command: "j0 serve"}
+	data, _ := json.Marshal(stale)
+	os.WriteFile(filepath.Join(dir, dataDirLockName), data, 0644)
+	lock, err = acquireDataDirLock(dir, "j0 exec", "", false)
+	if err != nil {
+		t.Fatalf("stale lock not replaced: %v", err)
+	}
+	lock.Release()
+}
+
+func TestSecondWriterError(t *testing.T) {
+	dir := t.TempDir()
+	holdLockAs(t, dir, "j0 serve", ":18555")
+
+	out, err := runCLI(t, "--data-dir", dir, "sessions", "create", "bash")
+	if err == nil {
+		t.Fatal("second writer got the lock")
+	}
+	if !strings.Contains(err.Error(), "owned by a running j0 serve") || !strings.Contains(err.Error(), "localhost:18555") {
+		t.Fatalf("error = %q, want it to name the server and its address", err)
+	}
+	if strings.Contains(out, "Usage:") {
+		t.Fatalf("lock error followed by usage:\n%s", out)
+	}
+
+	var reported bytes.Buffer
+	if code := reportError(&reported, "j0 sessions create", err); code != ExitConfigError {
+		t.Fatalf("exit code = %d, want %d", code, ExitConfigError)
+	}
+	if got := reported.String(); !strings.HasPrefix(got, "Error: data directory "+dir) || strings.Count(got, "\n") != 1 {
+		t.Fatalf("reported error = %q, want a single Error: line", got)
+	}
+}
+
+func TestMaintenanceWithRunningServer(t *testing.T) {
+	dir := t.TempDir()
+	var got struct {
+		method, path, auth string
+		body               Maintenance
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got.method, got.path, got.auth = r.Method, r.URL.Path, r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&got.body)
+		if got.auth != "Bearer admin-key" {
+			http.Error(w, "only admins can change maintenance mode", http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"maintenance": true}`))
+	}))
+	defer srv.Close()
+	holdLockAs(t, dir, "j0 serve", srv.URL[strings.LastIndex(srv.URL, ":"):])
+	defer func() { runningServer = nil }()
+
+	out, err := runCLI(t, "--data-dir", dir, "maintenance", "on", "--message", "upgrade", "--api-key", "admin-key")
+	if err != nil {
+		t.Fatalf("maintenance on alongside a server: %v\n%s", err, out)
+	}
+	if got.method != http.MethodPut || got.path != "/v1/maintenance" || !got.body.Enabled || got.body.Message != "upgrade" {
+		t.Fatalf("server got %s %s %+v", got.method, got.path, got.body)
+	}
+	if !strings.Contains(out, "Maintenance mode on") {
+		t.Fatalf("output = %q", out)
+	}
+	// The server owns the state; the CLI doesn't write it behind its back
+	if _, err := os.Stat(filepath.Join(dir, stateDirName, maintenanceFile)); !os.IsNotExist(err) {
+		t.Fatalf("maintenance state written locally: %v", err)
+	}
+
+	_, err = runCLI(t, "--data-dir", dir, "maintenance", "off", "--api-key", "operator-key")
+	if err == nil || !strings.Contains(err.Error(), "403 Forbidden") {
+		t.Fatalf("refused change error = %v, want the server's 403", err)
+	}
+	if got.body.Enabled {
+		t.Fatal("maintenance off sent enabled")
+	}
+
+	// Other writers still fail on the lock
+	if _, err := runCLI(t, "--data-dir", dir, "sessions", "create", "bash"); err == nil {
+		t.Fatal("sessions create ran alongside the server")
+	}
+}
+
+func TestMaintenanceWithoutServer(t *testing.T) {
+	dir := t.TempDir()
+	defer func(saved string) { dataDir = saved }(dataDir)
+
+	if out, err := runCLI(t, "--data-dir", dir, "maintenance", "on", "--message", "local"); err != nil {
+		t.Fatalf("maintenance on: %v\n%s", err, out)
+	}
+	m, err := loadMaintenance()
+	if err != nil || !m.Enabled || m.Message != "local" {
+		t.Fatalf("saved maintenance = %+v, %v", m, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, dataDirLockName)); !os.IsNotExist(err) {
+		t.Fatalf("lock not released: %v", err)
+	}
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// SessionDefaults are submission settings every execution in a session
+// inherits; whatever an execute request sets itself wins
+type SessionDefaults struct {
+	// Stdin or StdinTemplate is used when a request gives neither
+	Stdin         string `json:"stdin,omitempty"`
+	StdinTemplate string `json:"stdin_template,omitempty"`
+
+	CPUTimeLimit    *int   `json:"cpu_time_limit,omitempty"` // seconds
+	MemoryLimit     *int   `json:"memory_limit,omitempty"`   // KB
+	CompilerOptions string `json:"compiler_options,omitempty"`
+
+	SubmissionParams
+}
+
+// Validate checks the defaults against the backend's config_info (nil
+// skips the backend maxima)
+func (d *SessionDefaults) Validate(config map[string]interface{}) error {
+	if d.Stdin != "" && d.StdinTemplate != "" {
+		return &InvalidSubmissionError{Param: "stdin", Reason: "stdin and stdin_template are mutually exclusive"}
+	}
+	for _, l := range []struct {
+		param, max string
+		value      *int
+	}{
+		{"cpu_time_limit", "max_cpu_time_limit", d.CPUTimeLimit},
+		{"memory_limit", "max_memory_limit", d.MemoryLimit},
+	} {
+		if l.value == nil {
+			continue
+		}
+		if *l.value <= 0 {
+			return &InvalidSubmissionError{Param: l.param, Reason: "must be positive"}
+		}
+		if max, ok := config[l.max].(float64); ok && float64(*l.value) > max {
+			return &InvalidSubmissionError{Param: l.param, Reason: fmt.Sprintf("%d exceeds the backend maximum %g", *l.value, max)}
+		}
+	}
+	return d.SubmissionParams.Validate(config)
+}
+
+// withDefaults fills the parameters p leaves unset from d
+func (p SubmissionParams) withDefaults(d SubmissionParams) SubmissionParams {
+	if p.WallTimeLimit == nil {
+		p.WallTimeLimit = d.WallTimeLimit
+	}
+	if p.StackLimit == nil {
+		p.StackLimit = d.StackLimit
+	}
+	if p.MaxProcessesAndOrThreads == nil {
+		p.MaxProcessesAndOrThreads = d.MaxProcessesAndOrThreads
+	}
+	if p.EnablePerProcessAndThreadTimeLimit == nil {
+		p.EnablePerProcessAndThreadTimeLimit = d.EnablePerProcessAndThreadTimeLimit
+	}
+	if p.MaxFileSize == nil {
+		p.MaxFileSize = d.MaxFileSize
+	}
+	if p.RedirectStderrToStdout == nil {
+		p.RedirectStderrToStdout = d.RedirectStderrToStdout
+	}
+	if p.NumberOfRuns == nil {
+		p.NumberOfRuns = d.NumberOfRuns
+	}
+	return p
+}
+
+// applyRequest fills what req leaves unset; a nil d changes nothing
+func (d *SessionDefaults) applyRequest(req ExecRequest) ExecRequest {
+	if d == nil {
+		return req
+	}
+	if req.Stdin == "" && req.StdinTemplate == "" {
+		req.Stdin, req.StdinTemplate = d.Stdin, d.StdinTemplate
+	}
+	req.Params = req.Params.withDefaults(d.SubmissionParams)
+	return req
+}
+
+// applySubmission sets the session's limits and compiler options on sub
+func (d *SessionDefaults) applySubmission(sub *Judge0Submission) {
+	if d == nil {
+		return
+	}
+	if d.CPUTimeLimit != nil {
+		sub.CPUTimeLimit = *d.CPUTimeLimit
+	}
+	if d.MemoryLimit != nil {
+		sub.MemoryLimit = *d.MemoryLimit
+	}
+	if d.CompilerOptions != "" {
+		sub.CompilerOptions = strings.TrimSpace(sub.CompilerOptions + " " + d.CompilerOptions)
+	}
+}
+
+// SetDefaults replaces a session's default submission settings; nil clears them
+func (sm *SessionManager) SetDefaults(ctx context.Context, sessionID string, defaults *SessionDefaults) error {
+	if defaults != nil {
+		config, _ := backendConfig(ctx)
+		if err := defaults.Validate(config); err != nil {
+			return err
+		}
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	session, ok := sm.sessions[sessionID]
+	if !ok {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+	session.State.Defaults = defaults
+	session.UpdatedAt = time.Now()
+	return sm.saveSession(session)
+}
+
+// HTTP handlers
+
+// handleGetDefaults serves GET /sessions/{id}/defaults
+func handleGetDefaults(w http.ResponseWriter, r *http.Request) {
+	session, err := sessionsFor(r.Context()).GetSession(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	defaults := session.State.Defaults
+	if defaults == nil {
+		defaults = &SessionDefaults{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(defaults)
+}
+
+// handlePutDefaults serves PUT /sessions/{id}/defaults, replacing the
+// session's defaults
+func handlePutDefaults(w http.ResponseWriter, r *http.Request) {
+	var defaults SessionDefaults
+	if err := json.NewDecoder(r.Body).Decode(&defaults); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id := r.PathValue("id")
+	if err := sessionsFor(r.Context()).SetDefaults(r.Context(), id, &defaults); err != nil {
+		status := http.StatusBadRequest
+		if strings.Contains(err.Error(), "not found") {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	audit(r.Context(), "session.defaults_set", id, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(defaults)
+}
+
+// handleDeleteDefaults serves DELETE /sessions/{id}/defaults
+func handleDeleteDefaults(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if err := sessionsFor(r.Context()).SetDefaults(r.Context(), id, nil); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	audit(r.Context(), "session.defaults_clear", id, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func invokeMCPSetDefaults(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	sessionID, _ := params["session_id"].(string)
+	if sessionID == "" {
+		return nil, fmt.Errorf("session_id is required")
+	}
+	if err := authorizeSession(ctx, sessionID); err != nil {
+		return nil, err
+	}
+
+	var defaults *SessionDefaults
+	if raw, ok := params["defaults"].(map[string]interface{}); ok && len(raw) > 0 {
+		data, err := json.Marshal(raw)
+		if err != nil {
+			return nil, err
+		}
+		defaults = &SessionDefaults{}
+		if err := json.Unmarshal(data, defaults); err != nil {
+			return nil, fmt.Errorf("invalid defaults: %w", err)
+		}
+	}
+	if err := sessionsFor(ctx).SetDefaults(ctx, sessionID, defaults); err != nil {
+		return nil, err
+	}
+	audit(ctx, "session.defaults_set", sessionID, nil)
+
+	if defaults == nil {
+		defaults = &SessionDefaults{}
+	}
+	return defaults, nil
+}
+
+var sessionsDefaultsCmd = &cobra.Command{
+	Use:   "defaults <session-id>",
+	Short: "Show or set a session's default submission settings",
+	Long: `Show or set the submission settings every execution in a session
+inherits. Settings given to j0 exec override them.
+
+Without flags, the current defaults are shown. Setting flags replaces the
+defaults with the ones given; --clear removes them.
+
+Examples:
+  j0 sessions defaults sess-1a2b3c4d
+  j0 sessions defaults sess-1a2b3c4d --cpu-time-limit 10 --redirect-stderr
+  j0 sessions defaults sess-1a2b3c4d --compiler-options "-O2 -Wall" --stdin-template small
+  j0 sessions defaults sess-1a2b3c4d --clear`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		session, err := sessionManager.GetSession(args[0])
+		if err != nil {
+			return err
+		}
+
+		defaults := session.State.Defaults
+		flags := cmd.Flags()
+		if clear, _ := flags.GetBool("clear"); clear {
+			if err := sessionManager.SetDefaults(cmd.Context(), session.ID, nil); err != nil {
+				return err
+			}
+			fmt.Printf("Cleared defaults for %s\n", session.ID)
+			return nil
+		}
+
+		if cmd.LocalFlags().NFlag() > 0 {
+			defaults = &SessionDefaults{SubmissionParams: submissionParamsFromFlags(flags)}
+			defaults.Stdin, _ = flags.GetString("stdin")
+			defaults.StdinTemplate, _ = flags.GetString("stdin-template")
+			defaults.CompilerOptions, _ = flags.GetString("compiler-options")
+			if flags.Changed("cpu-time-limit") {
+				v, _ := flags.GetInt("cpu-time-limit")
+				defaults.CPUTimeLimit = &v
+			}
+			if flags.Changed("memory-limit") {
+				v, _ := flags.GetInt("memory-limit")
+				defaults.MemoryLimit = &v
+			}
+			if err := sessionManager.SetDefaults(cmd.Context(), session.ID, defaults); err != nil {
+				return err
+			}
+		}
+
+		if defaults == nil {
+			defaults = &SessionDefaults{}
+		}
+		return printJSON(defaults)
+	},
+}
+
+func init() {
+	f := sessionsDefaultsCmd.Flags()
+	f.String("stdin", "", "Standard input for executions that give none")
+	f.String("stdin-template", "", "Named stdin template for executions that give no input")
+	f.Int("cpu-time-limit", 0, "Judge0 CPU time limit, in seconds")
+	f.Int("memory-limit", 0, "Judge0 memory limit, in KB")
+	f.String("compiler-options", "", "Options passed to the compiler")
+	f.Float64("wall-time-limit", 0, "Judge0 wall clock limit for the program, in seconds")
+	f.Int("stack-limit", 0, "Judge0 stack limit, in KB")
+	f.Int("max-processes", 0, "Maximum processes and/or threads the program may create")
+	f.Bool("per-process-time-limit", false, "Apply the CPU time limit to each process and thread")
+	f.Int("max-file-size", 0, "Largest file the program may create, in KB")
+	f.Bool("redirect-stderr", false, "Merge stderr into stdout")
+	f.Int("runs", 0, "Run the program this many times; time and memory are averaged")
+	f.Bool("clear", false, "Remove the session's defaults")
+	sessionsCmd.AddCommand(sessionsDefaultsCmd)
+}
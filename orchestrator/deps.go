@@ -0,0 +1,202 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// installDeps enables automatic dependency installation from workspace
+// manifests (--install-deps, opt-in)
+var installDeps bool
+
+// depsStateDir holds manifest hashes of installed dependencies
+const depsStateDir = ".j0deps"
+
+// DependencyManifest describes how to vendor a language's dependencies
+// inside the Judge0 sandbox
+type DependencyManifest struct {
+	// File is the manifest name at the workspace root
+	File string
+	// Dir is where the install step vendors dependencies (workspace relative)
+	Dir string
+	// Install runs in the workspace and writes Dir; its output goes to stderr
+	Install string
+}
+
+// dependencyManifests are detected per session language
+var dependencyManifests = map[int]DependencyManifest{
+	LanguagePython3: {
+		File:    "requirements.txt",
+		Dir:     depsStateDir + "/python",
+		Install: "/usr/local/python-3.8.1/bin/python3 -m pip install --quiet --disable-pip-version-check --target " + depsStateDir + "/python -r requirements.txt",
+	},
+	LanguageJavaScript: {
+		File:    "package.json",
+		Dir:     "node_modules",
+		Install: "HOME=/tmp PATH=/usr/local/node-12.14.0/bin:$PATH npm install --silent --no-audit --no-fund --production",
+	},
+	LanguageGo: {
+		File:    "go.mod",
+		Dir:     "vendor",
+		Install: "HOME=/tmp GOPATH=/tmp/go GOCACHE=/tmp/.cache/go-build /usr/local/go-1.13.5/bin/go mod vendor",
+	},
+}
+
+// depsHashPath records the manifest hash the vendored directory was built from
+func depsHashPath(root string, m DependencyManifest) string {
+	return filepath.Join(root, depsStateDir, m.File+".sha256")
+}
+
+// ensureDependencies installs the session's dependencies when its workspace
+// has a manifest that changed since the last install. It reports whether an
+// install ran.
+func (sm *SessionManager) ensureDependencies(ctx context.Context, session *Session, langID int) (bool, error) {
+	m, ok := dependencyManifests[langID]
+	if !ok {
+		return false, nil
+	}
+
+	root := sm.WorkspaceDir(session.ID)
+	manifest, err := os.ReadFile(filepath.Join(root, m.File))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	hash := sha256Hex(manifest)
+	if cached, err := os.ReadFile(depsHashPath(root, m)); err == nil && strings.TrimSpace(string(cached)) == hash {
+		return false, nil
+	}
+
+	if session.Exam != nil {
+		return false, fmt.Errorf("%s changed but network access is disabled for this session; dependencies can't be installed", m.File)
+	}
+
+	ctx, span := startSpan(ctx, "dependencies.install", spanKindInternal)
+	defer span.Finish()
+	span.SetAttr("dependencies.manifest", m.File)
+
+	files, err := sm.WorkspaceArchive(session.ID)
+	if err != nil {
+		span.SetError(err)
+		return false, err
+	}
+
+	// The vendored directory comes back as a base64 tarball on stdout
+	script := fmt.Sprintf("set -e\n{ %s; } 1>&2\ntar czf - %s | base64 -w0\n", m.Install, shellQuote(m.Dir))
+	sub := NewSubmission(script, LanguageBash, "")
+	sub.AdditionalFiles = files
+	sub.CPUTimeLimit = 15
+	sub.MemoryLimit = 512000
+	enabled := true
+	sub.EnableNetwork = &enabled
+
+	result, err := judge0Client.Submit(ctx, sub, WaitOptions{Timeout: defaultExecTimeout * 4})
+	if err == nil && result.ExitCode != 0 {
+		err = fmt.Errorf("installing %s failed (exit %d): %s", m.File, result.ExitCode, strings.TrimSpace(result.Stderr+result.Message))
+	}
+	if err != nil {
+		span.SetError(err)
+		return false, err
+	}
+
+	if err := extractVendoredDir(root, m.Dir, result.Stdout); err != nil {
+		span.SetError(err)
+		return false, fmt.Errorf("failed to unpack %s dependencies: %w", m.File, err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, depsStateDir), 0755); err != nil {
+		return false, err
+	}
+	if err := os.WriteFile(depsHashPath(root, m), []byte(hash+"\n"), 0644); err != nil {
+		return false, err
+	}
+
+	log.Printf("Installed %s dependencies for %s", m.File, session.ID)
+	return true, nil
+}
+
+// extractVendoredDir replaces dir in the workspace with the contents of a
+// base64 tar.gz, rejecting entries outside dir
+func extractVendoredDir(root, dir, encoded string) error {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return err
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	target := filepath.Join(root, filepath.FromSlash(dir))
+	if err := os.RemoveAll(target); err != nil {
+		return err
+	}
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		name := filepath.Clean(filepath.FromSlash(hdr.Name))
+		if name != filepath.Clean(filepath.FromSlash(dir)) && !strings.HasPrefix(name, filepath.Clean(filepath.FromSlash(dir))+string(filepath.Separator)) {
+			return fmt.Errorf("unexpected archive entry: %s", hdr.Name)
+		}
+		path := filepath.Join(root, name)
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.FileMode(hdr.Mode)&0755|0600)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, tr)
+			f.Close()
+			if err != nil {
+				return err
+			}
+		}
+		// Symlinks and special files are skipped
+	}
+}
+
+// hasVendorDir reports whether Go dependencies were vendored
+func hasVendorDir(root string) bool {
+	info, err := os.Stat(filepath.Join(root, "vendor"))
+	return err == nil && info.IsDir()
+}
+
+// dependencyPrelude makes vendored dependencies importable where the
+// language doesn't find them on its own
+func dependencyPrelude(langID int, root string) string {
+	if langID != LanguagePython3 {
+		return ""
+	}
+	if _, err := os.Stat(filepath.Join(root, depsStateDir, "python")); err != nil {
+		return ""
+	}
+	return "import sys as _j0_sys, os as _j0_os\n_j0_sys.path.insert(0, _j0_os.path.abspath(\"" + depsStateDir + "/python\"))\n"
+}
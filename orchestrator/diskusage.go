@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// DiskUsage breaks down a session's on-disk footprint
+type DiskUsage struct {
+	// LogBytes covers the session log, its executions JSONL sidecar and
+	// its hash chain
+	LogBytes       int64 `json:"log_bytes"`
+	WorkspaceBytes int64 `json:"workspace_bytes"`
+	// ArtifactBytes covers raw transcripts, reports, exam ledgers and archives
+	ArtifactBytes int64 `json:"artifact_bytes"`
+	TotalBytes    int64 `json:"total_bytes"`
+}
+
+// SessionWithDisk is a session with its disk usage, as served by
+// GET /sessions/{id} and GET /sessions?usage=true
+type SessionWithDisk struct {
+	*Session
+	Disk DiskUsage `json:"disk"`
+}
+
+// sessionFiles lists a session's files outside its workspace: state,
+// logs and artifacts
+func (sm *SessionManager) sessionFiles(session *Session) (state, logs, artifacts []string) {
+	state = []string{filepath.Join(sm.dataDir, session.ID+".json")}
+	logs = []string{session.LogFile, sm.ExecutionsFile(session), sm.LogChainFile(session)}
+	artifacts = []string{
+		filepath.Join(sm.dataDir, "raw", filepath.Base(session.ID)),
+		sm.reportPath(session.ID),
+		filepath.Join(sm.dataDir, "archives", session.ID+".tar.gz"),
+		sm.examLedgerPath(session),
+	}
+	return state, logs, artifacts
+}
+
+// DiskUsage measures a session's files
+func (sm *SessionManager) DiskUsage(session *Session) DiskUsage {
+	_, logs, artifacts := sm.sessionFiles(session)
+
+	var usage DiskUsage
+	for _, path := range logs {
+		usage.LogBytes += dirSize(path)
+	}
+	usage.WorkspaceBytes = dirSize(sm.WorkspaceDir(session.ID))
+	for _, path := range artifacts {
+		usage.ArtifactBytes += dirSize(path)
+	}
+	usage.TotalBytes = usage.LogBytes + usage.WorkspaceBytes + usage.ArtifactBytes
+	return usage
+}
+
+// DeleteSession removes a closed session and everything it stored on disk
+func (sm *SessionManager) DeleteSession(id string) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	session, ok := sm.sessions[id]
+	if !ok {
+		return fmt.Errorf("session not found: %s", id)
+	}
+	if session.Status != "closed" {
+		return fmt.Errorf("session %s is %s; close it before deleting", id, session.Status)
+	}
+	if len(sm.inflight[id]) > 0 {
+		return fmt.Errorf("session %s has an execution in flight", id)
+	}
+
+	state, logs, artifacts := sm.sessionFiles(session)
+	paths := append(append(append(logs, artifacts...), sm.WorkspaceDir(id)), state...)
+	for _, path := range paths {
+		if err := os.RemoveAll(path); err != nil {
+			return fmt.Errorf("failed to delete %s: %w", path, err)
+		}
+	}
+	delete(sm.sessions, id)
+	return nil
+}
+
+// withDiskUsage attaches disk usage to sessions, largest first
+func withDiskUsage(sm *SessionManager, sessions []*Session) []SessionWithDisk {
+	out := make([]SessionWithDisk, len(sessions))
+	for i, s := range sessions {
+		out[i] = SessionWithDisk{Session: s, Disk: sm.DiskUsage(s)}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Disk.TotalBytes > out[j].Disk.TotalBytes })
+	return out
+}
+
+// parseByteSize reads sizes such as "1GB", "500M", "1.5GiB" or "4096";
+// units are powers of 1024
+func parseByteSize(value string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(value))
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "B"), "I")
+
+	multiplier := int64(1)
+	for i, unit := range []string{"K", "M", "G", "T"} {
+		if strings.HasSuffix(s, unit) {
+			multiplier = 1 << (10 * (i + 1))
+			s = strings.TrimSuffix(s, unit)
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q (e.g. 1GB, 500MB, 4096)", value)
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+// formatBytes prints a byte count with a binary unit
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%cB", float64(n)/float64(div), "KMGT"[exp])
+}
+
+// writeSessionsWithUsage serves GET /sessions?usage=true
+func writeSessionsWithUsage(w http.ResponseWriter, r *http.Request, sessions []*Session) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(withDiskUsage(sessionsFor(r.Context()), sessions))
+}
+
+var sessionsPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Delete closed sessions that use more than a given amount of disk",
+	Long: `Delete closed sessions whose logs, workspace and artifacts together
+exceed --larger-than, largest first. Active and paused sessions are only
+considered with --close-active, which closes them before deleting.
+
+Examples:
+  j0 sessions prune --larger-than 1GB --dry-run
+  j0 sessions prune --larger-than 500MB`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		larger, _ := cmd.Flags().GetString("larger-than")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		closeActive, _ := cmd.Flags().GetBool("close-active")
+
+		threshold, err := parseByteSize(larger)
+		if err != nil {
+			return err
+		}
+
+		var freed int64
+		pruned := 0
+		for _, s := range withDiskUsage(sessionManager, sessionManager.ListSessions()) {
+			if s.Disk.TotalBytes <= threshold {
+				break
+			}
+			if s.Status != "closed" && !closeActive {
+				fmt.Printf("Skipping %s (%s, %s): not closed\n", s.ID, formatBytes(s.Disk.TotalBytes), s.Status)
+				continue
+			}
+			if dryRun {
+				fmt.Printf("Would delete %s (%s)\n", s.ID, formatBytes(s.Disk.TotalBytes))
+				freed += s.Disk.TotalBytes
+				pruned++
+				continue
+			}
+
+			if s.Status != "closed" {
+				if err := sessionManager.CloseSession(s.ID); err != nil {
+					return err
+				}
+				audit(cmd.Context(), "session.close", s.ID, map[string]interface{}{"reason": "prune"})
+			}
+			if err := sessionManager.DeleteSession(s.ID); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+				continue
+			}
+			audit(cmd.Context(), "session.prune", s.ID, map[string]interface{}{"disk_bytes": s.Disk.TotalBytes})
+			fmt.Printf("Deleted %s (%s)\n", s.ID, formatBytes(s.Disk.TotalBytes))
+			freed += s.Disk.TotalBytes
+			pruned++
+		}
+
+		verb := "Deleted"
+		if dryRun {
+			verb = "Would delete"
+		}
+		fmt.Printf("%s %d session(s), %s\n", verb, pruned, formatBytes(freed))
+		return nil
+	},
+}
+
+func init() {
+	sessionsPruneCmd.Flags().String("larger-than", "", "Delete sessions using more than this much disk, e.g. 1GB")
+	sessionsPruneCmd.Flags().Bool("dry-run", false, "List the sessions that would be deleted")
+	sessionsPruneCmd.Flags().Bool("close-active", false, "Also close and delete active and paused sessions")
+	sessionsPruneCmd.MarkFlagRequired("larger-than")
+	sessionsCmd.AddCommand(sessionsPruneCmd)
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// UnsetEnv removes an environment variable from the session
+func (sm *SessionManager) UnsetEnv(sessionID, key string) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	session, ok := sm.sessions[sessionID]
+	if !ok {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+	if session.Exam != nil {
+		return fmt.Errorf("environment changes are not allowed in exam sessions")
+	}
+	_, plain := session.State.Env[key]
+	_, secret := session.State.Secrets[key]
+	if !plain && !secret {
+		return fmt.Errorf("env var not found: %s", key)
+	}
+
+	delete(session.State.Env, key)
+	delete(session.State.Secrets, key)
+	session.UpdatedAt = time.Now()
+	return sm.saveSession(session)
+}
+
+// ReplaceEnv replaces the session's environment variables, secrets
+// included, with env
+func (sm *SessionManager) ReplaceEnv(sessionID string, env map[string]string) error {
+	for key := range env {
+		if err := checkEnvName(key); err != nil {
+			return err
+		}
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	session, ok := sm.sessions[sessionID]
+	if !ok {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+	if session.Exam != nil {
+		return fmt.Errorf("environment changes are not allowed in exam sessions")
+	}
+
+	session.State.Env = make(map[string]string, len(env))
+	for key, value := range env {
+		session.State.Env[key] = value
+	}
+	session.State.Secrets = nil
+	session.UpdatedAt = time.Now()
+	return sm.saveSession(session)
+}
+
+// envErrorStatus maps env update errors to HTTP statuses
+func envErrorStatus(err error) int {
+	switch {
+	case strings.Contains(err.Error(), "not found"):
+		return http.StatusNotFound
+	case strings.Contains(err.Error(), "exam sessions"):
+		return http.StatusForbidden
+	}
+	return http.StatusBadRequest
+}
+
+// handleUnsetEnv serves DELETE /sessions/{id}/env/{key}
+func handleUnsetEnv(w http.ResponseWriter, r *http.Request) {
+	id, key := r.PathValue("id"), r.PathValue("key")
+	if err := sessionsFor(r.Context()).UnsetEnv(id, key); err != nil {
+		http.Error(w, err.Error(), envErrorStatus(err))
+		return
+	}
+	audit(r.Context(), "session.env_unset", id, map[string]interface{}{"key": key})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleReplaceEnv serves PUT /sessions/{id}/env, replacing the whole map
+func handleReplaceEnv(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	var env map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&env); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := sessionsFor(r.Context()).ReplaceEnv(id, env); err != nil {
+		http.Error(w, err.Error(), envErrorStatus(err))
+		return
+	}
+	// Values may be secrets; only the keys are audited
+	audit(r.Context(), "session.env_replace", id, map[string]interface{}{"keys": sortedEnvKeys(env)})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+func invokeMCPUnsetEnv(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	sessionID, _ := params["session_id"].(string)
+	key, _ := params["key"].(string)
+
+	if sessionID == "" {
+		return nil, fmt.Errorf("session_id is required")
+	}
+	if err := authorizeSession(ctx, sessionID); err != nil {
+		return nil, err
+	}
+	if key == "" {
+		return nil, fmt.Errorf("key is required")
+	}
+
+	if err := sessionsFor(ctx).UnsetEnv(sessionID, key); err != nil {
+		return nil, err
+	}
+	audit(ctx, "session.env_unset", sessionID, map[string]interface{}{"key": key, "via": "mcp"})
+
+	return map[string]string{"status": "ok"}, nil
+}
+
+// envCmd manages session environment variables
+var envCmd = &cobra.Command{
+	Use:   "env",
+	Short: "Manage session environment variables",
+}
+
+var envListCmd = &cobra.Command{
+	Use:   "list <session-id>",
+	Short: "List a session's environment variables",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		session, err := sessionManager.GetSession(args[0])
+		if err != nil {
+			return err
+		}
+
+		for _, key := range sortedEnvKeys(session.State.Env) {
+			fmt.Printf("%s=%s\n", key, session.State.Env[key])
+		}
+		for _, key := range sortedEnvKeys(session.State.Secrets) {
+			fmt.Printf("%s=<secret>\n", key)
+		}
+		return nil
+	},
+}
+
+var envSetCmd = &cobra.Command{
+	Use:   "set <session-id> <key> <value>",
+	Short: "Set an environment variable in a session",
+	Long: `Set an environment variable in a session. With --secret the value is
+stored encrypted with the key from --secret-key or --secret-key-command;
+tenant sessions use the tenant's own key.
+
+Examples:
+  j0 env set sess-1a2b3c4d DEBUG 1
+  j0 --secret-key j0.key env set sess-1a2b3c4d API_TOKEN "$TOKEN" --secret`,
+	Args: cobra.ExactArgs(3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		set := sessionManager.SetEnv
+		if secret, _ := cmd.Flags().GetBool("secret"); secret {
+			set = sessionManager.SetSecretEnv
+		}
+		if err := set(args[0], args[1], args[2]); err != nil {
+			return err
+		}
+		audit(cmd.Context(), "session.env_set", args[0], map[string]interface{}{"key": args[1]})
+		return nil
+	},
+}
+
+var envUnsetCmd = &cobra.Command{
+	Use:   "unset <session-id> <key>...",
+	Short: "Remove environment variables from a session",
+	Long: `Remove environment variables from a session. Later executions no
+longer see them.
+
+Examples:
+  j0 env unset sess-1a2b3c4d API_TOKEN
+  j0 env unset sess-1a2b3c4d FOO BAR`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		for _, key := range args[1:] {
+			if err := sessionManager.UnsetEnv(args[0], key); err != nil {
+				return err
+			}
+			audit(cmd.Context(), "session.env_unset", args[0], map[string]interface{}{"key": key})
+		}
+		return nil
+	},
+}
+
+func init() {
+	envSetCmd.Flags().Bool("secret", false, "Store the value encrypted at rest")
+	envCmd.AddCommand(envListCmd)
+	envCmd.AddCommand(envSetCmd)
+	envCmd.AddCommand(envUnsetCmd)
+	rootCmd.AddCommand(envCmd)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUnsetEnv(t *testing.T) {
+	sm, session := newTestSession(t, "bash", SessionOptions{})
+	sm.secretKey = bytes.Repeat([]byte{4}, 32)
+	sm.SetEnv(session.ID, "PLAIN", "1")
+	sm.SetSecretEnv(session.ID, "TOKEN", "secret")
+
+	tests := []struct {
+		key  string
+		want int
+	}{
+		{key: "PLAIN", want: http.StatusNoContent},
+		{key: "TOKEN", want: http.StatusNoContent},
+		{key: "PLAIN", want: http.StatusNotFound},
+		{key: "NEVER_SET", want: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodDelete, "/sessions/"+session.ID+"/env/"+tt.key, nil)
+		r = r.WithContext(withTenant(r.Context(), "", sm))
+		r.SetPathValue("id", session.ID)
+		r.SetPathValue("key", tt.key)
+		w := httptest.NewRecorder()
+		handleUnsetEnv(w, r)
+		if w.Code != tt.want {
+			t.Fatalf("unset %s: status %d, want %d", tt.key, w.Code, tt.want)
+		}
+	}
+
+	saved, _ := sm.GetSession(session.ID)
+	if len(saved.State.Env) != 0 || len(saved.State.Secrets) != 0 {
+		t.Fatalf("env %v, secrets %v left", saved.State.Env, saved.State.Secrets)
+	}
+}
+
+func TestReplaceEnv(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want int
+		env  map[string]string
+	}{
+		{name: "replace", body: `{"A": "1", "B": "two words"}`, want: http.StatusOK, env: map[string]string{"A": "1", "B": "two words"}},
+		{name: "clear", body: `{}`, want: http.StatusOK, env: map[string]string{}},
+		{name: "invalid name", body: `{"A": "1", "NOT-VALID": "x"}`, want: http.StatusBadRequest, env: map[string]string{"OLD": "1"}},
+		{name: "not json", body: `A=1`, want: http.StatusBadRequest, env: map[string]string{"OLD": "1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sm, session := newTestSession(t, "bash", SessionOptions{})
+			sm.secretKey = bytes.Repeat([]byte{4}, 32)
+			sm.SetEnv(session.ID, "OLD", "1")
+			sm.SetSecretEnv(session.ID, "TOKEN", "secret")
+
+			r := httptest.NewRequest(http.MethodPut, "/sessions/"+session.ID+"/env", strings.NewReader(tt.body))
+			r = r.WithContext(withTenant(r.Context(), "", sm))
+			r.SetPathValue("id", session.ID)
+			w := httptest.NewRecorder()
+			handleReplaceEnv(w, r)
+			if w.Code != tt.want {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+
+			saved, _ := sm.GetSession(session.ID)
+			if !reflect.DeepEqual(saved.State.Env, tt.env) {
+				t.Fatalf("env = %v, want %v", saved.State.Env, tt.env)
+			}
+			// A replace drops secrets too; a refused one keeps them
+			if _, kept := saved.State.Secrets["TOKEN"]; kept != (tt.want != http.StatusOK) {
+				t.Fatalf("secrets = %v", saved.State.Secrets)
+			}
+		})
+	}
+}
+
+func TestEnvChangesRefusedInExams(t *testing.T) {
+	sm, session := newTestSession(t, "bash", SessionOptions{})
+	sm.SetEnv(session.ID, "A", "1")
+	session.Exam = &ExamSettings{}
+
+	for name, err := range map[string]error{
+		"unset":   sm.UnsetEnv(session.ID, "A"),
+		"replace": sm.ReplaceEnv(session.ID, map[string]string{}),
+	} {
+		if err == nil || envErrorStatus(err) != http.StatusForbidden {
+			t.Errorf("%s in an exam session: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// envInjector rewrites code so it runs with env set
+type envInjector func(code string, env map[string]string) string
+
+// envInjectors set session environment variables for each backend
+// language, keyed by its lowercased catalog name without the version
+// ("c++" for "C++ (GCC 9.2.0)"). Interpreted languages get a prelude;
+// compiled ones a small harness that sets the variables before main runs.
+var envInjectors = map[string]envInjector{
+	"bash":       injectBashEnv,
+	"python":     injectPythonEnv,
+	"javascript": injectJavaScriptEnv,
+	"ruby":       injectRubyEnv,
+	"go":         injectGoEnv,
+	"c":          injectCEnv,
+	"c++":        injectCEnv,
+	"rust":       injectRustEnv,
+}
+
+// envInjectorFor returns the injector for a Judge0 language ID, whatever
+// name the session used for it: an alias, a qualified name like "gcc:12",
+// a catalog name or a --languages-config alias
+func envInjectorFor(langID int) (envInjector, bool) {
+	for name, id := range LanguageMap {
+		if inject, ok := envInjectors[name]; ok && id == langID {
+			return inject, true
+		}
+	}
+	for _, name := range catalogNames() {
+		if id, _ := catalogLanguageID(name); id != langID {
+			continue
+		}
+		base, _ := splitLanguageName(name)
+		if inject, ok := envInjectors[base]; ok {
+			return inject, true
+		}
+	}
+	return nil, false
+}
+
+// prepareCodeWithEnv wraps code to inject environment variables; custom
+// languages with a wrapper template are rendered through it
+func prepareCodeWithEnv(code string, env map[string]string, language string, langID int) string {
+	if wrapped, ok := wrapCustomLanguage(code, env, language); ok {
+		return wrapped
+	}
+	if len(env) == 0 {
+		return code
+	}
+	if inject, ok := envInjectorFor(langID); ok {
+		return inject(code, env)
+	}
+	// For other languages, just return the code as-is
+	return code
+}
+
+// sortedEnvKeys returns env's names in order, so generated code is stable
+func sortedEnvKeys(env map[string]string) []string {
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func injectBashEnv(code string, env map[string]string) string {
+	prefix := ""
+	for k, v := range env {
+		// Names are checked when set; skip any a session saved before then
+		if !validEnvName.MatchString(k) {
+			continue
+		}
+		prefix += fmt.Sprintf("export %s=%s\n", k, shellQuote(v))
+	}
+	return prefix + code
+}
+
+func injectPythonEnv(code string, env map[string]string) string {
+	prefix := "import os\n"
+	for k, v := range env {
+		prefix += fmt.Sprintf("os.environ[%q] = %q\n", k, v)
+	}
+	return prefix + code
+}
+
+func injectJavaScriptEnv(code string, env map[string]string) string {
+	prefix := ""
+	for k, v := range env {
+		prefix += fmt.Sprintf("process.env[%s] = %s;\n", jsString(k), jsString(v))
+	}
+	return prefix + code
+}
+
+func injectRubyEnv(code string, env map[string]string) string {
+	prefix := ""
+	for k, v := range env {
+		prefix += fmt.Sprintf("ENV[%s] = %s\n", rubyString(k), rubyString(v))
+	}
+	return prefix + code
+}
+
+// goPackageClause matches the package clause our import goes after
+var goPackageClause = regexp.MustCompile(`(?m)^package\s+\w+[^\n]*(\n|$)`)
+
+// injectGoEnv imports os under an alias after the package clause and
+// appends an init function setting the variables. A //line directive keeps
+// compiler errors on the user's line numbers.
+func injectGoEnv(code string, env map[string]string) string {
+	loc := goPackageClause.FindStringIndex(code)
+	if loc == nil {
+		return code
+	}
+	head := code[:loc[1]]
+	if !strings.HasSuffix(head, "\n") {
+		head += "\n"
+	}
+	next := strings.Count(head, "\n") + 1
+
+	var init strings.Builder
+	init.WriteString("\nfunc init() {\n")
+	for _, k := range sortedEnvKeys(env) {
+		fmt.Fprintf(&init, "\tj0os.Setenv(%q, %q)\n", k, env[k])
+	}
+	init.WriteString("}\n")
+
+	tail := code[loc[1]:]
+	if !strings.HasSuffix(tail, "\n") {
+		tail += "\n"
+	}
+	return head + "import j0os \"os\"\n" + fmt.Sprintf("//line main.go:%d\n", next) + tail + init.String()
+}
+
+// injectCEnv prepends a constructor that runs before main, for both C and
+// C++; #line restores the user's line numbers
+func injectCEnv(code string, env map[string]string) string {
+	var b strings.Builder
+	b.WriteString("#include <stdlib.h>\n")
+	b.WriteString("__attribute__((constructor)) static void j0_setenv(void) {\n")
+	for _, k := range sortedEnvKeys(env) {
+		fmt.Fprintf(&b, "\tsetenv(%s, %s, 1);\n", cString(k), cString(env[k]))
+	}
+	b.WriteString("}\n#line 1\n")
+	return b.String() + code
+}
+
+// rustMain matches the user's main function up to its opening brace:
+// public or async (under an attribute like #[tokio::main]) and with its
+// return type on any line. Group 1 is the name, group 2 the return type.
+var rustMain = regexp.MustCompile(`(?m)^[ \t]*(?:pub(?:\([^)]*\))?\s+)?(?:async\s+)?fn\s+(main)\s*\(\s*\)([^{]*)\{`)
+
+// injectRustEnv renames the user's main in place, keeping its line
+// numbers, and adds one that sets the variables, then calls it with the
+// same return type
+func injectRustEnv(code string, env map[string]string) string {
+	m := rustMain.FindStringSubmatchIndex(code)
+	if m == nil {
+		return code
+	}
+	ret := strings.Join(strings.Fields(code[m[4]:m[5]]), " ")
+
+	var b strings.Builder
+	b.WriteString(code[:m[2]])
+	b.WriteString("j0_user_main")
+	b.WriteString(code[m[3]:])
+	if !strings.HasSuffix(code, "\n") {
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\nfn main() " + ret)
+	if ret != "" {
+		b.WriteString(" ")
+	}
+	b.WriteString("{\n    #[allow(unused_unsafe)]\n    unsafe {\n")
+	for _, k := range sortedEnvKeys(env) {
+		fmt.Fprintf(&b, "        std::env::set_var(%s, %s);\n", rustString(k), rustString(env[k]))
+	}
+	b.WriteString("    }\n    j0_user_main()\n}\n")
+	return b.String()
+}
+
+// cString quotes s as a C string literal. Bytes outside printable ASCII
+// use three-digit octal escapes, which unlike \x can't swallow the
+// characters that follow.
+func cString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c == '?':
+			// Avoid trigraphs
+			b.WriteString(`\?`)
+		case c < 0x20 || c >= 0x7f:
+			fmt.Fprintf(&b, `\%03o`, c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// rustString quotes s as a Rust string literal
+func rustString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for len(s) > 0 {
+		r, size := utf8.DecodeRuneInString(s)
+		s = s[size:]
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\u{%x}`, r)
+		default:
+			// Invalid UTF-8 decodes as U+FFFD; Rust strings must be valid UTF-8
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// rubyEscaper escapes the only two characters special in single-quoted
+// Ruby strings
+var rubyEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+
+// rubyString quotes s as a single-quoted Ruby string literal, which has no
+// interpolation or escape sequences beyond \\ and \'
+func rubyString(s string) string {
+	return "'" + rubyEscaper.Replace(s) + "'"
+}
+
+// jsString quotes s as a JavaScript string literal. JSON strings are valid
+// JavaScript, and encoding/json escapes U+2028 and U+2029, which older
+// engines reject inside literals.
+func jsString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
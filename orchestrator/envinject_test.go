@@ -0,0 +1,261 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestInjectGoEnv(t *testing.T) {
+	env := map[string]string{"API_URL": "http://x", "QUOTE": "say \"hi\"\n"}
+
+	tests := []struct {
+		name string
+		code string
+		line string // //line directive expected before the user's second line
+	}{
+		{
+			name: "imports",
+			code: "package main\n\nimport \"fmt\"\n\nfunc main() { fmt.Println(1) }\n",
+			line: "//line main.go:2\n",
+		},
+		{
+			name: "comment before package",
+			code: "// Command demo\npackage main // demo\nfunc main() {}",
+			line: "//line main.go:3\n",
+		},
+		{
+			name: "own os import",
+			code: "package main\nimport \"os\"\nfunc main() { os.Exit(0) }\n",
+			line: "//line main.go:2\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := injectGoEnv(tt.code, env)
+			if _, err := parser.ParseFile(token.NewFileSet(), "main.go", got, 0); err != nil {
+				t.Fatalf("harness doesn't parse: %v\n%s", err, got)
+			}
+			if !strings.Contains(got, tt.line) {
+				t.Fatalf("harness lacks %q:\n%s", tt.line, got)
+			}
+			for _, want := range []string{`j0os.Setenv("API_URL", "http://x")`, `j0os.Setenv("QUOTE", "say \"hi\"\n")`} {
+				if !strings.Contains(got, want) {
+					t.Fatalf("harness lacks %s:\n%s", want, got)
+				}
+			}
+		})
+	}
+
+	if got := injectGoEnv("func main() {}", env); got != "func main() {}" {
+		t.Fatalf("code without a package clause changed:\n%s", got)
+	}
+}
+
+func TestInjectRustEnv(t *testing.T) {
+	env := map[string]string{"TOKEN": "a\"b\\c"}
+
+	tests := []struct {
+		name    string
+		code    string
+		renamed string // the user's main after renaming
+		main    string // the generated main's signature
+	}{
+		{
+			name:    "plain",
+			code:    "fn main() {\n    println!(\"hi\");\n}\n",
+			renamed: "fn j0_user_main() {\n",
+			main:    "\nfn main() {\n",
+		},
+		{
+			name:    "pub",
+			code:    "pub fn main() {\n}\n",
+			renamed: "pub fn j0_user_main() {\n",
+			main:    "\nfn main() {\n",
+		},
+		{
+			name:    "async with attribute",
+			code:    "#[tokio::main]\nasync fn main() {\n}\n",
+			renamed: "#[tokio::main]\nasync fn j0_user_main() {\n",
+			main:    "\nfn main() {\n",
+		},
+		{
+			name:    "result",
+			code:    "use std::error::Error;\nfn main() -> Result<(), Box<dyn Error>> {\n    Ok(())\n}",
+			renamed: "fn j0_user_main() -> Result<(), Box<dyn Error>> {\n",
+			main:    "\nfn main() -> Result<(), Box<dyn Error>> {\n",
+		},
+		{
+			name:    "return type on the next line",
+			code:    "fn main()\n    -> Result<(), String>\n{\n    Ok(())\n}\n",
+			renamed: "fn j0_user_main()\n    -> Result<(), String>\n{\n",
+			main:    "\nfn main() -> Result<(), String> {\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := injectRustEnv(tt.code, env)
+			if !strings.HasPrefix(got, tt.code[:strings.Index(tt.code, "fn main")]) {
+				t.Fatalf("code before main changed:\n%s", got)
+			}
+			if !strings.Contains(got, tt.renamed) {
+				t.Fatalf("user main not renamed to %q:\n%s", tt.renamed, got)
+			}
+			if !strings.Contains(got, tt.main) || strings.Count(got, "fn main(") != 1 {
+				t.Fatalf("want one generated %q:\n%s", tt.main, got)
+			}
+			// The user's lines keep their numbers
+			user := got[:strings.Index(got, "\nfn main(")]
+			if strings.Count(user, "\n") != strings.Count(strings.TrimSuffix(tt.code, "\n"), "\n")+1 {
+				t.Fatalf("user code lines moved:\n%s", got)
+			}
+			if !strings.Contains(got, `std::env::set_var("TOKEN", "a\"b\\c");`) {
+				t.Fatalf("harness lacks set_var:\n%s", got)
+			}
+		})
+	}
+
+	if got := injectRustEnv("fn helper() {}\n", env); got != "fn helper() {}\n" {
+		t.Fatalf("code without main changed:\n%s", got)
+	}
+}
+
+func TestInjectCEnv(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		env  map[string]string
+		want []string
+	}{
+		{
+			name: "c",
+			code: "#include <stdio.h>\nint main(void) { return 0; }\n",
+			env:  map[string]string{"B": "2", "A": "1"},
+			want: []string{`setenv("A", "1", 1);` + "\n\tsetenv(\"B\", \"2\", 1);"},
+		},
+		{
+			name: "c++",
+			code: "#include <iostream>\nint main() { std::cout << getenv(\"A\"); }\n",
+			env:  map[string]string{"A": "x\"y"},
+			want: []string{`setenv("A", "x\"y", 1);`},
+		},
+		{
+			name: "escapes",
+			code: "int main() {}\n",
+			env:  map[string]string{"A": "??=\n\x01é"},
+			want: []string{`setenv("A", "\?\?=\012\001\303\251", 1);`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := injectCEnv(tt.code, tt.env)
+			if !strings.HasSuffix(got, "\n#line 1\n"+tt.code) {
+				t.Fatalf("user code not kept after #line 1:\n%s", got)
+			}
+			if !strings.Contains(got, "__attribute__((constructor))") {
+				t.Fatalf("no constructor:\n%s", got)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Fatalf("harness lacks %s:\n%s", want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestPrepareCodeWithEnvByLanguageID(t *testing.T) {
+	withDataDir(t)
+	resetLanguageCatalog(t)
+	setLanguageCatalog([]map[string]interface{}{
+		{"id": float64(48), "name": "C (GCC 7.4.0)"},
+		{"id": float64(76), "name": "C++ (Clang 7.0.1)"},
+		{"id": float64(70), "name": "Python (2.7.17)"},
+		{"id": float64(73), "name": "Rust (1.40.0)"},
+		{"id": float64(77), "name": "COBOL (GnuCOBOL 2.2)"},
+	})
+	LanguageMap["py-ml"] = LanguagePython3
+	defer delete(LanguageMap, "py-ml")
+
+	env := map[string]string{"TOKEN": "t"}
+	tests := []struct {
+		language string
+		want     string // marker of the injection, "" for none
+	}{
+		{"python3", `os.environ["TOKEN"]`},
+		{"node", `process.env["TOKEN"]`},
+		{"c (gcc 7.4.0)", `setenv("TOKEN"`},
+		{"c-gcc-7.4.0", `setenv("TOKEN"`},
+		{"C++ (Clang 7.0.1)", `setenv("TOKEN"`},
+		{"python-2.7.17", `os.environ["TOKEN"]`},
+		{"Rust (1.40.0)", `std::env::set_var("TOKEN"`},
+		{"py-ml", `os.environ["TOKEN"]`},
+		{"cobol", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.language, func(t *testing.T) {
+			id, err := GetLanguageID(tt.language)
+			if err != nil {
+				t.Fatal(err)
+			}
+			code := "fn main() {}\n"
+			got := prepareCodeWithEnv(code, env, tt.language, id)
+			if tt.want == "" && got != code {
+				t.Fatalf("code changed:\n%s", got)
+			}
+			if !strings.Contains(got, tt.want) {
+				t.Fatalf("no injection %s:\n%s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestInjectJavaScriptEnv(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{name: "plain", value: "http://x", want: `process.env["V"] = "http://x";`},
+		{name: "quotes", value: `say "hi" 'there'`, want: `process.env["V"] = "say \"hi\" 'there'";`},
+		{name: "newline and backslash", value: "a\\b\nc", want: `process.env["V"] = "a\\b\nc";`},
+		{name: "line separator", value: "a\u2028b", want: `process.env["V"] = "a\u2028b";`},
+		{name: "template syntax", value: "${process.exit(1)}", want: `process.env["V"] = "${process.exit(1)}";`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code := "console.log(process.env.V);\n"
+			got := injectJavaScriptEnv(code, map[string]string{"V": tt.value})
+			if got != tt.want+"\n"+code {
+				t.Fatalf("got:\n%s\nwant:\n%s\n%s", got, tt.want, code)
+			}
+		})
+	}
+}
+
+func TestInjectRubyEnv(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{name: "plain", value: "http://x", want: `ENV['V'] = 'http://x'`},
+		{name: "quote", value: "it's", want: `ENV['V'] = 'it\'s'`},
+		{name: "backslash", value: `a\b\'`, want: `ENV['V'] = 'a\\b\\\''`},
+		{name: "interpolation", value: "#{`id`}", want: "ENV['V'] = '#{`id`}'"},
+		{name: "newline", value: "a\nb", want: "ENV['V'] = 'a\nb'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code := "puts ENV['V']\n"
+			got := injectRubyEnv(code, map[string]string{"V": tt.value})
+			if got != tt.want+"\n"+code {
+				t.Fatalf("got:\n%s\nwant:\n%s\n%s", got, tt.want, code)
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ExamSettings describes a time-boxed exam session.
+// Exam sessions cannot change their environment, run without network access,
+// record every execution in an append-only ledger and are closed and
+// archived automatically at the deadline.
+type ExamSettings struct {
+	EndsAt     time.Time `json:"ends_at"`
+	Executions int       `json:"executions"`
+	LastHash   string    `json:"last_hash,omitempty"`
+	Archive    string    `json:"archive,omitempty"`
+}
+
+// examLedgerPath returns the append-only ledger file for a session
+func (sm *SessionManager) examLedgerPath(session *Session) string {
+	return filepath.Join(sm.dataDir, "exams", session.ID+".ledger")
+}
+
+// recordExamExecution appends an execution to the exam ledger.
+// Each line carries a hash chained to the previous line so edits are detectable.
+// Callers must hold sm.mu.
+func (sm *SessionManager) recordExamExecution(session *Session, exec Execution) error {
+	path := sm.examLedgerPath(session)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	session.Exam.Executions++
+	codeHash := sha256.Sum256([]byte(exec.Code))
+	line := fmt.Sprintf("%d %s %s exit=%d code=%s prev=%s",
+		session.Exam.Executions,
+		exec.Time.UTC().Format(time.RFC3339Nano),
+		exec.ID,
+		exec.ExitCode,
+		hex.EncodeToString(codeHash[:]),
+		session.Exam.LastHash,
+	)
+	lineHash := sha256.Sum256([]byte(line))
+	session.Exam.LastHash = hex.EncodeToString(lineHash[:])
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "%s hash=%s\n", line, session.Exam.LastHash)
+	return err
+}
+
+// CloseExpiredExams closes and archives exam sessions past their deadline
+func (sm *SessionManager) CloseExpiredExams() {
+	now := time.Now()
+	var expired []string
+
+	sm.mu.RLock()
+	for id, s := range sm.sessions {
+		if s.Exam != nil && s.Status == "active" && now.After(s.Exam.EndsAt) {
+			expired = append(expired, id)
+		}
+	}
+	sm.mu.RUnlock()
+
+	for _, id := range expired {
+		if err := sm.CloseSession(id); err != nil {
+			log.Printf("Warning: failed to close expired exam %s: %v", id, err)
+			continue
+		}
+		audit(systemContext, "session.close", id, map[string]interface{}{"reason": "exam_ended"})
+		if err := sm.archiveExam(id); err != nil {
+			log.Printf("Warning: failed to archive exam %s: %v", id, err)
+		}
+	}
+}
+
+// RunExamReaper closes expired exams periodically until ctx is cancelled
+func (sm *SessionManager) RunExamReaper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		sm.CloseExpiredExams()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// archiveExam writes the session JSON, log, hash chain and ledger to a
+// tar.gz archive
+func (sm *SessionManager) archiveExam(id string) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	session, ok := sm.sessions[id]
+	if !ok {
+		return fmt.Errorf("session not found: %s", id)
+	}
+
+	archiveDir := filepath.Join(sm.dataDir, "archives")
+	if err := os.MkdirAll(archiveDir, 0755); err != nil {
+		return err
+	}
+
+	archivePath := filepath.Join(archiveDir, id+".tar.gz")
+	f, err := os.Create(archivePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+
+	files := []string{
+		filepath.Join(sm.dataDir, id+".json"),
+		sm.ExecutionsFile(session),
+		session.LogFile,
+		sm.LogChainFile(session),
+		sm.examLedgerPath(session),
+	}
+	for _, path := range files {
+		if err := addFileToTar(tw, path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	session.Exam.Archive = archivePath
+	return sm.saveSession(session)
+}
+
+// addFileToTar copies a file into a tar archive under its base name
+func addFileToTar(tw *tar.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	header.Name = filepath.Base(path)
+
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
@@ -2,10 +2,16 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	neturl "net/url"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -13,6 +19,12 @@ import (
 type Judge0Client struct {
 	baseURL    string
 	httpClient *http.Client
+	// callbacks, when set, lets Judge0 push results instead of waiting
+	// for the next poll
+	callbacks *CallbackReceiver
+	// pool, when set, spreads submissions over several Judge0 backends
+	// (see balancer.go) and baseURL is unused
+	pool *backendPool
 }
 
 // Judge0Submission represents a code submission request
@@ -26,6 +38,17 @@ type Judge0Submission struct {
 	AdditionalFiles  string `json:"additional_files,omitempty"`
 	CompilerOptions  string `json:"compiler_options,omitempty"`
 	CommandLineArgs  string `json:"command_line_arguments,omitempty"`
+	EnableNetwork    *bool  `json:"enable_network,omitempty"`
+	CallbackURL      string `json:"callback_url,omitempty"`
+
+	// Optional limits and behaviour, set from SubmissionParams
+	WallTimeLimit                      float64 `json:"wall_time_limit,omitempty"`
+	StackLimit                         int     `json:"stack_limit,omitempty"`
+	MaxProcessesAndOrThreads           int     `json:"max_processes_and_or_threads,omitempty"`
+	EnablePerProcessAndThreadTimeLimit *bool   `json:"enable_per_process_and_thread_time_limit,omitempty"`
+	MaxFileSize                        int     `json:"max_file_size,omitempty"`
+	RedirectStderrToStdout             *bool   `json:"redirect_stderr_to_stdout,omitempty"`
+	NumberOfRuns                       int     `json:"number_of_runs,omitempty"`
 }
 
 // Judge0Result represents execution result
@@ -41,10 +64,30 @@ type Judge0Result struct {
 	Status        Status  `json:"status"`
 }
 
+// decodeBase64 decodes the output fields of a base64_encoded=true result.
+// The decoded strings hold the exact bytes produced by the program.
+func (r *Judge0Result) decodeBase64() error {
+	for _, field := range []*string{&r.Stdout, &r.Stderr, &r.CompileOutput, &r.Message} {
+		if *field == "" {
+			continue
+		}
+		data, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(*field, "\n", ""))
+		if err != nil {
+			return err
+		}
+		*field = string(data)
+	}
+	return nil
+}
+
 // Status represents Judge0 execution status
 type Status struct {
 	ID          int    `json:"id"`
 	Description string `json:"description"`
+	// Code is the stable status code for ID and Summary its localized
+	// description (see status.go); Judge0 doesn't send either
+	Code    string `json:"code,omitempty"`
+	Summary string `json:"summary,omitempty"`
 }
 
 // Language IDs for common languages
@@ -88,110 +131,423 @@ func NewJudge0Client(baseURL string) *Judge0Client {
 	}
 }
 
-// GetLanguageID returns the Judge0 language ID for a language name
+// GetLanguageID returns the Judge0 language ID for a language name: a
+// LanguageMap alias, or any language in the backend's catalog
 func GetLanguageID(language string) (int, error) {
-	id, ok := LanguageMap[language]
-	if !ok {
-		return 0, fmt.Errorf("unsupported language: %s", language)
+	if id, ok := LanguageMap[language]; ok {
+		return id, nil
+	}
+	if id, ok := catalogLanguageID(language); ok {
+		return id, nil
 	}
-	return id, nil
+	return 0, newUnsupportedLanguageError(language)
 }
 
-// Execute submits code for execution and waits for result
-func (c *Judge0Client) Execute(code string, languageID int, stdin string) (*Judge0Result, error) {
-	// Create submission
-	submission := Judge0Submission{
+// NewSubmission returns a submission with the orchestrator's default limits
+func NewSubmission(code string, languageID int, stdin string) Judge0Submission {
+	return Judge0Submission{
 		SourceCode:   code,
 		LanguageID:   languageID,
 		Stdin:        stdin,
-		CPUTimeLimit: 5,     // 5 seconds
+		CPUTimeLimit: 5,      // 5 seconds
 		MemoryLimit:  128000, // 128MB
 	}
+}
+
+// Deadline kinds reported by DeadlineError
+const (
+	DeadlineQueue = "queue_timeout"     // Judge0 did not start the submission in time
+	DeadlineTotal = "execution_timeout" // the submission did not finish in time
+	DeadlinePolls = "poll_limit"        // the submission did not finish within --poll-max-attempts
+)
+
+// DeadlineError is returned when a submission misses its queue-wait or
+// total deadline, so callers can tell a busy backend from slow code
+type DeadlineError struct {
+	Kind    string
+	Token   string
+	Elapsed time.Duration
+}
+
+func (e *DeadlineError) Error() string {
+	if e.Kind == DeadlineQueue && e.Token == "" {
+		return fmt.Sprintf("still waiting for a language concurrency slot after %s: backend busy", e.Elapsed.Round(time.Millisecond))
+	}
+	if e.Kind == DeadlineQueue {
+		return fmt.Sprintf("submission %s still queued after %s: backend busy", e.Token, e.Elapsed.Round(time.Millisecond))
+	}
+	if e.Kind == DeadlinePolls {
+		return fmt.Sprintf("submission %s did not finish within %d polls (%s)", e.Token, pollStrategy.MaxAttempts, e.Elapsed.Round(time.Millisecond))
+	}
+	return fmt.Sprintf("submission %s did not finish within %s", e.Token, e.Elapsed.Round(time.Millisecond))
+}
+
+// WaitOptions controls how Submit waits for a result
+type WaitOptions struct {
+	// QueueTimeout fails fast if Judge0 hasn't started the submission
+	// within this duration (0 waits up to Timeout)
+	QueueTimeout time.Duration
+	// Timeout is the total deadline including queueing (0 uses defaultExecTimeout)
+	Timeout time.Duration
+	// OnStatus is called with the submission status after every poll
+	OnStatus func(Status)
+}
+
+// Execute submits code for execution and waits for result
+func (c *Judge0Client) Execute(ctx context.Context, code string, languageID int, stdin string) (*Judge0Result, error) {
+	return c.Submit(ctx, NewSubmission(code, languageID, stdin), WaitOptions{})
+}
+
+// Submit sends a prepared submission and waits for the result within the
+// deadlines in opts. The span in ctx, if any, is continued and propagated to Judge0.
+// Cancelling ctx (a client disconnect or server shutdown) stops polling;
+// the submission itself keeps running on Judge0.
+func (c *Judge0Client) Submit(ctx context.Context, submission Judge0Submission, opts WaitOptions) (*Judge0Result, error) {
+	if c.pool != nil {
+		return c.pool.submit(ctx, c.callbacks, submission, opts)
+	}
+
+	start := time.Now()
+	ctx, span := startSpan(ctx, "judge0.submit", spanKindInternal)
+	defer span.Finish()
+	span.SetAttr("judge0.language_id", submission.LanguageID)
+
+	var callback <-chan *Judge0Result
+	if c.callbacks != nil {
+		var nonce string
+		submission.CallbackURL, nonce, callback = c.callbacks.Register()
+		defer c.callbacks.Release(nonce)
+	}
+
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = defaultExecTimeout
+	}
+
+	// Fast submissions can be run synchronously; queue timeouts and
+	// callbacks need polling, and so do backends that reject wait=true
+	if useWait(c.baseURL, opts, timeout, c.httpClient.Timeout, callback != nil) {
+		result, err := c.submitAndWait(ctx, submission, start, timeout, opts)
+		if !errors.Is(err, errWaitRejected) {
+			span.SetError(err)
+			return result, err
+		}
+		waitRejected.Store(c.baseURL, true)
+		span.SetAttr("judge0.wait_rejected", true)
+	}
 
 	// Submit
-	token, err := c.createSubmission(submission)
+	created, err := c.createSubmission(ctx, submission, false)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create submission: %w", err)
+		span.SetError(err)
+		return nil, &CreateSubmissionError{Err: err}
 	}
+	span.SetAttr("judge0.token", created.Token)
 
 	// Poll for result
-	return c.waitForResult(token)
+	result, err := c.waitForResult(ctx, created.Token, start, opts, callback)
+	span.SetError(err)
+	return result, err
+}
+
+// submitAndWait creates a submission with wait=true, so Judge0 answers
+// with the finished result. A result that is somehow still running is
+// polled for the rest of the deadline.
+func (c *Judge0Client) submitAndWait(ctx context.Context, submission Judge0Submission, start time.Time, timeout time.Duration, opts WaitOptions) (*Judge0Result, error) {
+	waitCtx, cancel := context.WithDeadline(ctx, start.Add(timeout))
+	defer cancel()
+
+	result, err := c.createSubmission(waitCtx, submission, true)
+	if errors.Is(err, errWaitRejected) {
+		return nil, err
+	}
+	if err != nil && ctx.Err() == nil && waitCtx.Err() != nil {
+		return nil, &DeadlineError{Kind: DeadlineTotal, Elapsed: time.Since(start)}
+	}
+	var transport *neturl.Error
+	if err != nil && !errors.As(err, &transport) {
+		// Judge0 answered with an error status, so nothing was created
+		return nil, &CreateSubmissionError{Err: err}
+	}
+	if err != nil {
+		return nil, err
+	}
+	spanFromContext(ctx).SetAttr("judge0.token", result.Token)
+
+	result.Status.Code = statusCode(result.Status.ID)
+	if result.Status.ID < 3 {
+		return c.waitForResult(ctx, result.Token, start, opts, nil)
+	}
+	if opts.OnStatus != nil {
+		opts.OnStatus(result.Status)
+	}
+	spanFromContext(ctx).SetAttr("judge0.status", result.Status.Description)
+	return result, nil
+}
+
+// CreateSubmissionError is returned by Submit when Judge0 didn't accept
+// the submission, so nothing ran and it is safe to send elsewhere
+type CreateSubmissionError struct {
+	Err error
+}
+
+func (e *CreateSubmissionError) Error() string {
+	return "failed to create submission: " + e.Err.Error()
 }
 
-// createSubmission sends code to Judge0 and returns submission token
-func (c *Judge0Client) createSubmission(sub Judge0Submission) (string, error) {
+func (e *CreateSubmissionError) Unwrap() error {
+	return e.Err
+}
+
+// Judge0ServerError is returned when Judge0 answers with a 5xx status
+type Judge0ServerError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *Judge0ServerError) Error() string {
+	return fmt.Sprintf("judge0 server error (%d): %s", e.StatusCode, e.Body)
+}
+
+// createSubmission sends code to Judge0 and returns the created submission:
+// only its token, or with wait the finished result. Text fields are base64
+// encoded so arbitrary bytes survive the round trip.
+func (c *Judge0Client) createSubmission(ctx context.Context, sub Judge0Submission, wait bool) (*Judge0Result, error) {
+	ctx, span := startSpan(ctx, "judge0.create_submission", spanKindClient)
+	defer span.Finish()
+
+	sub.SourceCode = base64.StdEncoding.EncodeToString([]byte(sub.SourceCode))
+	sub.Stdin = base64.StdEncoding.EncodeToString([]byte(sub.Stdin))
+	if sub.ExpectedOutput != "" {
+		sub.ExpectedOutput = base64.StdEncoding.EncodeToString([]byte(sub.ExpectedOutput))
+	}
+
 	data, err := json.Marshal(sub)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	url := c.baseURL + "/submissions?base64_encoded=false&wait=false"
-	req, err := http.NewRequest("POST", url, bytes.NewReader(data))
+	url := c.baseURL + "/submissions?base64_encoded=true&wait=" + strconv.FormatBool(wait)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(data))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/json")
+	injectTraceparent(ctx, req)
+	setJudge0Auth(ctx, req)
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return "", err
+		span.SetError(err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
+	span.SetAttr("http.status_code", resp.StatusCode)
 	if resp.StatusCode != http.StatusCreated {
 		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("submission failed: %s - %s", resp.Status, string(body))
+		if wait && resp.StatusCode == http.StatusBadRequest && strings.Contains(string(body), "wait") {
+			return nil, errWaitRejected
+		}
+		var err error = fmt.Errorf("submission failed: %s - %s", resp.Status, string(body))
+		if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+			err = &BackendAuthError{StatusCode: resp.StatusCode, Body: string(body)}
+		}
+		if resp.StatusCode == http.StatusTooManyRequests {
+			err = newRateLimitError(resp, body)
+		}
+		if resp.StatusCode >= 500 {
+			err = &Judge0ServerError{StatusCode: resp.StatusCode, Body: string(body)}
+		}
+		span.SetError(err)
+		return nil, err
 	}
 
-	var result struct {
-		Token string `json:"token"`
-	}
+	var result Judge0Result
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", err
+		return nil, err
+	}
+	if err := result.decodeBase64(); err != nil {
+		return nil, fmt.Errorf("invalid result encoding: %w", err)
 	}
 
-	return result.Token, nil
+	return &result, nil
 }
 
-// waitForResult polls Judge0 until execution completes
-func (c *Judge0Client) waitForResult(token string) (*Judge0Result, error) {
-	url := c.baseURL + "/submissions/" + token + "?base64_encoded=false"
+// waitForResult polls Judge0 until execution completes or a deadline
+// measured from start passes. A verified callback for the token ends the
+// wait between polls. Time spent in queue and processing is recorded on
+// the span in ctx.
+func (c *Judge0Client) waitForResult(ctx context.Context, token string, start time.Time, opts WaitOptions, callback <-chan *Judge0Result) (*Judge0Result, error) {
+	url := c.baseURL + "/submissions/" + token + "?base64_encoded=true"
+	parent := spanFromContext(ctx)
+	var queued time.Duration
 
-	maxAttempts := 30
-	for i := 0; i < maxAttempts; i++ {
-		req, err := http.NewRequest("GET", url, nil)
-		if err != nil {
-			return nil, err
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = defaultExecTimeout
+	}
+
+	var pushed *Judge0Result
+	for i := 0; ; i++ {
+		result := pushed
+		pushed = nil
+		if result == nil {
+			var err error
+			result, err = c.pollSubmission(ctx, url, i)
+			var limited *RateLimitError
+			if errors.As(err, &limited) {
+				// A rate-limited poll doesn't lose the submission; back
+				// off and poll again if the deadline allows
+				backoff := limited.RetryAfter
+				if backoff <= 0 {
+					backoff = rateLimitBackoff
+				}
+				if time.Since(start)+backoff >= timeout {
+					return nil, err
+				}
+				select {
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				case <-time.After(backoff):
+				}
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
 		}
 
-		resp, err := c.httpClient.Do(req)
-		if err != nil {
-			return nil, err
+		result.Status.Code = statusCode(result.Status.ID)
+		if opts.OnStatus != nil {
+			opts.OnStatus(result.Status)
 		}
 
-		var result Judge0Result
-		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-			resp.Body.Close()
-			return nil, err
+		// Status ID 1 = In Queue; the first other status ends queueing
+		if queued == 0 && result.Status.ID != 1 {
+			queued = time.Since(start)
+			parent.SetAttr("judge0.queue_ms", queued.Milliseconds())
 		}
-		resp.Body.Close()
 
 		// Status ID 1-2 = In Queue/Processing
 		// Status ID 3+ = Finished (with various outcomes)
 		if result.Status.ID >= 3 {
-			return &result, nil
+			parent.SetAttr("judge0.processing_ms", (time.Since(start) - queued).Milliseconds())
+			parent.SetAttr("judge0.status", result.Status.Description)
+			parent.SetAttr("judge0.polls", i+1)
+			return result, nil
+		}
+
+		elapsed := time.Since(start)
+		if queued == 0 && opts.QueueTimeout > 0 && elapsed >= opts.QueueTimeout {
+			return nil, &DeadlineError{Kind: DeadlineQueue, Token: token, Elapsed: elapsed}
+		}
+		if elapsed >= timeout {
+			return nil, &DeadlineError{Kind: DeadlineTotal, Token: token, Elapsed: elapsed}
+		}
+
+		if pollStrategy.MaxAttempts > 0 && i+1 >= pollStrategy.MaxAttempts {
+			return nil, &DeadlineError{Kind: DeadlinePolls, Token: token, Elapsed: elapsed}
 		}
 
-		time.Sleep(500 * time.Millisecond)
+		// Wake up at the next deadline rather than overshooting it
+		wait := pollStrategy.delay(i)
+		if remaining := timeout - elapsed; remaining < wait {
+			wait = remaining
+		}
+		if queued == 0 && opts.QueueTimeout > 0 {
+			if remaining := opts.QueueTimeout - elapsed; remaining < wait {
+				wait = remaining
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case r := <-callback:
+			if r.Token == token {
+				pushed = r
+			}
+		case <-time.After(wait):
+		}
+	}
+}
+
+// pollSubmission fetches the current state of a submission
+func (c *Judge0Client) pollSubmission(ctx context.Context, url string, attempt int) (*Judge0Result, error) {
+	ctx, span := startSpan(ctx, "judge0.poll", spanKindClient)
+	defer span.Finish()
+	span.SetAttr("judge0.attempt", attempt)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	injectTraceparent(ctx, req)
+	setJudge0Auth(ctx, req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		span.SetError(err)
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, &BackendAuthError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, newRateLimitError(resp, body)
+	}
+	if resp.StatusCode >= 500 {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, &Judge0ServerError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	// An unknown or expired token won't turn into a result by polling again
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		err := fmt.Errorf("poll failed: %s - %s", resp.Status, string(body))
+		span.SetError(err)
+		return nil, err
+	}
+
+	var result Judge0Result
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		span.SetError(err)
+		return nil, err
 	}
 
-	return nil, fmt.Errorf("execution timed out waiting for result")
+	if err := result.decodeBase64(); err != nil {
+		return nil, fmt.Errorf("invalid result encoding: %w", err)
+	}
+
+	span.SetAttr("judge0.status", result.Status.Description)
+	return &result, nil
+}
+
+// endpoint returns the URL of a Judge0 metadata endpoint
+func (c *Judge0Client) endpoint(path string) string {
+	if c.pool != nil {
+		return c.pool.baseURL() + path
+	}
+	return c.baseURL + path
+}
+
+// get requests a Judge0 endpoint with the orchestrator's credentials
+func (c *Judge0Client) get(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	setJudge0Auth(ctx, req)
+	return c.httpClient.Do(req)
 }
 
 // About returns Judge0 instance information
-func (c *Judge0Client) About() (map[string]interface{}, error) {
-	url := c.baseURL + "/about"
-	resp, err := c.httpClient.Get(url)
+func (c *Judge0Client) About(ctx context.Context) (map[string]interface{}, error) {
+	url := c.endpoint("/about")
+	resp, err := c.get(ctx, url)
 	if err != nil {
 		return nil, err
 	}
@@ -205,10 +561,77 @@ func (c *Judge0Client) About() (map[string]interface{}, error) {
 	return result, nil
 }
 
+// ConfigInfo returns the Judge0 instance's default and maximum limits
+func (c *Judge0Client) ConfigInfo(ctx context.Context) (map[string]interface{}, error) {
+	url := c.endpoint("/config_info")
+	resp, err := c.get(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("config_info failed: %s", resp.Status)
+	}
+
+	var result map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Workers returns Judge0 queue and worker counts
+func (c *Judge0Client) Workers(ctx context.Context) ([]map[string]interface{}, error) {
+	url := c.endpoint("/workers")
+	resp, err := c.get(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("judge0 workers: %s", resp.Status)
+	}
+
+	var result []map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Statistics returns Judge0's submission statistics; invalidate bypasses
+// Judge0's cache of them
+func (c *Judge0Client) Statistics(ctx context.Context, invalidate bool) (map[string]interface{}, error) {
+	url := c.endpoint("/statistics")
+	if invalidate {
+		url += "?invalidate_cache=true"
+	}
+	resp, err := c.get(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("judge0 statistics: %s", resp.Status)
+	}
+
+	var result map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
 // Languages returns supported languages
-func (c *Judge0Client) Languages() ([]map[string]interface{}, error) {
-	url := c.baseURL + "/languages"
-	resp, err := c.httpClient.Get(url)
+func (c *Judge0Client) Languages(ctx context.Context) ([]map[string]interface{}, error) {
+	url := c.endpoint("/languages")
+	resp, err := c.get(ctx, url)
 	if err != nil {
 		return nil, err
 	}
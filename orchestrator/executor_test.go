@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPollSubmissionStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		check  func(error) bool
+	}{
+		{
+			name: "ok", status: http.StatusOK,
+			body:  `{"token":"tok","status":{"id":3,"description":"Accepted"}}`,
+			check: func(err error) bool { return err == nil },
+		},
+		{
+			name: "unknown token", status: http.StatusNotFound, body: `{"error":"not found"}`,
+			check: func(err error) bool { return err != nil && strings.Contains(err.Error(), "404") },
+		},
+		{
+			name: "bad request", status: http.StatusBadRequest, body: `{"message":"invalid token"}`,
+			check: func(err error) bool { return err != nil && strings.Contains(err.Error(), "400") },
+		},
+		{
+			name: "gone", status: http.StatusGone,
+			check: func(err error) bool { return err != nil && strings.Contains(err.Error(), "410") },
+		},
+		{
+			name: "unauthorized", status: http.StatusUnauthorized,
+			check: func(err error) bool {
+				var auth *BackendAuthError
+				return errors.As(err, &auth)
+			},
+		},
+		{
+			name: "rate limited", status: http.StatusTooManyRequests,
+			check: func(err error) bool {
+				var limited *RateLimitError
+				return errors.As(err, &limited)
+			},
+		},
+		{
+			name: "server error", status: http.StatusServiceUnavailable,
+			check: func(err error) bool {
+				var server *Judge0ServerError
+				return errors.As(err, &server)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			c := NewJudge0Client(srv.URL)
+			result, err := c.pollSubmission(context.Background(), srv.URL+"/submissions/tok?base64_encoded=true", 0)
+			if !tt.check(err) {
+				t.Fatalf("pollSubmission error = %v", err)
+			}
+			if err == nil && result.Status.ID != 3 {
+				t.Fatalf("status = %+v", result.Status)
+			}
+		})
+	}
+}
+
+func TestWaitForResultStopsOnClientError(t *testing.T) {
+	polls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		polls++
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	c := NewJudge0Client(srv.URL)
+	start := time.Now()
+	_, err := c.waitForResult(context.Background(), "expired", start, WaitOptions{Timeout: 10 * time.Second}, nil)
+	if err == nil {
+		t.Fatal("waitForResult succeeded for an unknown token")
+	}
+	if polls != 1 || time.Since(start) > 5*time.Second {
+		t.Fatalf("polled %d times over %s; want one poll", polls, time.Since(start))
+	}
+}
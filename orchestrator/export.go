@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// ExportFilter selects the sessions and executions in a bulk export
+type ExportFilter struct {
+	Language string
+	Status   string
+	Owner    string
+	// Since and Until bound execution times; a session is exported when it
+	// was created in the range or has executions in it
+	Since time.Time
+	Until time.Time
+}
+
+// exportFilterKeys are the accepted filter names, as query parameters or
+// `j0 export --filter key=value`
+var exportFilterKeys = []string{"language", "status", "owner", "since", "until"}
+
+// parseExportFilter builds a filter from named values. Times are RFC 3339
+// or plain dates (UTC midnight).
+func parseExportFilter(values map[string]string) (ExportFilter, error) {
+	f := ExportFilter{
+		Language: values["language"],
+		Status:   values["status"],
+		Owner:    values["owner"],
+	}
+	for name, dst := range map[string]*time.Time{"since": &f.Since, "until": &f.Until} {
+		v := values[name]
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			if t, err = time.Parse("2006-01-02", v); err != nil {
+				return f, fmt.Errorf("invalid %s %q (want RFC 3339 or YYYY-MM-DD)", name, v)
+			}
+		}
+		*dst = t
+	}
+	return f, nil
+}
+
+func (f ExportFilter) inRange(t time.Time) bool {
+	return (f.Since.IsZero() || !t.Before(f.Since)) && (f.Until.IsZero() || t.Before(f.Until))
+}
+
+// ExportRecord is one line of a bulk export: a session, followed by one
+// record per execution in it
+type ExportRecord struct {
+	Type      string     `json:"type"` // "session" or "execution"
+	SessionID string     `json:"session_id"`
+	Language  string     `json:"language"`
+	Session   *Session   `json:"session,omitempty"`
+	Execution *Execution `json:"execution,omitempty"`
+}
+
+// ExportSessions writes matching sessions and their executions to w as
+// JSONL, oldest session first. Executions are read from each session's
+// JSONL sidecar, so archived ones are included. flush, if set, is called
+// after each session. It returns the number of sessions written.
+func ExportSessions(w io.Writer, sm *SessionManager, sessions []*Session, f ExportFilter, flush func()) (int, error) {
+	sessions = append([]*Session(nil), sessions...)
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].CreatedAt.Before(sessions[j].CreatedAt)
+	})
+
+	enc := json.NewEncoder(w)
+	written := 0
+	for _, s := range sessions {
+		if (f.Language != "" && s.Language != f.Language) ||
+			(f.Status != "" && s.Status != f.Status) ||
+			(f.Owner != "" && s.Owner != f.Owner) {
+			continue
+		}
+
+		history, err := readJSONLExecutions(sm.ExecutionsFile(s))
+		if err != nil {
+			return written, fmt.Errorf("reading executions of %s: %w", s.ID, err)
+		}
+		var matched []Execution
+		for _, exec := range history {
+			if f.inRange(exec.Time) {
+				matched = append(matched, exec)
+			}
+		}
+		if len(matched) == 0 && !f.inRange(s.CreatedAt) {
+			continue
+		}
+
+		// History is exported line by line below
+		session := *s
+		session.State.History = nil
+		if err := enc.Encode(ExportRecord{Type: "session", SessionID: s.ID, Language: s.Language, Session: &session}); err != nil {
+			return written, err
+		}
+		for i := range matched {
+			if err := enc.Encode(ExportRecord{Type: "execution", SessionID: s.ID, Language: s.Language, Execution: &matched[i]}); err != nil {
+				return written, err
+			}
+		}
+		written++
+		if flush != nil {
+			flush()
+		}
+	}
+	return written, nil
+}
+
+// handleExport serves GET /export?language=&status=&owner=&since=&until=&format=jsonl,
+// streaming the matching sessions and executions to admins
+func handleExport(w http.ResponseWriter, r *http.Request) {
+	if !hasRole(r.Context(), RoleAdmin) {
+		http.Error(w, "only admins can export sessions", http.StatusForbidden)
+		return
+	}
+	query := r.URL.Query()
+	if format := query.Get("format"); format != "" && format != "jsonl" {
+		http.Error(w, "unsupported format "+format+" (want jsonl)", http.StatusBadRequest)
+		return
+	}
+	values := make(map[string]string)
+	for _, key := range exportFilterKeys {
+		values[key] = query.Get(key)
+	}
+	filter, err := parseExportFilter(values)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	audit(ctx, "sessions.export", "", map[string]interface{}{"filter": query.Encode()})
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	flusher, _ := w.(http.Flusher)
+	flush := func() {
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	sm := sessionsFor(ctx)
+	// Headers are sent with the first session, so errors can only end the stream
+	ExportSessions(w, sm, visibleSessions(ctx, sm.ListSessions()), filter, flush)
+}
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export sessions and executions as JSONL",
+	Long: `Write every matching session and its executions to stdout (or --output)
+as JSON lines, for loading into a data warehouse.
+
+Each session is one {"type":"session"} line followed by one
+{"type":"execution"} line per execution, including archived ones.
+
+Filters (repeatable --filter key=value):
+  language  language name, e.g. python
+  status    active, paused or closed
+  owner     the identity that created the session
+  since     executions at or after this time (RFC 3339 or YYYY-MM-DD)
+  until     executions before this time
+
+Examples:
+  j0 export > sessions.jsonl
+  j0 export --filter language=python --filter since=2024-01-01 -o python.jsonl`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		filters, _ := cmd.Flags().GetStringArray("filter")
+		output, _ := cmd.Flags().GetString("output")
+
+		values := make(map[string]string)
+		for _, kv := range filters {
+			key, value, ok := strings.Cut(kv, "=")
+			if !ok || !slices.Contains(exportFilterKeys, key) {
+				return fmt.Errorf("invalid filter %q (want key=value with key one of %s)", kv, strings.Join(exportFilterKeys, ", "))
+			}
+			values[key] = value
+		}
+		filter, err := parseExportFilter(values)
+		if err != nil {
+			return err
+		}
+
+		w := io.Writer(os.Stdout)
+		if output != "" {
+			f, err := os.Create(output)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			w = f
+		}
+		n, err := ExportSessions(w, sessionManager, sessionManager.ListSessions(), filter, nil)
+		if err != nil {
+			return err
+		}
+		if output != "" {
+			fmt.Fprintf(os.Stderr, "Exported %d sessions to %s\n", n, output)
+		}
+		return nil
+	},
+}
+
+func init() {
+	exportCmd.Flags().StringArray("filter", nil, "Filter as key=value (language, status, owner, since, until)")
+	exportCmd.Flags().StringP("output", "o", "", "Write to this file instead of stdout")
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// languageFallbacks maps a Judge0 language ID to the ID to try when the
+// backend doesn't offer it, set by --language-fallback. Entries chain, so
+// 92=71,71=70 tries 3.11, then 3.8, then 2.7.
+var languageFallbacks = make(map[int]int)
+
+// LanguageFallback records that an execution ran under a fallback language
+// because the one asked for was missing from the backend
+type LanguageFallback struct {
+	RequestedID int    `json:"requested_id"`
+	UsedID      int    `json:"used_id"`
+	Message     string `json:"message"`
+}
+
+// configureLanguageFallbacks sets the fallback chains from --language-fallback
+func configureLanguageFallbacks(fallbacks map[string]int) error {
+	for from, to := range fallbacks {
+		id, err := strconv.Atoi(from)
+		if err != nil || id < 1 || to < 1 {
+			return fmt.Errorf("language fallback %s=%d: want language IDs, e.g. 92=71", from, to)
+		}
+		if id == to {
+			return fmt.Errorf("language fallback %s=%d falls back to itself", from, to)
+		}
+		languageFallbacks[id] = to
+	}
+	return nil
+}
+
+// resolveLanguageFallback returns the language ID to submit for id: id
+// itself when the backend offers it (or its languages can't be listed, in
+// which case Judge0 reports the problem), otherwise the first fallback in
+// its chain that the backend offers
+func resolveLanguageFallback(ctx context.Context, id int) (int, *LanguageFallback) {
+	if _, ok := languageFallbacks[id]; !ok {
+		return id, nil
+	}
+	languages, err := backendLanguages(ctx)
+	if err != nil {
+		return id, nil
+	}
+	names := make(map[int]string, len(languages))
+	for _, lang := range languages {
+		if v, ok := lang["id"].(float64); ok {
+			names[int(v)], _ = lang["name"].(string)
+		}
+	}
+	if _, ok := names[id]; ok {
+		return id, nil
+	}
+
+	seen := map[int]bool{id: true}
+	for next, ok := languageFallbacks[id]; ok && !seen[next]; next, ok = languageFallbacks[next] {
+		if name, available := names[next]; available {
+			return next, &LanguageFallback{
+				RequestedID: id,
+				UsedID:      next,
+				Message:     fmt.Sprintf("language %d is not available on the backend; ran as %d (%s)", id, next, name),
+			}
+		}
+		seen[next] = true
+	}
+	return id, nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"go/format"
+	"strings"
+)
+
+// Formatter normalizes source code before it is executed and recorded
+type Formatter interface {
+	Format(ctx context.Context, code string) (string, error)
+}
+
+// goFormatter formats Go in-process with the standard library
+type goFormatter struct{}
+
+func (goFormatter) Format(ctx context.Context, code string) (string, error) {
+	out, err := format.Source([]byte(code))
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// sandboxFormatter runs a formatter command (e.g. "black -q -") inside a
+// Judge0 bash submission, feeding the code on stdin and reading the result
+// from stdout. The command must be installed in the Judge0 image.
+type sandboxFormatter struct {
+	command string
+}
+
+func (f sandboxFormatter) Format(ctx context.Context, code string) (string, error) {
+	result, err := judge0Client.Submit(ctx, NewSubmission(f.command, LanguageBash, code), WaitOptions{})
+	if err != nil {
+		return "", err
+	}
+	if result.ExitCode != 0 || result.Status.ID != 3 {
+		msg := strings.TrimSpace(result.Stderr)
+		if msg == "" {
+			msg = result.Status.Description
+		}
+		return "", fmt.Errorf("%s: %s", f.command, msg)
+	}
+	return result.Stdout, nil
+}
+
+// formatters maps Judge0 language IDs to their formatter
+var formatters = map[int]Formatter{
+	LanguageGo: goFormatter{},
+}
+
+// configureFormatters registers sandbox formatter commands by language name,
+// e.g. {"python": "black -q -", "javascript": "prettier --stdin-filepath x.js"}
+func configureFormatters(commands map[string]string) error {
+	for language, command := range commands {
+		id, err := GetLanguageID(language)
+		if err != nil {
+			return fmt.Errorf("formatter: %w", err)
+		}
+		if command == "builtin" {
+			if _, ok := formatters[id]; !ok {
+				return fmt.Errorf("formatter: no builtin formatter for %s", language)
+			}
+			continue
+		}
+		formatters[id] = sandboxFormatter{command: command}
+	}
+	return nil
+}
+
+// formatCode runs the formatter for a language. It returns the code
+// unchanged and ok=false when no formatter is configured.
+func formatCode(ctx context.Context, language, code string) (formatted string, ok bool, err error) {
+	id, err := GetLanguageID(language)
+	if err != nil {
+		return code, false, err
+	}
+	formatter, found := formatters[id]
+	if !found {
+		return code, false, nil
+	}
+
+	ctx, span := startSpan(ctx, "format", spanKindInternal)
+	defer span.Finish()
+
+	formatted, err = formatter.Format(ctx, code)
+	if err != nil {
+		span.SetError(err)
+		return code, false, err
+	}
+	return formatted, true, nil
+}
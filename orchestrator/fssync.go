@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// fsCmd manages session workspace files
+var fsCmd = &cobra.Command{
+	Use:   "fs",
+	Short: "Manage session workspace files",
+}
+
+func init() {
+	fsCmd.AddCommand(fsListCmd)
+	fsCmd.AddCommand(fsSyncCmd)
+}
+
+var fsListCmd = &cobra.Command{
+	Use:   "ls <session-id>",
+	Short: "List files in a session workspace",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		files, err := sessionManager.ListFiles(args[0])
+		if err != nil {
+			return err
+		}
+
+		if len(files) == 0 {
+			fmt.Println("Workspace is empty.")
+			return nil
+		}
+
+		for _, f := range files {
+			fmt.Printf("%10d  %s  %s\n", f.Size, f.ModTime.Format("2006-01-02 15:04:05"), f.Path)
+		}
+		return nil
+	},
+}
+
+var fsSyncCmd = &cobra.Command{
+	Use:   "sync <session-id> <local-dir>",
+	Short: "Sync a local directory with a session workspace",
+	Long: `Mirror files between a local directory and a session workspace.
+
+Files that exist on only one side are copied to the other; when both sides
+differ, the most recently modified copy wins. Deletions are not propagated.
+Workspace files are sent to Judge0 with every execution in the session.
+
+Examples:
+  j0 fs sync sess-abc123 ./project
+  j0 fs sync sess-abc123 ./project --watch`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sessionID, localDir := args[0], args[1]
+		watch, _ := cmd.Flags().GetBool("watch")
+		interval, _ := cmd.Flags().GetDuration("interval")
+
+		if err := os.MkdirAll(localDir, 0755); err != nil {
+			return err
+		}
+
+		if !watch {
+			return syncWorkspace(sessionID, localDir)
+		}
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		fmt.Printf("Watching %s <-> %s (Ctrl-C to stop)\n", localDir, sessionID)
+		for {
+			if err := syncWorkspace(sessionID, localDir); err != nil {
+				return err
+			}
+			select {
+			case <-cmd.Context().Done():
+				return nil
+			case <-ticker.C:
+			}
+		}
+	},
+}
+
+func init() {
+	fsSyncCmd.Flags().Bool("watch", false, "Keep syncing until interrupted")
+	fsSyncCmd.Flags().Duration("interval", time.Second, "Polling interval with --watch")
+}
+
+// syncWorkspace performs one two-way sync pass, newest modification wins
+func syncWorkspace(sessionID, localDir string) error {
+	remoteFiles, err := sessionManager.ListFiles(sessionID)
+	if err != nil {
+		return err
+	}
+	localFiles, err := listDirFiles(localDir)
+	if err != nil {
+		return err
+	}
+
+	remote := make(map[string]WorkspaceFile)
+	for _, f := range remoteFiles {
+		remote[f.Path] = f
+	}
+	local := make(map[string]WorkspaceFile)
+	for _, f := range localFiles {
+		if !ignoredSyncPath(f.Path) {
+			local[f.Path] = f
+		}
+	}
+
+	remoteRoot := sessionManager.WorkspaceDir(sessionID)
+
+	for path, lf := range local {
+		rf, ok := remote[path]
+		if ok && !lf.ModTime.After(rf.ModTime) {
+			continue
+		}
+		if ok && sameFileContent(filepath.Join(localDir, path), filepath.Join(remoteRoot, path)) {
+			continue
+		}
+		if err := copyWithModTime(filepath.Join(localDir, path), filepath.Join(remoteRoot, path), lf.ModTime); err != nil {
+			return fmt.Errorf("push %s: %w", path, err)
+		}
+		fmt.Printf("push  %s\n", path)
+		audit(context.Background(), "session.file_write", sessionID, map[string]interface{}{"path": path, "bytes": lf.Size, "via": "fs sync"})
+	}
+
+	for path, rf := range remote {
+		lf, ok := local[path]
+		if ok && !rf.ModTime.After(lf.ModTime) {
+			continue
+		}
+		if ok && sameFileContent(filepath.Join(localDir, path), filepath.Join(remoteRoot, path)) {
+			continue
+		}
+		if err := copyWithModTime(filepath.Join(remoteRoot, path), filepath.Join(localDir, path), rf.ModTime); err != nil {
+			return fmt.Errorf("pull %s: %w", path, err)
+		}
+		fmt.Printf("pull  %s\n", path)
+	}
+
+	return nil
+}
+
+// ignoredSyncPath skips VCS metadata
+func ignoredSyncPath(path string) bool {
+	return path == ".git" || strings.HasPrefix(path, ".git/")
+}
+
+// sameFileContent reports whether two files have identical contents
+func sameFileContent(a, b string) bool {
+	da, err := os.ReadFile(a)
+	if err != nil {
+		return false
+	}
+	db, err := os.ReadFile(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(da, db)
+}
+
+// copyWithModTime copies src to dst and preserves the source modification
+// time so the next pass sees both sides as in sync
+func copyWithModTime(src, dst string, modTime time.Time) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(dst, data, 0644); err != nil {
+		return err
+	}
+	return os.Chtimes(dst, modTime, modTime)
+}
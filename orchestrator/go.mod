@@ -1,10 +1,10 @@
 module github.com/justSteve/judge0-orchestrator
 
-go 1.21
-
-require github.com/spf13/cobra v1.8.0
+go 1.22
 
 require (
-	github.com/inconshreveable/mousetrap v1.1.0 // indirect
-	github.com/spf13/pflag v1.0.5 // indirect
+	github.com/spf13/cobra v1.8.0
+	github.com/spf13/pflag v1.0.5
 )
+
+require github.com/inconshreveable/mousetrap v1.1.0 // indirect
@@ -0,0 +1,1095 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// graphqlSchema documents the read-only GraphQL API served at /graphql
+const graphqlSchema = `type Query {
+  sessions(status: SessionStatus, language: String, owner: String, first: Int): [Session!]!
+  session(id: ID!): Session
+  execution(id: ID!): Execution
+}
+
+enum SessionStatus { ACTIVE PAUSED CLOSED }
+
+type Session {
+  id: ID!
+  name: String
+  language: String!
+  status: SessionStatus!
+  strict: Boolean!
+  stateful: Boolean!
+  owner: String
+  createdAt: String!
+  updatedAt: String!
+  lastActivity: String
+  env: [EnvVar!]!
+  metadata: [EnvVar!]!
+  executionCount: Int!
+  archived: Int!
+  executions(first: Int, last: Int): [Execution!]!
+}
+
+type EnvVar { name: String! value: String! }
+
+type Execution {
+  id: ID!
+  code: String!
+  stdout: String!
+  stderr: String!
+  exitCode: Int!
+  status: String
+  statusDescription: String
+  time: String!
+  durationMs: Float!
+  cpuTime: Float
+  memoryKb: Int
+  passed: Boolean
+  expectedExitCode: Int
+  replayOf: String
+  phase: String
+  compileOutput: String
+  formatted: Boolean!
+}
+`
+
+// GraphQLRequest is the body of POST /graphql
+type GraphQLRequest struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+	OperationName string                 `json:"operationName,omitempty"`
+}
+
+// GraphQLError is a query or field error; Path locates field errors
+type GraphQLError struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+}
+
+// GraphQLResponse follows the GraphQL response format
+type GraphQLResponse struct {
+	Data   interface{}    `json:"data,omitempty"`
+	Errors []GraphQLError `json:"errors,omitempty"`
+}
+
+// handleGraphQL serves GET /graphql?query=... and POST /graphql. Only
+// queries are supported; sessions are filtered to those the caller may see.
+func handleGraphQL(w http.ResponseWriter, r *http.Request) {
+	var req GraphQLRequest
+	if r.Method == http.MethodGet {
+		q := r.URL.Query()
+		req.Query, req.OperationName = q.Get("query"), q.Get("operationName")
+		if v := q.Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				writeGraphQL(w, http.StatusBadRequest, GraphQLResponse{Errors: []GraphQLError{{Message: "invalid variables: " + err.Error()}}})
+				return
+			}
+		}
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeGraphQL(w, http.StatusBadRequest, GraphQLResponse{Errors: []GraphQLError{{Message: "invalid request body: " + err.Error()}}})
+		return
+	}
+
+	if strings.TrimSpace(req.Query) == "" {
+		writeGraphQL(w, http.StatusBadRequest, GraphQLResponse{Errors: []GraphQLError{{Message: "query is required"}}})
+		return
+	}
+
+	resp, err := executeGraphQL(r.Context(), req)
+	if err != nil {
+		writeGraphQL(w, http.StatusBadRequest, GraphQLResponse{Errors: []GraphQLError{{Message: err.Error()}}})
+		return
+	}
+	writeGraphQL(w, http.StatusOK, resp)
+}
+
+func writeGraphQL(w http.ResponseWriter, status int, resp GraphQLResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// handleGraphQLSchema serves the schema in SDL for client tooling
+func handleGraphQLSchema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(graphqlSchema))
+}
+
+// executeGraphQL parses and runs a query. Parse and validation errors are
+// returned as err; field errors are reported in the response alongside
+// partial data.
+func executeGraphQL(ctx context.Context, req GraphQLRequest) (GraphQLResponse, error) {
+	doc, err := parseGraphQL(req.Query)
+	if err != nil {
+		return GraphQLResponse{}, err
+	}
+
+	op, err := doc.operation(req.OperationName)
+	if err != nil {
+		return GraphQLResponse{}, err
+	}
+	if op.kind != "query" {
+		return GraphQLResponse{}, fmt.Errorf("%s operations are not supported; /graphql is read-only", op.kind)
+	}
+
+	vars := map[string]interface{}{}
+	for name, def := range op.vars {
+		if v, ok := req.Variables[name]; ok {
+			vars[name] = v
+		} else if def != nil {
+			vars[name] = def
+		}
+	}
+
+	e := &gqlExecutor{ctx: ctx, doc: doc, vars: vars}
+	data := e.selectSet(gqlQuery{ctx: ctx}, op.selections, nil)
+	return GraphQLResponse{Data: data, Errors: e.errors}, nil
+}
+
+// gqlNode is an object type the executor can select fields from
+type gqlNode interface {
+	typeName() string
+	field(name string, args map[string]interface{}) (interface{}, error)
+}
+
+type gqlExecutor struct {
+	ctx    context.Context
+	doc    *gqlDocument
+	vars   map[string]interface{}
+	errors []GraphQLError
+	// spreads guards against fragments that include themselves
+	spreads map[string]bool
+}
+
+// gqlObject is a JSON object that keeps the order fields were selected in
+type gqlObject []gqlEntry
+
+type gqlEntry struct {
+	key   string
+	value interface{}
+}
+
+func (o gqlObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, e := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(e.key)
+		buf.Write(key)
+		buf.WriteByte(':')
+		value, err := json.Marshal(e.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func (e *gqlExecutor) fail(path []interface{}, format string, args ...interface{}) {
+	e.errors = append(e.errors, GraphQLError{Message: fmt.Sprintf(format, args...), Path: append([]interface{}(nil), path...)})
+}
+
+// collectFields flattens fragments into the fields that apply to node,
+// merging repeated response keys
+func (e *gqlExecutor) collectFields(node gqlNode, sels []gqlSelection, fields []*gqlField, path []interface{}) []*gqlField {
+	for _, sel := range sels {
+		switch {
+		case sel.field != nil:
+			merged := false
+			for i, f := range fields {
+				if f.responseKey() == sel.field.responseKey() {
+					copied := *f
+					copied.selections = append(append([]gqlSelection(nil), f.selections...), sel.field.selections...)
+					fields[i] = &copied
+					merged = true
+					break
+				}
+			}
+			if !merged {
+				fields = append(fields, sel.field)
+			}
+		case sel.spread != "":
+			frag, ok := e.doc.fragments[sel.spread]
+			if !ok {
+				e.fail(path, "unknown fragment %q", sel.spread)
+				continue
+			}
+			if e.spreads[sel.spread] {
+				e.fail(path, "fragment %q spreads itself", sel.spread)
+				continue
+			}
+			if frag.on != node.typeName() {
+				continue
+			}
+			if e.spreads == nil {
+				e.spreads = map[string]bool{}
+			}
+			e.spreads[sel.spread] = true
+			fields = e.collectFields(node, frag.selections, fields, path)
+			delete(e.spreads, sel.spread)
+		default:
+			if sel.on == "" || sel.on == node.typeName() {
+				fields = e.collectFields(node, sel.inline, fields, path)
+			}
+		}
+	}
+	return fields
+}
+
+// selectSet resolves the selected fields of node
+func (e *gqlExecutor) selectSet(node gqlNode, sels []gqlSelection, path []interface{}) gqlObject {
+	obj := gqlObject{}
+	for _, f := range e.collectFields(node, sels, nil, path) {
+		fieldPath := append(path, f.responseKey())
+		if f.name == "__typename" {
+			obj = append(obj, gqlEntry{f.responseKey(), node.typeName()})
+			continue
+		}
+
+		args := make(map[string]interface{}, len(f.args))
+		for name, v := range f.args {
+			args[name] = e.resolveValue(v)
+		}
+		value, err := node.field(f.name, args)
+		if err != nil {
+			e.fail(fieldPath, "%v", err)
+			obj = append(obj, gqlEntry{f.responseKey(), nil})
+			continue
+		}
+		obj = append(obj, gqlEntry{f.responseKey(), e.complete(value, f, node.typeName(), fieldPath)})
+	}
+	return obj
+}
+
+// complete applies sub-selections to object and list results
+func (e *gqlExecutor) complete(value interface{}, f *gqlField, parent string, path []interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	switch v := value.(type) {
+	case gqlNode:
+		if len(f.selections) == 0 {
+			e.fail(path, "field %q of type %q must have a selection of subfields", f.name, parent)
+			return nil
+		}
+		return e.selectSet(v, f.selections, path)
+	case []gqlNode:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = e.complete(item, f, parent, append(path, i))
+		}
+		return items
+	}
+	if len(f.selections) > 0 {
+		e.fail(path, "field %q on type %q has no subfields", f.name, parent)
+		return nil
+	}
+	return value
+}
+
+// resolveValue substitutes variables into a parsed argument value
+func (e *gqlExecutor) resolveValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case gqlVariable:
+		return e.vars[string(v)]
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = e.resolveValue(item)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			out[k] = e.resolveValue(item)
+		}
+		return out
+	}
+	return v
+}
+
+// Argument helpers; values come from query literals or JSON variables
+
+func gqlStringArg(args map[string]interface{}, name string) (string, error) {
+	switch v := args[name].(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case gqlEnum:
+		return string(v), nil
+	}
+	return "", fmt.Errorf("argument %q must be a string", name)
+}
+
+func gqlIntArg(args map[string]interface{}, name string) (int, bool, error) {
+	switch v := args[name].(type) {
+	case nil:
+		return 0, false, nil
+	case int:
+		return v, true, nil
+	case float64:
+		if v == float64(int(v)) {
+			return int(v), true, nil
+		}
+	}
+	return 0, false, fmt.Errorf("argument %q must be an integer", name)
+}
+
+func gqlCheckArgs(args map[string]interface{}, typeName, field string, allowed ...string) error {
+	for name := range args {
+		found := false
+		for _, a := range allowed {
+			found = found || a == name
+		}
+		if !found {
+			return fmt.Errorf("unknown argument %q on field %s.%s", name, typeName, field)
+		}
+	}
+	return nil
+}
+
+func gqlUnknownField(typeName, field string) error {
+	return fmt.Errorf("cannot query field %q on type %q", field, typeName)
+}
+
+// gqlTime formats timestamps as RFC 3339; zero times are null
+func gqlTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// gqlQuery is the root Query type
+type gqlQuery struct {
+	ctx context.Context
+}
+
+func (gqlQuery) typeName() string { return "Query" }
+
+func (q gqlQuery) field(name string, args map[string]interface{}) (interface{}, error) {
+	switch name {
+	case "sessions":
+		if err := gqlCheckArgs(args, "Query", name, "status", "language", "owner", "first"); err != nil {
+			return nil, err
+		}
+		status, err := gqlStringArg(args, "status")
+		if err != nil {
+			return nil, err
+		}
+		status = strings.ToLower(status)
+		if status != "" && status != "active" && status != "paused" && status != "closed" {
+			return nil, fmt.Errorf("invalid status %q (want ACTIVE, PAUSED or CLOSED)", args["status"])
+		}
+		language, err := gqlStringArg(args, "language")
+		if err != nil {
+			return nil, err
+		}
+		owner, err := gqlStringArg(args, "owner")
+		if err != nil {
+			return nil, err
+		}
+		first, limited, err := gqlIntArg(args, "first")
+		if err != nil {
+			return nil, err
+		}
+
+		sessions := visibleSessions(q.ctx, sessionsFor(q.ctx).ListSessions())
+		sort.Slice(sessions, func(i, j int) bool { return sessions[i].CreatedAt.Before(sessions[j].CreatedAt) })
+
+		nodes := []gqlNode{}
+		for _, s := range sessions {
+			if (status != "" && s.Status != status) || (language != "" && s.Language != language) || (owner != "" && s.Owner != owner) {
+				continue
+			}
+			if limited && len(nodes) >= first {
+				break
+			}
+			nodes = append(nodes, gqlSession{ctx: q.ctx, s: s})
+		}
+		return nodes, nil
+
+	case "session":
+		if err := gqlCheckArgs(args, "Query", name, "id"); err != nil {
+			return nil, err
+		}
+		id, err := gqlStringArg(args, "id")
+		if err != nil || id == "" {
+			return nil, fmt.Errorf("argument \"id\" is required")
+		}
+		if authorizeSession(q.ctx, id) != nil {
+			return nil, nil
+		}
+		s, err := sessionsFor(q.ctx).GetSession(id)
+		if err != nil {
+			return nil, nil
+		}
+		return gqlSession{ctx: q.ctx, s: s}, nil
+
+	case "execution":
+		if err := gqlCheckArgs(args, "Query", name, "id"); err != nil {
+			return nil, err
+		}
+		id, err := gqlStringArg(args, "id")
+		if err != nil || id == "" {
+			return nil, fmt.Errorf("argument \"id\" is required")
+		}
+		s, exec, err := sessionsFor(q.ctx).FindExecution(id)
+		if err != nil || !canAccessSession(q.ctx, s) {
+			return nil, nil
+		}
+		return gqlExecution{exec}, nil
+	}
+	return nil, gqlUnknownField("Query", name)
+}
+
+type gqlSession struct {
+	ctx context.Context
+	s   *Session
+}
+
+func (gqlSession) typeName() string { return "Session" }
+
+func (n gqlSession) field(name string, args map[string]interface{}) (interface{}, error) {
+	if name != "executions" && len(args) > 0 {
+		return nil, gqlCheckArgs(args, "Session", name)
+	}
+
+	s := n.s
+	switch name {
+	case "id":
+		return s.ID, nil
+	case "name":
+		if s.Name == "" {
+			return nil, nil
+		}
+		return s.Name, nil
+	case "language":
+		return s.Language, nil
+	case "status":
+		return strings.ToUpper(s.Status), nil
+	case "strict":
+		return s.Strict, nil
+	case "stateful":
+		return s.Stateful, nil
+	case "owner":
+		if s.Owner == "" {
+			return nil, nil
+		}
+		return s.Owner, nil
+	case "createdAt":
+		return gqlTime(s.CreatedAt), nil
+	case "updatedAt":
+		return gqlTime(s.UpdatedAt), nil
+	case "lastActivity":
+		return gqlTime(s.LastActivity), nil
+	case "env":
+		return gqlPairs(s.State.Env), nil
+	case "metadata":
+		return gqlPairs(s.Metadata), nil
+	case "executionCount":
+		return s.State.Archived + len(s.State.History), nil
+	case "archived":
+		return s.State.Archived, nil
+	case "executions":
+		if err := gqlCheckArgs(args, "Session", name, "first", "last"); err != nil {
+			return nil, err
+		}
+		first, hasFirst, err := gqlIntArg(args, "first")
+		if err != nil {
+			return nil, err
+		}
+		last, hasLast, err := gqlIntArg(args, "last")
+		if err != nil {
+			return nil, err
+		}
+		if first < 0 || last < 0 {
+			return nil, fmt.Errorf("first and last must not be negative")
+		}
+
+		history := s.State.History
+		if hasFirst && first < len(history) {
+			history = history[:first]
+		}
+		if hasLast && last < len(history) {
+			history = history[len(history)-last:]
+		}
+		nodes := make([]gqlNode, len(history))
+		for i := range history {
+			exec := history[i]
+			nodes[i] = gqlExecution{&exec}
+		}
+		return nodes, nil
+	}
+	return nil, gqlUnknownField("Session", name)
+}
+
+// gqlPairs lists a string map as EnvVar objects sorted by name
+func gqlPairs(m map[string]string) []gqlNode {
+	names := make([]string, 0, len(m))
+	for k := range m {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	nodes := make([]gqlNode, len(names))
+	for i, k := range names {
+		nodes[i] = gqlEnvVar{k, m[k]}
+	}
+	return nodes
+}
+
+type gqlEnvVar struct {
+	name, value string
+}
+
+func (gqlEnvVar) typeName() string { return "EnvVar" }
+
+func (n gqlEnvVar) field(name string, args map[string]interface{}) (interface{}, error) {
+	if len(args) > 0 {
+		return nil, gqlCheckArgs(args, "EnvVar", name)
+	}
+	switch name {
+	case "name":
+		return n.name, nil
+	case "value":
+		return n.value, nil
+	}
+	return nil, gqlUnknownField("EnvVar", name)
+}
+
+type gqlExecution struct {
+	e *Execution
+}
+
+func (gqlExecution) typeName() string { return "Execution" }
+
+func (n gqlExecution) field(name string, args map[string]interface{}) (interface{}, error) {
+	if len(args) > 0 {
+		return nil, gqlCheckArgs(args, "Execution", name)
+	}
+
+	e := n.e
+	optional := func(s string) interface{} {
+		if s == "" {
+			return nil
+		}
+		return s
+	}
+	switch name {
+	case "id":
+		return e.ID, nil
+	case "code":
+		return e.Code, nil
+	case "stdout":
+		return e.Output, nil
+	case "stderr":
+		return e.Stderr, nil
+	case "exitCode":
+		return e.ExitCode, nil
+	case "status":
+		return optional(e.Status), nil
+	case "statusDescription":
+		return optional(e.StatusDescription), nil
+	case "time":
+		return gqlTime(e.Time), nil
+	case "durationMs":
+		return e.Duration, nil
+	case "cpuTime":
+		return e.CPUTime, nil
+	case "memoryKb":
+		return e.Memory, nil
+	case "passed":
+		if e.Passed == nil {
+			return nil, nil
+		}
+		return *e.Passed, nil
+	case "expectedExitCode":
+		if e.ExpectedExitCode == nil {
+			return nil, nil
+		}
+		return *e.ExpectedExitCode, nil
+	case "replayOf":
+		return optional(e.ReplayOf), nil
+	case "phase":
+		return optional(e.Phase), nil
+	case "compileOutput":
+		return optional(e.CompileOutput), nil
+	case "formatted":
+		return e.Formatted, nil
+	}
+	return nil, gqlUnknownField("Execution", name)
+}
+
+// Query documents
+
+type gqlDocument struct {
+	operations []*gqlOperation
+	fragments  map[string]*gqlFragment
+}
+
+type gqlOperation struct {
+	kind, name string
+	// vars maps declared variables to their default values
+	vars       map[string]interface{}
+	selections []gqlSelection
+}
+
+type gqlFragment struct {
+	on         string
+	selections []gqlSelection
+}
+
+// gqlSelection is a field, a fragment spread or an inline fragment
+type gqlSelection struct {
+	field  *gqlField
+	spread string
+	on     string
+	inline []gqlSelection
+}
+
+type gqlField struct {
+	alias, name string
+	args        map[string]interface{}
+	selections  []gqlSelection
+}
+
+func (f *gqlField) responseKey() string {
+	if f.alias != "" {
+		return f.alias
+	}
+	return f.name
+}
+
+// Argument values that aren't plain JSON values
+type (
+	gqlVariable string
+	gqlEnum     string
+)
+
+// operation picks the operation to run
+func (d *gqlDocument) operation(name string) (*gqlOperation, error) {
+	if name == "" {
+		if len(d.operations) != 1 {
+			return nil, fmt.Errorf("operationName is required when the document has %d operations", len(d.operations))
+		}
+		return d.operations[0], nil
+	}
+	for _, op := range d.operations {
+		if op.name == name {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown operation %q", name)
+}
+
+type gqlToken struct {
+	kind string // "punct", "name", "int", "float", "string", "eof"
+	text string
+	pos  int
+}
+
+// lexGraphQL splits a document into tokens, dropping whitespace, commas
+// and comments
+func lexGraphQL(src string) ([]gqlToken, error) {
+	var tokens []gqlToken
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			i++
+		case c == '#':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(src[i:], "..."):
+			tokens = append(tokens, gqlToken{"punct", "...", i})
+			i += 3
+		case strings.IndexByte("!$():=@[]{}|&", c) >= 0:
+			tokens = append(tokens, gqlToken{"punct", string(c), i})
+			i++
+		case c == '_' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z':
+			start := i
+			for i < len(src) && (src[i] == '_' || src[i] >= 'A' && src[i] <= 'Z' || src[i] >= 'a' && src[i] <= 'z' || src[i] >= '0' && src[i] <= '9') {
+				i++
+			}
+			tokens = append(tokens, gqlToken{"name", src[start:i], start})
+		case c == '-' || c >= '0' && c <= '9':
+			start, kind := i, "int"
+			i++
+			for i < len(src) && strings.IndexByte("0123456789.eE+-", src[i]) >= 0 {
+				if strings.IndexByte(".eE", src[i]) >= 0 {
+					kind = "float"
+				}
+				i++
+			}
+			tokens = append(tokens, gqlToken{kind, src[start:i], start})
+		case c == '"':
+			if strings.HasPrefix(src[i:], `"""`) {
+				end := strings.Index(src[i+3:], `"""`)
+				if end < 0 {
+					return nil, fmt.Errorf("unterminated block string at offset %d", i)
+				}
+				tokens = append(tokens, gqlToken{"string", src[i+3 : i+3+end], i})
+				i += end + 6
+				continue
+			}
+			start := i
+			i++
+			for i < len(src) && src[i] != '"' && src[i] != '\n' {
+				if src[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			if i >= len(src) || src[i] != '"' {
+				return nil, fmt.Errorf("unterminated string at offset %d", start)
+			}
+			i++
+			s, err := strconv.Unquote(src[start:i])
+			if err != nil {
+				return nil, fmt.Errorf("invalid string at offset %d", start)
+			}
+			tokens = append(tokens, gqlToken{"string", s, start})
+		default:
+			r, _ := utf8.DecodeRuneInString(src[i:])
+			return nil, fmt.Errorf("unexpected character %q at offset %d", r, i)
+		}
+	}
+	return append(tokens, gqlToken{"eof", "", len(src)}), nil
+}
+
+type gqlParser struct {
+	tokens []gqlToken
+	pos    int
+}
+
+// parseGraphQL parses an executable document
+func parseGraphQL(src string) (*gqlDocument, error) {
+	tokens, err := lexGraphQL(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &gqlParser{tokens: tokens}
+	doc := &gqlDocument{fragments: map[string]*gqlFragment{}}
+
+	for p.peek().kind != "eof" {
+		t := p.peek()
+		switch {
+		case t.kind == "punct" && t.text == "{":
+			sels, err := p.selectionSet()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, &gqlOperation{kind: "query", selections: sels})
+		case t.kind == "name" && (t.text == "query" || t.text == "mutation" || t.text == "subscription"):
+			op, err := p.operation()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, op)
+		case t.kind == "name" && t.text == "fragment":
+			p.next()
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.keyword("on"); err != nil {
+				return nil, err
+			}
+			on, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			sels, err := p.selectionSet()
+			if err != nil {
+				return nil, err
+			}
+			doc.fragments[name] = &gqlFragment{on: on, selections: sels}
+		default:
+			return nil, p.unexpected()
+		}
+	}
+	if len(doc.operations) == 0 {
+		return nil, fmt.Errorf("document contains no operations")
+	}
+	return doc, nil
+}
+
+func (p *gqlParser) peek() gqlToken { return p.tokens[p.pos] }
+
+func (p *gqlParser) next() gqlToken {
+	t := p.tokens[p.pos]
+	if t.kind != "eof" {
+		p.pos++
+	}
+	return t
+}
+
+func (p *gqlParser) unexpected() error {
+	t := p.peek()
+	if t.kind == "eof" {
+		return fmt.Errorf("syntax error: unexpected end of query")
+	}
+	return fmt.Errorf("syntax error: unexpected %q at offset %d", t.text, t.pos)
+}
+
+// accept consumes the punctuator if it is next
+func (p *gqlParser) accept(punct string) bool {
+	if t := p.peek(); t.kind == "punct" && t.text == punct {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *gqlParser) expect(punct string) error {
+	if !p.accept(punct) {
+		return p.unexpected()
+	}
+	return nil
+}
+
+func (p *gqlParser) name() (string, error) {
+	if t := p.peek(); t.kind == "name" {
+		p.pos++
+		return t.text, nil
+	}
+	return "", p.unexpected()
+}
+
+func (p *gqlParser) keyword(word string) error {
+	if t := p.peek(); t.kind == "name" && t.text == word {
+		p.pos++
+		return nil
+	}
+	return p.unexpected()
+}
+
+func (p *gqlParser) operation() (*gqlOperation, error) {
+	op := &gqlOperation{kind: p.next().text, vars: map[string]interface{}{}}
+	if p.peek().kind == "name" {
+		op.name = p.next().text
+	}
+
+	if p.accept("(") {
+		for !p.accept(")") {
+			if err := p.expect("$"); err != nil {
+				return nil, err
+			}
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			if err := p.skipType(); err != nil {
+				return nil, err
+			}
+			var def interface{}
+			if p.accept("=") {
+				if def, err = p.value(true); err != nil {
+					return nil, err
+				}
+			}
+			op.vars[name] = def
+		}
+	}
+	if p.peek().text == "@" {
+		return nil, fmt.Errorf("directives are not supported")
+	}
+
+	sels, err := p.selectionSet()
+	if err != nil {
+		return nil, err
+	}
+	op.selections = sels
+	return op, nil
+}
+
+// skipType consumes a variable type such as [String!]!; arguments are
+// checked by the resolvers rather than against declared types
+func (p *gqlParser) skipType() error {
+	if p.accept("[") {
+		if err := p.skipType(); err != nil {
+			return err
+		}
+		if err := p.expect("]"); err != nil {
+			return err
+		}
+	} else if _, err := p.name(); err != nil {
+		return err
+	}
+	p.accept("!")
+	return nil
+}
+
+func (p *gqlParser) selectionSet() ([]gqlSelection, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var sels []gqlSelection
+	for !p.accept("}") {
+		if p.peek().kind == "eof" {
+			return nil, p.unexpected()
+		}
+
+		if p.accept("...") {
+			if t := p.peek(); t.kind == "name" && t.text != "on" {
+				sels = append(sels, gqlSelection{spread: p.next().text})
+				continue
+			}
+			var on string
+			if p.peek().text == "on" {
+				p.next()
+				var err error
+				if on, err = p.name(); err != nil {
+					return nil, err
+				}
+			}
+			inline, err := p.selectionSet()
+			if err != nil {
+				return nil, err
+			}
+			sels = append(sels, gqlSelection{on: on, inline: inline})
+			continue
+		}
+
+		field, err := p.field()
+		if err != nil {
+			return nil, err
+		}
+		sels = append(sels, gqlSelection{field: field})
+	}
+	if len(sels) == 0 {
+		return nil, fmt.Errorf("syntax error: empty selection set")
+	}
+	return sels, nil
+}
+
+func (p *gqlParser) field() (*gqlField, error) {
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	f := &gqlField{name: name}
+	if p.accept(":") {
+		f.alias = name
+		if f.name, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.accept("(") {
+		f.args = map[string]interface{}{}
+		for !p.accept(")") {
+			arg, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			if f.args[arg], err = p.value(false); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if p.peek().text == "@" {
+		return nil, fmt.Errorf("directives are not supported")
+	}
+
+	if t := p.peek(); t.kind == "punct" && t.text == "{" {
+		if f.selections, err = p.selectionSet(); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// value parses an argument or default value; constant values may not
+// reference variables
+func (p *gqlParser) value(constant bool) (interface{}, error) {
+	start := p.pos
+	t := p.next()
+	switch t.kind {
+	case "int":
+		n, err := strconv.Atoi(t.text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer %q", t.text)
+		}
+		return n, nil
+	case "float":
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", t.text)
+		}
+		return f, nil
+	case "string":
+		return t.text, nil
+	case "name":
+		switch t.text {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		return gqlEnum(t.text), nil
+	case "punct":
+		switch t.text {
+		case "$":
+			if constant {
+				return nil, fmt.Errorf("variables are not allowed in default values")
+			}
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			return gqlVariable(name), nil
+		case "[":
+			list := []interface{}{}
+			for !p.accept("]") {
+				v, err := p.value(constant)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, v)
+			}
+			return list, nil
+		case "{":
+			obj := map[string]interface{}{}
+			for !p.accept("}") {
+				key, err := p.name()
+				if err != nil {
+					return nil, err
+				}
+				if err := p.expect(":"); err != nil {
+					return nil, err
+				}
+				if obj[key], err = p.value(constant); err != nil {
+					return nil, err
+				}
+			}
+			return obj, nil
+		}
+	}
+	p.pos = start
+	return nil, p.unexpected()
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// HealthCheck is the result of probing one dependency
+type HealthCheck struct {
+	Status    string `json:"status"` // "ok" or "fail"
+	LatencyMs int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+// runHealthCheck times a probe and converts its error into a HealthCheck
+func runHealthCheck(probe func() error) HealthCheck {
+	start := time.Now()
+	err := probe()
+	check := HealthCheck{Status: "ok", LatencyMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		check.Status = "fail"
+		check.Error = err.Error()
+	}
+	return check
+}
+
+// checkJudge0Workers fails when Judge0 reports no worker able to take work
+func checkJudge0Workers(ctx context.Context) error {
+	queues, err := judge0Client.Workers(ctx)
+	if err != nil {
+		return err
+	}
+
+	available := 0.0
+	for _, q := range queues {
+		if n, ok := q["available"].(float64); ok {
+			available += n
+		}
+	}
+	if available == 0 {
+		return fmt.Errorf("no judge0 workers available")
+	}
+	return nil
+}
+
+// checkJudge0Backends fails when every pooled backend's circuit is open
+func checkJudge0Backends() error {
+	for _, b := range judge0Client.pool.Status() {
+		if b.Circuit != CircuitOpen {
+			return nil
+		}
+	}
+	return judge0Client.pool.unavailable()
+}
+
+// checkDataDir verifies the data directory accepts writes
+func checkDataDir() error {
+	f, err := os.CreateTemp(dataDir, ".health-*")
+	if err != nil {
+		return err
+	}
+	name := f.Name()
+	_, err = f.WriteString("ok")
+	f.Close()
+	os.Remove(name)
+	return err
+}
+
+// handleHealthReady serves GET /health/ready, returning 503 if any
+// dependency check fails so load balancers stop routing here
+func handleHealthReady(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]HealthCheck{
+		"judge0":   runHealthCheck(func() error { _, err := judge0Client.About(r.Context()); return err }),
+		"workers":  runHealthCheck(func() error { return checkJudge0Workers(r.Context()) }),
+		"data_dir": runHealthCheck(checkDataDir),
+	}
+	if judge0Client.pool != nil {
+		checks["backends"] = runHealthCheck(checkJudge0Backends)
+	}
+
+	status, code := "ok", http.StatusOK
+	for _, c := range checks {
+		if c.Status != "ok" {
+			status, code = "unavailable", http.StatusServiceUnavailable
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	resp := map[string]interface{}{
+		"status": status,
+		"checks": checks,
+	}
+	if judge0Client.pool != nil {
+		resp["backends"] = judge0Client.pool.Status()
+	}
+	json.NewEncoder(w).Encode(resp)
+}
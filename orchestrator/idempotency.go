@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// idempotencyKeyHeader lets clients retry POST /sessions/{id}/execute
+// after a dropped connection without running the code twice
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// idempotencyRetention is how long a keyed execution's outcome is kept
+// after it finishes
+const idempotencyRetention = 24 * time.Hour
+
+// sseEvent is a recorded stream event. Data is localized when sent:
+// Status and *Execution values are rendered for the receiving client.
+type sseEvent struct {
+	name string
+	data interface{}
+}
+
+// idempotentExecution is an execution started with an Idempotency-Key.
+// It runs detached from the request, and its stream events are recorded
+// so a retry can replay them.
+type idempotentExecution struct {
+	*pendingExecution
+	requestHash string
+
+	mu      sync.Mutex
+	execID  string
+	events  []sseEvent
+	changed chan struct{} // closed when an event is recorded
+}
+
+var idempotentExecutions = struct {
+	sync.Mutex
+	m map[string]*idempotentExecution
+}{m: make(map[string]*idempotentExecution)}
+
+func (e *idempotentExecution) record(name string, data interface{}) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.events = append(e.events, sseEvent{name, data})
+	close(e.changed)
+	e.changed = make(chan struct{})
+}
+
+// eventsFrom returns the events recorded after the first n and a channel
+// closed when more arrive
+func (e *idempotentExecution) eventsFrom(n int) ([]sseEvent, <-chan struct{}) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if n > len(e.events) {
+		n = len(e.events)
+	}
+	return e.events[n:], e.changed
+}
+
+// startIdempotentExecution runs req detached from the request and records
+// its progress, registering it for long-poll resumes once it has an ID
+func startIdempotentExecution(ctx context.Context, mapKey, sessionID, requestHash string, req ExecRequest) *idempotentExecution {
+	e := &idempotentExecution{
+		pendingExecution: &pendingExecution{done: make(chan struct{})},
+		requestHash:      requestHash,
+		changed:          make(chan struct{}),
+	}
+	req.OnStart = func(execID string) {
+		e.mu.Lock()
+		e.execID = execID
+		e.mu.Unlock()
+		pendingExecutions.Lock()
+		pendingExecutions.m[pendingKey(sessionID, execID)] = e.pendingExecution
+		pendingExecutions.Unlock()
+		e.record("queued", map[string]string{"session_id": sessionID, "execution_id": execID})
+	}
+	req.OnStatus = func(status Status) {
+		e.record("status", status)
+	}
+	if req.Timeout == 0 {
+		req.Timeout = longPollExecTimeout
+	}
+
+	go func() {
+		e.exec, e.err = runExecution(context.WithoutCancel(ctx), sessionID, req)
+		if e.err != nil {
+			e.record("error", map[string]string{"error": e.err.Error(), "code": errorCode(e.err)})
+		} else {
+			if e.exec.Output != "" {
+				e.record("stdout", map[string]string{"data": e.exec.Output})
+			}
+			if e.exec.Stderr != "" {
+				e.record("stderr", map[string]string{"data": e.exec.Stderr})
+			}
+			e.record("done", e.exec)
+		}
+		close(e.done)
+
+		forget := func() {
+			idempotentExecutions.Lock()
+			delete(idempotentExecutions.m, mapKey)
+			idempotentExecutions.Unlock()
+			if e.execID != "" {
+				pendingExecutions.Lock()
+				delete(pendingExecutions.m, pendingKey(sessionID, e.execID))
+				pendingExecutions.Unlock()
+			}
+		}
+		if e.execID == "" {
+			// Rejected before it started (maintenance, a busy strict
+			// session): nothing ran, so a retry may try again
+			forget()
+			return
+		}
+		time.AfterFunc(idempotencyRetention, forget)
+	}()
+	return e
+}
+
+// handleIdempotentExecute serves an execute carrying an Idempotency-Key.
+// The first request with a key starts the execution; retries with the
+// same key and body attach to it instead of running the code again: they
+// get the finished result, wait for a running one, or, when streaming,
+// replay the events recorded so far (after Last-Event-ID, if sent) and
+// follow the rest.
+func handleIdempotentExecute(w http.ResponseWriter, r *http.Request, sessionID, key string, body interface{}, req ExecRequest) {
+	data, _ := json.Marshal(body)
+	requestHash := sha256Hex(data)
+	// Keys are scoped to the caller and session so clients can't collide
+	mapKey := principalFromContext(r.Context()) + "\x00" + sessionID + "\x00" + key
+
+	idempotentExecutions.Lock()
+	e, replayed := idempotentExecutions.m[mapKey]
+	if !replayed {
+		e = startIdempotentExecution(r.Context(), mapKey, sessionID, requestHash, req)
+		idempotentExecutions.m[mapKey] = e
+	}
+	idempotentExecutions.Unlock()
+
+	if e.requestHash != requestHash {
+		http.Error(w, idempotencyKeyHeader+" "+key+" was already used with a different request", http.StatusUnprocessableEntity)
+		return
+	}
+	if replayed {
+		w.Header().Set("Idempotent-Replayed", "true")
+	}
+
+	if r.URL.Query().Get("stream") == "true" {
+		streamIdempotent(w, r, e)
+		return
+	}
+
+	var timeout <-chan time.Time
+	wait := time.Duration(0)
+	if v := r.URL.Query().Get("wait"); v != "" {
+		var err error
+		if wait, err = parseWait(v); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		setLongPollHeaders(w, wait)
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case <-e.done:
+		writeLongPollResult(w, r, e.pendingExecution)
+	case <-timeout:
+		e.mu.Lock()
+		execID := e.execID
+		e.mu.Unlock()
+		writeStillRunning(w, sessionID, execID, wait)
+	case <-r.Context().Done():
+		// The client went away; the execution keeps running for its retry
+	}
+}
+
+// streamIdempotent sends a keyed execution's events as SSE, numbered so a
+// reconnecting client can skip those it has seen
+func streamIdempotent(w http.ResponseWriter, r *http.Request, e *idempotentExecution) {
+	sse, err := newSSEWriter(w)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	next := 0
+	if last := r.Header.Get("Last-Event-ID"); last != "" {
+		n, err := strconv.Atoi(last)
+		if err != nil || n < 0 {
+			http.Error(w, fmt.Sprintf("invalid Last-Event-ID %q", last), http.StatusBadRequest)
+			return
+		}
+		next = n + 1
+	}
+
+	// A keyed execution rejected before it started fails like an
+	// unkeyed one, with a plain error response
+	select {
+	case <-e.done:
+		if e.execID == "" && e.err != nil {
+			writeExecuteError(w, e.err)
+			return
+		}
+	default:
+	}
+
+	locale := requestLocale(r)
+	for {
+		events, changed := e.eventsFrom(next)
+		for _, ev := range events {
+			data := ev.data
+			switch v := data.(type) {
+			case Status:
+				data = v.localized(locale)
+			case *Execution:
+				data = executionResponse(v, locale)
+			}
+			if err := sse.EventWithID(next, ev.name, data); err != nil {
+				return
+			}
+			if ev.name == "done" || ev.name == "error" {
+				return
+			}
+			next++
+		}
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
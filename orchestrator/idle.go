@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Heartbeat marks a session as in use without executing anything, so a
+// wrapper holding it for a human keeps it from being reaped as idle
+func (sm *SessionManager) Heartbeat(sessionID string) (*Session, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	session, ok := sm.sessions[sessionID]
+	if !ok {
+		return nil, fmt.Errorf("session not found: %s", sessionID)
+	}
+	if session.Status != "active" {
+		return nil, fmt.Errorf("session is not active: %s", session.Status)
+	}
+
+	session.LastActivity = time.Now()
+	return session, sm.saveSession(session)
+}
+
+// lastActivity falls back to UpdatedAt for sessions saved before heartbeats existed
+func (s *Session) lastActivity() time.Time {
+	if s.LastActivity.IsZero() {
+		return s.UpdatedAt
+	}
+	return s.LastActivity
+}
+
+// CloseIdleSessions closes active sessions with no activity within timeout
+func (sm *SessionManager) CloseIdleSessions(timeout time.Duration) {
+	now := time.Now()
+	var idle []string
+
+	sm.mu.RLock()
+	for id, s := range sm.sessions {
+		if s.Status == "active" && s.Exam == nil && now.Sub(s.lastActivity()) > timeout {
+			idle = append(idle, id)
+		}
+	}
+	sm.mu.RUnlock()
+
+	for _, id := range idle {
+		log.Printf("Closing idle session %s", id)
+		if err := sm.CloseSession(id); err != nil {
+			log.Printf("Warning: failed to close idle session %s: %v", id, err)
+			continue
+		}
+		audit(systemContext, "session.close", id, map[string]interface{}{"reason": "idle"})
+	}
+}
+
+// RunIdleReaper closes idle sessions periodically until ctx is cancelled
+func (sm *SessionManager) RunIdleReaper(ctx context.Context, timeout, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		sm.CloseIdleSessions(timeout)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// handleHeartbeat serves POST /sessions/{id}/heartbeat
+func handleHeartbeat(w http.ResponseWriter, r *http.Request) {
+	session, err := sessionsFor(r.Context()).Heartbeat(r.PathValue("id"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if strings.Contains(err.Error(), "not found") {
+			status = http.StatusNotFound
+		} else if strings.Contains(err.Error(), "not active") {
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	resp := map[string]interface{}{
+		"id":            session.ID,
+		"last_activity": session.LastActivity,
+	}
+	if idleTimeout > 0 {
+		resp["idle_expires_at"] = session.LastActivity.Add(idleTimeout)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
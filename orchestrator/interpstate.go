@@ -0,0 +1,279 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// stateSentinel marks the interpreter state a stateful execution prints at
+// the end of stdout
+const stateSentinel = "__J0_INTERPRETER_STATE_4be1d2__"
+
+// maxInterpreterState caps the encoded state kept for a session; larger
+// state is dropped rather than re-submitted with every execution
+const maxInterpreterState = 1 << 20
+
+// statefulHarnesses wrap code so it starts from a session's saved
+// interpreter state and prints the state it ends with, keyed by language ID
+var statefulHarnesses = map[int]func(code, state string) string{
+	LanguagePython3:    wrapPythonState,
+	LanguageJavaScript: wrapJavaScriptState,
+}
+
+// checkStateful rejects stateful sessions in languages without a harness
+func checkStateful(language string, stateful bool) error {
+	if stateful && !supportsStateful(language) {
+		return fmt.Errorf("stateful sessions are not supported for %s", language)
+	}
+	return nil
+}
+
+// supportsStateful reports whether sessions in language can keep
+// interpreter state between executions
+func supportsStateful(language string) bool {
+	id, ok := LanguageMap[language]
+	if !ok {
+		return false
+	}
+	_, ok = statefulHarnesses[id]
+	return ok
+}
+
+// wrapPythonState runs the user code in a namespace restored from state,
+// then pickles the namespace's globals after a sentinel on stdout. Modules
+// are re-imported by name; values that can't be pickled, such as functions
+// and classes defined in the code, are listed so the caller can say so.
+// The user code is compiled separately so tracebacks match its lines.
+func wrapPythonState(code, state string) string {
+	return fmt.Sprintf(`import sys as _j0_sys, pickle as _j0_pickle, base64 as _j0_b64
+import importlib as _j0_importlib, types as _j0_types, traceback as _j0_traceback
+_j0_ns = {"__name__": "__main__", "__builtins__": __builtins__}
+def _j0_restore(blob):
+    if not blob:
+        return
+    try:
+        saved = _j0_pickle.loads(_j0_b64.b64decode(blob))
+    except Exception:
+        return
+    for name, (kind, data) in saved.items():
+        try:
+            if kind == "module":
+                _j0_ns[name] = _j0_importlib.import_module(data)
+            else:
+                _j0_ns[name] = _j0_pickle.loads(data)
+        except Exception:
+            pass
+def _j0_save():
+    saved, skipped = {}, []
+    for name, value in list(_j0_ns.items()):
+        if name.startswith("__"):
+            continue
+        if isinstance(value, _j0_types.ModuleType):
+            saved[name] = ("module", value.__name__)
+            continue
+        try:
+            saved[name] = ("pickle", _j0_pickle.dumps(value))
+        except Exception:
+            skipped.append(name)
+    blob = _j0_b64.b64encode(_j0_pickle.dumps(saved)).decode()
+    _j0_sys.stdout.flush()
+    _j0_sys.stdout.write("\n%s\n" + blob + "\n" + ",".join(sorted(skipped)) + "\n")
+_j0_restore(%s)
+try:
+    exec(compile(%s, "main.py", "exec"), _j0_ns)
+except SystemExit:
+    raise
+except BaseException as e:
+    _j0_traceback.print_exception(type(e), e, e.__traceback__.tb_next)
+    _j0_sys.exit(1)
+finally:
+    _j0_save()
+`, stateSentinel, strconv.Quote(state), strconv.Quote(code))
+}
+
+// jsDeclaration matches the first name a declaration introduces; later
+// names in a list and destructuring patterns aren't parsed
+var jsDeclaration = regexp.MustCompile(`\b(?:const|let|var|class|function\*?)\s+([A-Za-z_$][\w$]*)`)
+
+// jsDeclaredNames lists the names code may declare at top level. Names
+// declared inside functions match too; the harness skips any it can't read.
+func jsDeclaredNames(code string) []string {
+	seen := map[string]bool{}
+	names := []string{}
+	for _, m := range jsDeclaration.FindAllStringSubmatch(code, -1) {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			names = append(names, m[1])
+		}
+	}
+	return names
+}
+
+// jsRequire matches a name bound to a required module
+var jsRequire = regexp.MustCompile(`\b(?:const|let|var)\s+([A-Za-z_$][\w$]*)\s*=\s*require\(\s*["']([^"']+)["']\s*\)`)
+
+// jsRequiredModules maps names the code binds with require to the module
+// specifier, so the harness can require them again instead of serializing
+func jsRequiredModules(code string) map[string]string {
+	modules := map[string]string{}
+	for _, m := range jsRequire.FindAllStringSubmatch(code, -1) {
+		modules[m[1]] = m[2]
+	}
+	return modules
+}
+
+// wrapJavaScriptState runs the user code as a script in the global scope,
+// whose top-level let and const bindings later scripts can read, and on
+// exit prints each declared name and new global v8-serialized after a
+// sentinel on stdout. Required modules are required again by specifier.
+// Restored values become configurable globals, so the code may declare
+// them again. Values v8 can't serialize, such as functions, are listed so
+// the caller can say so.
+func wrapJavaScriptState(code, state string) string {
+	names, _ := json.Marshal(jsDeclaredNames(code))
+	modules, _ := json.Marshal(jsRequiredModules(code))
+	return fmt.Sprintf(`const _j0_vm = require("vm"), _j0_v8 = require("v8"), _j0_fs = require("fs");
+const _j0_builtins = new Set(Object.getOwnPropertyNames(globalThis));
+const _j0_restored = [], _j0_modules = {};
+(function (blob) {
+  if (!blob) return;
+  let saved;
+  try {
+    saved = JSON.parse(Buffer.from(blob, "base64").toString());
+  } catch (e) {
+    return;
+  }
+  for (const [name, [kind, data]] of Object.entries(saved)) {
+    try {
+      let value;
+      if (kind === "module") {
+        value = require(data);
+        _j0_modules[name] = data;
+      } else {
+        value = _j0_v8.deserialize(Buffer.from(data, "base64"));
+      }
+      Object.defineProperty(globalThis, name, {
+        value, writable: true, enumerable: true, configurable: true,
+      });
+      _j0_restored.push(name);
+    } catch (e) {}
+  }
+})(%s);
+Object.assign(_j0_modules, %s);
+Object.assign(globalThis, { require, module, exports, __filename, __dirname });
+process.on("exit", () => {
+  const names = new Set([..._j0_restored, ...%s]);
+  for (const name of Object.getOwnPropertyNames(globalThis)) {
+    if (!_j0_builtins.has(name)) names.add(name);
+  }
+  for (const name of ["require", "module", "exports", "__filename", "__dirname"]) names.delete(name);
+  const saved = {}, skipped = [];
+  for (const name of names) {
+    if (_j0_builtins.has(name)) continue;
+    let value;
+    try {
+      value = _j0_vm.runInThisContext(name);
+    } catch (e) {
+      continue;
+    }
+    if (value === undefined && !Object.prototype.hasOwnProperty.call(globalThis, name)) continue;
+    try {
+      if (name in _j0_modules && value === require(_j0_modules[name])) {
+        saved[name] = ["module", _j0_modules[name]];
+        continue;
+      }
+    } catch (e) {}
+    try {
+      saved[name] = ["v8", _j0_v8.serialize(value).toString("base64")];
+    } catch (e) {
+      skipped.push(name);
+    }
+  }
+  const blob = Buffer.from(JSON.stringify(saved)).toString("base64");
+  _j0_fs.writeSync(1, "\n%s\n" + blob + "\n" + skipped.sort().join(",") + "\n");
+});
+try {
+  _j0_vm.runInThisContext(%s, { filename: "main.js" });
+} catch (e) {
+  console.error(e && e.stack ? e.stack : e);
+  process.exitCode = 1;
+}
+`, jsString(state), modules, names, stateSentinel, jsString(code))
+}
+
+// extractInterpreterState splits the state dump off stdout, returning the
+// user's output, the encoded state and the names it couldn't keep. ok is
+// false when the program didn't get as far as printing its state.
+func extractInterpreterState(stdout string) (out, state string, skipped []string, ok bool) {
+	marker := "\n" + stateSentinel + "\n"
+	i := strings.LastIndex(stdout, marker)
+	if i < 0 {
+		return stdout, "", nil, false
+	}
+
+	lines := strings.SplitN(stdout[i+len(marker):], "\n", 3)
+	if len(lines) < 2 {
+		return stdout, "", nil, false
+	}
+	if lines[1] != "" {
+		skipped = strings.Split(lines[1], ",")
+	}
+	return stdout[:i], lines[0], skipped, true
+}
+
+// stateErrors explains what a stateful execution didn't carry over
+func stateErrors(state string, skipped []string) []string {
+	var errs []string
+	if len(state) > maxInterpreterState {
+		errs = append(errs, fmt.Sprintf("interpreter state not kept: %d bytes exceeds the %d byte limit", len(state), maxInterpreterState))
+	}
+	if len(skipped) > 0 {
+		errs = append(errs, fmt.Sprintf("not kept between executions: %s", strings.Join(skipped, ", ")))
+	}
+	return errs
+}
+
+// interpreterStateName seals interpreter state like a secret env var; it
+// isn't a valid variable name, so no secret shares its AAD
+const interpreterStateName = "#interpreter"
+
+// SetInterpreterState stores the state the next execution starts from.
+// The state holds the program's variables, often read from secret env
+// vars, so it is encrypted whenever a secret key is configured.
+func (sm *SessionManager) SetInterpreterState(sessionID, state string) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	session, ok := sm.sessions[sessionID]
+	if !ok {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+	if key := sm.sealingKey(); key != nil && state != "" {
+		sealed, err := encryptSecret(key, sessionID, interpreterStateName, state)
+		if err != nil {
+			return err
+		}
+		state = sealed
+	}
+	session.State.Interpreter = state
+	session.UpdatedAt = time.Now()
+	return sm.saveSession(session)
+}
+
+// interpreterState returns the state a stateful execution starts from,
+// decrypting it when it was sealed
+func (sm *SessionManager) interpreterState(s *Session) (string, error) {
+	state := s.State.Interpreter
+	if !strings.HasPrefix(state, secretPrefix) {
+		return state, nil
+	}
+	state, err := decryptSecret(sm.sealingKey(), s.ID, interpreterStateName, state)
+	if err != nil {
+		return "", fmt.Errorf("interpreter state: %w", err)
+	}
+	return state, nil
+}
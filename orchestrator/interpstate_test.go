@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestSession creates a session in a fresh data directory
+func newTestSession(t *testing.T, language string, opts SessionOptions) (*SessionManager, *Session) {
+	t.Helper()
+	sm, err := NewSessionManager(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err := sm.CreateSession(language, "", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sm, session
+}
+
+func TestInterpreterStateSealing(t *testing.T) {
+	// base64 of a pickled namespace holding a value read from a secret
+	const state = "gASVHAAAAAAAAAB9lIwFdG9rZW6UjAdodW50ZXIylHMu"
+
+	tests := []struct {
+		name   string
+		key    []byte
+		sealed bool
+	}{
+		{name: "no secret key", key: nil, sealed: false},
+		{name: "secret key", key: bytes.Repeat([]byte{7}, 32), sealed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sm, session := newTestSession(t, "python", SessionOptions{Stateful: true})
+			sm.secretKey = tt.key
+
+			if err := sm.SetInterpreterState(session.ID, state); err != nil {
+				t.Fatal(err)
+			}
+			record, err := os.ReadFile(filepath.Join(sm.dataDir, session.ID+".json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Contains(string(record), state); got == tt.sealed {
+				t.Fatalf("state in plaintext on disk = %v, want %v", got, !tt.sealed)
+			}
+
+			saved, err := sm.GetSession(session.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := sm.interpreterState(saved)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != state {
+				t.Fatalf("interpreter state = %q, want %q", got, state)
+			}
+		})
+	}
+}
+
+func TestInterpreterStateBoundToSession(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	sm, session := newTestSession(t, "python", SessionOptions{Stateful: true})
+	sm.secretKey = key
+	if err := sm.SetInterpreterState(session.ID, "c3RhdGU="); err != nil {
+		t.Fatal(err)
+	}
+	saved, _ := sm.GetSession(session.ID)
+
+	// Copied into another session's record
+	other := *saved
+	other.ID = "another-session"
+	if _, err := sm.interpreterState(&other); err == nil {
+		t.Fatal("sealed state opened under another session")
+	}
+
+	// Read with another key
+	sm.secretKey = bytes.Repeat([]byte{8}, 32)
+	if _, err := sm.interpreterState(saved); err == nil {
+		t.Fatal("sealed state opened with another key")
+	}
+}
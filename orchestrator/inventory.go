@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// EnvironmentInventory describes what a session's runtime has installed.
+// It is collected by running an introspection snippet in the session and
+// cached on it until refreshed.
+type EnvironmentInventory struct {
+	Runtime     string             `json:"runtime"`
+	Version     string             `json:"version"`
+	LanguageID  int                `json:"language_id"`
+	Packages    []InstalledPackage `json:"packages"`
+	CollectedAt time.Time          `json:"collected_at"`
+}
+
+// InstalledPackage is one package visible to a session's programs
+type InstalledPackage struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// introspectionSnippets print the runtime and its version on the first
+// line, then one package per line, each as "name<TAB>version"
+var introspectionSnippets = map[int]string{
+	LanguagePython3: `import platform
+try:
+    from importlib import metadata
+    packages = [(d.metadata["Name"], d.version) for d in metadata.distributions()]
+except ImportError:
+    import pkg_resources
+    packages = [(d.project_name, d.version) for d in pkg_resources.working_set]
+print("python\t" + platform.python_version())
+for name, version in packages:
+    print(name + "\t" + version)
+`,
+	LanguageJavaScript: `const path = require("path");
+const { execFileSync } = require("child_process");
+console.log("node\t" + process.version.replace(/^v/, ""));
+let out = "";
+try {
+  out = execFileSync(path.join(path.dirname(process.execPath), "npm"), ["ls", "--json", "--depth=0"], { stdio: ["ignore", "pipe", "ignore"] }).toString();
+} catch (e) {
+  out = e.stdout ? e.stdout.toString() : "";
+}
+try {
+  const deps = JSON.parse(out).dependencies || {};
+  for (const name of Object.keys(deps)) {
+    if (deps[name].version) console.log(name + "\t" + deps[name].version);
+  }
+} catch (e) {}
+`,
+	LanguageRuby: `puts "ruby\t#{RUBY_VERSION}"
+Gem::Specification.each { |spec| puts "#{spec.name}\t#{spec.version}" }
+`,
+	LanguageGo: `package main
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+func main() {
+	fmt.Printf("go\t%s\n", strings.TrimPrefix(runtime.Version(), "go"))
+}
+`,
+	LanguageBash: `echo -e "bash\t${BASH_VERSION}"
+dpkg-query -W -f='${Package}\t${Version}\n' 2>/dev/null
+`,
+	LanguageC: `#include <stdio.h>
+int main(void) { printf("gcc\t%s\n", __VERSION__); return 0; }
+`,
+	LanguageCPP: `#include <cstdio>
+int main() { std::printf("g++\t%s\n", __VERSION__); return 0; }
+`,
+}
+
+// parseInventory reads the output of an introspection snippet
+func parseInventory(output string) (*EnvironmentInventory, error) {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	runtime, version, ok := strings.Cut(lines[0], "\t")
+	if !ok {
+		return nil, fmt.Errorf("unexpected introspection output: %q", lines[0])
+	}
+
+	inv := &EnvironmentInventory{Runtime: runtime, Version: strings.TrimSpace(version), Packages: []InstalledPackage{}}
+	seen := make(map[string]bool)
+	for _, line := range lines[1:] {
+		name, version, ok := strings.Cut(strings.TrimSpace(line), "\t")
+		if !ok || name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		inv.Packages = append(inv.Packages, InstalledPackage{Name: name, Version: version})
+	}
+	sort.Slice(inv.Packages, func(i, j int) bool {
+		return strings.ToLower(inv.Packages[i].Name) < strings.ToLower(inv.Packages[j].Name)
+	})
+	return inv, nil
+}
+
+// collectInventory runs the session language's introspection snippet with
+// the session's workspace, so vendored dependencies are included. It is
+// not recorded as an execution.
+func collectInventory(ctx context.Context, session *Session) (*EnvironmentInventory, error) {
+	ctx, span := startSpan(ctx, "session.inventory", spanKindInternal)
+	defer span.Finish()
+
+	if err := checkJudge0Credentials(ctx); err != nil {
+		return nil, err
+	}
+	if err := checkExecutionsOpen(time.Now()); err != nil {
+		return nil, err
+	}
+
+	langID, err := GetLanguageID(session.Language)
+	if err != nil {
+		return nil, err
+	}
+	snippet, ok := introspectionSnippets[langID]
+	if !ok {
+		return nil, fmt.Errorf("environment introspection is not supported for %s", session.Language)
+	}
+
+	workspace := sessionsFor(ctx).WorkspaceDir(session.ID)
+	sub := NewSubmission(dependencyPrelude(langID, workspace)+snippet, langID, "")
+	if session.LanguageID != 0 {
+		sub.LanguageID = session.LanguageID
+	}
+	if sub.AdditionalFiles, err = sessionsFor(ctx).WorkspaceArchive(session.ID); err != nil {
+		return nil, fmt.Errorf("failed to package workspace: %w", err)
+	}
+	sub.LanguageID, _ = resolveLanguageFallback(ctx, sub.LanguageID)
+
+	release, _, err := submissionLimiter.acquire(ctx, langID, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	result, err := judge0Client.Submit(ctx, sub, WaitOptions{})
+	if err == nil && result.ExitCode != 0 {
+		err = fmt.Errorf("introspection failed (exit %d): %s", result.ExitCode, strings.TrimSpace(result.Stderr+result.CompileOutput+result.Message))
+	}
+	if err != nil {
+		span.SetError(err)
+		return nil, err
+	}
+
+	inv, err := parseInventory(result.Stdout)
+	if err != nil {
+		span.SetError(err)
+		return nil, err
+	}
+	inv.LanguageID = sub.LanguageID
+	inv.CollectedAt = time.Now()
+	span.SetAttr("inventory.packages", len(inv.Packages))
+	return inv, nil
+}
+
+// Inventory returns the session's cached environment inventory, collecting
+// it first when there is none or refresh is set
+func (sm *SessionManager) Inventory(ctx context.Context, sessionID string, refresh bool) (*EnvironmentInventory, error) {
+	session, err := sm.GetSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	sm.mu.RLock()
+	cached := session.Inventory
+	sm.mu.RUnlock()
+	if cached != nil && !refresh {
+		return cached, nil
+	}
+
+	inv, err := collectInventory(ctx, session)
+	if err != nil {
+		return nil, err
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	session.Inventory = inv
+	return inv, sm.saveSession(session)
+}
+
+// invalidateInventory drops a cached inventory after the session's
+// installed packages changed. Callers must hold sm.mu.
+func (sm *SessionManager) invalidateInventory(session *Session) {
+	session.Inventory = nil
+}
+
+// handleGetEnvironment serves GET /sessions/{id}/environment; pass
+// refresh=true to collect the inventory again
+func handleGetEnvironment(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, err := sessionsFor(r.Context()).GetSession(id); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	refresh := r.URL.Query().Get("refresh") == "true"
+	inv, err := sessionsFor(r.Context()).Inventory(r.Context(), id, refresh)
+	if err != nil {
+		if strings.Contains(err.Error(), "not supported") {
+			http.Error(w, err.Error(), http.StatusNotImplemented)
+			return
+		}
+		writeExecuteError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(inv)
+}
+
+func invokeMCPGetEnvironment(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	sessionID, _ := params["session_id"].(string)
+	if sessionID == "" {
+		return nil, fmt.Errorf("session_id is required")
+	}
+	if err := authorizeSession(ctx, sessionID); err != nil {
+		return nil, err
+	}
+	refresh, _ := params["refresh"].(bool)
+	return sessionsFor(ctx).Inventory(ctx, sessionID, refresh)
+}
+
+var sessionsEnvironmentCmd = &cobra.Command{
+	Use:   "environment <session-id>",
+	Short: "Show the runtime version and packages installed in a session",
+	Long: `Show the runtime version and packages a session's programs can use.
+
+The inventory is collected once by running an introspection snippet in
+the session and cached; use --refresh to collect it again.
+
+Examples:
+  j0 sessions environment sess-1a2b3c4d
+  j0 sessions environment sess-1a2b3c4d --refresh --json`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		refresh, _ := cmd.Flags().GetBool("refresh")
+		inv, err := sessionManager.Inventory(cmd.Context(), args[0], refresh)
+		if err != nil {
+			return err
+		}
+
+		if jsonOut, _ := cmd.Flags().GetBool("json"); jsonOut {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(inv)
+		}
+
+		fmt.Printf("%s %s (collected %s)\n", inv.Runtime, inv.Version, inv.CollectedAt.Format("2006-01-02 15:04:05"))
+		if len(inv.Packages) == 0 {
+			return nil
+		}
+		fmt.Println()
+		fmt.Printf("%-40s %s\n", "PACKAGE", "VERSION")
+		fmt.Println(strings.Repeat("-", 60))
+		for _, p := range inv.Packages {
+			fmt.Printf("%-40s %s\n", p.Name, p.Version)
+		}
+		return nil
+	},
+}
+
+func init() {
+	sessionsEnvironmentCmd.Flags().Bool("refresh", false, "Collect the inventory again instead of using the cached one")
+	sessionsEnvironmentCmd.Flags().Bool("json", false, "Output as JSON")
+	sessionsCmd.AddCommand(sessionsEnvironmentCmd)
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// serveJudge0Info answers an operator endpoint with what fetch returns
+// from Judge0; backend failures are reported as 502
+func serveJudge0Info(w http.ResponseWriter, r *http.Request, fetch func(ctx context.Context) (interface{}, error)) {
+	info, err := fetch(r.Context())
+	if err != nil {
+		http.Error(w, "judge0: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(info)
+}
+
+// handleJudge0Config serves GET /judge0/config, Judge0's /config_info
+func handleJudge0Config(w http.ResponseWriter, r *http.Request) {
+	serveJudge0Info(w, r, func(ctx context.Context) (interface{}, error) {
+		return judge0Client.ConfigInfo(ctx)
+	})
+}
+
+// handleJudge0Workers serves GET /judge0/workers, Judge0's queues and workers
+func handleJudge0Workers(w http.ResponseWriter, r *http.Request) {
+	serveJudge0Info(w, r, func(ctx context.Context) (interface{}, error) {
+		return judge0Client.Workers(ctx)
+	})
+}
+
+// handleJudge0Statistics serves GET /judge0/statistics; pass
+// invalidate_cache=true to have Judge0 recompute them
+func handleJudge0Statistics(w http.ResponseWriter, r *http.Request) {
+	invalidate := r.URL.Query().Get("invalidate_cache") == "true"
+	serveJudge0Info(w, r, func(ctx context.Context) (interface{}, error) {
+		return judge0Client.Statistics(ctx, invalidate)
+	})
+}
+
+// printJSON writes v to stdout, indented
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+var judge0Cmd = &cobra.Command{
+	Use:   "judge0",
+	Short: "Inspect the Judge0 backend's configuration, workers and statistics",
+}
+
+var judge0ConfigCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Show Judge0's configuration and limits (/config_info)",
+	Long: `Show the Judge0 instance's configuration: default and maximum
+limits, and which features are enabled.
+
+Examples:
+  j0 judge0 config
+  j0 judge0 config --json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := judge0Client.ConfigInfo(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("failed to get Judge0 config: %w", err)
+		}
+		if jsonOut, _ := cmd.Flags().GetBool("json"); jsonOut {
+			return printJSON(config)
+		}
+
+		keys := make([]string, 0, len(config))
+		for k := range config {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Printf("%-45s %v\n", k, config[k])
+		}
+		return nil
+	},
+}
+
+var judge0WorkersCmd = &cobra.Command{
+	Use:   "workers",
+	Short: "Show Judge0's queue depth and worker counts (/workers)",
+	Long: `Show each Judge0 queue with the submissions waiting in it and the
+state of its workers.
+
+Examples:
+  j0 judge0 workers
+  j0 judge0 workers --json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		queues, err := judge0Client.Workers(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("failed to get Judge0 workers: %w", err)
+		}
+		if jsonOut, _ := cmd.Flags().GetBool("json"); jsonOut {
+			return printJSON(queues)
+		}
+
+		fmt.Printf("%-12s %8s %10s %6s %8s %7s %7s\n", "QUEUE", "SIZE", "AVAILABLE", "IDLE", "WORKING", "PAUSED", "FAILED")
+		fmt.Println(strings.Repeat("-", 64))
+		for _, q := range queues {
+			fmt.Printf("%-12v %8v %10v %6v %8v %7v %7v\n", q["queue"], q["size"], q["available"], q["idle"], q["working"], q["paused"], q["failed"])
+		}
+		return nil
+	},
+}
+
+var judge0StatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show Judge0's submission statistics (/statistics)",
+	Long: `Show Judge0's submission statistics: totals, submissions per
+language and status, and database size.
+
+Judge0 caches its statistics; --refresh has it recompute them.
+
+Examples:
+  j0 judge0 stats
+  j0 judge0 stats --refresh`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		refresh, _ := cmd.Flags().GetBool("refresh")
+		stats, err := judge0Client.Statistics(cmd.Context(), refresh)
+		if err != nil {
+			return fmt.Errorf("failed to get Judge0 statistics: %w", err)
+		}
+		return printJSON(stats)
+	},
+}
+
+func init() {
+	judge0ConfigCmd.Flags().Bool("json", false, "Output as JSON")
+	judge0WorkersCmd.Flags().Bool("json", false, "Output as JSON")
+	judge0StatsCmd.Flags().Bool("refresh", false, "Have Judge0 recompute its cached statistics")
+
+	judge0Cmd.AddCommand(judge0ConfigCmd)
+	judge0Cmd.AddCommand(judge0WorkersCmd)
+	judge0Cmd.AddCommand(judge0StatsCmd)
+	rootCmd.AddCommand(judge0Cmd)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testJWKS serves key's public half as kid and returns a key set for it
+func testJWKS(t *testing.T, key *rsa.PrivateKey, kid string) *JWKS {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{publicJWK(&key.PublicKey, kid)},
+		})
+	}))
+	t.Cleanup(srv.Close)
+	return NewJWKS(srv.URL)
+}
+
+// unsignedJWT builds a token with the given header and claims and no
+// valid signature
+func unsignedJWT(t *testing.T, header map[string]string, claims JWTClaims, sig string) string {
+	t.Helper()
+	h, err := json.Marshal(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c) + "." + sig
+}
+
+func TestVerifyJWT(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := testJWKS(t, key, "k1")
+
+	now := time.Now().Unix()
+	valid := JWTClaims{"sub": "alice", "exp": float64(now + 60)}
+	sign := func(claims JWTClaims, key *rsa.PrivateKey, kid string) string {
+		token, err := signJWT(claims, key, kid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		want  string // error substring, "" for success
+	}{
+		{name: "valid", token: sign(valid, key, "k1")},
+		{
+			name:  "alg none",
+			token: unsignedJWT(t, map[string]string{"alg": "none", "kid": "k1"}, valid, ""),
+			want:  "unsupported token algorithm: none",
+		},
+		{
+			name:  "alg HS256",
+			token: unsignedJWT(t, map[string]string{"alg": "HS256", "kid": "k1"}, valid, "c2ln"),
+			want:  "unsupported token algorithm: HS256",
+		},
+		{
+			name:  "unknown kid",
+			token: sign(valid, key, "k2"),
+			want:  "unknown signing key: k2",
+		},
+		{
+			name:  "signed by another key under a known kid",
+			token: sign(valid, other, "k1"),
+			want:  "invalid token signature",
+		},
+		{
+			name: "claims swapped after signing",
+			token: func() string {
+				parts := strings.Split(sign(valid, key, "k1"), ".")
+				return unsignedJWT(t, map[string]string{"alg": "RS256", "kid": "k1"}, JWTClaims{"sub": "admin", "exp": float64(now + 60)}, parts[2])
+			}(),
+			want: "invalid token signature",
+		},
+		{
+			name:  "expired",
+			token: sign(JWTClaims{"sub": "alice", "exp": float64(now - 60)}, key, "k1"),
+			want:  "token expired",
+		},
+		{
+			name:  "not yet valid",
+			token: sign(JWTClaims{"sub": "alice", "exp": float64(now + 120), "nbf": float64(now + 60)}, key, "k1"),
+			want:  "token not yet valid",
+		},
+		{
+			name:  "no exp",
+			token: sign(JWTClaims{"sub": "alice"}, key, "k1"),
+			want:  "token has no exp claim",
+		},
+		{
+			name:  "malformed",
+			token: "not.a-token",
+			want:  "malformed token",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := verifyJWT(tt.token, keys)
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("verifyJWT: %v", err)
+				}
+				if claims.String("sub") != "alice" {
+					t.Fatalf("sub = %q, want alice", claims.String("sub"))
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("verifyJWT error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,454 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// canonicalLanguages lists the preferred name for each supported language
+var canonicalLanguages = []string{"bash", "python", "go", "javascript", "ruby", "rust", "c", "cpp"}
+
+// compiledLanguages are compiled before they run, within Judge0's Processing status
+var compiledLanguages = map[int]bool{LanguageGo: true, LanguageRust: true, LanguageC: true, LanguageCPP: true}
+
+// maxLanguageSuggestions caps the close matches returned for an unknown language
+const maxLanguageSuggestions = 5
+
+// UnsupportedLanguageError is returned for unknown languages and carries
+// close matches so callers can correct the name instead of retrying blindly
+type UnsupportedLanguageError struct {
+	Language       string   `json:"language"`
+	Suggestions    []string `json:"suggestions"`
+	Canonical      []string `json:"supported"`
+	BackendMatches []string `json:"backend_matches,omitempty"`
+}
+
+func (e *UnsupportedLanguageError) Error() string {
+	msg := "unsupported language: " + e.Language
+	if len(e.Suggestions) > 0 {
+		msg += " (did you mean " + strings.Join(e.Suggestions, ", ") + "?)"
+	}
+	return msg + "; supported: " + strings.Join(e.Canonical, ", ")
+}
+
+// newUnsupportedLanguageError suggests aliases within a small edit distance
+func newUnsupportedLanguageError(language string) *UnsupportedLanguageError {
+	aliases := catalogNames()
+	for alias := range LanguageMap {
+		aliases = append(aliases, alias)
+	}
+
+	return &UnsupportedLanguageError{
+		Language:    language,
+		Suggestions: closeMatches(language, aliases),
+		Canonical:   canonicalLanguages,
+	}
+}
+
+// validateLanguage checks a language name for session creation. Names
+// missing from the catalog are looked up again after refreshing it from the
+// backend's /languages list (best effort), which also supplies matches.
+func validateLanguage(ctx context.Context, language string) error {
+	if _, err := GetLanguageID(language); err == nil {
+		return nil
+	}
+
+	languages, lerr := backendLanguages(ctx)
+	_, err := GetLanguageID(language)
+	var unsupported *UnsupportedLanguageError
+	if !errors.As(err, &unsupported) {
+		return err
+	}
+
+	if lerr == nil {
+		names := make([]string, 0, len(languages))
+		for _, l := range languages {
+			if name, ok := l["name"].(string); ok {
+				names = append(names, name)
+			}
+		}
+		unsupported.BackendMatches = closeMatches(language, names)
+	}
+	return unsupported
+}
+
+// languageCacheTTL is how long the backend's /languages list is reused
+const languageCacheTTL = 10 * time.Minute
+
+var languageCache struct {
+	mu        sync.Mutex
+	languages []map[string]interface{}
+	fetched   time.Time
+}
+
+// backendLanguages returns Judge0's /languages list, cached for languageCacheTTL
+func backendLanguages(ctx context.Context) ([]map[string]interface{}, error) {
+	languageCache.mu.Lock()
+	defer languageCache.mu.Unlock()
+
+	if languageCache.languages != nil && time.Since(languageCache.fetched) < languageCacheTTL {
+		return languageCache.languages, nil
+	}
+	languages, err := judge0Client.Languages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	languageCache.languages = languages
+	languageCache.fetched = time.Now()
+	setLanguageCatalog(languages)
+	if err := saveLanguageCatalog(languages); err != nil && verbose {
+		log.Printf("Warning: failed to cache language catalog: %v", err)
+	}
+	return languages, nil
+}
+
+// LanguageInfo describes a backend language and the names sessions can use for it
+type LanguageInfo struct {
+	ID      int      `json:"id"`
+	Name    string   `json:"name"`
+	Version string   `json:"version,omitempty"`
+	Aliases []string `json:"aliases,omitempty"`
+	// EnvInjection reports j0 wraps code in this language to set the
+	// session's environment variables
+	EnvInjection bool `json:"env_injection"`
+}
+
+// hasEnvInjection reports whether code in the language, under any of its
+// names, gets the session's environment injected
+func hasEnvInjection(id int, names []string) bool {
+	if _, ok := envInjectorFor(id); ok {
+		return true
+	}
+	for _, name := range names {
+		if lang, ok := customLanguages[strings.ToLower(name)]; ok && lang.wrapper != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// languageAliases maps Judge0 language IDs to their LanguageMap and
+// catalog names, canonical name first
+func languageAliases() map[int][]string {
+	aliases := make(map[int][]string)
+	for alias, id := range LanguageMap {
+		aliases[id] = append(aliases[id], alias)
+	}
+	for _, name := range catalogNames() {
+		id, _ := catalogLanguageID(name)
+		if !strings.Contains(name, " (") && !slices.Contains(aliases[id], name) {
+			aliases[id] = append(aliases[id], name)
+		}
+	}
+	for id, names := range aliases {
+		sort.Slice(names, func(i, j int) bool {
+			ci, cj := slices.Contains(canonicalLanguages, names[i]), slices.Contains(canonicalLanguages, names[j])
+			if ci != cj {
+				return ci
+			}
+			return names[i] < names[j]
+		})
+		aliases[id] = names
+	}
+	return aliases
+}
+
+// listLanguages describes the backend's languages. Unless all is set, only
+// those sessions can be created for (with an alias) are included. When the
+// backend can't be reached, the aliased languages are listed without
+// versions along with the error.
+func listLanguages(ctx context.Context, all bool) ([]LanguageInfo, error) {
+	aliases := languageAliases()
+	backend, err := backendLanguages(ctx)
+
+	infos := []LanguageInfo{}
+	seen := make(map[int]bool)
+	for _, l := range backend {
+		id, _ := l["id"].(float64)
+		name, _ := l["name"].(string)
+		info := LanguageInfo{ID: int(id), Aliases: aliases[int(id)], EnvInjection: hasEnvInjection(int(id), aliases[int(id)])}
+		info.Name, info.Version = splitLanguageName(name)
+		if !all && len(info.Aliases) == 0 {
+			continue
+		}
+		seen[info.ID] = true
+		infos = append(infos, info)
+	}
+	for id, names := range aliases {
+		if !seen[id] && err != nil {
+			infos = append(infos, LanguageInfo{ID: id, Name: names[0], Aliases: names, EnvInjection: hasEnvInjection(id, names)})
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+	return infos, err
+}
+
+// languageToolchains are qualifiers naming a compiler instead of a
+// language: "gcc:12" is C built by GCC 12
+var languageToolchains = map[string]struct{ language, toolchain string }{
+	"gcc":     {"c", "gcc"},
+	"g++":     {"cpp", "gcc"},
+	"clang":   {"c", "clang"},
+	"clang++": {"cpp", "clang"},
+}
+
+// parseLanguageSpec splits a qualified language name like "python:3.11" or
+// "gcc:12" into the language, the toolchain it names (if any) and the version
+func parseLanguageSpec(spec string) (language, toolchain, version string) {
+	language, version, _ = strings.Cut(strings.TrimSpace(spec), ":")
+	if tc, ok := languageToolchains[strings.ToLower(language)]; ok {
+		return tc.language, tc.toolchain, version
+	}
+	return language, "", version
+}
+
+// resolveLanguageSpec validates a session's language, optionally qualified
+// with a version ("python:3.11") or toolchain and version ("gcc:12"), and
+// resolves the backend language it pins. version is the separate version
+// field and can't be combined with a qualified name. It returns the
+// unqualified language name.
+func resolveLanguageSpec(ctx context.Context, spec, version string) (string, *LanguageInfo, error) {
+	language, toolchain, qualified := parseLanguageSpec(spec)
+	if qualified != "" && version != "" && qualified != version {
+		return "", nil, fmt.Errorf("language %s conflicts with version %s", spec, version)
+	}
+	if qualified != "" {
+		version = qualified
+	}
+	if strings.Contains(spec, ":") && version == "" {
+		return "", nil, fmt.Errorf("language %s has an empty version", spec)
+	}
+
+	if err := validateLanguage(ctx, language); err != nil {
+		return "", nil, err
+	}
+	if version == "" && toolchain == "" {
+		return language, nil, nil
+	}
+	pinned, err := resolveLanguageVersion(ctx, language, toolchain, version)
+	if err != nil {
+		return "", nil, err
+	}
+	return language, pinned, nil
+}
+
+// splitToolchain splits a backend version like "GCC 9.2.0" into the
+// lowercased toolchain and version number
+func splitToolchain(version string) (string, string) {
+	if i := strings.LastIndex(version, " "); i > 0 {
+		return strings.ToLower(version[:i]), version[i+1:]
+	}
+	return "", version
+}
+
+// resolveLanguageVersion finds the backend language for a pinned version of
+// language, built by toolchain when one is given. version matches exactly
+// or as a prefix ("3.11" matches "3.11.2", "12" matches "GCC 12.1.0"); the
+// newest match wins.
+func resolveLanguageVersion(ctx context.Context, language, toolchain, version string) (*LanguageInfo, error) {
+	id, err := GetLanguageID(language)
+	if err != nil {
+		return nil, err
+	}
+	backend, err := listLanguages(ctx, true)
+	if err != nil {
+		return nil, fmt.Errorf("can't resolve %s %s: backend languages unavailable: %w", language, version, err)
+	}
+
+	var base string
+	for _, l := range backend {
+		if l.ID == id {
+			base = l.Name
+		}
+	}
+	if base == "" {
+		return nil, fmt.Errorf("the backend does not offer %s", language)
+	}
+
+	var best *LanguageInfo
+	var bestNumber string
+	var available []string
+	for i, l := range backend {
+		if l.Name != base || l.Version == "" {
+			continue
+		}
+		available = append(available, l.Version)
+		tc, number := splitToolchain(l.Version)
+		if toolchain != "" && tc != toolchain {
+			continue
+		}
+		if version != "" && l.Version != version && number != version && !strings.HasPrefix(number, version+".") {
+			continue
+		}
+		if best == nil || compareVersions(number, bestNumber) > 0 {
+			best, bestNumber = &backend[i], number
+		}
+	}
+	if best == nil {
+		want := version
+		if toolchain != "" {
+			want = strings.TrimSpace(toolchain + " " + version)
+		}
+		return nil, fmt.Errorf("%s %s is not available (backend offers %s)", language, want, strings.Join(available, ", "))
+	}
+	return best, nil
+}
+
+// compareVersions compares dotted version strings numerically
+func compareVersions(a, b string) int {
+	pa, pb := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var na, nb int
+		if i < len(pa) {
+			na, _ = strconv.Atoi(pa[i])
+		}
+		if i < len(pb) {
+			nb, _ = strconv.Atoi(pb[i])
+		}
+		if na != nb {
+			return na - nb
+		}
+	}
+	return 0
+}
+
+// closeMatches returns candidates that contain the input, share a prefix with
+// it, or are within a small edit distance, closest first
+func closeMatches(input string, candidates []string) []string {
+	input = strings.ToLower(strings.TrimSpace(input))
+	if input == "" {
+		return nil
+	}
+
+	type match struct {
+		name     string
+		distance int
+	}
+	var matches []match
+	for _, c := range candidates {
+		lower := strings.ToLower(c)
+		// Backend names look like "Python (3.8.1)"; compare against the first word
+		word := strings.Fields(lower + " ")[0]
+
+		distance := levenshtein(input, word)
+		limit := 2
+		if len(input) <= 3 {
+			limit = 1
+		}
+
+		switch {
+		case distance <= limit:
+		case len(input) >= 2 && (strings.HasPrefix(word, input) || strings.HasPrefix(input, word) && len(word) >= 2):
+		case len(input) >= 3 && strings.Contains(lower, input):
+		default:
+			continue
+		}
+		matches = append(matches, match{c, distance})
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].distance != matches[j].distance {
+			return matches[i].distance < matches[j].distance
+		}
+		return matches[i].name < matches[j].name
+	})
+
+	names := []string{}
+	for _, m := range matches {
+		if len(names) == maxLanguageSuggestions {
+			break
+		}
+		names = append(names, m.name)
+	}
+	return names
+}
+
+// levenshtein returns the edit distance between two strings
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+// writeLanguageError responds with a structured 400 for unknown languages
+func writeLanguageError(w http.ResponseWriter, err error) {
+	var unsupported *UnsupportedLanguageError
+	if !errors.As(err, &unsupported) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(struct {
+		Error string `json:"error"`
+		*UnsupportedLanguageError
+	}{unsupported.Error(), unsupported})
+}
+
+var languagesCmd = &cobra.Command{
+	Use:   "languages",
+	Short: "List the backend's languages",
+	Long: `List the languages the connected Judge0 instance offers, with their
+IDs, versions, the names sessions can use for them, and whether j0 injects
+session environment variables into their code.
+
+Examples:
+  j0 languages
+  j0 languages --json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		languages, err := listLanguages(cmd.Context(), true)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: backend languages unavailable, listing j0's aliases only: %v\n", err)
+		}
+		if jsonOut, _ := cmd.Flags().GetBool("json"); jsonOut {
+			return printJSON(languages)
+		}
+
+		fmt.Printf("%-5s %-28s %-22s %-4s %s\n", "ID", "NAME", "VERSION", "ENV", "ALIASES")
+		fmt.Println(strings.Repeat("-", 90))
+		for _, l := range languages {
+			env := "-"
+			if l.EnvInjection {
+				env = "yes"
+			}
+			fmt.Printf("%-5d %-28s %-22s %-4s %s\n", l.ID, l.Name, l.Version, env, strings.Join(l.Aliases, ", "))
+		}
+		return nil
+	},
+}
+
+func init() {
+	languagesCmd.Flags().Bool("json", false, "Output as JSON")
+	rootCmd.AddCommand(languagesCmd)
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// LimitRow is one resource in the limits matrix. Backend values come from
+// Judge0's config_info; Default is what j0 requests per submission and
+// Effective what an execution actually gets.
+type LimitRow struct {
+	Resource       string      `json:"resource"`
+	Unit           string      `json:"unit,omitempty"`
+	BackendDefault interface{} `json:"backend_default,omitempty"`
+	BackendMax     interface{} `json:"backend_max,omitempty"`
+	Default        interface{} `json:"default,omitempty"`
+	Effective      interface{} `json:"effective,omitempty"`
+	Note           string      `json:"note,omitempty"`
+}
+
+// PolicyLimits are the deployment's own ceilings
+type PolicyLimits struct {
+	ExecutionTimeoutSeconds float64 `json:"execution_timeout_seconds"`
+	SoftLimitPercent        float64 `json:"soft_limit_percent"`
+	ExecuteRateLimit        int     `json:"execute_rate_limit_per_minute"`
+	CreateRateLimit         int     `json:"create_rate_limit_per_minute"`
+	BudgetMemorySeconds     float64 `json:"budget_memory_mb_seconds,omitempty"`
+	BudgetDiskBytes         int64   `json:"budget_disk_bytes,omitempty"`
+	BudgetAction            string  `json:"budget_action,omitempty"`
+	MaxHistoryPerSession    int     `json:"max_history_per_session,omitempty"`
+	StrictSessions          bool    `json:"strict_sessions"`
+	// LanguageConcurrency caps concurrent submissions per language
+	LanguageConcurrency map[string]int `json:"language_concurrency,omitempty"`
+}
+
+// CallerLimits is the caller's standing against its quotas
+type CallerLimits struct {
+	Principal string       `json:"principal,omitempty"`
+	Tenant    string       `json:"tenant,omitempty"`
+	Quota     *TenantQuota `json:"quota,omitempty"`
+	Usage     *TenantUsage `json:"usage,omitempty"`
+	// Remaining requests in the current window; -1 means unlimited
+	APIKeyRemaining  *int `json:"api_key_requests_remaining,omitempty"`
+	ExecuteRemaining *int `json:"executions_remaining,omitempty"`
+	CreateRemaining  *int `json:"session_creates_remaining,omitempty"`
+}
+
+// LimitsReport is served by GET /limits and printed by j0 limits
+type LimitsReport struct {
+	Matrix       []LimitRow             `json:"matrix"`
+	Policy       PolicyLimits           `json:"policy"`
+	Caller       CallerLimits           `json:"caller"`
+	Backend      map[string]interface{} `json:"backend,omitempty"`
+	BackendError string                 `json:"backend_error,omitempty"`
+}
+
+// minLimit picks the lower of j0's default and the backend maximum; Judge0
+// rejects submissions above its maxima
+func minLimit(requested float64, max interface{}) interface{} {
+	if m, ok := max.(float64); ok && m > 0 && m < requested {
+		return m
+	}
+	return requested
+}
+
+// buildLimitsReport assembles the limits matrix for the caller in ctx
+func buildLimitsReport(ctx context.Context) *LimitsReport {
+	report := &LimitsReport{
+		Policy: PolicyLimits{
+			ExecutionTimeoutSeconds: defaultExecTimeout.Seconds(),
+			SoftLimitPercent:        softLimitPercent,
+			ExecuteRateLimit:        executeRateLimit,
+			CreateRateLimit:         createRateLimit,
+			BudgetMemorySeconds:     sessionBudgets.MemorySeconds,
+			BudgetDiskBytes:         sessionBudgets.DiskBytes,
+			MaxHistoryPerSession:    maxHistory,
+			LanguageConcurrency:     submissionLimiter.Limits(),
+			StrictSessions:          strictSessions,
+		},
+	}
+	if sessionBudgets.Enabled() {
+		report.Policy.BudgetAction = sessionBudgets.Action
+	}
+
+	config, err := judge0Client.ConfigInfo(ctx)
+	if err != nil {
+		report.BackendError = err.Error()
+		config = map[string]interface{}{}
+	} else {
+		report.Backend = config
+	}
+
+	defaults := NewSubmission("", 0, "")
+	report.Matrix = []LimitRow{
+		{
+			Resource: "cpu_time", Unit: "s",
+			BackendDefault: config["cpu_time_limit"], BackendMax: config["max_cpu_time_limit"],
+			Default: float64(defaults.CPUTimeLimit), Effective: minLimit(float64(defaults.CPUTimeLimit), config["max_cpu_time_limit"]),
+		},
+		{
+			Resource: "memory", Unit: "KB",
+			BackendDefault: config["memory_limit"], BackendMax: config["max_memory_limit"],
+			Default: float64(defaults.MemoryLimit), Effective: minLimit(float64(defaults.MemoryLimit), config["max_memory_limit"]),
+		},
+		{
+			Resource: "wall_time", Unit: "s",
+			BackendDefault: config["wall_time_limit"], BackendMax: config["max_wall_time_limit"],
+			Default: defaultExecTimeout.Seconds(), Effective: defaultExecTimeout.Seconds(),
+			Note: "j0 stops waiting after the execution timeout (per-request timeout overrides)",
+		},
+		{
+			Resource: "stack", Unit: "KB",
+			BackendDefault: config["stack_limit"], BackendMax: config["max_stack_limit"],
+			Effective: config["stack_limit"],
+		},
+		{
+			Resource:       "processes",
+			BackendDefault: config["max_processes_and_or_threads"], BackendMax: config["max_max_processes_and_or_threads"],
+			Effective: config["max_processes_and_or_threads"],
+		},
+		{
+			Resource: "file_size", Unit: "KB",
+			BackendDefault: config["max_file_size"], BackendMax: config["max_max_file_size"],
+			Effective: config["max_file_size"],
+		},
+		{
+			Resource:       "network",
+			BackendDefault: config["enable_network"], BackendMax: config["allow_enable_network"],
+			Effective: config["enable_network"],
+			Note:      "always disabled in exam sessions",
+		},
+	}
+
+	report.Caller = callerLimits(ctx)
+	return report
+}
+
+// callerLimits reports the tenant quota and rate limit state of the caller
+func callerLimits(ctx context.Context) CallerLimits {
+	caller := CallerLimits{Principal: principalFromContext(ctx), Tenant: tenantFromContext(ctx)}
+
+	if caller.Tenant != "" {
+		if t, err := tenantRegistry.Get(caller.Tenant); err == nil {
+			caller.Quota = &t.Quota
+		}
+		if usage, err := tenantRegistry.Usage(caller.Tenant); err == nil {
+			caller.Usage = &usage
+		}
+	}
+
+	if name, ok := strings.CutPrefix(caller.Principal, "key:"); ok && apiKeys != nil {
+		if l, ok := apiKeys.limiters[name]; ok {
+			n := l.available()
+			caller.APIKeyRemaining = &n
+		}
+	}
+
+	// Anonymous HTTP callers are limited by address
+	client := caller.Principal
+	if client == "" {
+		client = actorFromContext(ctx)
+	}
+	if executeLimiter != nil {
+		n := executeLimiter.Remaining(client)
+		caller.ExecuteRemaining = &n
+	}
+	if createLimiter != nil {
+		n := createLimiter.Remaining(client)
+		caller.CreateRemaining = &n
+	}
+	return caller
+}
+
+// handleLimits serves GET /limits
+func handleLimits(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(buildLimitsReport(r.Context()))
+}
+
+// limitsCmd prints the effective limits matrix
+var limitsCmd = &cobra.Command{
+	Use:   "limits",
+	Short: "Show effective execution limits, policy ceilings and quota state",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		report := buildLimitsReport(cmd.Context())
+
+		if jsonOut, _ := cmd.Flags().GetBool("json"); jsonOut {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(report)
+		}
+
+		if report.BackendError != "" {
+			fmt.Fprintf(os.Stderr, "Warning: backend limits unavailable: %s\n", report.BackendError)
+		}
+
+		fmt.Printf("%-10s %-5s %-16s %-12s %-10s %s\n", "RESOURCE", "UNIT", "BACKEND DEFAULT", "BACKEND MAX", "J0", "EFFECTIVE")
+		fmt.Println(strings.Repeat("-", 70))
+		for _, row := range report.Matrix {
+			fmt.Printf("%-10s %-5s %-16s %-12s %-10s %s\n",
+				row.Resource, orDash(row.Unit), limitValue(row.BackendDefault), limitValue(row.BackendMax),
+				limitValue(row.Default), limitValue(row.Effective))
+		}
+
+		p := report.Policy
+		fmt.Println()
+		fmt.Printf("Execution timeout:   %gs\n", p.ExecutionTimeoutSeconds)
+		fmt.Printf("Soft limit warnings: %g%%\n", p.SoftLimitPercent)
+		if p.BudgetMemorySeconds > 0 || p.BudgetDiskBytes > 0 {
+			fmt.Printf("Session budgets:     %g MB·s memory, %d disk bytes (%s)\n", p.BudgetMemorySeconds, p.BudgetDiskBytes, p.BudgetAction)
+		}
+		if p.MaxHistoryPerSession > 0 {
+			fmt.Printf("History in memory:   %d executions per session\n", p.MaxHistoryPerSession)
+		}
+		if len(p.LanguageConcurrency) > 0 {
+			caps := make([]string, 0, len(p.LanguageConcurrency))
+			for language, n := range p.LanguageConcurrency {
+				caps = append(caps, fmt.Sprintf("%s=%d", language, n))
+			}
+			sort.Strings(caps)
+			fmt.Printf("Concurrency caps:    %s\n", strings.Join(caps, ", "))
+		}
+
+		if c := report.Caller; c.Tenant != "" {
+			fmt.Println()
+			fmt.Printf("Tenant %s:\n", c.Tenant)
+			if c.Quota != nil && c.Usage != nil {
+				fmt.Printf("  sessions:        %d / %s\n", c.Usage.Sessions, quotaValue(int64(c.Quota.MaxSessions)))
+				fmt.Printf("  active sessions: %d / %s\n", c.Usage.ActiveSessions, quotaValue(int64(c.Quota.MaxActiveSessions)))
+				fmt.Printf("  disk bytes:      %d / %s\n", c.Usage.DiskBytes, quotaValue(c.Quota.MaxDiskBytes))
+			}
+		}
+		return nil
+	},
+}
+
+// limitValue formats a matrix cell; missing values print as "-"
+func limitValue(v interface{}) string {
+	if v == nil {
+		return "-"
+	}
+	return fmt.Sprint(v)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// quotaValue prints 0 (no quota) as unlimited
+func quotaValue(n int64) string {
+	if n == 0 {
+		return "unlimited"
+	}
+	return fmt.Sprint(n)
+}
+
+func init() {
+	limitsCmd.Flags().Bool("json", false, "Output as JSON")
+}
@@ -0,0 +1,433 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// Session logs are append-only text. Every entry appended to a log also
+// gets a record in a hash chain sidecar (logs/<id>.chain) holding the
+// entry's byte range and SHA-256, linked to the previous record's hash.
+// Editing, removing or inserting log bytes breaks the chain. With a
+// signing key, signed checkpoints of the chain head are added every
+// --log-checkpoint-every entries and when the session closes, and recorded
+// in the audit trail, so rewriting the log together with its chain is
+// detectable too.
+
+// logSigningKeyEnv names the key file when --log-signing-key isn't set
+const logSigningKeyEnv = "J0_LOG_SIGNING_KEY"
+
+// Chain record types
+const (
+	chainEntry      = "entry"
+	chainCheckpoint = "checkpoint"
+)
+
+var (
+	// logSigningKeyFile is set from --log-signing-key
+	logSigningKeyFile string
+	// logCheckpointEvery is set from --log-checkpoint-every
+	logCheckpointEvery int
+	// logSigningKey signs checkpoints; nil disables them
+	logSigningKey ed25519.PrivateKey
+)
+
+// LogChainRecord is one line of a session's hash chain
+type LogChainRecord struct {
+	Type string    `json:"type"`
+	Seq  int       `json:"seq"`
+	Time time.Time `json:"time"`
+
+	// Entry records cover log bytes [Offset, Offset+Length)
+	Offset      int64  `json:"offset,omitempty"`
+	Length      int64  `json:"length,omitempty"`
+	EntrySHA256 string `json:"entry_sha256,omitempty"`
+	Prev        string `json:"prev,omitempty"`
+
+	// Hash is the entry's chain hash, or for checkpoints the chain head
+	// being signed
+	Hash string `json:"hash"`
+
+	// Checkpoints are signed with an Ed25519 key
+	Reason    string `json:"reason,omitempty"`
+	PublicKey string `json:"public_key,omitempty"`
+	Signature string `json:"signature,omitempty"`
+}
+
+// loadLogSigningKey reads a hex-encoded Ed25519 seed, as written by
+// j0 log keygen
+func loadLogSigningKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read log signing key: %w", err)
+	}
+	seed, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("invalid log signing key %s: want a hex-encoded %d-byte Ed25519 seed", path, ed25519.SeedSize)
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// LogChainFile returns the path of a session's hash chain
+func (sm *SessionManager) LogChainFile(session *Session) string {
+	return strings.TrimSuffix(session.LogFile, ".log") + ".chain"
+}
+
+// entryHash links an entry to the chain
+func entryHash(sessionID string, r LogChainRecord) string {
+	return sha256Hex([]byte(fmt.Sprintf("%s\n%d\n%s\n%d\n%d\n%s\n%s",
+		sessionID, r.Seq, r.Time.UTC().Format(time.RFC3339Nano), r.Offset, r.Length, r.EntrySHA256, r.Prev)))
+}
+
+// checkpointMessage is what a checkpoint signature covers
+func checkpointMessage(sessionID string, r LogChainRecord) []byte {
+	return []byte(fmt.Sprintf("j0 log checkpoint\n%s\n%d\n%s\n%s",
+		sessionID, r.Seq, r.Hash, r.Time.UTC().Format(time.RFC3339Nano)))
+}
+
+// chainHead returns the sequence number and hash of the last chain record
+func (sm *SessionManager) chainHead(session *Session) (int, string, error) {
+	last, err := tailLines(sm.LogChainFile(session), 1)
+	if os.IsNotExist(err) || (err == nil && strings.TrimSpace(last) == "") {
+		return 0, "", nil
+	}
+	if err != nil {
+		return 0, "", err
+	}
+	var r LogChainRecord
+	if err := json.Unmarshal([]byte(strings.TrimSpace(last)), &r); err != nil {
+		return 0, "", fmt.Errorf("corrupt hash chain: %w", err)
+	}
+	return r.Seq, r.Hash, nil
+}
+
+// chainLogEntry records an entry appended to the log at offset. Callers
+// must hold sm.mu.
+func (sm *SessionManager) chainLogEntry(session *Session, offset int64, entry string) error {
+	seq, prev, err := sm.chainHead(session)
+	if err != nil {
+		return err
+	}
+
+	r := LogChainRecord{
+		Type:        chainEntry,
+		Seq:         seq + 1,
+		Time:        time.Now().UTC(),
+		Offset:      offset,
+		Length:      int64(len(entry)),
+		EntrySHA256: sha256Hex([]byte(entry)),
+		Prev:        prev,
+	}
+	r.Hash = entryHash(session.ID, r)
+	if err := appendJSONLine(sm.LogChainFile(session), r); err != nil {
+		return err
+	}
+
+	if logCheckpointEvery > 0 && r.Seq%logCheckpointEvery == 0 {
+		return sm.checkpointLog(session, "interval")
+	}
+	return nil
+}
+
+// checkpointLog signs the chain head, if a signing key is configured and
+// the chain has entries. Callers must hold sm.mu.
+func (sm *SessionManager) checkpointLog(session *Session, reason string) error {
+	if logSigningKey == nil {
+		return nil
+	}
+	seq, head, err := sm.chainHead(session)
+	if err != nil || seq == 0 {
+		return err
+	}
+
+	r := LogChainRecord{
+		Type:      chainCheckpoint,
+		Seq:       seq,
+		Time:      time.Now().UTC(),
+		Hash:      head,
+		Reason:    reason,
+		PublicKey: hex.EncodeToString(logSigningKey.Public().(ed25519.PublicKey)),
+	}
+	r.Signature = hex.EncodeToString(ed25519.Sign(logSigningKey, checkpointMessage(session.ID, r)))
+	if err := appendJSONLine(sm.LogChainFile(session), r); err != nil {
+		return err
+	}
+
+	// A copy outside the data directory's log files
+	audit(systemContext, "session.log_checkpoint", session.ID, map[string]interface{}{
+		"seq":        r.Seq,
+		"hash":       r.Hash,
+		"public_key": r.PublicKey,
+	})
+	return nil
+}
+
+// LogVerification is the result of checking a session log against its chain
+type LogVerification struct {
+	SessionID string `json:"session_id"`
+	Valid     bool   `json:"valid"`
+	Entries   int    `json:"entries"`
+	LogBytes  int64  `json:"log_bytes"`
+	Head      string `json:"head,omitempty"`
+
+	Checkpoints int `json:"checkpoints"`
+	// LastCheckpoint is the sequence number the newest valid checkpoint
+	// signed; entries after it are only protected by the chain
+	LastCheckpoint int      `json:"last_checkpoint,omitempty"`
+	PublicKeys     []string `json:"public_keys,omitempty"`
+
+	// UnchainedPrefix counts log bytes written before chaining began
+	UnchainedPrefix int64    `json:"unchained_prefix,omitempty"`
+	Errors          []string `json:"errors,omitempty"`
+	Warnings        []string `json:"warnings,omitempty"`
+}
+
+func (v *LogVerification) fail(format string, args ...interface{}) {
+	v.Errors = append(v.Errors, fmt.Sprintf(format, args...))
+}
+
+// VerifyLog checks a session log against its hash chain. With trustedKey
+// (hex), checkpoints must be signed by that key; otherwise signatures are
+// checked against the key recorded in each checkpoint.
+func (sm *SessionManager) VerifyLog(sessionID, trustedKey string) (*LogVerification, error) {
+	session, err := sm.GetSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	sm.mu.RLock()
+	data, err := os.ReadFile(session.LogFile)
+	if err != nil && !os.IsNotExist(err) {
+		sm.mu.RUnlock()
+		return nil, fmt.Errorf("failed to read log file: %w", err)
+	}
+	// Read both under the lock so an append can't land between them
+	chain, cerr := os.ReadFile(sm.LogChainFile(session))
+	sm.mu.RUnlock()
+
+	v := &LogVerification{SessionID: sessionID, LogBytes: int64(len(data))}
+	if os.IsNotExist(cerr) {
+		if len(data) > 0 {
+			v.fail("no hash chain: the log was written before chaining was enabled or the chain was removed")
+		}
+		v.Valid = len(v.Errors) == 0
+		return v, nil
+	}
+	if cerr != nil {
+		return nil, cerr
+	}
+
+	keys := map[string]bool{}
+	var prev string
+	var next int64 = -1
+	scanner := bufio.NewScanner(bytes.NewReader(chain))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var r LogChainRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			v.fail("chain line %d: unreadable record", line)
+			break
+		}
+
+		switch r.Type {
+		case chainEntry:
+			if r.Seq != v.Entries+1 {
+				v.fail("entry %d: out of sequence (expected %d)", r.Seq, v.Entries+1)
+			}
+			if r.Prev != prev {
+				v.fail("entry %d: does not link to the previous entry", r.Seq)
+			}
+			if entryHash(sessionID, r) != r.Hash {
+				v.fail("entry %d: record hash mismatch (record edited)", r.Seq)
+			}
+			switch {
+			case next < 0 && r.Offset > 0:
+				v.UnchainedPrefix = r.Offset
+				v.Warnings = append(v.Warnings, fmt.Sprintf("the first %d log bytes predate the hash chain and are not covered", r.Offset))
+			case next >= 0 && r.Offset != next:
+				v.fail("entry %d: starts at byte %d, expected %d (log bytes inserted or removed)", r.Seq, r.Offset, next)
+			}
+			if end := r.Offset + r.Length; end > int64(len(data)) {
+				v.fail("entry %d: log truncated (entry ends at byte %d of %d)", r.Seq, end, len(data))
+			} else if sha256Hex(data[r.Offset:end]) != r.EntrySHA256 {
+				v.fail("entry %d: log bytes %d-%d were modified", r.Seq, r.Offset, end)
+			}
+			v.Entries++
+			prev = r.Hash
+			next = r.Offset + r.Length
+
+		case chainCheckpoint:
+			v.Checkpoints++
+			if r.Seq != v.Entries || r.Hash != prev {
+				v.fail("checkpoint at entry %d: does not match the chain", r.Seq)
+				continue
+			}
+			pub, err := hex.DecodeString(r.PublicKey)
+			if err != nil || len(pub) != ed25519.PublicKeySize {
+				v.fail("checkpoint at entry %d: invalid public key", r.Seq)
+				continue
+			}
+			sig, _ := hex.DecodeString(r.Signature)
+			if !ed25519.Verify(pub, checkpointMessage(sessionID, r), sig) {
+				v.fail("checkpoint at entry %d: invalid signature", r.Seq)
+				continue
+			}
+			if trustedKey != "" && !strings.EqualFold(r.PublicKey, trustedKey) {
+				v.fail("checkpoint at entry %d: signed by untrusted key %s", r.Seq, r.PublicKey)
+				continue
+			}
+			if !keys[r.PublicKey] {
+				keys[r.PublicKey] = true
+				v.PublicKeys = append(v.PublicKeys, r.PublicKey)
+			}
+			v.LastCheckpoint = r.Seq
+
+		default:
+			v.fail("chain line %d: unknown record type %q", line, r.Type)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if next < 0 {
+		next = 0
+	}
+	if extra := int64(len(data)) - next; extra > 0 && v.Entries > 0 {
+		v.fail("%d log bytes were appended outside the hash chain", extra)
+	} else if v.Entries == 0 && len(data) > 0 {
+		v.fail("the hash chain is empty but the log is not")
+	}
+
+	switch {
+	case trustedKey != "" && v.LastCheckpoint == 0 && v.Entries > 0:
+		v.fail("no checkpoint signed by the trusted key")
+	case v.Checkpoints == 0 && v.Entries > 0:
+		v.Warnings = append(v.Warnings, "no signed checkpoints: the chain proves internal consistency only")
+	case trustedKey == "" && v.Checkpoints > 0:
+		v.Warnings = append(v.Warnings, "checkpoint signatures were checked against the keys they carry; pass the deployment's public key to pin it")
+	}
+	if v.LastCheckpoint > 0 && v.LastCheckpoint < v.Entries {
+		v.Warnings = append(v.Warnings, fmt.Sprintf("entries after %d are not covered by a signed checkpoint", v.LastCheckpoint))
+	}
+
+	v.Head = prev
+	v.Valid = len(v.Errors) == 0
+	return v, nil
+}
+
+// handleVerifyLog serves GET /sessions/{id}/log/verify[?public_key=<hex>]
+func handleVerifyLog(w http.ResponseWriter, r *http.Request) {
+	result, err := sessionsFor(r.Context()).VerifyLog(r.PathValue("id"), r.URL.Query().Get("public_key"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+var logVerifyCmd = &cobra.Command{
+	Use:   "verify <session-id>",
+	Short: "Check that a session log hasn't been edited",
+	Long: `Check a session log against its hash chain: every entry must be present,
+unmodified and in order, and signed checkpoints must verify. Exits non-zero
+if the log was tampered with.
+
+Examples:
+  j0 log verify sess-abc123
+  j0 log verify sess-abc123 --public-key 3b6a27bc...`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		publicKey, _ := cmd.Flags().GetString("public-key")
+		asJSON, _ := cmd.Flags().GetBool("json")
+		result, err := sessionManager.VerifyLog(args[0], publicKey)
+		if err != nil {
+			return err
+		}
+
+		if asJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(result); err != nil {
+				return err
+			}
+		} else {
+			fmt.Printf("Entries:     %d (%d bytes)\n", result.Entries, result.LogBytes)
+			fmt.Printf("Checkpoints: %d", result.Checkpoints)
+			if result.LastCheckpoint > 0 {
+				fmt.Printf(" (last signed at entry %d)", result.LastCheckpoint)
+			}
+			fmt.Println()
+			if result.Head != "" {
+				fmt.Printf("Head:        %s\n", result.Head)
+			}
+			for _, w := range result.Warnings {
+				fmt.Printf("[warning] %s\n", w)
+			}
+			for _, e := range result.Errors {
+				fmt.Printf("[error] %s\n", e)
+			}
+		}
+
+		if !result.Valid {
+			return fmt.Errorf("log verification failed for %s", args[0])
+		}
+		if !asJSON {
+			fmt.Println("OK")
+		}
+		return nil
+	},
+}
+
+var logKeygenCmd = &cobra.Command{
+	Use:   "keygen <key-file>",
+	Short: "Generate a key for signing log checkpoints",
+	Long: `Write a new Ed25519 signing key to <key-file> and print its public key.
+Start j0 with --log-signing-key <key-file> (or ` + logSigningKeyEnv + `) to sign
+checkpoints, and give auditors the public key for j0 log verify --public-key.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		seed := make([]byte, ed25519.SeedSize)
+		if _, err := rand.Read(seed); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(args[0], os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			return fmt.Errorf("failed to create key file: %w", err)
+		}
+		defer f.Close()
+		if _, err := fmt.Fprintln(f, hex.EncodeToString(seed)); err != nil {
+			return err
+		}
+
+		key := ed25519.NewKeyFromSeed(seed)
+		fmt.Println(hex.EncodeToString(key.Public().(ed25519.PublicKey)))
+		return nil
+	},
+}
+
+func init() {
+	logVerifyCmd.Flags().String("public-key", "", "Require checkpoints signed by this Ed25519 public key (hex)")
+	logVerifyCmd.Flags().Bool("json", false, "Output as JSON")
+	logCmd.AddCommand(logVerifyCmd, logKeygenCmd)
+}
+
+// checkpointOnClose signs a closing session's chain head
+func (sm *SessionManager) checkpointOnClose(session *Session) {
+	if err := sm.checkpointLog(session, "close"); err != nil {
+		log.Printf("Warning: failed to checkpoint log for %s: %v", session.ID, err)
+	}
+}
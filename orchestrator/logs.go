@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// logPollInterval is how often followed log files are checked for new data
+const logPollInterval = 500 * time.Millisecond
+
+// tailChunkSize is the block size used when scanning a log backwards
+const tailChunkSize = 64 * 1024
+
+// tailLines returns the last n lines of a file, reading backwards from the
+// end so only the needed tail is loaded into memory
+func tailLines(path string, n int) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	pos := info.Size()
+	var tail []byte
+	newlines := 0
+
+	for pos > 0 && newlines < n {
+		size := int64(tailChunkSize)
+		if pos < size {
+			size = pos
+		}
+		pos -= size
+
+		chunk := make([]byte, size)
+		if _, err := f.ReadAt(chunk, pos); err != nil && err != io.EOF {
+			return "", err
+		}
+
+		// A trailing newline terminates the last line rather than starting a new one
+		if tail == nil && bytes.HasSuffix(chunk, []byte("\n")) {
+			newlines--
+		}
+		newlines += bytes.Count(chunk, []byte("\n"))
+		tail = append(chunk, tail...)
+	}
+
+	// Drop everything before the start of the n-th line from the end
+	for newlines >= n {
+		i := bytes.IndexByte(tail, '\n')
+		if i < 0 {
+			break
+		}
+		tail = tail[i+1:]
+		newlines--
+	}
+
+	return string(tail), nil
+}
+
+// readLogRange reads up to limit bytes starting at offset
+func readLogRange(path string, offset, limit int64) (*LogPage, error) {
+	if offset < 0 || limit <= 0 {
+		return nil, fmt.Errorf("invalid offset or limit")
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	size := info.Size()
+	if offset > size {
+		offset = size
+	}
+	if offset+limit > size {
+		limit = size - offset
+	}
+
+	data := make([]byte, limit)
+	n, err := f.ReadAt(data, offset)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return &LogPage{
+		Data:       string(data[:n]),
+		Offset:     offset,
+		NextOffset: offset + int64(n),
+		Size:       size,
+	}, nil
+}
+
+// LogMatch is a log line matching a search, with surrounding context
+type LogMatch struct {
+	Line   int      `json:"line"`
+	Text   string   `json:"text"`
+	Before []string `json:"before,omitempty"`
+	After  []string `json:"after,omitempty"`
+}
+
+// searchLog scans a log file line by line and returns up to limit matches,
+// each with contextLines lines of surrounding context
+func searchLog(path string, match func(string) bool, contextLines, limit int) ([]LogMatch, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	matches := []LogMatch{}
+	var before []string
+	var pending []int // indexes of matches still collecting trailing context
+
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+
+		// Feed trailing context to earlier matches
+		still := pending[:0]
+		for _, i := range pending {
+			matches[i].After = append(matches[i].After, line)
+			if len(matches[i].After) < contextLines {
+				still = append(still, i)
+			}
+		}
+		pending = still
+
+		if len(matches) < limit && match(line) {
+			matches = append(matches, LogMatch{
+				Line:   lineNo,
+				Text:   line,
+				Before: append([]string(nil), before...),
+			})
+			if contextLines > 0 {
+				pending = append(pending, len(matches)-1)
+			}
+		}
+
+		if contextLines > 0 {
+			before = append(before, line)
+			if len(before) > contextLines {
+				before = before[1:]
+			}
+		}
+
+		if len(matches) >= limit && len(pending) == 0 {
+			break
+		}
+	}
+
+	return matches, scanner.Err()
+}
+
+// handleSearchLog serves GET /sessions/{id}/log/search?q=...&regex=true&context=2&limit=100
+func handleSearchLog(w http.ResponseWriter, r *http.Request) {
+	session, err := sessionsFor(r.Context()).GetSession(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	query := r.URL.Query()
+	q := query.Get("q")
+	if q == "" {
+		http.Error(w, "q is required", http.StatusBadRequest)
+		return
+	}
+
+	match := func(line string) bool { return strings.Contains(line, q) }
+	if query.Get("regex") == "true" {
+		re, err := regexp.Compile(q)
+		if err != nil {
+			http.Error(w, "invalid regex: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		match = re.MatchString
+	}
+
+	contextLines, limit := 2, 100
+	if v := query.Get("context"); v != "" {
+		if contextLines, err = strconv.Atoi(v); err != nil || contextLines < 0 {
+			http.Error(w, "invalid context", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := query.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	matches, err := searchLog(session.LogFile, match, contextLines, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"query":   q,
+		"matches": matches,
+	})
+}
+
+// followLog polls a log file starting at offset and passes every newly
+// appended chunk to fn until ctx is cancelled or fn returns an error.
+func followLog(ctx context.Context, path string, offset int64, fn func([]byte) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(logPollInterval)
+	defer ticker.Stop()
+
+	buf := make([]byte, 32*1024)
+	for {
+		for {
+			n, err := f.Read(buf)
+			if n > 0 {
+				if err := fn(buf[:n]); err != nil {
+					return err
+				}
+			}
+			if err == io.EOF || n == 0 {
+				break
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// logSize returns the current size of a log file
+func logSize(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// handleFollowLog streams newly appended log data as SSE "log" events
+func handleFollowLog(w http.ResponseWriter, r *http.Request, session *Session) {
+	sse, err := newSSEWriter(w)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Start at the end so only live activity is streamed
+	offset := logSize(session.LogFile)
+	sse.start()
+	sse.flusher.Flush()
+
+	followLog(r.Context(), session.LogFile, offset, func(chunk []byte) error {
+		return sse.Event("log", map[string]string{"data": string(chunk)})
+	})
+}
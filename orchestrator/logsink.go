@@ -0,0 +1,356 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LogSink stores session log entries.
+// The local log file is always kept so tail, follow and search keep working;
+// remote sinks replicate it so logs survive container restarts.
+type LogSink interface {
+	// Create initializes an empty log for a new session
+	Create(session *Session) error
+	// Append adds an entry to the session log
+	Append(session *Session, entry string) error
+	// Location returns the remote log location, or "" for local-only sinks
+	Location(session *Session) string
+	// Restore recreates a missing local log from the remote copy
+	Restore(session *Session) error
+}
+
+// FileLogSink writes logs to local files only
+type FileLogSink struct{}
+
+// Create writes an empty log file
+func (FileLogSink) Create(session *Session) error {
+	return os.WriteFile(session.LogFile, []byte{}, 0644)
+}
+
+// Append appends an entry to the log file
+func (FileLogSink) Append(session *Session, entry string) error {
+	f, err := os.OpenFile(session.LogFile, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	defer f.Close()
+
+	_, err = f.WriteString(entry)
+	return err
+}
+
+// Location is empty for local logs
+func (FileLogSink) Location(session *Session) string {
+	return ""
+}
+
+// Restore is a no-op for local logs
+func (FileLogSink) Restore(session *Session) error {
+	return nil
+}
+
+// S3Config configures an S3 or MinIO log bucket
+type S3Config struct {
+	Endpoint  string // e.g. https://s3.us-east-1.amazonaws.com or http://minio:9000
+	Region    string
+	Bucket    string
+	Prefix    string
+	AccessKey string
+	SecretKey string
+	PathStyle bool // required by most MinIO deployments
+}
+
+// S3LogSink mirrors every log to an S3-compatible object store. Objects
+// are keyed by tenant and session so tenants sharing a bucket never
+// overwrite each other's logs.
+type S3LogSink struct {
+	FileLogSink
+	config     S3Config
+	httpClient *http.Client
+	// tenant scopes object keys; "" is the default data directory
+	tenant  string
+	uploads *s3Uploads
+}
+
+// s3Uploads replicates logs in the background so appends never wait on
+// S3. Appends that arrive while a log is uploading are coalesced into its
+// next upload.
+type s3Uploads struct {
+	mu        sync.Mutex
+	pending   map[string]string // object key -> local log file
+	uploading bool
+	idle      *sync.Cond
+	wake      chan struct{}
+}
+
+func (u *s3Uploads) queue(key, logFile string) {
+	u.mu.Lock()
+	u.pending[key] = logFile
+	u.mu.Unlock()
+	select {
+	case u.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run uploads queued logs until the process exits
+func (u *s3Uploads) run(s *S3LogSink) {
+	for range u.wake {
+		u.mu.Lock()
+		batch := u.pending
+		u.pending = make(map[string]string)
+		u.uploading = true
+		u.mu.Unlock()
+
+		for key, logFile := range batch {
+			if err := s.upload(key, logFile); err != nil {
+				log.Printf("Warning: failed to upload log %s: %v", key, err)
+			}
+		}
+
+		u.mu.Lock()
+		u.uploading = false
+		if len(u.pending) == 0 {
+			u.idle.Broadcast()
+		}
+		u.mu.Unlock()
+	}
+}
+
+// Flush waits for queued uploads, so the CLI doesn't exit before its logs
+// are replicated
+func (s *S3LogSink) Flush() {
+	u := s.uploads
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for len(u.pending) > 0 || u.uploading {
+		u.idle.Wait()
+	}
+}
+
+// NewS3LogSink validates the config and creates the sink
+func NewS3LogSink(config S3Config) (*S3LogSink, error) {
+	if config.Bucket == "" {
+		return nil, fmt.Errorf("s3 bucket is required")
+	}
+	if config.AccessKey == "" || config.SecretKey == "" {
+		return nil, fmt.Errorf("s3 credentials are required (AWS_ACCESS_KEY_ID / AWS_SECRET_ACCESS_KEY)")
+	}
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+	if config.Endpoint == "" {
+		config.Endpoint = "https://s3." + config.Region + ".amazonaws.com"
+	}
+	config.Endpoint = strings.TrimSuffix(config.Endpoint, "/")
+
+	uploads := &s3Uploads{pending: make(map[string]string), wake: make(chan struct{}, 1)}
+	uploads.idle = sync.NewCond(&uploads.mu)
+	s := &S3LogSink{
+		config:     config,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		uploads:    uploads,
+	}
+	go uploads.run(s)
+	return s, nil
+}
+
+// ForTenant returns the sink for a tenant's sessions, sharing uploads
+func (s *S3LogSink) ForTenant(tenantID string) *S3LogSink {
+	scoped := *s
+	scoped.tenant = tenantID
+	return &scoped
+}
+
+// Create writes the local file and queues an empty remote object
+func (s *S3LogSink) Create(session *Session) error {
+	if err := s.FileLogSink.Create(session); err != nil {
+		return err
+	}
+	s.uploads.queue(s.key(session), session.LogFile)
+	return nil
+}
+
+// Append writes locally and queues an upload of the full log.
+// S3 has no append operation, so the object is replaced each time.
+func (s *S3LogSink) Append(session *Session, entry string) error {
+	if err := s.FileLogSink.Append(session, entry); err != nil {
+		return err
+	}
+	s.uploads.queue(s.key(session), session.LogFile)
+	return nil
+}
+
+// Location returns the s3:// URI of the session log
+func (s *S3LogSink) Location(session *Session) string {
+	return "s3://" + s.config.Bucket + "/" + s.key(session)
+}
+
+// Restore downloads the remote log when the local copy is missing
+func (s *S3LogSink) Restore(session *Session) error {
+	if _, err := os.Stat(session.LogFile); err == nil {
+		return nil
+	}
+
+	resp, err := s.do("GET", s.key(session), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("s3 download failed: %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(session.LogFile, data, 0644)
+}
+
+// key returns <prefix>/<tenant>/<session>/<log file>
+func (s *S3LogSink) key(session *Session) string {
+	tenant := s.tenant
+	if tenant == "" {
+		tenant = "default"
+	}
+	key := tenant + "/" + session.ID + "/" + filepath.Base(session.LogFile)
+	if s.config.Prefix != "" {
+		key = strings.TrimSuffix(s.config.Prefix, "/") + "/" + key
+	}
+	return key
+}
+
+func (s *S3LogSink) upload(key, logFile string) error {
+	data, err := os.ReadFile(logFile)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.do("PUT", key, data)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("s3 upload failed: %s", resp.Status)
+	}
+	return nil
+}
+
+// do sends a SigV4-signed request for an object key
+func (s *S3LogSink) do(method, key string, body []byte) (*http.Response, error) {
+	base, err := url.Parse(s.config.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid s3 endpoint: %w", err)
+	}
+
+	objectPath := "/" + s3EscapePath(key)
+	if s.config.PathStyle {
+		objectPath = "/" + s.config.Bucket + objectPath
+	} else {
+		base.Host = s.config.Bucket + "." + base.Host
+	}
+	base.Path = objectPath
+
+	req, err := http.NewRequest(method, base.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	// Keep the escaped path exactly as signed
+	req.URL.RawPath = objectPath
+
+	s.sign(req, body, time.Now().UTC())
+	return s.httpClient.Do(req)
+}
+
+// sign applies AWS Signature Version 4 headers to the request
+func (s *S3LogSink) sign(req *http.Request, body []byte, now time.Time) {
+	payloadHash := sha256Hex(body)
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{
+		"host":                 req.URL.Host,
+		"x-amz-content-sha256": payloadHash,
+		"x-amz-date":           amzDate,
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.config.Region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.config.SecretKey), date)
+	key = hmacSHA256(key, s.config.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.config.AccessKey, scope, signedHeaders, signature,
+	))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3EscapePath URI-encodes each segment of an object key as SigV4 requires
+func s3EscapePath(key string) string {
+	segments := strings.Split(key, "/")
+	for i, seg := range segments {
+		segments[i] = strings.ReplaceAll(url.PathEscape(seg), "+", "%2B")
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Long-poll bounds: a single request waits at most maxLongPollWait, and an
+// execution started by one runs for up to longPollExecTimeout unless the
+// request sets its own timeout
+const (
+	maxLongPollWait     = 5 * time.Minute
+	longPollExecTimeout = 10 * time.Minute
+	// pendingRetention keeps outcomes of long-polled executions, including
+	// failures that never reach the history, for clients that resume late
+	pendingRetention = 10 * time.Minute
+)
+
+// pendingExecution is a long-polled execution that may outlive the request
+// that started it
+type pendingExecution struct {
+	done chan struct{}
+	exec *Execution
+	err  error
+}
+
+var pendingExecutions = struct {
+	sync.Mutex
+	m map[string]*pendingExecution
+}{m: make(map[string]*pendingExecution)}
+
+func pendingKey(sessionID, execID string) string {
+	return sessionID + "/" + execID
+}
+
+func lookupPending(sessionID, execID string) *pendingExecution {
+	pendingExecutions.Lock()
+	defer pendingExecutions.Unlock()
+	return pendingExecutions.m[pendingKey(sessionID, execID)]
+}
+
+// parseWait reads ?wait= as a duration ("120s") or seconds ("120")
+func parseWait(value string) (time.Duration, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		secs, serr := strconv.ParseFloat(value, 64)
+		if serr != nil {
+			return 0, fmt.Errorf("invalid wait %q", value)
+		}
+		d = seconds(secs)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("invalid wait %q", value)
+	}
+	if d > maxLongPollWait {
+		d = maxLongPollWait
+	}
+	return d, nil
+}
+
+// setLongPollHeaders asks intermediaries to keep the connection open for
+// the whole wait
+func setLongPollHeaders(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("Keep-Alive", fmt.Sprintf("timeout=%d", int(wait.Seconds())+5))
+	w.Header().Set("Cache-Control", "no-store")
+}
+
+// writeStillRunning answers a long poll that timed out with the execution
+// ID to resume from
+func writeStillRunning(w http.ResponseWriter, sessionID, execID string, wait time.Duration) {
+	poll := fmt.Sprintf("/v1/sessions/%s/executions/%s?wait=%s", sessionID, execID, wait)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", poll)
+	w.Header().Set("Retry-After", "0")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{
+		"execution_id": execID,
+		"status":       "running",
+		"poll_url":     poll,
+	})
+}
+
+// handleExecuteLongPoll serves POST /sessions/{id}/execute?wait=<duration>.
+// The execution runs detached from the request; if it hasn't finished when
+// the wait expires the client gets 202 with the execution ID and resumes
+// with GET /sessions/{id}/executions/{exec_id}?wait=<duration>.
+func handleExecuteLongPoll(w http.ResponseWriter, r *http.Request, sessionID string, req ExecRequest, wait time.Duration) {
+	if req.Timeout == 0 {
+		req.Timeout = longPollExecTimeout
+	}
+
+	started := make(chan string, 1)
+	onStart := req.OnStart
+	req.OnStart = func(execID string) {
+		if onStart != nil {
+			onStart(execID)
+		}
+		started <- execID
+	}
+
+	p := &pendingExecution{done: make(chan struct{})}
+	ctx := context.WithoutCancel(r.Context())
+	go func() {
+		p.exec, p.err = runExecution(ctx, sessionID, req)
+		close(p.done)
+	}()
+
+	setLongPollHeaders(w, wait)
+
+	// Executions rejected before they start (inactive session, busy strict
+	// session) fail without an ID
+	var execID string
+	select {
+	case execID = <-started:
+	case <-p.done:
+		writeLongPollResult(w, r, p)
+		return
+	}
+
+	pendingExecutions.Lock()
+	pendingExecutions.m[pendingKey(sessionID, execID)] = p
+	pendingExecutions.Unlock()
+	go func() {
+		<-p.done
+		time.AfterFunc(pendingRetention, func() {
+			pendingExecutions.Lock()
+			delete(pendingExecutions.m, pendingKey(sessionID, execID))
+			pendingExecutions.Unlock()
+		})
+	}()
+
+	awaitPending(w, r, p, sessionID, execID, wait)
+}
+
+// awaitPending waits up to wait for a pending execution to finish
+func awaitPending(w http.ResponseWriter, r *http.Request, p *pendingExecution, sessionID, execID string, wait time.Duration) {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-p.done:
+		writeLongPollResult(w, r, p)
+	case <-timer.C:
+		writeStillRunning(w, sessionID, execID, wait)
+	case <-r.Context().Done():
+		// The client went away; the execution keeps running
+	}
+}
+
+func writeLongPollResult(w http.ResponseWriter, r *http.Request, p *pendingExecution) {
+	if p.err != nil {
+		writeExecuteError(w, p.err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(executionResponse(p.exec, requestLocale(r)))
+}
+
+// resumeLongPoll handles GET /sessions/{id}/executions/{exec_id}?wait= for
+// executions started by a long poll. It returns false when the execution
+// is not pending so the stored record is served instead.
+func resumeLongPoll(w http.ResponseWriter, r *http.Request, sessionID, execID string) bool {
+	p := lookupPending(sessionID, execID)
+	if p == nil {
+		return false
+	}
+
+	wait := time.Duration(0)
+	if v := r.URL.Query().Get("wait"); v != "" {
+		var err error
+		if wait, err = parseWait(v); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return true
+		}
+	}
+
+	select {
+	case <-p.done:
+		if p.err == nil && r.URL.Query().Get("wait") == "" {
+			// Finished: the history has the full record
+			return false
+		}
+		writeLongPollResult(w, r, p)
+		return true
+	default:
+	}
+
+	if wait == 0 {
+		writeStillRunning(w, sessionID, execID, maxLongPollWait)
+		return true
+	}
+	setLongPollHeaders(w, wait)
+	awaitPending(w, r, p, sessionID, execID, wait)
+	return true
+}
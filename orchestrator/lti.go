@@ -0,0 +1,421 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LTI 1.3 tool support: OIDC login initiation, resource link launches that
+// map an LTI user + resource link onto an orchestrator session, and grade
+// passback through Assignment and Grade Services (AGS).
+
+// LTI claim names
+const (
+	ltiClaimMessageType  = "https://purl.imsglobal.org/spec/lti/claim/message_type"
+	ltiClaimDeploymentID = "https://purl.imsglobal.org/spec/lti/claim/deployment_id"
+	ltiClaimResourceLink = "https://purl.imsglobal.org/spec/lti/claim/resource_link"
+	ltiClaimCustom       = "https://purl.imsglobal.org/spec/lti/claim/custom"
+	ltiClaimAGSEndpoint  = "https://purl.imsglobal.org/spec/lti-ags/claim/endpoint"
+	ltiScopeScore        = "https://purl.imsglobal.org/spec/lti-ags/scope/score"
+)
+
+// LTIConfig describes the registration with an LTI platform (Canvas, Moodle, ...)
+type LTIConfig struct {
+	Issuer          string `json:"issuer"`
+	ClientID        string `json:"client_id"`
+	DeploymentID    string `json:"deployment_id"`
+	AuthLoginURL    string `json:"auth_login_url"`
+	AuthTokenURL    string `json:"auth_token_url"`
+	KeySetURL       string `json:"keyset_url"`
+	LaunchURL       string `json:"launch_url"`
+	PrivateKeyPath  string `json:"private_key"`
+	KeyID           string `json:"key_id"`
+	DefaultLanguage string `json:"default_language"`
+}
+
+// LTITool handles LTI launches and grade passback
+type LTITool struct {
+	config     LTIConfig
+	keys       *JWKS
+	privateKey *rsa.PrivateKey
+	httpClient *http.Client
+
+	// state -> nonce for pending OIDC logins
+	pending map[string]ltiLogin
+	mu      sync.Mutex
+}
+
+type ltiLogin struct {
+	nonce   string
+	expires time.Time
+}
+
+// ltiStateCookie binds a pending login to the browser that started it.
+// The platform posts the launch cross-site, so the cookie must be
+// SameSite=None.
+const ltiStateCookie = "j0_lti_state"
+
+// NewLTITool loads an LTI registration from a JSON config file
+func NewLTITool(configPath string) (*LTITool, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read LTI config: %w", err)
+	}
+
+	var config LTIConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse LTI config: %w", err)
+	}
+
+	if config.Issuer == "" || config.ClientID == "" || config.AuthLoginURL == "" || config.KeySetURL == "" {
+		return nil, fmt.Errorf("LTI config requires issuer, client_id, auth_login_url and keyset_url")
+	}
+	if config.DefaultLanguage == "" {
+		config.DefaultLanguage = "python"
+	}
+
+	tool := &LTITool{
+		config:     config,
+		keys:       NewJWKS(config.KeySetURL),
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		pending:    make(map[string]ltiLogin),
+	}
+
+	if config.PrivateKeyPath != "" {
+		tool.privateKey, err = loadRSAPrivateKey(config.PrivateKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load LTI private key: %w", err)
+		}
+	}
+
+	return tool, nil
+}
+
+// Register adds the LTI endpoints to the mux
+func (t *LTITool) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /lti/login", t.handleLogin)
+	mux.HandleFunc("POST /lti/login", t.handleLogin)
+	mux.HandleFunc("POST /lti/launch", t.handleLaunch)
+	mux.HandleFunc("GET /lti/jwks", t.handleJWKS)
+	mux.HandleFunc("POST /lti/sessions/{id}/grade", t.handleGrade)
+}
+
+// handleLogin answers the platform's third-party initiated login with an
+// OIDC authentication request redirect
+func (t *LTITool) handleLogin(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+
+	if r.Form.Get("iss") != t.config.Issuer {
+		http.Error(w, "unknown issuer", http.StatusBadRequest)
+		return
+	}
+
+	state := randomHex(16)
+	nonce := randomHex(16)
+
+	t.mu.Lock()
+	now := time.Now()
+	for k, l := range t.pending {
+		if now.After(l.expires) {
+			delete(t.pending, k)
+		}
+	}
+	t.pending[state] = ltiLogin{nonce: nonce, expires: now.Add(10 * time.Minute)}
+	t.mu.Unlock()
+
+	redirectURI := t.config.LaunchURL
+	if redirectURI == "" {
+		redirectURI = r.Form.Get("target_link_uri")
+	}
+
+	q := url.Values{}
+	q.Set("scope", "openid")
+	q.Set("response_type", "id_token")
+	q.Set("response_mode", "form_post")
+	q.Set("prompt", "none")
+	q.Set("client_id", t.config.ClientID)
+	q.Set("redirect_uri", redirectURI)
+	q.Set("login_hint", r.Form.Get("login_hint"))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	if hint := r.Form.Get("lti_message_hint"); hint != "" {
+		q.Set("lti_message_hint", hint)
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     ltiStateCookie,
+		Value:    state,
+		Path:     "/lti/",
+		MaxAge:   int((10 * time.Minute).Seconds()),
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteNoneMode,
+	})
+	http.Redirect(w, r, t.config.AuthLoginURL+"?"+q.Encode(), http.StatusFound)
+}
+
+// handleLaunch validates the id_token and returns the session for the
+// launching user and resource link, creating it on first launch
+func (t *LTITool) handleLaunch(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	state := r.PostForm.Get("state")
+	cookie, err := r.Cookie(ltiStateCookie)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		http.Error(w, "state does not match this browser's login", http.StatusBadRequest)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: ltiStateCookie, Path: "/lti/", MaxAge: -1, Secure: true, HttpOnly: true, SameSite: http.SameSiteNoneMode})
+
+	t.mu.Lock()
+	login, ok := t.pending[state]
+	delete(t.pending, state)
+	t.mu.Unlock()
+
+	if !ok || time.Now().After(login.expires) {
+		http.Error(w, "invalid or expired state", http.StatusBadRequest)
+		return
+	}
+
+	claims, err := t.validateLaunch(r.PostForm.Get("id_token"), login.nonce)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	userID := claims.String("sub")
+	resourceLink, _ := claims[ltiClaimResourceLink].(map[string]interface{})
+	linkID, _ := resourceLink["id"].(string)
+	if userID == "" || linkID == "" {
+		http.Error(w, "launch is missing user or resource link", http.StatusBadRequest)
+		return
+	}
+
+	language := t.config.DefaultLanguage
+	if custom, ok := claims[ltiClaimCustom].(map[string]interface{}); ok {
+		if l, ok := custom["language"].(string); ok && l != "" {
+			language = l
+		}
+	}
+	if _, err := GetLanguageID(language); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	session := sessionsFor(r.Context()).FindSession(func(s *Session) bool {
+		return s.Status == "active" &&
+			s.Metadata["lti_user"] == userID &&
+			s.Metadata["lti_resource_link"] == linkID
+	})
+
+	if session == nil {
+		metadata := map[string]string{
+			"lti_issuer":        t.config.Issuer,
+			"lti_user":          userID,
+			"lti_resource_link": linkID,
+		}
+		if ags, ok := claims[ltiClaimAGSEndpoint].(map[string]interface{}); ok {
+			if lineitem, ok := ags["lineitem"].(string); ok {
+				metadata["lti_lineitem"] = lineitem
+			}
+		}
+
+		title, _ := resourceLink["title"].(string)
+		session, err = sessionsFor(r.Context()).CreateSession(language, title, SessionOptions{Metadata: metadata})
+		if err != nil {
+			http.Error(w, err.Error(), createErrorStatus(err))
+			return
+		}
+		audit(withActor(r.Context(), "lti:"+metadata["lti_user"]), "session.create", session.ID, map[string]interface{}{
+			"language": language,
+			"via":      "lti",
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(session)
+}
+
+// validateLaunch verifies the id_token signature and LTI-required claims
+func (t *LTITool) validateLaunch(idToken, nonce string) (JWTClaims, error) {
+	if idToken == "" {
+		return nil, fmt.Errorf("id_token is required")
+	}
+
+	claims, err := verifyJWT(idToken, t.keys)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.String("iss") != t.config.Issuer {
+		return nil, fmt.Errorf("unexpected issuer: %s", claims.String("iss"))
+	}
+	if !claims.Audience(t.config.ClientID) {
+		return nil, fmt.Errorf("token audience does not include client_id")
+	}
+	if claims.String("nonce") != nonce {
+		return nil, fmt.Errorf("nonce mismatch")
+	}
+	if t.config.DeploymentID != "" && claims.String(ltiClaimDeploymentID) != t.config.DeploymentID {
+		return nil, fmt.Errorf("unknown deployment")
+	}
+	if claims.String(ltiClaimMessageType) != "LtiResourceLinkRequest" {
+		return nil, fmt.Errorf("unsupported message type: %s", claims.String(ltiClaimMessageType))
+	}
+
+	return claims, nil
+}
+
+// handleJWKS publishes the tool's public key for the platform
+func (t *LTITool) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	keys := []map[string]string{}
+	if t.privateKey != nil {
+		keys = append(keys, publicJWK(&t.privateKey.PublicKey, t.config.KeyID))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+}
+
+// handleGrade posts a score for the session's LTI user back to the
+// platform. Scores are signed with the tool's key, so only authenticated
+// admins may post them.
+func (t *LTITool) handleGrade(w http.ResponseWriter, r *http.Request) {
+	if principalFromContext(r.Context()) == "" || !hasRole(r.Context(), RoleAdmin) {
+		http.Error(w, "grade passback requires admin credentials", http.StatusForbidden)
+		return
+	}
+	session, err := sessionsFor(r.Context()).GetSession(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	var req struct {
+		Score   float64 `json:"score"`
+		Maximum float64 `json:"maximum"`
+		Comment string  `json:"comment,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Maximum <= 0 {
+		http.Error(w, "maximum must be positive", http.StatusBadRequest)
+		return
+	}
+
+	if err := t.PostScore(session, req.Score, req.Maximum, req.Comment); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// PostScore sends a score to the AGS line item recorded on the session
+func (t *LTITool) PostScore(session *Session, score, maximum float64, comment string) error {
+	lineitem := session.Metadata["lti_lineitem"]
+	if lineitem == "" {
+		return fmt.Errorf("session has no LTI line item")
+	}
+
+	token, err := t.accessToken(ltiScopeScore)
+	if err != nil {
+		return fmt.Errorf("failed to obtain AGS token: %w", err)
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"userId":           session.Metadata["lti_user"],
+		"scoreGiven":       score,
+		"scoreMaximum":     maximum,
+		"comment":          comment,
+		"activityProgress": "Completed",
+		"gradingProgress":  "FullyGraded",
+		"timestamp":        time.Now().Format(time.RFC3339),
+	})
+	if err != nil {
+		return err
+	}
+
+	// The scores endpoint is the line item URL with /scores appended to its path
+	scoresURL := lineitem + "/scores"
+	if i := strings.Index(lineitem, "?"); i >= 0 {
+		scoresURL = lineitem[:i] + "/scores" + lineitem[i:]
+	}
+
+	req, err := http.NewRequest("POST", scoresURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/vnd.ims.lis.v1.score+json")
+
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("score submission failed: %s", resp.Status)
+	}
+	return nil
+}
+
+// accessToken obtains an OAuth2 token using a signed client assertion
+func (t *LTITool) accessToken(scope string) (string, error) {
+	if t.privateKey == nil || t.config.AuthTokenURL == "" {
+		return "", fmt.Errorf("LTI config requires private_key and auth_token_url for grade passback")
+	}
+
+	now := time.Now()
+	assertion, err := signJWT(JWTClaims{
+		"iss": t.config.ClientID,
+		"sub": t.config.ClientID,
+		"aud": t.config.AuthTokenURL,
+		"iat": now.Unix(),
+		"exp": now.Add(5 * time.Minute).Unix(),
+		"jti": generateID("jti"),
+	}, t.privateKey, t.config.KeyID)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("client_assertion_type", "urn:ietf:params:oauth:client-assertion-type:jwt-bearer")
+	form.Set("client_assertion", assertion)
+	form.Set("scope", scope)
+
+	resp, err := t.httpClient.PostForm(t.config.AuthTokenURL, form)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token request failed: %s", resp.Status)
+	}
+
+	var result struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	return result.AccessToken, nil
+}
@@ -1,11 +1,20 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -17,18 +26,63 @@ var (
 	dataDir    string
 	httpPort   int
 	verbose    bool
+
+	strictSessions bool
+	maxHistory     int
+	idleTimeout    time.Duration
+	sessionBudgets Budgets
+
+	logBackend string
+	s3Config   S3Config
+
+	webhookURLs     []string
+	webhookTemplate string
+	webhookRetries  int
+
+	otelEndpoint string
+	otelService  string
+
+	formatCodeDefault bool
+	formatterCommands map[string]string
+
+	languageConcurrency map[string]int
+	languageFallback    map[string]int
+
+	softLimitPercent float64
+
+	tenantID string
+
+	tlsOptions TLSOptions
 )
 
 // Global instances
 var (
-	sessionManager *SessionManager
-	judge0Client   *Judge0Client
+	sessionManager  *SessionManager
+	judge0Client    *Judge0Client
+	webhookNotifier *WebhookNotifier
+	problemStore    *ProblemStore
+	snapshotStore   *SnapshotStore
+	s3LogSink       *S3LogSink
 )
 
 func main() {
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+	// The first interrupt or SIGTERM cancels the command's context so
+	// Judge0 polls stop and serve shuts down; a second one kills j0
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	err := rootCmd.ExecuteContext(ctx)
+	stop()
+	heldLock.Release()
+	if err != nil {
+		command := rootCmd.Name()
+		if cmd, _, findErr := rootCmd.Find(os.Args[1:]); findErr == nil {
+			command = cmd.CommandPath()
+		}
+		os.Exit(reportError(os.Stderr, command, err))
 	}
 }
 
@@ -48,35 +102,288 @@ Examples:
   j0 serve                        # Start HTTP server
   j0 sessions create bash         # Create a bash session
   j0 exec <session-id> "echo hi"  # Execute code in session
-  j0 log <session-id> --follow    # Watch session output`,
+  j0 log <session-id> --follow    # Watch session output
+
+` + exitCodesHelp,
+	SilenceErrors: true,
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 		// Skip initialization for help commands
 		if cmd.Name() == "help" || cmd.Name() == "version" {
+			commandStarted = true
 			return nil
 		}
 
 		var err error
+		if err := sessionBudgets.Validate(); err != nil {
+			return err
+		}
+		if err := validateLocale(defaultLocale); err != nil {
+			return err
+		}
+		defaultLocale = matchLocale(defaultLocale)
+
+		if logCheckpointEvery < 0 {
+			return fmt.Errorf("invalid --log-checkpoint-every: %d", logCheckpointEvery)
+		}
+		if logSigningKeyFile != "" {
+			if logSigningKey, err = loadLogSigningKey(logSigningKeyFile); err != nil {
+				return err
+			}
+		}
+		if redactPatterns, err = compileRedactPatterns(redactPatternSpecs); err != nil {
+			return err
+		}
+		if cmd != envKeygenCmd {
+			if err := configureSecretKey(cmd.Context()); err != nil {
+				return err
+			}
+		}
+
+		// Only one process may write to a data directory at a time. A
+		// held lock isn't a usage mistake, so it isn't followed by usage.
+		readOnly := readOnlyCommands[cmd.CommandPath()]
+		if !readOnly {
+			cmd.SilenceUsage = true
+			addr := ""
+			if cmd == serveCmd {
+				addr = fmt.Sprintf(":%d", httpPort)
+			}
+			heldLock, err = acquireDataDirLock(dataDir, cmd.CommandPath(), addr, cmd == serveCmd && tlsOptions.Enabled())
+			var held *DataDirLockedError
+			switch {
+			case err == nil:
+			case errors.As(err, &held) && held.Owner.Command == "j0 serve" && serverForwardedCommands[cmd.CommandPath()]:
+				runningServer = held.Owner
+				readOnly = true
+			default:
+				return err
+			}
+		}
+
+		switch logBackend {
+		case "file":
+		case "s3":
+			s3Config.AccessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+			s3Config.SecretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+			s3LogSink, err = NewS3LogSink(s3Config)
+			if err != nil {
+				return fmt.Errorf("failed to configure s3 log backend: %w", err)
+			}
+		default:
+			return fmt.Errorf("unknown log backend: %s", logBackend)
+		}
+
+		if len(webhookURLs) > 0 {
+			webhookNotifier, err = NewWebhookNotifier(webhookURLs, webhookTemplate, webhookRetries)
+			if err != nil {
+				return err
+			}
+		}
+		if err := validateReportMode(closeReportMode); err != nil {
+			return err
+		}
+
+		// Applied to the default and every tenant session manager
+		setupSessions := func(tenantID string, sm *SessionManager) error {
+			sm.Strict = strictSessions
+			sm.SetMaxHistory(maxHistory)
+			if !readOnly {
+				sm.CloseExpiredExams()
+			}
+			if s3LogSink != nil {
+				sm.SetLogSink(s3LogSink.ForTenant(tenantID))
+			}
+			if webhookNotifier != nil {
+				sm.Subscribe(webhookNotifier.Notify)
+			}
+			if closeReportMode != "" {
+				sm.Subscribe(reportOnClose(sm, closeReportMode, webhookNotifier))
+			}
+			return nil
+		}
+
 		sessionManager, err = NewSessionManager(dataDir)
 		if err != nil {
 			return fmt.Errorf("failed to initialize session manager: %w", err)
 		}
+		setupSessions("", sessionManager)
+
+		tenantRegistry, err = NewTenantRegistry(dataDir, setupSessions)
+		if err != nil {
+			return err
+		}
+		if tenantID != "" {
+			sm, err := tenantRegistry.Sessions(tenantID)
+			if err != nil {
+				return err
+			}
+			sessionManager = sm
+			cmd.SetContext(withTenant(cmd.Context(), tenantID, sm))
+		}
+
+		problemStore, err = NewProblemStore(dataDir)
+		if err != nil {
+			return err
+		}
+
+		snapshotStore, err = NewSnapshotStore(dataDir)
+		if err != nil {
+			return err
+		}
+
+		if otelEndpoint != "" {
+			tracer = NewTracer(otelEndpoint, otelService)
+			go tracer.Run(cmd.Context(), 5*time.Second)
+		}
+
+		if err := configureFormatters(formatterCommands); err != nil {
+			return err
+		}
+		if err := configureLanguageConcurrency(languageConcurrency); err != nil {
+			return err
+		}
+		if err := configureLanguageFallbacks(languageFallback); err != nil {
+			return err
+		}
+		if err := configureCustomLanguages(languagesConfig); err != nil {
+			return err
+		}
+		if err := configureExecutionWindows(); err != nil {
+			return err
+		}
 
+		auditLog, err = NewAuditLog(dataDir)
+		if err != nil {
+			return err
+		}
+
+		deploymentBanner, err = loadBanner(dataDir)
+		if err != nil {
+			return err
+		}
+		if cmd != serveCmd {
+			if err := presentBanner(cmd.CommandPath(), readOnly); err != nil {
+				return err
+			}
+		}
+
+		// RapidAPI, or else a backend started by j0 backend up, is used
+		// unless --judge0-url is given
+		if !cmd.Flags().Changed("judge0-url") && rapidAPIKey != "" {
+			judge0URL = rapidAPIURL()
+		} else if !cmd.Flags().Changed("judge0-url") {
+			if state, err := loadBackendState(); err != nil {
+				return err
+			} else if state != nil {
+				judge0URL = state.URL
+			}
+		}
+		if err := pollStrategy.Validate(); err != nil {
+			return err
+		}
+		if defaultExecTimeout <= 0 {
+			return fmt.Errorf("invalid --exec-timeout: %s", defaultExecTimeout)
+		}
 		judge0Client = NewJudge0Client(judge0URL)
+		if len(judge0Backends) > 0 {
+			pool, err := newBackendPool(judge0Backends, judge0Balance)
+			if err != nil {
+				return err
+			}
+			judge0Client.pool = pool
+		}
+
+		// From here on errors come from the command itself: classify them
+		// by exit code and don't follow them with usage
+		commandStarted = true
+		cmd.SilenceUsage = true
 		return nil
 	},
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		// Let pending webhook deliveries finish before the CLI exits
+		if webhookNotifier != nil {
+			webhookNotifier.Wait()
+		}
+		if tracer != nil {
+			tracer.Flush()
+		}
+		if s3LogSink != nil {
+			s3LogSink.Flush()
+		}
+	},
 }
 
 func init() {
 	rootCmd.PersistentFlags().StringVar(&judge0URL, "judge0-url", "http://localhost:2358", "Judge0 API URL")
+	rootCmd.PersistentFlags().StringSliceVar(&judge0Backends, "judge0-backends", nil, "Comma-separated Judge0 API URLs to spread submissions over (overrides --judge0-url)")
+	rootCmd.PersistentFlags().StringVar(&judge0Balance, "judge0-balance", BalanceRoundRobin, "How submissions are spread over --judge0-backends: "+BalanceRoundRobin+" or "+BalanceLeastOutstanding)
+	rootCmd.PersistentFlags().BoolVar(&judge0Wait, "judge0-wait", false, "Run submissions with Judge0's synchronous wait=true mode, polling only if the backend rejects it or a queue timeout is set")
+	rootCmd.PersistentFlags().DurationVar(&pollStrategy.Interval, "poll-interval", pollStrategy.Interval, "Delay between Judge0 status polls")
+	rootCmd.PersistentFlags().Float64Var(&pollStrategy.Backoff, "poll-backoff", pollStrategy.Backoff, "Multiply the poll delay by this after each poll (1 = fixed interval)")
+	rootCmd.PersistentFlags().DurationVar(&pollStrategy.MaxInterval, "poll-max-interval", pollStrategy.MaxInterval, "Upper bound of the poll delay with --poll-backoff")
+	rootCmd.PersistentFlags().IntVar(&pollStrategy.MaxAttempts, "poll-max-attempts", 0, "Give up on a submission after this many polls (0 = until the deadline)")
+	rootCmd.PersistentFlags().DurationVar(&defaultExecTimeout, "exec-timeout", defaultExecTimeout, "Total deadline of an execution when the request doesn't set one")
+	rootCmd.PersistentFlags().StringVar(&judge0Auth.Token, "judge0-auth-token", os.Getenv(judge0AuthTokenEnv), "X-Auth-Token sent to a secured Judge0 (env "+judge0AuthTokenEnv+")")
+	rootCmd.PersistentFlags().StringVar(&judge0Auth.User, "judge0-auth-user", os.Getenv(judge0AuthUserEnv), "X-Auth-User sent to a secured Judge0 (env "+judge0AuthUserEnv+")")
+	rootCmd.PersistentFlags().StringVar(&rapidAPIKey, "rapidapi-key", os.Getenv(rapidAPIKeyEnv), "Use the Judge0 hosted on RapidAPI with this key (env "+rapidAPIKeyEnv+")")
+	rootCmd.PersistentFlags().StringVar(&rapidAPIHost, "rapidapi-host", defaultRapidAPIHost, "RapidAPI host of the hosted Judge0")
 	rootCmd.PersistentFlags().StringVar(&dataDir, "data-dir", "./data", "Directory for session data")
+	rootCmd.PersistentFlags().StringVar(&tenantID, "tenant", "", "Operate on this tenant's data instead of the default data directory")
 	rootCmd.PersistentFlags().IntVar(&httpPort, "port", 8080, "HTTP server port")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Verbose output")
+	rootCmd.PersistentFlags().BoolVar(&jsonErrors, "json-errors", false, "Write errors to stderr as JSON with a type and exit code")
+	rootCmd.PersistentFlags().IntVar(&maxHistory, "max-history-per-session", 0, "Executions kept in memory per session; older ones stay in the JSONL sidecar (0 keeps all)")
+	rootCmd.PersistentFlags().Float64Var(&sessionBudgets.MemorySeconds, "budget-memory-seconds", 0, "Per-session budget of cumulative memory MB-seconds (0 disables)")
+	rootCmd.PersistentFlags().Int64Var(&sessionBudgets.DiskBytes, "budget-disk-bytes", 0, "Per-session budget of log disk usage in bytes (0 disables)")
+	rootCmd.PersistentFlags().StringVar(&sessionBudgets.Action, "budget-action", "pause", "Action when a budget is exceeded: pause or close")
+	rootCmd.PersistentFlags().StringVar(&logBackend, "log-backend", "file", "Log storage backend: file or s3")
+	rootCmd.PersistentFlags().StringVar(&s3Config.Endpoint, "s3-endpoint", "", "S3/MinIO endpoint URL (default: AWS for --s3-region)")
+	rootCmd.PersistentFlags().StringVar(&s3Config.Region, "s3-region", "us-east-1", "S3 region")
+	rootCmd.PersistentFlags().StringVar(&s3Config.Bucket, "s3-bucket", "", "S3 bucket for session logs")
+	rootCmd.PersistentFlags().StringVar(&s3Config.Prefix, "s3-prefix", "logs", "Key prefix for session logs, stored as <prefix>/<tenant>/<session>/")
+	rootCmd.PersistentFlags().BoolVar(&s3Config.PathStyle, "s3-path-style", false, "Use path-style bucket addressing (MinIO)")
+	rootCmd.PersistentFlags().StringArrayVar(&webhookURLs, "webhook-url", nil, "URL notified on session creation and closure (repeatable)")
+	rootCmd.PersistentFlags().StringVar(&closeReportMode, "close-report", "", "Generate an activity report when sessions close: artifact, webhook or both")
+	rootCmd.PersistentFlags().StringVar(&webhookTemplate, "webhook-template", "", "Go text/template file used to render webhook payloads")
+	rootCmd.PersistentFlags().IntVar(&webhookRetries, "webhook-retries", 3, "Retries for failed webhook deliveries")
+	rootCmd.PersistentFlags().BoolVar(&strictSessions, "strict-sessions", false, "Reject executions while another is in flight in the same session")
+	rootCmd.PersistentFlags().StringVar(&otelEndpoint, "otel-endpoint", os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"), "OTLP/HTTP collector URL for trace export (tracing disabled if empty)")
+	rootCmd.PersistentFlags().BoolVar(&installDeps, "install-deps", false, "Install dependencies from requirements.txt, package.json or go.mod in session workspaces before running code (off by default; installers run arbitrary package scripts)")
+	rootCmd.PersistentFlags().Float64Var(&softLimitPercent, "soft-limit-percent", 80, "Warn when an execution uses this percentage of a CPU or memory limit (0 disables)")
+	rootCmd.PersistentFlags().BoolVar(&formatCodeDefault, "format-code", false, "Format code before executing and recording it (per-request \"format\" overrides)")
+	rootCmd.PersistentFlags().StringToIntVar(&languageConcurrency, "language-concurrency", nil, "Maximum concurrent submissions per language, e.g. rust=2,cpp=2 (others are unlimited)")
+	rootCmd.PersistentFlags().StringArrayVar(&executionWindowSpecs, "execution-windows", nil, "Weekly windows in which code may run, e.g. \"Mon-Fri 08:00-18:00\" (repeatable; default always)")
+	rootCmd.PersistentFlags().StringVar(&executionWindowTimezone, "execution-timezone", "", "IANA time zone of --execution-windows (default local time)")
+	rootCmd.PersistentFlags().StringVar(&languagesConfig, "languages-config", "", "JSON file registering custom language aliases for Judge0 language IDs, with optional code wrapper templates")
+	rootCmd.PersistentFlags().StringToIntVar(&languageFallback, "language-fallback", nil, "Language ID to use when the backend lacks one, e.g. 92=71,71=70 (chains are followed)")
+	rootCmd.PersistentFlags().StringToStringVar(&formatterCommands, "formatter", nil, "Formatter run in the sandbox per language, e.g. python=\"black -q -\" (go is builtin)")
+	rootCmd.PersistentFlags().StringVar(&bannerFile, "banner-file", "", "Usage policy banner shown on first use and by /capabilities (default: banner.txt in the data directory)")
+	rootCmd.PersistentFlags().BoolVar(&requirePolicyAck, "require-policy-ack", false, "Refuse to run code until the banner's usage policy is accepted")
+	rootCmd.PersistentFlags().BoolVar(&acceptPolicy, "accept-policy", false, "Accept the deployment's usage policy")
+	rootCmd.PersistentFlags().StringVar(&defaultLocale, "locale", "en", "Locale for status summaries when clients don't send Accept-Language (en, es, fr, de)")
+	rootCmd.PersistentFlags().StringVar(&logSigningKeyFile, "log-signing-key", os.Getenv(logSigningKeyEnv), "Ed25519 key file (from j0 log keygen) used to sign log hash chain checkpoints")
+	rootCmd.PersistentFlags().StringVar(&secretKeyFile, "secret-key", os.Getenv(secretKeyEnv), "AES-256 key file (from j0 env keygen) used to encrypt secret env vars")
+	rootCmd.PersistentFlags().StringVar(&secretKeyCommand, "secret-key-command", os.Getenv(secretKeyCommandEnv), "Shell command printing the secret key, e.g. to fetch it from a KMS")
+	rootCmd.PersistentFlags().StringArrayVar(&redactPatternSpecs, "redact-pattern", defaultRedactPatterns, "Regexp masked in execution output, history and logs (repeatable; replaces the built-in credential patterns)")
+	rootCmd.PersistentFlags().IntVar(&logCheckpointEvery, "log-checkpoint-every", 100, "Sign a log checkpoint every N entries, besides on close (0 = only on close)")
+	rootCmd.PersistentFlags().StringVar(&otelService, "otel-service-name", envOr("OTEL_SERVICE_NAME", "j0"), "Service name reported on exported spans")
 
 	rootCmd.AddCommand(serveCmd)
 	rootCmd.AddCommand(sessionsCmd)
 	rootCmd.AddCommand(execCmd)
 	rootCmd.AddCommand(logCmd)
 	rootCmd.AddCommand(aboutCmd)
+	rootCmd.AddCommand(problemsCmd)
+	rootCmd.AddCommand(analyticsCmd)
+	rootCmd.AddCommand(exportCmd)
+	rootCmd.AddCommand(maintenanceCmd)
+	rootCmd.AddCommand(fsCmd)
+	rootCmd.AddCommand(replayCmd)
+	rootCmd.AddCommand(tenantsCmd)
+	rootCmd.AddCommand(limitsCmd)
+	rootCmd.AddCommand(mcpServeCmd)
+	rootCmd.AddCommand(previewCmd)
+	rootCmd.AddCommand(backendCmd)
 }
 
 // serveCmd starts the HTTP server
@@ -84,39 +391,262 @@ var serveCmd = &cobra.Command{
 	Use:   "serve",
 	Short: "Start the HTTP API server",
 	RunE: func(cmd *cobra.Command, args []string) error {
+		// Structured JSON logs; log.Printf output is routed through the same handler
+		slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, nil)))
+
 		mux := http.NewServeMux()
+		executeLimiter = NewClientLimiter(executeRateLimit)
+		createLimiter = NewClientLimiter(createRateLimit)
 
 		// Session endpoints
-		mux.HandleFunc("POST /sessions", handleCreateSession)
+		mux.HandleFunc("POST /sessions", rateLimited(createLimiter, handleCreateSession))
 		mux.HandleFunc("GET /sessions", handleListSessions)
 		mux.HandleFunc("GET /sessions/{id}", handleGetSession)
-		mux.HandleFunc("POST /sessions/{id}/execute", handleExecute)
+		mux.HandleFunc("POST /sessions/{id}/execute", rateLimited(executeLimiter, handleExecute))
+		mux.HandleFunc("POST /sessions/{id}/preview", handlePreview)
+		mux.HandleFunc("GET /sessions/{id}/ws", handleSessionWebSocket)
 		mux.HandleFunc("GET /sessions/{id}/log", handleGetLog)
+		mux.HandleFunc("GET /sessions/{id}/log/search", handleSearchLog)
+		mux.HandleFunc("GET /sessions/{id}/log/verify", handleVerifyLog)
+		mux.HandleFunc("GET /sessions/{id}/executions", handleListExecutions)
+		mux.HandleFunc("GET /sessions/{id}/report", handleSessionReport)
+		mux.HandleFunc("GET /sessions/{id}/executions/{exec_id}", handleGetExecution)
+		mux.HandleFunc("POST /sessions/{id}/executions/{exec_id}/rerun", rateLimited(executeLimiter, handleRerunExecution))
+
+		// Workspace files
+		mux.HandleFunc("GET /sessions/{id}/files", handleListFiles)
+		mux.HandleFunc("GET /sessions/{id}/files/{path...}", handleReadFile)
+		mux.HandleFunc("PUT /sessions/{id}/files/{path...}", handleWriteFile)
+		mux.HandleFunc("DELETE /sessions/{id}/files/{path...}", handleDeleteFile)
+		mux.HandleFunc("POST /sessions/{id}/heartbeat", handleHeartbeat)
+		mux.HandleFunc("GET /sessions/{id}/motd", handleGetMOTD)
+		mux.HandleFunc("GET /sessions/{id}/environment", handleGetEnvironment)
+		mux.HandleFunc("POST /sessions/{id}/init", rateLimited(executeLimiter, handleRunInit))
+		mux.HandleFunc("GET /sessions/{id}/stdin-templates", handleListStdinTemplates)
+		mux.HandleFunc("PUT /sessions/{id}/stdin-templates/{name}", handlePutStdinTemplate)
+		mux.HandleFunc("DELETE /sessions/{id}/stdin-templates/{name}", handleDeleteStdinTemplate)
+		mux.HandleFunc("GET /sessions/{id}/defaults", handleGetDefaults)
+		mux.HandleFunc("PUT /sessions/{id}/defaults", handlePutDefaults)
+		mux.HandleFunc("DELETE /sessions/{id}/defaults", handleDeleteDefaults)
 		mux.HandleFunc("DELETE /sessions/{id}", handleCloseSession)
 
-		// Health check
+		// Anonymized usage analytics
+		mux.HandleFunc("GET /analytics/export", handleAnalyticsExport)
+
+		// Bulk JSONL export of sessions and executions
+		mux.HandleFunc("GET /export", handleExport)
+
+		// Public result snapshots
+		mux.HandleFunc("POST /executions/{id}/publish", handlePublishExecution)
+		mux.HandleFunc("GET /s/{id}", handleGetSnapshot)
+
+		// Problem endpoints
+		mux.HandleFunc("POST /problems", handleCreateProblem)
+		mux.HandleFunc("GET /problems", handleListProblems)
+		mux.HandleFunc("GET /problems/{id}", handleGetProblem)
+		mux.HandleFunc("POST /problems/{id}/testcases/import", handleImportTestCases)
+		mux.HandleFunc("PUT /problems/{id}/stdin-templates/{name}", handlePutProblemStdinTemplate)
+
+		// Health check: /health is liveness only, /health/ready probes dependencies
 		mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
 			json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 		})
+		mux.HandleFunc("GET /health/ready", handleHealthReady)
+		mux.HandleFunc("GET /capabilities", handleCapabilities)
+		mux.HandleFunc("GET /limits", handleLimits)
+		mux.HandleFunc("GET /statuses", handleListStatuses)
+
+		// Judge0 backend configuration, queues and statistics for operators
+		mux.HandleFunc("GET /judge0/config", handleJudge0Config)
+		mux.HandleFunc("GET /judge0/workers", handleJudge0Workers)
+		mux.HandleFunc("GET /judge0/statistics", handleJudge0Statistics)
+
+		// Read-only GraphQL queries over sessions and executions
+		mux.HandleFunc("GET /graphql", handleGraphQL)
+		mux.HandleFunc("POST /graphql", handleGraphQL)
+		mux.HandleFunc("GET /graphql/schema", handleGraphQLSchema)
+
+		// API description and interactive docs
+		mux.HandleFunc("GET /openapi.json", handleOpenAPI)
+		mux.HandleFunc("GET /docs", handleDocs)
+
+		// Aggregated status for UIs
+		mux.HandleFunc("GET /dashboard", handleDashboard)
+
+		// Maintenance mode and execution windows
+		mux.HandleFunc("GET /maintenance", handleGetMaintenance)
+		mux.HandleFunc("PUT /maintenance", handleSetMaintenance)
+
+		// Audit trail of state-changing operations
+		mux.HandleFunc("GET /audit", handleAuditQuery)
 
 		// MCP endpoints
 		SetupMCPEndpoints(mux)
 
-		addr := fmt.Sprintf(":%d", httpPort)
-		log.Printf("Starting server on %s", addr)
-		log.Printf("Judge0 URL: %s", judge0URL)
+		// LTI 1.3 tool endpoints
+		if ltiConfig, _ := cmd.Flags().GetString("lti-config"); ltiConfig != "" {
+			tool, err := NewLTITool(ltiConfig)
+			if err != nil {
+				return err
+			}
+			tool.Register(mux)
+		}
+
+		// Tenant admin API
+		SetupTenantEndpoints(mux)
+
+		// Judge0 result callbacks
+		if callbackURL, _ := cmd.Flags().GetString("judge0-callback-url"); callbackURL != "" {
+			maxAge, _ := cmd.Flags().GetDuration("callback-max-age")
+			receiver := NewCallbackReceiver(callbackURL, os.Getenv(callbackSecretEnv), maxAge)
+			receiver.RegisterRoutes(mux)
+			judge0Client.callbacks = receiver
+		}
+
+		// Background reapers run for the default and every tenant data root
+		startReapers := func(sm *SessionManager) {
+			// Pause or close sessions exceeding resource budgets
+			if sessionBudgets.Enabled() {
+				go sm.RunWatchdog(cmd.Context(), sessionBudgets, 30*time.Second)
+			}
+
+			// Close and archive exam sessions at their deadline
+			go sm.RunExamReaper(cmd.Context(), 10*time.Second)
+
+			// Close sessions with no executions or heartbeats within the idle timeout
+			if idleTimeout > 0 {
+				go sm.RunIdleReaper(cmd.Context(), idleTimeout, time.Minute)
+			}
+		}
+		if tenantID == "" {
+			startReapers(sessionManager)
+		}
+		tenantRegistry.OnOpen(startReapers)
+
+		keysFile, _ := cmd.Flags().GetString("api-keys")
+		keyRateLimit, _ := cmd.Flags().GetInt("api-key-rate-limit")
+		keys, err := LoadAPIKeys(keysFile, keyRateLimit)
+		if err != nil {
+			return err
+		}
+		apiKeys = keys
+		if issuer, _ := cmd.Flags().GetString("oidc-issuer"); issuer != "" {
+			audience, _ := cmd.Flags().GetString("oidc-audience")
+			jwksURL, _ := cmd.Flags().GetString("oidc-jwks-url")
+			rolesClaim, _ := cmd.Flags().GetString("oidc-roles-claim")
+			adminRole, _ := cmd.Flags().GetString("oidc-admin-role")
+			readOnlyRole, _ := cmd.Flags().GetString("oidc-read-only-role")
+			oidcAuth, err = NewOIDCAuth(issuer, audience, jwksURL, rolesClaim, adminRole, readOnlyRole)
+			if err != nil {
+				return err
+			}
+		}
+		if sunset, _ := cmd.Flags().GetString("legacy-api-sunset"); sunset != "" {
+			if legacySunset, err = time.Parse("2006-01-02", sunset); err != nil {
+				return fmt.Errorf("invalid --legacy-api-sunset: %w", err)
+			}
+		}
+
+		if err := validatePassthroughMode(judge0AuthPassthrough); err != nil {
+			return err
+		}
+
+		if apiKeys == nil && oidcAuth == nil {
+			log.Printf("Warning: no API keys or OIDC issuer configured; /sessions and /mcp are unauthenticated")
+		}
+
+		// Requests derive from the command's context, so shutting down
+		// cancels in-flight Judge0 polls
+		server := &http.Server{
+			Addr:        fmt.Sprintf(":%d", httpPort),
+			Handler:     withRequestID(traceHTTP(withAPIVersion(withAuth(withRBAC(withTenantScope(withSessionOwnership(withJudge0Passthrough(mux)))))))),
+			BaseContext: func(net.Listener) context.Context { return cmd.Context() },
+		}
+		if tlsOptions.Enabled() {
+			config, err := tlsOptions.Config()
+			if err != nil {
+				return err
+			}
+			server.TLSConfig = config
+		}
+
+		log.Printf("Starting server on %s", server.Addr)
+		if judge0Client.pool != nil {
+			log.Printf("Judge0 backends (%s): %s", judge0Balance, strings.Join(judge0Backends, ", "))
+			go judge0Client.pool.RunHealthChecks(cmd.Context(), backendProbeInterval)
+		} else {
+			log.Printf("Judge0 URL: %s", judge0URL)
+		}
 		log.Printf("Data directory: %s", dataDir)
+		if languageSyncInterval > 0 {
+			go RunLanguageSync(cmd.Context(), languageSyncInterval)
+		}
+
+		go func() {
+			<-cmd.Context().Done()
+			log.Printf("Shutting down")
+			ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+			defer cancel()
+			server.Shutdown(ctx)
+		}()
 
-		return http.ListenAndServe(addr, mux)
+		if server.TLSConfig != nil {
+			log.Printf("TLS enabled (client certificates: %s)", clientCertMode(tlsOptions))
+			// Certificates are already loaded into TLSConfig
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
 	},
 }
 
+// shutdownTimeout bounds how long serve waits for requests to finish
+// after an interrupt
+const shutdownTimeout = 10 * time.Second
+
+// clientCertMode describes mTLS settings for the startup log
+func clientCertMode(o TLSOptions) string {
+	if o.ClientCAFile == "" {
+		return "off"
+	}
+	return o.ClientAuth
+}
+
+func init() {
+	serveCmd.Flags().String("lti-config", "", "LTI 1.3 platform registration (JSON) enabling /lti endpoints")
+	serveCmd.Flags().DurationVar(&languageSyncInterval, "language-sync-interval", languageSyncInterval, "How often to refresh the language catalog from Judge0's /languages (0 disables syncing)")
+	serveCmd.Flags().DurationVar(&idleTimeout, "idle-timeout", 0, "Close sessions with no executions or heartbeats for this long (0 disables)")
+	serveCmd.Flags().String("legacy-api-sunset", "", "Date (YYYY-MM-DD) announced in Sunset headers on unprefixed routes, which alias /v1")
+	serveCmd.Flags().String("judge0-callback-url", "", "This server's base URL as reachable from Judge0 workers; enables signed result callbacks")
+	serveCmd.Flags().StringVar(&judge0AuthPassthrough, "judge0-auth-passthrough", PassthroughOff, "Forward clients' "+judge0TokenHeader+"/"+judge0UserHeader+" headers to Judge0: off, optional or required")
+	serveCmd.Flags().StringSliceVar(&wsAllowedOrigins, "ws-allowed-origins", nil, "Comma-separated origins, e.g. https://ide.example.com, allowed to open session WebSockets besides this server's own")
+	serveCmd.Flags().Duration("callback-max-age", 5*time.Minute, "Reject Judge0 callbacks whose signed URL is older than this")
+	serveCmd.Flags().String("api-keys", "", "JSON file of API keys required on all but the public routes (also read from "+apiKeysEnv+")")
+	serveCmd.Flags().Int("api-key-rate-limit", 120, "Default requests per minute per API key (0 = unlimited)")
+	serveCmd.Flags().IntVar(&executeRateLimit, "execute-rate-limit", 60, "Executions per minute per API key, user or client IP (0 = unlimited)")
+	serveCmd.Flags().IntVar(&createRateLimit, "create-rate-limit", 10, "Session creations per minute per API key, user or client IP (0 = unlimited)")
+	serveCmd.Flags().String("oidc-issuer", "", "Accept bearer JWTs from this OIDC issuer; the subject owns the sessions it creates")
+	serveCmd.Flags().String("oidc-audience", "", "Required aud claim for OIDC tokens")
+	serveCmd.Flags().String("oidc-jwks-url", "", "JWKS URL (default: discovered from the issuer)")
+	serveCmd.Flags().String("oidc-roles-claim", "roles", "Claim path holding the caller's roles (e.g. realm_access.roles)")
+	serveCmd.Flags().String("oidc-admin-role", "admin", "Role that may access and close every user's sessions")
+	serveCmd.Flags().String("oidc-read-only-role", "read-only", "Role limited to listing sessions and reading logs")
+	serveCmd.Flags().StringVar(&tlsOptions.CertFile, "tls-cert", "", "TLS certificate (PEM); serves HTTPS when set with --tls-key")
+	serveCmd.Flags().StringVar(&tlsOptions.KeyFile, "tls-key", "", "TLS private key (PEM)")
+	serveCmd.Flags().StringVar(&tlsOptions.ClientCAFile, "tls-client-ca", "", "CA bundle (PEM) for verifying client certificates (enables mTLS)")
+	serveCmd.Flags().StringVar(&tlsOptions.ClientAuth, "tls-client-auth", "require", "Client certificate policy with --tls-client-ca: require or optional")
+}
+
 // aboutCmd shows Judge0 instance info
 var aboutCmd = &cobra.Command{
 	Use:   "about",
 	Short: "Show Judge0 instance information",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		info, err := judge0Client.About()
+		info, err := judge0Client.About(cmd.Context())
 		if err != nil {
 			return fmt.Errorf("failed to get Judge0 info: %w", err)
 		}
@@ -133,6 +663,20 @@ func handleCreateSession(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		Language string `json:"language"`
 		Name     string `json:"name,omitempty"`
+		Strict   bool   `json:"strict,omitempty"`
+		// Stateful carries interpreter state between executions
+		Stateful bool `json:"stateful,omitempty"`
+		// Version pins a backend version of the language, e.g. "3.11"
+		Version string `json:"version,omitempty"`
+
+		// ExamDuration creates a time-boxed exam session (e.g. "90m")
+		ExamDuration string `json:"exam_duration,omitempty"`
+		// ProblemID links the session to a problem for its stdin templates
+		ProblemID string `json:"problem_id,omitempty"`
+		// AcceptPolicy accepts the usage policy shown by /capabilities
+		AcceptPolicy bool `json:"accept_policy,omitempty"`
+		// InitScript runs once the session exists; its output is replayed on attach
+		InitScript string `json:"init_script,omitempty"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -145,17 +689,71 @@ func handleCreateSession(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validate language
-	if _, err := GetLanguageID(req.Language); err != nil {
+	// Validate language and resolve a pinned version
+	language, pinned, err := resolveLanguageSpec(r.Context(), req.Language, req.Version)
+	if err != nil {
+		writeLanguageError(w, err)
+		return
+	}
+	req.Language = language
+
+	if err := checkStateful(req.Language, req.Stateful); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	session, err := sessionManager.CreateSession(req.Language, req.Name)
+	if err := checkPolicyAccepted(req.AcceptPolicy); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	opts := SessionOptions{Strict: req.Strict, Stateful: req.Stateful, Owner: principalFromContext(r.Context()), Language: pinned}
+	if req.ExamDuration != "" {
+		d, err := time.ParseDuration(req.ExamDuration)
+		if err != nil || d <= 0 {
+			http.Error(w, "invalid exam_duration", http.StatusBadRequest)
+			return
+		}
+		opts.ExamDuration = d
+	}
+
+	if req.ProblemID != "" {
+		if _, err := problemStore.Get(req.ProblemID); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		opts.Metadata = map[string]string{"problem_id": req.ProblemID}
+	}
+	if len(req.InitScript) > maxInitScript {
+		http.Error(w, fmt.Sprintf("init_script exceeds %d bytes", maxInitScript), http.StatusBadRequest)
+		return
+	}
+
+	_, span := startSpan(r.Context(), "session.create", spanKindInternal)
+	session, err := sessionsFor(r.Context()).CreateSession(req.Language, req.Name, opts)
+	span.SetError(err)
+	span.Finish()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), createErrorStatus(err))
 		return
 	}
+	audit(r.Context(), "session.create", session.ID, map[string]interface{}{
+		"language": session.Language,
+		"version":  session.Version,
+		"name":     session.Name,
+		"strict":   session.Strict,
+		"stateful": session.Stateful,
+		"exam":     session.Exam != nil,
+
+		"accept_policy": req.AcceptPolicy,
+	})
+
+	if req.InitScript != "" {
+		if _, err := runSessionInit(r.Context(), session.ID, req.InitScript); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
@@ -163,34 +761,61 @@ func handleCreateSession(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleListSessions(w http.ResponseWriter, r *http.Request) {
-	sessions := sessionManager.ListSessions()
+	sessions := visibleSessions(r.Context(), sessionsFor(r.Context()).ListSessions())
+	if r.URL.Query().Get("usage") == "true" {
+		writeSessionsWithUsage(w, r, sessions)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(sessions)
 }
 
 func handleGetSession(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
-	session, err := sessionManager.GetSession(id)
+	session, err := sessionsFor(r.Context()).GetSession(id)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(session)
+	json.NewEncoder(w).Encode(SessionWithDisk{Session: session, Disk: sessionsFor(r.Context()).DiskUsage(session)})
 }
 
 func handleExecute(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
-	session, err := sessionManager.GetSession(id)
+	session, err := sessionsFor(r.Context()).GetSession(id)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
 
 	var req struct {
-		Code  string `json:"code"`
-		Stdin string `json:"stdin,omitempty"`
+		Code       string `json:"code"`
+		Stdin      string `json:"stdin,omitempty"`
+		Trace      bool   `json:"trace,omitempty"`
+		TraceLimit int    `json:"trace_limit,omitempty"`
+
+		StdinTemplate string            `json:"stdin_template,omitempty"`
+		StdinParams   map[string]string `json:"stdin_params,omitempty"`
+
+		// Deadlines in seconds: queue wait before Judge0 starts, and total
+		QueueTimeout float64 `json:"queue_timeout,omitempty"`
+		Timeout      float64 `json:"timeout,omitempty"`
+
+		ExpectedExitCode *int `json:"expected_exit_code,omitempty"`
+
+		// Format overrides --format-code for this execution
+		Format *bool `json:"format,omitempty"`
+
+		// CaptureEnv maps env var names to output captures, e.g. {"TOKEN": "last_line"}
+		CaptureEnv map[string]string `json:"capture_env,omitempty"`
+
+		// Preview attaches a static analysis of the code to the result
+		Preview bool `json:"preview,omitempty"`
+
+		// Optional Judge0 limits and settings, e.g. wall_time_limit
+		SubmissionParams
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -203,51 +828,204 @@ func handleExecute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get language ID
-	langID, err := GetLanguageID(session.Language)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if session.Status != "active" {
+		http.Error(w, fmt.Sprintf("session is not active: %s", session.Status), http.StatusConflict)
 		return
 	}
 
-	// Prepare code with environment variables
-	fullCode := prepareCodeWithEnv(req.Code, session.State.Env, session.Language)
+	execReq := ExecRequest{
+		Code:          req.Code,
+		Stdin:         req.Stdin,
+		StdinTemplate: req.StdinTemplate,
+		StdinParams:   req.StdinParams,
+		Trace:         req.Trace,
+		TraceLimit:    req.TraceLimit,
+		QueueTimeout:  seconds(req.QueueTimeout),
+		Timeout:       seconds(req.Timeout),
 
-	// Execute
-	startTime := time.Now()
-	result, err := judge0Client.Execute(fullCode, langID, req.Stdin)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		ExpectedExitCode: req.ExpectedExitCode,
+		Format:           req.Format,
+		CaptureEnv:       req.CaptureEnv,
+		Preview:          req.Preview,
+		Params:           req.SubmissionParams,
+	}
+
+	if key := r.Header.Get(idempotencyKeyHeader); key != "" {
+		handleIdempotentExecute(w, r, id, key, req, execReq)
+		return
+	}
+
+	if r.URL.Query().Get("stream") == "true" {
+		handleExecuteStream(w, r, id, execReq)
 		return
 	}
-	duration := time.Since(startTime).Seconds() * 1000
 
-	// Record execution
-	exec := Execution{
-		Code:     req.Code,
-		Output:   result.Stdout,
-		Stderr:   result.Stderr,
-		ExitCode: result.ExitCode,
-		Time:     startTime,
-		Duration: duration,
+	if v := r.URL.Query().Get("wait"); v != "" {
+		wait, err := parseWait(v)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		handleExecuteLongPoll(w, r, id, execReq, wait)
+		return
 	}
 
-	if err := sessionManager.AddExecution(id, exec); err != nil {
-		log.Printf("Warning: failed to record execution: %v", err)
+	exec, err := runExecution(r.Context(), id, execReq)
+	if err != nil {
+		writeExecuteError(w, err)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"stdout":    result.Stdout,
-		"stderr":    result.Stderr,
-		"exit_code": result.ExitCode,
-		"time_ms":   duration,
-	})
+	json.NewEncoder(w).Encode(executionResponse(exec, requestLocale(r)))
+}
+
+// writeExecuteError maps execution errors to HTTP responses
+func writeExecuteError(w http.ResponseWriter, err error) {
+	var inflight *InFlightError
+	if errors.As(err, &inflight) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":        inflight.Error(),
+			"execution_id": inflight.ExecutionID,
+		})
+		return
+	}
+
+	// Queue timeouts mean the backend is busy (503); total timeouts mean
+	// the code itself is slow (504)
+	var deadline *DeadlineError
+	if errors.As(err, &deadline) {
+		status := http.StatusGatewayTimeout
+		if deadline.Kind == DeadlineQueue {
+			status = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":      deadline.Error(),
+			"code":       deadline.Kind,
+			"token":      deadline.Token,
+			"elapsed_ms": deadline.Elapsed.Milliseconds(),
+		})
+		return
+	}
+
+	// Maintenance and closed execution windows are temporary (503), with
+	// when executions reopen
+	var closed *ExecutionsClosedError
+	if errors.As(err, &closed) {
+		resp := map[string]interface{}{"error": closed.Error(), "code": closed.Code}
+		if closed.NextOpen != nil {
+			resp["next_open"] = closed.NextOpen
+			if wait := time.Until(*closed.NextOpen); wait > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+
+	// With every pooled backend's circuit open, retry once one half-opens
+	var unavailable *BackendsUnavailableError
+	if errors.As(err, &unavailable) {
+		if wait := time.Until(unavailable.RetryAt); wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		}
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	// Rate limits from a hosted Judge0 are passed on with when to retry
+	var limited *RateLimitError
+	if errors.As(err, &limited) {
+		if limited.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(limited.RetryAfter.Seconds())))
+		}
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	}
+
+	var invalid *InvalidSubmissionError
+	if errors.As(err, &invalid) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Judge0's own verdict on passthrough credentials is passed on
+	var backendAuth *BackendAuthError
+	if errors.As(err, &backendAuth) {
+		http.Error(w, err.Error(), backendAuth.StatusCode)
+		return
+	}
+	if errors.Is(err, errJudge0CredentialsRequired) {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+// seconds converts a JSON seconds value to a duration
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
 }
 
 func handleGetLog(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
-	log, err := sessionManager.GetLog(id, 100)
+
+	if r.URL.Query().Get("follow") == "true" {
+		session, err := sessionsFor(r.Context()).GetSession(id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		handleFollowLog(w, r, session)
+		return
+	}
+
+	query := r.URL.Query()
+
+	// Byte-offset pagination
+	if query.Has("offset") {
+		offset, err := strconv.ParseInt(query.Get("offset"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid offset", http.StatusBadRequest)
+			return
+		}
+		limit := int64(64 * 1024)
+		if l := query.Get("limit"); l != "" {
+			if limit, err = strconv.ParseInt(l, 10, 64); err != nil {
+				http.Error(w, "invalid limit", http.StatusBadRequest)
+				return
+			}
+		}
+
+		page, err := sessionsFor(r.Context()).GetLogPage(id, offset, limit)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(page)
+		return
+	}
+
+	lines := 100
+	if l := query.Get("lines"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil {
+			http.Error(w, "invalid lines", http.StatusBadRequest)
+			return
+		}
+		lines = n
+	}
+
+	log, err := sessionsFor(r.Context()).GetLog(id, lines)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusNotFound)
 		return
@@ -257,39 +1035,54 @@ func handleGetLog(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(log))
 }
 
-func handleCloseSession(w http.ResponseWriter, r *http.Request) {
+// handleListExecutions returns the execution history as a JSON array,
+// or streams the JSONL sidecar with ?format=jsonl
+func handleListExecutions(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
-	if err := sessionManager.CloseSession(id); err != nil {
+	session, err := sessionsFor(r.Context()).GetSession(id)
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
-}
+	if r.URL.Query().Get("format") == "jsonl" {
+		executions, err := sessionsFor(r.Context()).OpenExecutions(session.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer executions.Close()
 
-// prepareCodeWithEnv wraps code to inject environment variables
-func prepareCodeWithEnv(code string, env map[string]string, language string) string {
-	if len(env) == 0 {
-		return code
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		io.Copy(w, executions)
+		return
 	}
 
-	switch language {
-	case "bash", "shell", "sh":
-		prefix := ""
-		for k, v := range env {
-			prefix += fmt.Sprintf("export %s=%q\n", k, v)
-		}
-		return prefix + code
-
-	case "python", "python3":
-		prefix := "import os\n"
-		for k, v := range env {
-			prefix += fmt.Sprintf("os.environ[%q] = %q\n", k, v)
-		}
-		return prefix + code
+	history, err := sessionsFor(r.Context()).ListExecutions(session.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
 
-	default:
-		// For other languages, just return the code as-is
-		return code
+func handleCloseSession(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	session, err := sessionsFor(r.Context()).GetSession(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
 	}
+	if err := authorizeClose(r.Context(), session); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err := sessionsFor(r.Context()).CloseSession(id); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	audit(r.Context(), "session.close", id, nil)
+
+	w.WriteHeader(http.StatusNoContent)
 }
@@ -0,0 +1,440 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// maintenanceFile holds the maintenance state in the data directory's
+// state files; servers read it on every execution
+const maintenanceFile = "maintenance.json"
+
+// Maintenance is the admin-controlled switch that stops new executions
+// while keeping sessions, logs and other reads available
+type Maintenance struct {
+	Enabled bool   `json:"enabled"`
+	Message string `json:"message,omitempty"`
+	// Until, when set, ends maintenance automatically
+	Until *time.Time `json:"until,omitempty"`
+	SetBy string     `json:"set_by,omitempty"`
+	SetAt time.Time  `json:"set_at"`
+}
+
+// active reports whether maintenance is on at now
+func (m *Maintenance) active(now time.Time) bool {
+	return m != nil && m.Enabled && (m.Until == nil || now.Before(*m.Until))
+}
+
+func loadMaintenance() (*Maintenance, error) {
+	data, err := os.ReadFile(statePath(dataDir, maintenanceFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var m Maintenance
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", maintenanceFile, err)
+	}
+	return &m, nil
+}
+
+func saveMaintenance(m *Maintenance) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeStateFile(dataDir, maintenanceFile, data)
+}
+
+// executionWindow is a weekly span in which code may run, from
+// --execution-windows, e.g. "Mon-Fri 08:00-18:00"
+type executionWindow struct {
+	days       [7]bool // indexed by time.Weekday
+	start, end int     // minutes after midnight; end is exclusive
+}
+
+// Execution window settings. With no windows, executions are allowed at
+// any time.
+var (
+	executionWindowSpecs    []string
+	executionWindowTimezone string
+
+	executionWindows  []executionWindow
+	executionLocation = time.Local
+)
+
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+func parseWeekday(s string) (int, error) {
+	for i, name := range weekdayNames {
+		if strings.EqualFold(s, name) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid weekday %q (want Mon, Tue, ...)", s)
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		if s == "24:00" {
+			return 24 * 60, nil
+		}
+		return 0, fmt.Errorf("invalid time %q (want HH:MM)", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// parseExecutionWindow parses "Mon-Fri 08:00-18:00" or "Sat,Sun 10:00-14:00"
+func parseExecutionWindow(spec string) (executionWindow, error) {
+	var w executionWindow
+	days, hours, ok := strings.Cut(strings.TrimSpace(spec), " ")
+	if !ok {
+		return w, fmt.Errorf("invalid execution window %q (want e.g. \"Mon-Fri 08:00-18:00\")", spec)
+	}
+	for _, part := range strings.Split(days, ",") {
+		first, last, isRange := strings.Cut(part, "-")
+		from, err := parseWeekday(first)
+		if err != nil {
+			return w, err
+		}
+		to := from
+		if isRange {
+			if to, err = parseWeekday(last); err != nil {
+				return w, err
+			}
+		}
+		for d := from; ; d = (d + 1) % 7 {
+			w.days[d] = true
+			if d == to {
+				break
+			}
+		}
+	}
+
+	start, end, ok := strings.Cut(strings.TrimSpace(hours), "-")
+	if !ok {
+		return w, fmt.Errorf("invalid execution window %q (want e.g. \"Mon-Fri 08:00-18:00\")", spec)
+	}
+	var err error
+	if w.start, err = parseClock(start); err != nil {
+		return w, err
+	}
+	if w.end, err = parseClock(end); err != nil {
+		return w, err
+	}
+	if w.end <= w.start {
+		return w, fmt.Errorf("execution window %q ends before it starts", spec)
+	}
+	return w, nil
+}
+
+// configureExecutionWindows applies --execution-windows and --execution-timezone
+func configureExecutionWindows() error {
+	if executionWindowTimezone != "" {
+		loc, err := time.LoadLocation(executionWindowTimezone)
+		if err != nil {
+			return fmt.Errorf("invalid --execution-timezone: %w", err)
+		}
+		executionLocation = loc
+	}
+	for _, spec := range executionWindowSpecs {
+		w, err := parseExecutionWindow(spec)
+		if err != nil {
+			return err
+		}
+		executionWindows = append(executionWindows, w)
+	}
+	return nil
+}
+
+// windowOpen reports whether t falls in an execution window
+func windowOpen(t time.Time) bool {
+	if len(executionWindows) == 0 {
+		return true
+	}
+	t = t.In(executionLocation)
+	minute := t.Hour()*60 + t.Minute()
+	for _, w := range executionWindows {
+		if w.days[t.Weekday()] && minute >= w.start && minute < w.end {
+			return true
+		}
+	}
+	return false
+}
+
+// nextWindowOpen returns the first time at or after t that falls in an
+// execution window
+func nextWindowOpen(t time.Time) time.Time {
+	if windowOpen(t) {
+		return t
+	}
+	local := t.In(executionLocation)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, executionLocation)
+	var next time.Time
+	for day := 0; day <= 7; day++ {
+		date := midnight.AddDate(0, 0, day)
+		for _, w := range executionWindows {
+			if !w.days[date.Weekday()] {
+				continue
+			}
+			open := date.Add(time.Duration(w.start) * time.Minute)
+			if open.After(t) && (next.IsZero() || open.Before(next)) {
+				next = open
+			}
+		}
+		if !next.IsZero() {
+			return next
+		}
+	}
+	return next
+}
+
+// ExecutionsClosedError is returned for executions during maintenance or
+// outside the execution windows
+type ExecutionsClosedError struct {
+	// Code is "maintenance" or "outside_execution_window"
+	Code    string
+	Message string
+	// NextOpen is when executions are next allowed, if known
+	NextOpen *time.Time
+}
+
+func (e *ExecutionsClosedError) Error() string {
+	msg := e.Message
+	if e.NextOpen != nil {
+		msg += "; executions reopen at " + e.NextOpen.Format(time.RFC3339)
+	}
+	return msg
+}
+
+// checkExecutionsOpen rejects executions during maintenance or outside the
+// configured execution windows
+func checkExecutionsOpen(now time.Time) error {
+	m, err := loadMaintenance()
+	if err != nil {
+		return err
+	}
+	if m.active(now) {
+		msg := "the orchestrator is in maintenance mode"
+		if m.Message != "" {
+			msg += ": " + m.Message
+		}
+		closed := &ExecutionsClosedError{Code: "maintenance", Message: msg}
+		if m.Until != nil {
+			next := nextWindowOpen(*m.Until)
+			closed.NextOpen = &next
+		}
+		return closed
+	}
+	if !windowOpen(now) {
+		next := nextWindowOpen(now)
+		return &ExecutionsClosedError{Code: "outside_execution_window", Message: "code can only run during the execution windows (" + strings.Join(executionWindowSpecs, "; ") + ")", NextOpen: &next}
+	}
+	return nil
+}
+
+// maintenanceResponse reports maintenance and window state to clients
+func maintenanceResponse(now time.Time) (map[string]interface{}, error) {
+	m, err := loadMaintenance()
+	if err != nil {
+		return nil, err
+	}
+	closed, _ := checkExecutionsOpen(now).(*ExecutionsClosedError)
+	resp := map[string]interface{}{
+		"maintenance":    m.active(now),
+		"execution_open": closed == nil,
+	}
+	if m.active(now) {
+		resp["details"] = m
+	}
+	if len(executionWindowSpecs) > 0 {
+		resp["execution_windows"] = executionWindowSpecs
+		resp["timezone"] = executionLocation.String()
+	}
+	if closed != nil {
+		resp["code"] = closed.Code
+		if closed.NextOpen != nil {
+			resp["next_open"] = closed.NextOpen
+		}
+	}
+	return resp, nil
+}
+
+func handleGetMaintenance(w http.ResponseWriter, r *http.Request) {
+	resp, err := maintenanceResponse(time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// handleSetMaintenance serves PUT /maintenance for admins
+func handleSetMaintenance(w http.ResponseWriter, r *http.Request) {
+	if !hasRole(r.Context(), RoleAdmin) {
+		http.Error(w, "only admins can change maintenance mode", http.StatusForbidden)
+		return
+	}
+	var m Maintenance
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	m.SetBy = principalFromContext(r.Context())
+	m.SetAt = time.Now()
+	if err := saveMaintenance(&m); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	audit(r.Context(), "maintenance.set", "", map[string]interface{}{"enabled": m.Enabled, "message": m.Message, "until": m.Until})
+	handleGetMaintenance(w, r)
+}
+
+// maintenanceAPIKeyEnv holds the API key j0 maintenance sends to a running
+// server when --api-key isn't given
+const maintenanceAPIKeyEnv = "J0_API_KEY"
+
+// setMaintenance applies a maintenance change from the CLI. While j0 serve
+// owns the data directory the change is sent to its PUT /v1/maintenance,
+// which records and audits it.
+func setMaintenance(cmd *cobra.Command, m *Maintenance) error {
+	if runningServer == nil {
+		if err := saveMaintenance(m); err != nil {
+			return err
+		}
+		audit(cmd.Context(), "maintenance.set", "", map[string]interface{}{"enabled": m.Enabled, "message": m.Message, "until": m.Until})
+		return nil
+	}
+
+	apiKey, _ := cmd.Flags().GetString("api-key")
+	body, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	url := runningServer.baseURL() + "/v1/maintenance"
+	req, err := http.NewRequestWithContext(cmd.Context(), http.MethodPut, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach the running server (pid %d): %w", runningServer.PID, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("running server refused the maintenance change: %s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+var maintenanceCmd = &cobra.Command{
+	Use:   "maintenance",
+	Short: "Stop or allow code execution for maintenance",
+	Long: `Maintenance mode rejects new executions with a 503 while sessions, logs
+and other reads keep working. It applies to a running server immediately:
+while j0 serve owns the data directory, on and off are sent to its
+PUT /v1/maintenance, authenticated with --api-key (an admin key) when the
+server requires keys.
+
+Examples:
+  j0 maintenance on --message "Judge0 upgrade" --until 2024-05-01T18:00:00Z
+  j0 maintenance status
+  j0 maintenance off`,
+}
+
+var maintenanceOnCmd = &cobra.Command{
+	Use:   "on",
+	Short: "Enter maintenance mode",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		message, _ := cmd.Flags().GetString("message")
+		until, _ := cmd.Flags().GetString("until")
+
+		m := &Maintenance{Enabled: true, Message: message, SetBy: principalFromContext(cmd.Context()), SetAt: time.Now()}
+		if until != "" {
+			t, err := time.Parse(time.RFC3339, until)
+			if err != nil {
+				return fmt.Errorf("invalid --until %q (want RFC 3339)", until)
+			}
+			m.Until = &t
+		}
+		if err := setMaintenance(cmd, m); err != nil {
+			return err
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), "Maintenance mode on")
+		return nil
+	},
+}
+
+var maintenanceOffCmd = &cobra.Command{
+	Use:   "off",
+	Short: "Leave maintenance mode",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := setMaintenance(cmd, &Maintenance{SetBy: principalFromContext(cmd.Context()), SetAt: time.Now()}); err != nil {
+			return err
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), "Maintenance mode off")
+		return nil
+	},
+}
+
+var maintenanceStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show maintenance mode and execution windows",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		now := time.Now()
+		m, err := loadMaintenance()
+		if err != nil {
+			return err
+		}
+		if m.active(now) {
+			fmt.Printf("Maintenance: on (set by %s at %s)\n", m.SetBy, m.SetAt.Format(time.RFC3339))
+			if m.Message != "" {
+				fmt.Printf("Message: %s\n", m.Message)
+			}
+			if m.Until != nil {
+				fmt.Printf("Until: %s\n", m.Until.Format(time.RFC3339))
+			}
+		} else {
+			fmt.Println("Maintenance: off")
+		}
+		if len(executionWindowSpecs) > 0 {
+			fmt.Printf("Execution windows (%s): %s\n", executionLocation, strings.Join(executionWindowSpecs, "; "))
+		}
+		if closed, ok := checkExecutionsOpen(now).(*ExecutionsClosedError); ok {
+			fmt.Printf("Executions: closed (%s)\n", closed.Code)
+			if closed.NextOpen != nil {
+				fmt.Printf("Next open: %s\n", closed.NextOpen.Format(time.RFC3339))
+			}
+		} else {
+			fmt.Println("Executions: open")
+		}
+		return nil
+	},
+}
+
+func init() {
+	maintenanceOnCmd.Flags().String("message", "", "Reason shown to clients")
+	maintenanceOnCmd.Flags().String("until", "", "End maintenance automatically at this time (RFC 3339)")
+	maintenanceCmd.PersistentFlags().String("api-key", os.Getenv(maintenanceAPIKeyEnv), "API key for a running server that requires keys (also read from "+maintenanceAPIKeyEnv+")")
+
+	maintenanceCmd.AddCommand(maintenanceOnCmd)
+	maintenanceCmd.AddCommand(maintenanceOffCmd)
+	maintenanceCmd.AddCommand(maintenanceStatusCmd)
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withDataDir points the global data directory at a fresh temp dir
+func withDataDir(t *testing.T) string {
+	t.Helper()
+	saved := dataDir
+	dataDir = t.TempDir()
+	t.Cleanup(func() { dataDir = saved })
+	return dataDir
+}
+
+func TestMaintenanceStateIsNotASession(t *testing.T) {
+	dir := withDataDir(t)
+	if err := saveMaintenance(&Maintenance{Enabled: true, Message: "upgrade", SetAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, stateDirName, maintenanceFile)); err != nil {
+		t.Fatalf("maintenance state not under %s/: %v", stateDirName, err)
+	}
+
+	sm, err := NewSessionManager(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sessions := sm.ListSessions(); len(sessions) != 0 {
+		t.Fatalf("maintenance state loaded as %d session(s)", len(sessions))
+	}
+
+	m, err := loadMaintenance()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.active(time.Now()) || m.Message != "upgrade" {
+		t.Fatalf("loaded maintenance = %+v", m)
+	}
+}
+
+func TestLoadSessionsSkipsRecordsWithoutID(t *testing.T) {
+	dir := withDataDir(t)
+	// Left in the root by an older version
+	legacy := []byte(`{"enabled": true, "message": "old", "set_at": "2024-01-01T00:00:00Z"}`)
+	if err := os.WriteFile(filepath.Join(dir, maintenanceFile), legacy, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sm, err := NewSessionManager(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sessions := sm.ListSessions(); len(sessions) != 0 {
+		t.Fatalf("record without an ID loaded as %d session(s)", len(sessions))
+	}
+
+	// The legacy file is moved into the state directory on first use
+	m, err := loadMaintenance()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m == nil || m.Message != "old" {
+		t.Fatalf("legacy maintenance state not migrated: %+v", m)
+	}
+	if _, err := os.Stat(filepath.Join(dir, maintenanceFile)); !os.IsNotExist(err) {
+		t.Fatalf("legacy maintenance file still in the data dir root: %v", err)
+	}
+}
+
+// withExecutionWindows configures --execution-windows in UTC for the test
+func withExecutionWindows(t *testing.T, specs ...string) {
+	t.Helper()
+	savedSpecs, savedWindows, savedLocation := executionWindowSpecs, executionWindows, executionLocation
+	t.Cleanup(func() {
+		executionWindowSpecs, executionWindows, executionLocation = savedSpecs, savedWindows, savedLocation
+	})
+	executionWindowSpecs, executionWindows, executionWindowTimezone = specs, nil, "UTC"
+	defer func() { executionWindowTimezone = "" }()
+	if err := configureExecutionWindows(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseExecutionWindow(t *testing.T) {
+	tests := []struct {
+		spec  string
+		days  string // the weekdays open, Sunday first
+		start int
+		end   int
+		err   string
+	}{
+		{spec: "Mon-Fri 08:00-18:00", days: "0111110", start: 8 * 60, end: 18 * 60},
+		{spec: "sat,SUN 10:00-14:30", days: "1000001", start: 10 * 60, end: 14*60 + 30},
+		{spec: "Fri-Mon 00:00-24:00", days: "1100011", start: 0, end: 24 * 60},
+		{spec: " Wed 09:15-09:16 ", days: "0001000", start: 9*60 + 15, end: 9*60 + 16},
+		{spec: "Mon-Fri", err: "invalid execution window"},
+		{spec: "Mon-Fry 08:00-18:00", err: "invalid weekday"},
+		{spec: "Mon 8am-6pm", err: "invalid time"},
+		{spec: "Mon 18:00-08:00", err: "ends before it starts"},
+		{spec: "Mon 08:00", err: "invalid execution window"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			w, err := parseExecutionWindow(tt.spec)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var days strings.Builder
+			for _, open := range w.days {
+				if open {
+					days.WriteByte('1')
+				} else {
+					days.WriteByte('0')
+				}
+			}
+			if days.String() != tt.days || w.start != tt.start || w.end != tt.end {
+				t.Fatalf("window = %s %d-%d, want %s %d-%d", days.String(), w.start, w.end, tt.days, tt.start, tt.end)
+			}
+		})
+	}
+}
+
+func TestExecutionWindowsOpen(t *testing.T) {
+	withDataDir(t)
+	withExecutionWindows(t, "Mon-Fri 08:00-18:00", "Sat 10:00-12:00")
+
+	// 2024-01-01 is a Monday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, 1, day, hour, minute, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name string
+		now  time.Time
+		next time.Time
+	}{
+		{name: "inside", now: at(1, 9, 30), next: at(1, 9, 30)},
+		{name: "at the start", now: at(1, 8, 0), next: at(1, 8, 0)},
+		{name: "at the end", now: at(1, 18, 0), next: at(2, 8, 0)},
+		{name: "before the start", now: at(3, 7, 59), next: at(3, 8, 0)},
+		{name: "friday evening", now: at(5, 19, 0), next: at(6, 10, 0)},
+		{name: "saturday afternoon", now: at(6, 13, 0), next: at(8, 8, 0)},
+		{name: "other time zone", now: time.Date(2024, 1, 1, 9, 0, 0, 0, time.FixedZone("UTC+2", 2*3600)), next: at(1, 8, 0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			open := tt.next.Equal(tt.now)
+			if got := windowOpen(tt.now); got != open {
+				t.Fatalf("windowOpen = %v, want %v", got, open)
+			}
+			if got := nextWindowOpen(tt.now); !got.Equal(tt.next) {
+				t.Fatalf("nextWindowOpen = %s, want %s", got, tt.next)
+			}
+			var closed *ExecutionsClosedError
+			err := checkExecutionsOpen(tt.now)
+			if open != (err == nil) || (!open && (!errors.As(err, &closed) || closed.Code != "outside_execution_window" || !closed.NextOpen.Equal(tt.next))) {
+				t.Fatalf("checkExecutionsOpen = %v", err)
+			}
+		})
+	}
+}
+
+func TestCheckExecutionsOpenDuringMaintenance(t *testing.T) {
+	withDataDir(t)
+	withExecutionWindows(t, "Mon-Fri 08:00-18:00")
+
+	monday := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	until := time.Date(2024, 1, 5, 20, 0, 0, 0, time.UTC)
+	if err := saveMaintenance(&Maintenance{Enabled: true, Message: "upgrade", Until: &until}); err != nil {
+		t.Fatal(err)
+	}
+
+	var closed *ExecutionsClosedError
+	err := checkExecutionsOpen(monday)
+	if !errors.As(err, &closed) || closed.Code != "maintenance" || !strings.Contains(closed.Message, "upgrade") {
+		t.Fatalf("during maintenance: %v", err)
+	}
+	// Maintenance ends Friday night; the next window opens Monday morning
+	if want := time.Date(2024, 1, 8, 8, 0, 0, 0, time.UTC); closed.NextOpen == nil || !closed.NextOpen.Equal(want) {
+		t.Fatalf("next open = %v, want %s", closed.NextOpen, want)
+	}
+	if err := checkExecutionsOpen(time.Date(2024, 1, 8, 9, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("after maintenance ended: %v", err)
+	}
+}
+
+func TestSetMaintenanceNeedsAdmin(t *testing.T) {
+	withDataDir(t)
+
+	tests := []struct {
+		role string
+		want int
+	}{
+		{role: RoleReadOnly, want: http.StatusForbidden},
+		{role: RoleOperator, want: http.StatusForbidden},
+		{role: RoleAdmin, want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.role, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPut, "/v1/maintenance", strings.NewReader(`{"enabled": true}`))
+			r = r.WithContext(withRole(r.Context(), tt.role))
+			w := httptest.NewRecorder()
+			handleSetMaintenance(w, r)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+	if m, _ := loadMaintenance(); !m.active(time.Now()) {
+		t.Fatal("admin change not saved")
+	}
+}
@@ -1,10 +1,14 @@
 package main
 
 import (
+	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
-	"time"
+	"strings"
+	"unicode/utf8"
 )
 
 // MCP Tool Definitions
@@ -30,12 +34,28 @@ func MCPTools() []MCPTool {
 				"properties": map[string]interface{}{
 					"language": map[string]interface{}{
 						"type":        "string",
-						"description": "Programming language for the session (bash, python, go, javascript, ruby, rust, c, cpp)",
+						"description": "Programming language for the session (bash, python, go, javascript, ruby, rust, c, cpp), optionally pinned to a version as in python:3.11 or gcc:12",
 					},
 					"name": map[string]interface{}{
 						"type":        "string",
 						"description": "Optional human-readable name for the session",
 					},
+					"version": map[string]interface{}{
+						"type":        "string",
+						"description": "Pin a backend version of the language, e.g. \"3.11\" (see j0_list_languages)",
+					},
+					"strict": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Reject executions while another one is in flight in this session",
+					},
+					"stateful": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Keep interpreter state (Python and JavaScript globals) between executions, like a REPL",
+					},
+					"accept_policy": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Accept the deployment's usage policy (see /capabilities) when it requires acknowledgment",
+					},
 				},
 				"required": []string{"language"},
 			},
@@ -58,10 +78,111 @@ func MCPTools() []MCPTool {
 						"type":        "string",
 						"description": "Optional standard input for the code",
 					},
+					"stdin_template": map[string]interface{}{
+						"type":        "string",
+						"description": "Name of a session or problem stdin template to use instead of stdin",
+					},
+					"stdin_params": map[string]interface{}{
+						"type":                 "object",
+						"description":          "Values for {{name}} placeholders in the stdin template",
+						"additionalProperties": map[string]interface{}{"type": "string"},
+					},
+					"trace": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Return a line-by-line execution trace with local variables (Python only)",
+					},
+					"queue_timeout": map[string]interface{}{
+						"type":        "number",
+						"description": "Fail with queue_timeout if the backend hasn't started the code within this many seconds",
+					},
+					"timeout": map[string]interface{}{
+						"type":        "number",
+						"description": "Fail with execution_timeout if the code hasn't finished within this many seconds",
+					},
+					"expected_exit_code": map[string]interface{}{
+						"type":        "integer",
+						"description": "Mark the execution passed only if it exits with this code",
+					},
+					"format": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Format the code before running it (defaults to the server setting)",
+					},
+					"capture_env": map[string]interface{}{
+						"type":                 "object",
+						"description":          "Store parts of a successful run's stdout as session env vars: {VAR: \"last_line\" | \"stdout\" | \"regex:<pattern>\" | \"json:<path>\"}",
+						"additionalProperties": map[string]interface{}{"type": "string"},
+					},
+					"preview": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Attach a static analysis of the code (imports, network, file writes, processes) to the result",
+					},
+					"wall_time_limit": map[string]interface{}{
+						"type":        "number",
+						"description": "Wall clock limit in seconds for the program (up to the backend's max_wall_time_limit)",
+					},
+					"stack_limit": map[string]interface{}{
+						"type":        "integer",
+						"description": "Stack limit in KB",
+					},
+					"max_processes_and_or_threads": map[string]interface{}{
+						"type":        "integer",
+						"description": "Maximum processes and/or threads the program may create",
+					},
+					"enable_per_process_and_thread_time_limit": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Apply the CPU time limit to each process and thread rather than the program as a whole",
+					},
+					"max_file_size": map[string]interface{}{
+						"type":        "integer",
+						"description": "Largest file the program may create, in KB",
+					},
+					"redirect_stderr_to_stdout": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Merge stderr into stdout",
+					},
+					"number_of_runs": map[string]interface{}{
+						"type":        "integer",
+						"description": "Run the program this many times; time and memory are averaged",
+					},
+					"max_output_bytes": map[string]interface{}{
+						"type":        "integer",
+						"description": fmt.Sprintf("Truncate stdout, stderr and compiler output beyond this many bytes (default %d); the rest is fetched with j0_get_output", mcpDefaultMaxOutputBytes),
+					},
+				},
+				"required": []string{"session_id", "code"},
+			},
+		},
+		{
+			Name:        "j0_preview",
+			Description: "Statically analyze code without running it: the modules it imports, network, file write, process and low-level system indicators, and an overall risk level. Use it to check untrusted code before j0_execute.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"session_id": map[string]interface{}{
+						"type":        "string",
+						"description": "The session whose language the code is in",
+					},
+					"code": map[string]interface{}{
+						"type":        "string",
+						"description": "The code to analyze",
+					},
 				},
 				"required": []string{"session_id", "code"},
 			},
 		},
+		{
+			Name:        "j0_list_languages",
+			Description: "List the languages the Judge0 backend supports, with their IDs, versions and the aliases accepted by j0_create_session. Use it instead of guessing language names.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"all": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Include backend languages that sessions can't be created for (no alias)",
+					},
+				},
+			},
+		},
 		{
 			Name:        "j0_get_session",
 			Description: "Get details about a session including its state, environment variables, and execution history.",
@@ -76,6 +197,42 @@ func MCPTools() []MCPTool {
 				"required": []string{"session_id"},
 			},
 		},
+		{
+			Name:        "j0_get_environment",
+			Description: "Get the runtime version and installed packages of a session's language, with versions. The inventory is cached, so use it instead of executing code to find out what's installed.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"session_id": map[string]interface{}{
+						"type":        "string",
+						"description": "The session to describe",
+					},
+					"refresh": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Collect the inventory again, e.g. after installing dependencies",
+					},
+				},
+				"required": []string{"session_id"},
+			},
+		},
+		{
+			Name:        "j0_set_session_defaults",
+			Description: "Set submission settings every execution in a session inherits (stdin, cpu_time_limit, memory_limit, compiler_options, wall_time_limit, stack_limit, redirect_stderr_to_stdout, ...). Settings given to j0_execute override them. Replaces the previous defaults; an empty object clears them.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"session_id": map[string]interface{}{
+						"type":        "string",
+						"description": "The session to configure",
+					},
+					"defaults": map[string]interface{}{
+						"type":        "object",
+						"description": "Default settings, using j0_execute's parameter names plus stdin_template, cpu_time_limit (seconds), memory_limit (KB) and compiler_options",
+					},
+				},
+				"required": []string{"session_id", "defaults"},
+			},
+		},
 		{
 			Name:        "j0_list_sessions",
 			Description: "List all execution sessions with their status and basic info.",
@@ -98,10 +255,32 @@ func MCPTools() []MCPTool {
 						"type":        "integer",
 						"description": "Number of lines to retrieve (default: 100)",
 					},
+					"max_output_bytes": map[string]interface{}{
+						"type":        "integer",
+						"description": fmt.Sprintf("Truncate the log beyond this many bytes (default %d); the rest is fetched with j0_get_output", mcpDefaultMaxOutputBytes),
+					},
 				},
 				"required": []string{"session_id"},
 			},
 		},
+		{
+			Name:        "j0_get_output",
+			Description: "Fetch the next chunk of output truncated by j0_execute or j0_get_log, using the *_cursor value from that result. Returns a further cursor while more remains.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"cursor": map[string]interface{}{
+						"type":        "string",
+						"description": "A stdout_cursor, stderr_cursor, compile_output_cursor, log_cursor or cursor value",
+					},
+					"max_output_bytes": map[string]interface{}{
+						"type":        "integer",
+						"description": fmt.Sprintf("Chunk size in bytes (default %d)", mcpDefaultMaxOutputBytes),
+					},
+				},
+				"required": []string{"cursor"},
+			},
+		},
 		{
 			Name:        "j0_close_session",
 			Description: "Close a session. The session log is preserved but no more executions can be performed.",
@@ -134,10 +313,91 @@ func MCPTools() []MCPTool {
 						"type":        "string",
 						"description": "Environment variable value",
 					},
+					"secret": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Store the value encrypted at rest, e.g. for API tokens",
+					},
 				},
 				"required": []string{"session_id", "key", "value"},
 			},
 		},
+		{
+			Name:        "j0_unset_env",
+			Description: "Remove an environment variable from a session. Subsequent executions no longer see it.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"session_id": map[string]interface{}{
+						"type":        "string",
+						"description": "The session ID to modify",
+					},
+					"key": map[string]interface{}{
+						"type":        "string",
+						"description": "Environment variable name",
+					},
+				},
+				"required": []string{"session_id", "key"},
+			},
+		},
+		{
+			Name:        "j0_write_file",
+			Description: "Create or overwrite a file in a session's workspace. Workspace files sit next to the program on every execution, so a multi-file project can be built up before running it.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"session_id": map[string]interface{}{
+						"type":        "string",
+						"description": "The session whose workspace to write to",
+					},
+					"path": map[string]interface{}{
+						"type":        "string",
+						"description": "File path relative to the workspace, e.g. src/util.py; directories are created",
+					},
+					"content": map[string]interface{}{
+						"type":        "string",
+						"description": "The file content",
+					},
+					"encoding": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"utf-8", "base64"},
+						"description": "How content is encoded (default utf-8; use base64 for binary files)",
+					},
+				},
+				"required": []string{"session_id", "path", "content"},
+			},
+		},
+		{
+			Name:        "j0_read_file",
+			Description: "Read a file from a session's workspace, including files written by executions. Binary files are returned base64-encoded.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"session_id": map[string]interface{}{
+						"type":        "string",
+						"description": "The session whose workspace to read from",
+					},
+					"path": map[string]interface{}{
+						"type":        "string",
+						"description": "File path relative to the workspace",
+					},
+				},
+				"required": []string{"session_id", "path"},
+			},
+		},
+		{
+			Name:        "j0_list_files",
+			Description: "List the files in a session's workspace with their sizes.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"session_id": map[string]interface{}{
+						"type":        "string",
+						"description": "The session whose workspace to list",
+					},
+				},
+				"required": []string{"session_id"},
+			},
+		},
 	}
 }
 
@@ -149,8 +409,15 @@ func SetupMCPEndpoints(mux *http.ServeMux) {
 	// Tool invocation endpoint
 	mux.HandleFunc("POST /mcp/invoke", handleMCPInvoke)
 
-	// Additional API endpoint for setting env vars
+	// MCP Streamable HTTP transport for remote MCP hosts
+	mux.HandleFunc("POST /mcp", handleMCPPost)
+	mux.HandleFunc("GET /mcp", handleMCPStream)
+	mux.HandleFunc("DELETE /mcp", handleMCPDelete)
+
+	// Additional API endpoints for managing env vars
 	mux.HandleFunc("POST /sessions/{id}/env", handleSetEnv)
+	mux.HandleFunc("PUT /sessions/{id}/env", handleReplaceEnv)
+	mux.HandleFunc("DELETE /sessions/{id}/env/{key}", handleUnsetEnv)
 }
 
 func handleMCPTools(w http.ResponseWriter, r *http.Request) {
@@ -169,29 +436,29 @@ func handleMCPInvoke(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var result interface{}
-	var err error
+	if !hasRole(r.Context(), RoleOperator) && !mcpReadOnlyTools[req.Tool] {
+		http.Error(w, "read-only credentials can't invoke "+req.Tool, http.StatusForbidden)
+		return
+	}
 
 	switch req.Tool {
 	case "j0_create_session":
-		result, err = invokeMCPCreateSession(req.Params)
+		if !createLimiter.Allow(w, r) {
+			return
+		}
 	case "j0_execute":
-		result, err = invokeMCPExecute(req.Params)
-	case "j0_get_session":
-		result, err = invokeMCPGetSession(req.Params)
-	case "j0_list_sessions":
-		result, err = invokeMCPListSessions(req.Params)
-	case "j0_get_log":
-		result, err = invokeMCPGetLog(req.Params)
-	case "j0_close_session":
-		result, err = invokeMCPCloseSession(req.Params)
-	case "j0_set_env":
-		result, err = invokeMCPSetEnv(req.Params)
-	default:
+		if !executeLimiter.Allow(w, r) {
+			return
+		}
+	}
+
+	invoke, ok := mcpInvokers[req.Tool]
+	if !ok {
 		http.Error(w, fmt.Sprintf("unknown tool: %s", req.Tool), http.StatusBadRequest)
 		return
 	}
 
+	result, err := invoke(r.Context(), req.Params)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -207,6 +474,8 @@ func handleSetEnv(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		Key   string `json:"key"`
 		Value string `json:"value"`
+		// Secret stores the value encrypted at rest
+		Secret bool `json:"secret,omitempty"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -214,10 +483,20 @@ func handleSetEnv(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := sessionManager.SetEnv(id, req.Key, req.Value); err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+	set := sessionsFor(r.Context()).SetEnv
+	if req.Secret {
+		set = sessionsFor(r.Context()).SetSecretEnv
+	}
+	if err := set(id, req.Key, req.Value); err != nil {
+		status := envErrorStatus(err)
+		if errors.Is(err, errNoSecretKey) {
+			status = http.StatusNotImplemented
+		}
+		http.Error(w, err.Error(), status)
 		return
 	}
+	// Values may be secrets; only the key is audited
+	audit(r.Context(), "session.env_set", id, map[string]interface{}{"key": req.Key, "secret": req.Secret})
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
@@ -225,130 +504,414 @@ func handleSetEnv(w http.ResponseWriter, r *http.Request) {
 
 // MCP Tool Invocation Helpers
 
-func invokeMCPCreateSession(params map[string]interface{}) (interface{}, error) {
+// mcpInvokers maps tool names to their implementations, shared by the HTTP
+// endpoint and the stdio server
+var mcpInvokers = map[string]func(ctx context.Context, params map[string]interface{}) (interface{}, error){
+	"j0_create_session":       invokeMCPCreateSession,
+	"j0_execute":              invokeMCPExecute,
+	"j0_preview":              invokeMCPPreview,
+	"j0_list_languages":       invokeMCPListLanguages,
+	"j0_get_session":          invokeMCPGetSession,
+	"j0_get_environment":      invokeMCPGetEnvironment,
+	"j0_set_session_defaults": invokeMCPSetDefaults,
+	"j0_list_sessions":        invokeMCPListSessions,
+	"j0_get_log":              invokeMCPGetLog,
+	"j0_get_output":           invokeMCPGetOutput,
+	"j0_close_session":        invokeMCPCloseSession,
+	"j0_set_env":              invokeMCPSetEnv,
+	"j0_unset_env":            invokeMCPUnsetEnv,
+	"j0_write_file":           invokeMCPWriteFile,
+	"j0_read_file":            invokeMCPReadFile,
+	"j0_list_files":           invokeMCPListFiles,
+}
+
+func invokeMCPCreateSession(ctx context.Context, params map[string]interface{}) (interface{}, error) {
 	language, _ := params["language"].(string)
 	name, _ := params["name"].(string)
+	strict, _ := params["strict"].(bool)
+	stateful, _ := params["stateful"].(bool)
+	accepted, _ := params["accept_policy"].(bool)
+	version, _ := params["version"].(string)
 
 	if language == "" {
 		return nil, fmt.Errorf("language is required")
 	}
+	if err := checkPolicyAccepted(accepted); err != nil {
+		return nil, err
+	}
 
-	if _, err := GetLanguageID(language); err != nil {
+	language, pinned, err := resolveLanguageSpec(ctx, language, version)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkStateful(language, stateful); err != nil {
 		return nil, err
 	}
 
-	return sessionManager.CreateSession(language, name)
+	opts := SessionOptions{Strict: strict, Stateful: stateful, Owner: principalFromContext(ctx), Language: pinned}
+
+	session, err := sessionsFor(ctx).CreateSession(language, name, opts)
+	if err != nil {
+		return nil, err
+	}
+	audit(ctx, "session.create", session.ID, map[string]interface{}{
+		"language": language,
+		"version":  session.Version,
+		"name":     name,
+		"strict":   strict,
+		"stateful": stateful,
+		"via":      "mcp",
+	})
+	return session, nil
 }
 
-func invokeMCPExecute(params map[string]interface{}) (interface{}, error) {
+func invokeMCPExecute(ctx context.Context, params map[string]interface{}) (interface{}, error) {
 	sessionID, _ := params["session_id"].(string)
 	code, _ := params["code"].(string)
 	stdin, _ := params["stdin"].(string)
+	stdinTemplate, _ := params["stdin_template"].(string)
+	trace, _ := params["trace"].(bool)
+	queueTimeout, _ := params["queue_timeout"].(float64)
+	timeout, _ := params["timeout"].(float64)
+	preview, _ := params["preview"].(bool)
+
+	var expectedExit *int
+	if v, ok := params["expected_exit_code"].(float64); ok {
+		code := int(v)
+		expectedExit = &code
+	}
+
+	var formatOverride *bool
+	if v, ok := params["format"].(bool); ok {
+		formatOverride = &v
+	}
+
+	stdinParams := make(map[string]string)
+	if raw, ok := params["stdin_params"].(map[string]interface{}); ok {
+		for k, v := range raw {
+			stdinParams[k] = fmt.Sprint(v)
+		}
+	}
+
+	captureEnv := make(map[string]string)
+	if raw, ok := params["capture_env"].(map[string]interface{}); ok {
+		for k, v := range raw {
+			captureEnv[k] = fmt.Sprint(v)
+		}
+	}
 
 	if sessionID == "" {
 		return nil, fmt.Errorf("session_id is required")
 	}
+	if err := authorizeSession(ctx, sessionID); err != nil {
+		return nil, err
+	}
 	if code == "" {
 		return nil, fmt.Errorf("code is required")
 	}
-
-	session, err := sessionManager.GetSession(sessionID)
+	maxOutput, err := maxOutputBytes(params)
 	if err != nil {
 		return nil, err
 	}
-
-	langID, err := GetLanguageID(session.Language)
+	submissionParams, err := submissionParamsFrom(params)
 	if err != nil {
 		return nil, err
 	}
 
-	fullCode := prepareCodeWithEnv(code, session.State.Env, session.Language)
-
-	startTime := time.Now()
-	result, err := judge0Client.Execute(fullCode, langID, stdin)
+	session, err := sessionsFor(ctx).GetSession(sessionID)
 	if err != nil {
 		return nil, err
 	}
-	duration := time.Since(startTime).Seconds() * 1000
+	langID, _ := GetLanguageID(session.Language)
+
+	// MCP clients that set a log level hear about queueing and status
+	// changes; those that sent a progress token get progress notifications
+	lastStatus := 0
+	exec, err := runExecution(ctx, sessionID, ExecRequest{
+		OnStart: func(execID string) {
+			mcpLog(ctx, "info", map[string]string{"session_id": sessionID, "execution_id": execID, "status": "queued"})
+			mcpProgress(ctx, "Execution "+execID+" accepted")
+		},
+		OnSlotWait: func(ahead int) {
+			mcpProgress(ctx, fmt.Sprintf("Waiting for a %s slot (%d ahead)", session.Language, ahead))
+		},
+		OnStatus: func(status Status) {
+			if status.ID == lastStatus {
+				return
+			}
+			lastStatus = status.ID
+			status = status.localized(defaultLocale)
+			mcpLog(ctx, "info", map[string]string{"session_id": sessionID, "status": status.Code, "summary": status.Summary})
+			switch {
+			case status.ID == 1:
+				mcpProgress(ctx, "Queued at the backend")
+			case status.ID == 2 && compiledLanguages[langID]:
+				mcpProgress(ctx, "Compiling and running")
+			case status.ID == 2:
+				mcpProgress(ctx, "Running")
+			}
+		},
+		Code:          code,
+		Stdin:         stdin,
+		StdinTemplate: stdinTemplate,
+		StdinParams:   stdinParams,
+		Trace:         trace,
+		QueueTimeout:  seconds(queueTimeout),
+		Timeout:       seconds(timeout),
+
+		ExpectedExitCode: expectedExit,
+		Format:           formatOverride,
+		CaptureEnv:       captureEnv,
+		Preview:          preview,
+		Params:           submissionParams,
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Judge0 only returns output once the run ends; the last notification
+	// previews it ahead of the full result
+	if exec.Phase == PhaseCompile {
+		mcpProgress(ctx, "Compilation failed: "+outputPreview(exec.CompileOutput))
+	} else {
+		mcpProgress(ctx, fmt.Sprintf("Finished with exit code %d: %s", exec.ExitCode, outputPreview(exec.Output)))
+	}
 
-	exec := Execution{
-		Code:     code,
-		Output:   result.Stdout,
-		Stderr:   result.Stderr,
-		ExitCode: result.ExitCode,
-		Time:     startTime,
-		Duration: duration,
+	resp := executionResponse(exec, defaultLocale)
+	for _, field := range []string{"stdout", "stderr", "compile_output"} {
+		truncateOutput(resp, field, maxOutput, outputCursor{SessionID: sessionID, ExecutionID: exec.ID})
 	}
+	return resp, nil
+}
 
-	sessionManager.AddExecution(sessionID, exec)
+// outputPreview is the start of an execution's stdout for progress messages
+func outputPreview(output string) string {
+	const max = 200
+	if output == "" {
+		return "(no output)"
+	}
+	if len(output) > max {
+		return strings.ToValidUTF8(output[:max], "") + "..."
+	}
+	return output
+}
 
-	return map[string]interface{}{
-		"stdout":    result.Stdout,
-		"stderr":    result.Stderr,
-		"exit_code": result.ExitCode,
-		"time_ms":   duration,
-	}, nil
+func invokeMCPPreview(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	sessionID, _ := params["session_id"].(string)
+	code, _ := params["code"].(string)
+	if sessionID == "" {
+		return nil, fmt.Errorf("session_id is required")
+	}
+	if err := authorizeSession(ctx, sessionID); err != nil {
+		return nil, err
+	}
+	if code == "" {
+		return nil, fmt.Errorf("code is required")
+	}
+	session, err := sessionsFor(ctx).GetSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return analyzeCode(session.Language, code), nil
 }
 
-func invokeMCPGetSession(params map[string]interface{}) (interface{}, error) {
+func invokeMCPGetSession(ctx context.Context, params map[string]interface{}) (interface{}, error) {
 	sessionID, _ := params["session_id"].(string)
 	if sessionID == "" {
 		return nil, fmt.Errorf("session_id is required")
 	}
-	return sessionManager.GetSession(sessionID)
+	if err := authorizeSession(ctx, sessionID); err != nil {
+		return nil, err
+	}
+	return sessionsFor(ctx).GetSession(sessionID)
 }
 
-func invokeMCPListSessions(params map[string]interface{}) (interface{}, error) {
-	return sessionManager.ListSessions(), nil
+func invokeMCPListSessions(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	return visibleSessions(ctx, sessionsFor(ctx).ListSessions()), nil
 }
 
-func invokeMCPGetLog(params map[string]interface{}) (interface{}, error) {
+func invokeMCPListLanguages(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	all, _ := params["all"].(bool)
+	languages, err := listLanguages(ctx, all)
+	result := map[string]interface{}{"languages": languages}
+	if err != nil {
+		result["backend_error"] = err.Error()
+	}
+	return result, nil
+}
+
+func invokeMCPGetLog(ctx context.Context, params map[string]interface{}) (interface{}, error) {
 	sessionID, _ := params["session_id"].(string)
 	if sessionID == "" {
 		return nil, fmt.Errorf("session_id is required")
 	}
+	if err := authorizeSession(ctx, sessionID); err != nil {
+		return nil, err
+	}
 
 	lines := 100
 	if l, ok := params["lines"].(float64); ok {
 		lines = int(l)
 	}
+	maxOutput, err := maxOutputBytes(params)
+	if err != nil {
+		return nil, err
+	}
 
-	content, err := sessionManager.GetLog(sessionID, lines)
+	session, err := sessionsFor(ctx).GetSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	content, err := sessionsFor(ctx).GetLog(sessionID, lines)
 	if err != nil {
 		return nil, err
 	}
 
-	return map[string]string{"log": content}, nil
+	// The excerpt is the end of the log, so cursors continue from where it starts
+	resp := map[string]interface{}{"log": content}
+	start := max(logSize(session.LogFile)-int64(len(content)), 0)
+	truncateOutput(resp, "log", maxOutput, outputCursor{SessionID: sessionID, Offset: start})
+	return resp, nil
 }
 
-func invokeMCPCloseSession(params map[string]interface{}) (interface{}, error) {
+func invokeMCPCloseSession(ctx context.Context, params map[string]interface{}) (interface{}, error) {
 	sessionID, _ := params["session_id"].(string)
 	if sessionID == "" {
 		return nil, fmt.Errorf("session_id is required")
 	}
+	if err := authorizeSession(ctx, sessionID); err != nil {
+		return nil, err
+	}
+	session, err := sessionsFor(ctx).GetSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if err := authorizeClose(ctx, session); err != nil {
+		return nil, err
+	}
 
-	if err := sessionManager.CloseSession(sessionID); err != nil {
+	if err := sessionsFor(ctx).CloseSession(sessionID); err != nil {
 		return nil, err
 	}
+	audit(ctx, "session.close", sessionID, map[string]interface{}{"via": "mcp"})
 
 	return map[string]string{"status": "closed"}, nil
 }
 
-func invokeMCPSetEnv(params map[string]interface{}) (interface{}, error) {
+func invokeMCPSetEnv(ctx context.Context, params map[string]interface{}) (interface{}, error) {
 	sessionID, _ := params["session_id"].(string)
 	key, _ := params["key"].(string)
 	value, _ := params["value"].(string)
+	secret, _ := params["secret"].(bool)
 
 	if sessionID == "" {
 		return nil, fmt.Errorf("session_id is required")
 	}
+	if err := authorizeSession(ctx, sessionID); err != nil {
+		return nil, err
+	}
 	if key == "" {
 		return nil, fmt.Errorf("key is required")
 	}
 
-	if err := sessionManager.SetEnv(sessionID, key, value); err != nil {
+	set := sessionsFor(ctx).SetEnv
+	if secret {
+		set = sessionsFor(ctx).SetSecretEnv
+	}
+	if err := set(sessionID, key, value); err != nil {
 		return nil, err
 	}
+	audit(ctx, "session.env_set", sessionID, map[string]interface{}{"key": key, "secret": secret, "via": "mcp"})
 
 	return map[string]string{"status": "ok"}, nil
 }
+
+func invokeMCPWriteFile(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	sessionID, _ := params["session_id"].(string)
+	path, _ := params["path"].(string)
+	content, ok := params["content"].(string)
+	encoding, _ := params["encoding"].(string)
+
+	if sessionID == "" {
+		return nil, fmt.Errorf("session_id is required")
+	}
+	if err := authorizeSession(ctx, sessionID); err != nil {
+		return nil, err
+	}
+	if path == "" {
+		return nil, fmt.Errorf("path is required")
+	}
+	if !ok {
+		return nil, fmt.Errorf("content is required")
+	}
+
+	data := []byte(content)
+	switch encoding {
+	case "", "utf-8":
+	case "base64":
+		var err error
+		if data, err = base64.StdEncoding.DecodeString(content); err != nil {
+			return nil, fmt.Errorf("invalid base64 content: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unknown encoding %q (want utf-8 or base64)", encoding)
+	}
+	if len(data) > maxWorkspaceBytes {
+		return nil, fmt.Errorf("file too large: %d bytes (max %d)", len(data), maxWorkspaceBytes)
+	}
+
+	if err := sessionsFor(ctx).WriteFile(sessionID, path, data); err != nil {
+		return nil, err
+	}
+	audit(ctx, "session.file_write", sessionID, map[string]interface{}{
+		"path":   path,
+		"bytes":  len(data),
+		"sha256": sha256Hex(data),
+		"via":    "mcp",
+	})
+
+	return map[string]interface{}{"path": path, "bytes": len(data)}, nil
+}
+
+func invokeMCPReadFile(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	sessionID, _ := params["session_id"].(string)
+	path, _ := params["path"].(string)
+
+	if sessionID == "" {
+		return nil, fmt.Errorf("session_id is required")
+	}
+	if err := authorizeSession(ctx, sessionID); err != nil {
+		return nil, err
+	}
+	if path == "" {
+		return nil, fmt.Errorf("path is required")
+	}
+
+	data, err := sessionsFor(ctx).ReadFile(sessionID, path)
+	if err != nil {
+		return nil, err
+	}
+	result := map[string]interface{}{"path": path, "bytes": len(data)}
+	if utf8.Valid(data) {
+		result["encoding"] = "utf-8"
+		result["content"] = string(data)
+	} else {
+		result["encoding"] = "base64"
+		result["content"] = base64.StdEncoding.EncodeToString(data)
+	}
+	return result, nil
+}
+
+func invokeMCPListFiles(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	sessionID, _ := params["session_id"].(string)
+	if sessionID == "" {
+		return nil, fmt.Errorf("session_id is required")
+	}
+	if err := authorizeSession(ctx, sessionID); err != nil {
+		return nil, err
+	}
+	files, err := sessionsFor(ctx).ListFiles(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"files": files}, nil
+}
@@ -0,0 +1,385 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MCP Streamable HTTP headers
+const (
+	mcpSessionHeader  = "Mcp-Session-Id"
+	mcpProtocolHeader = "MCP-Protocol-Version"
+)
+
+// mcpSessionIdleTimeout ends MCP sessions that haven't been used for a while
+const mcpSessionIdleTimeout = 30 * time.Minute
+
+// mcpKeepAlive is how often idle SSE streams get a comment so proxies
+// don't close them
+const mcpKeepAlive = 15 * time.Second
+
+// mcpHTTPSession is an MCP session on the Streamable HTTP transport. It is
+// bound to the principal that initialized it.
+type mcpHTTPSession struct {
+	id        string
+	principal string
+	state     mcpState
+
+	mu       sync.Mutex
+	lastSeen time.Time
+	// stream delivers notifications to the GET /mcp stream, if one is open
+	stream chan rpcMessage
+	closed chan struct{}
+}
+
+var mcpHTTPSessions = struct {
+	sync.Mutex
+	m map[string]*mcpHTTPSession
+}{m: make(map[string]*mcpHTTPSession)}
+
+func newMCPHTTPSession(principal string) *mcpHTTPSession {
+	b := make([]byte, 16)
+	rand.Read(b)
+	s := &mcpHTTPSession{
+		id:        hex.EncodeToString(b),
+		principal: principal,
+		lastSeen:  time.Now(),
+		closed:    make(chan struct{}),
+	}
+
+	mcpHTTPSessions.Lock()
+	defer mcpHTTPSessions.Unlock()
+	for id, old := range mcpHTTPSessions.m {
+		if old.idle() {
+			old.end()
+			delete(mcpHTTPSessions.m, id)
+		}
+	}
+	mcpHTTPSessions.m[s.id] = s
+	return s
+}
+
+func (s *mcpHTTPSession) idle() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stream == nil && time.Since(s.lastSeen) > mcpSessionIdleTimeout
+}
+
+func (s *mcpHTTPSession) touch() {
+	s.mu.Lock()
+	s.lastSeen = time.Now()
+	s.mu.Unlock()
+}
+
+// end closes the session's GET stream
+func (s *mcpHTTPSession) end() {
+	select {
+	case <-s.closed:
+	default:
+		close(s.closed)
+	}
+}
+
+// notify sends a notification on the GET stream. Without an open stream, or
+// when the client isn't keeping up, it is dropped.
+func (s *mcpHTTPSession) notify(msg rpcMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stream == nil {
+		return
+	}
+	select {
+	case s.stream <- msg:
+	default:
+	}
+}
+
+// lookupMCPSession finds the request's MCP session, writing the error the
+// spec asks for when there is none: 400 without a session ID and 404 for
+// unknown or expired sessions, which tells the client to initialize again
+func lookupMCPSession(w http.ResponseWriter, r *http.Request) *mcpHTTPSession {
+	id := r.Header.Get(mcpSessionHeader)
+	if id == "" {
+		http.Error(w, "missing "+mcpSessionHeader+" header; initialize first", http.StatusBadRequest)
+		return nil
+	}
+
+	mcpHTTPSessions.Lock()
+	s, ok := mcpHTTPSessions.m[id]
+	if ok && s.idle() {
+		s.end()
+		delete(mcpHTTPSessions.m, id)
+		ok = false
+	}
+	mcpHTTPSessions.Unlock()
+
+	// Another caller's session ID is as good as unknown
+	if !ok || s.principal != principalFromContext(r.Context()) {
+		http.Error(w, "MCP session not found", http.StatusNotFound)
+		return nil
+	}
+	s.touch()
+	return s
+}
+
+// checkMCPOrigin rejects browser requests from other origins, guarding
+// local servers against DNS rebinding
+func checkMCPOrigin(w http.ResponseWriter, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host != r.Host {
+		http.Error(w, "origin not allowed: "+origin, http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// checkMCPProtocolVersion rejects protocol versions this server doesn't
+// speak. Clients that don't send the header are assumed to speak 2025-03-26.
+func checkMCPProtocolVersion(w http.ResponseWriter, r *http.Request) bool {
+	version := r.Header.Get(mcpProtocolHeader)
+	if version == "" {
+		return true
+	}
+	for _, v := range mcpProtocolVersions {
+		if v == version {
+			return true
+		}
+	}
+	http.Error(w, "unsupported "+mcpProtocolHeader+": "+version, http.StatusBadRequest)
+	return false
+}
+
+func acceptsEventStream(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+// writeRPC writes a single JSON-RPC message as the response body
+func writeRPC(w http.ResponseWriter, status int, msg rpcMessage) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(msg)
+}
+
+func rpcResponse(id *json.RawMessage, result interface{}, rerr *rpcError) rpcMessage {
+	if id == nil {
+		null := json.RawMessage("null")
+		id = &null
+	}
+	msg := rpcMessage{JSONRPC: "2.0", ID: id, Result: result, Error: rerr}
+	if rerr == nil && result == nil {
+		msg.Result = struct{}{}
+	}
+	return msg
+}
+
+// handleMCPPost serves POST /mcp: one JSON-RPC message per request.
+// Notifications and responses are acknowledged with 202. Requests are
+// answered with JSON, or with an SSE stream carrying progress notifications
+// and then the response when the client accepts text/event-stream.
+func handleMCPPost(w http.ResponseWriter, r *http.Request) {
+	if !checkMCPOrigin(w, r) {
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 16<<20))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		writeRPC(w, http.StatusBadRequest, rpcResponse(nil, nil, &rpcError{Code: rpcInvalidRequest, Message: "batches are not supported"}))
+		return
+	}
+	var msg rpcMessage
+	if err := json.Unmarshal(body, &msg); err != nil {
+		writeRPC(w, http.StatusBadRequest, rpcResponse(nil, nil, &rpcError{Code: rpcParseError, Message: "parse error: " + err.Error()}))
+		return
+	}
+	if msg.JSONRPC != "2.0" || (msg.Method == "" && msg.Result == nil && msg.Error == nil) {
+		writeRPC(w, http.StatusBadRequest, rpcResponse(msg.ID, nil, &rpcError{Code: rpcInvalidRequest, Message: "invalid request"}))
+		return
+	}
+
+	if msg.Method == "initialize" {
+		if msg.ID == nil {
+			writeRPC(w, http.StatusBadRequest, rpcResponse(nil, nil, &rpcError{Code: rpcInvalidRequest, Message: "initialize must be a request"}))
+			return
+		}
+		session := newMCPHTTPSession(principalFromContext(r.Context()))
+		result, rerr := handleMCPRequest(r.Context(), &session.state, msg, session.notify)
+		w.Header().Set(mcpSessionHeader, session.id)
+		writeRPC(w, http.StatusOK, rpcResponse(msg.ID, result, rerr))
+		return
+	}
+
+	if !checkMCPProtocolVersion(w, r) {
+		return
+	}
+	session := lookupMCPSession(w, r)
+	if session == nil {
+		return
+	}
+
+	// Notifications (including notifications/initialized) and responses
+	// need no answer. A client cancels a request by closing its stream.
+	if msg.ID == nil || msg.Method == "" {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	if msg.Method == "tools/call" {
+		if err := authorizeMCPCall(r, msg); err != nil {
+			writeRPC(w, http.StatusOK, rpcResponse(msg.ID, mcpToolResult(err.Error(), nil, true), nil))
+			return
+		}
+	}
+
+	if msg.Method != "tools/call" || !acceptsEventStream(r) {
+		result, rerr := handleMCPRequest(r.Context(), &session.state, msg, session.notify)
+		writeRPC(w, http.StatusOK, rpcResponse(msg.ID, result, rerr))
+		return
+	}
+
+	sse, err := newSSEWriter(w)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var mu sync.Mutex
+	send := func(m rpcMessage) {
+		mu.Lock()
+		defer mu.Unlock()
+		sse.Event("message", m)
+	}
+	mu.Lock()
+	sse.start()
+	mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(mcpKeepAlive)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				mu.Lock()
+				fmt.Fprint(w, ": keepalive\n\n")
+				sse.flusher.Flush()
+				mu.Unlock()
+			}
+		}
+	}()
+
+	result, rerr := handleMCPRequest(r.Context(), &session.state, msg, send)
+	close(done)
+	if r.Context().Err() != nil {
+		return
+	}
+	send(rpcResponse(msg.ID, result, rerr))
+}
+
+// authorizeMCPCall applies the per-tool role check and rate limits that
+// POST /mcp/invoke applies
+func authorizeMCPCall(r *http.Request, msg rpcMessage) error {
+	params, err := mcpCallParams(msg)
+	if err != nil {
+		return nil
+	}
+	if !hasRole(r.Context(), RoleOperator) && !mcpReadOnlyTools[params.Name] {
+		return fmt.Errorf("read-only credentials can't invoke %s", params.Name)
+	}
+	switch params.Name {
+	case "j0_create_session":
+		return createLimiter.AllowClient(r)
+	case "j0_execute":
+		return executeLimiter.AllowClient(r)
+	}
+	return nil
+}
+
+// handleMCPStream serves GET /mcp: an SSE stream of notifications for the
+// session that aren't tied to a streamed request. A session has at most one.
+func handleMCPStream(w http.ResponseWriter, r *http.Request) {
+	if !checkMCPOrigin(w, r) || !checkMCPProtocolVersion(w, r) {
+		return
+	}
+	if !acceptsEventStream(r) {
+		http.Error(w, "GET /mcp requires Accept: text/event-stream", http.StatusNotAcceptable)
+		return
+	}
+	session := lookupMCPSession(w, r)
+	if session == nil {
+		return
+	}
+	sse, err := newSSEWriter(w)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	stream := make(chan rpcMessage, 64)
+	session.mu.Lock()
+	if session.stream != nil {
+		session.mu.Unlock()
+		http.Error(w, "this MCP session already has an open stream", http.StatusConflict)
+		return
+	}
+	session.stream = stream
+	session.mu.Unlock()
+	defer func() {
+		session.mu.Lock()
+		session.stream = nil
+		session.lastSeen = time.Now()
+		session.mu.Unlock()
+	}()
+
+	sse.start()
+	ticker := time.NewTicker(mcpKeepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case msg := <-stream:
+			if sse.Event("message", msg) != nil {
+				return
+			}
+		case <-ticker.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+			sse.flusher.Flush()
+		case <-session.closed:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// handleMCPDelete serves DELETE /mcp: the client ends its session
+func handleMCPDelete(w http.ResponseWriter, r *http.Request) {
+	if !checkMCPOrigin(w, r) {
+		return
+	}
+	session := lookupMCPSession(w, r)
+	if session == nil {
+		return
+	}
+	mcpHTTPSessions.Lock()
+	delete(mcpHTTPSessions.m, session.id)
+	mcpHTTPSessions.Unlock()
+	session.end()
+	w.WriteHeader(http.StatusNoContent)
+}
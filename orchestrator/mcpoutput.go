@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+)
+
+// mcpDefaultMaxOutputBytes bounds each output field of an MCP tool result
+// unless the caller passes max_output_bytes, to protect model context windows
+const mcpDefaultMaxOutputBytes = 16 << 10
+
+// outputCursor locates the rest of a truncated output. It is handed to
+// clients base64-encoded and is opaque to them.
+type outputCursor struct {
+	SessionID   string `json:"s"`
+	ExecutionID string `json:"e,omitempty"`
+	// Field is stdout, stderr, compile_output or log
+	Field  string `json:"f"`
+	Offset int64  `json:"o"`
+}
+
+func (c outputCursor) String() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func parseOutputCursor(s string) (outputCursor, error) {
+	var c outputCursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || json.Unmarshal(data, &c) != nil || c.SessionID == "" || c.Offset < 0 {
+		return c, fmt.Errorf("invalid cursor")
+	}
+	return c, nil
+}
+
+// maxOutputBytes reads the max_output_bytes tool parameter
+func maxOutputBytes(params map[string]interface{}) (int, error) {
+	v, ok := params["max_output_bytes"].(float64)
+	if !ok {
+		return mcpDefaultMaxOutputBytes, nil
+	}
+	if v < 1 {
+		return 0, fmt.Errorf("max_output_bytes must be positive")
+	}
+	return int(v), nil
+}
+
+// runeCut returns n, moved back so s[:n] doesn't end inside a UTF-8 sequence
+func runeCut(s string, n int) int {
+	if n >= len(s) {
+		return len(s)
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return n
+}
+
+// truncateOutput keeps the first max bytes of a tool result field. When it
+// cuts, the field ends with a marker and a cursor for j0_get_output is
+// stored under <field>_cursor.
+func truncateOutput(resp map[string]interface{}, field string, max int, cursor outputCursor) {
+	text, _ := resp[field].(string)
+	if len(text) <= max {
+		return
+	}
+	cut := runeCut(text, max)
+	cursor.Field = field
+	cursor.Offset += int64(cut)
+	resp[field] = text[:cut] + fmt.Sprintf("\n[truncated: showing %d of %d bytes; call j0_get_output with %s_cursor for the rest]", cut, len(text), field)
+	resp[field+"_truncated"] = true
+	resp[field+"_cursor"] = cursor.String()
+}
+
+// invokeMCPGetOutput serves j0_get_output: the next chunk of a truncated
+// execution output or log
+func invokeMCPGetOutput(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	raw, _ := params["cursor"].(string)
+	if raw == "" {
+		return nil, fmt.Errorf("cursor is required")
+	}
+	cursor, err := parseOutputCursor(raw)
+	if err != nil {
+		return nil, err
+	}
+	max, err := maxOutputBytes(params)
+	if err != nil {
+		return nil, err
+	}
+	if err := authorizeSession(ctx, cursor.SessionID); err != nil {
+		return nil, err
+	}
+
+	var text string
+	var total int64
+	switch cursor.Field {
+	case "log":
+		session, err := sessionsFor(ctx).GetSession(cursor.SessionID)
+		if err != nil {
+			return nil, err
+		}
+		// Read a little extra so a chunk can end on a rune boundary
+		page, err := readLogRange(session.LogFile, cursor.Offset, int64(max)+utf8.UTFMax)
+		if err != nil {
+			return nil, err
+		}
+		text, total = page.Data, page.Size
+	case "stdout", "stderr", "compile_output":
+		exec, err := sessionsFor(ctx).GetExecution(cursor.SessionID, cursor.ExecutionID)
+		if err != nil {
+			return nil, err
+		}
+		full := map[string]string{"stdout": exec.Output, "stderr": exec.Stderr, "compile_output": exec.CompileOutput}[cursor.Field]
+		total = int64(len(full))
+		if cursor.Offset < total {
+			text = full[cursor.Offset:]
+		}
+	default:
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	cut := runeCut(text, max)
+	if cut == 0 && text != "" {
+		// Always make progress, even when max is smaller than a rune
+		_, cut = utf8.DecodeRuneInString(text)
+	}
+	result := map[string]interface{}{
+		"data":        text[:cut],
+		"offset":      cursor.Offset,
+		"total_bytes": total,
+	}
+	if next := cursor.Offset + int64(cut); next < total {
+		cursor.Offset = next
+		result["cursor"] = cursor.String()
+	}
+	return result, nil
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// mcpPromptLogLines is the default log excerpt included in prompts
+const mcpPromptLogLines = 200
+
+// mcpPromptArgument describes a prompt parameter for prompts/list
+type mcpPromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+// mcpPrompt is a parameterized prompt template
+type mcpPrompt struct {
+	Name        string              `json:"name"`
+	Title       string              `json:"title"`
+	Description string              `json:"description"`
+	Arguments   []mcpPromptArgument `json:"arguments"`
+
+	build func(ctx context.Context, args map[string]string) (string, []interface{}, error)
+}
+
+var sessionIDArgument = mcpPromptArgument{Name: "session_id", Description: "The session ID", Required: true}
+
+var mcpPrompts = []mcpPrompt{
+	{
+		Name:        "debug_execution",
+		Title:       "Debug a failing execution",
+		Description: "Explain why an execution failed and suggest a fix, with its code, output and the session log leading up to it",
+		Arguments: []mcpPromptArgument{
+			sessionIDArgument,
+			{Name: "execution_id", Description: "The execution to debug (default: the session's latest failed execution)"},
+		},
+		build: buildDebugPrompt,
+	},
+	{
+		Name:        "summarize_session",
+		Title:       "Summarize session activity",
+		Description: "Summarize what was done in a session, what worked and what failed, from its statistics and log",
+		Arguments: []mcpPromptArgument{
+			sessionIDArgument,
+			{Name: "lines", Description: fmt.Sprintf("Log lines to include (default %d, 0 for the whole log)", mcpPromptLogLines)},
+		},
+		build: buildSummarizePrompt,
+	},
+	{
+		Name:        "review_code",
+		Title:       "Review code before running it",
+		Description: "Review untrusted code for what it would do before running it in a session, with its static preview",
+		Arguments: []mcpPromptArgument{
+			sessionIDArgument,
+			{Name: "code", Description: "The code to review", Required: true},
+		},
+		build: buildReviewPrompt,
+	},
+}
+
+// mcpListPrompts serves prompts/list
+func mcpListPrompts() interface{} {
+	return map[string]interface{}{"prompts": mcpPrompts}
+}
+
+// mcpGetPrompt serves prompts/get: the prompt's text followed by the
+// session log excerpt as an embedded resource
+func mcpGetPrompt(ctx context.Context, params json.RawMessage) (interface{}, *rpcError) {
+	var req struct {
+		Name      string            `json:"name"`
+		Arguments map[string]string `json:"arguments"`
+	}
+	if err := json.Unmarshal(params, &req); err != nil {
+		return nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+	}
+
+	var prompt *mcpPrompt
+	for i := range mcpPrompts {
+		if mcpPrompts[i].Name == req.Name {
+			prompt = &mcpPrompts[i]
+		}
+	}
+	if prompt == nil {
+		return nil, &rpcError{Code: rpcInvalidParams, Message: "unknown prompt: " + req.Name}
+	}
+	for _, arg := range prompt.Arguments {
+		if arg.Required && req.Arguments[arg.Name] == "" {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: "missing required argument: " + arg.Name}
+		}
+	}
+	if err := authorizeSession(ctx, req.Arguments["session_id"]); err != nil {
+		return nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+	}
+
+	text, attachments, err := prompt.build(ctx, req.Arguments)
+	if err != nil {
+		return nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+	}
+
+	messages := []map[string]interface{}{
+		{"role": "user", "content": map[string]string{"type": "text", "text": text}},
+	}
+	for _, a := range attachments {
+		messages = append(messages, map[string]interface{}{"role": "user", "content": a})
+	}
+	return map[string]interface{}{
+		"description": prompt.Description,
+		"messages":    messages,
+	}, nil
+}
+
+// logExcerpt embeds the tail of a session log as a resource
+func logExcerpt(ctx context.Context, sessionID string, lines int) (interface{}, error) {
+	log, err := sessionsFor(ctx).GetLog(sessionID, lines)
+	if err != nil {
+		return nil, err
+	}
+	if log == "" {
+		log = "(the session log is empty)"
+	}
+	return map[string]interface{}{
+		"type": "resource",
+		"resource": map[string]string{
+			"uri":      sessionLogURI(sessionID),
+			"mimeType": "text/plain",
+			"text":     log,
+		},
+	}, nil
+}
+
+func buildDebugPrompt(ctx context.Context, args map[string]string) (string, []interface{}, error) {
+	sm := sessionsFor(ctx)
+	session, err := sm.GetSession(args["session_id"])
+	if err != nil {
+		return "", nil, err
+	}
+
+	var exec *Execution
+	if id := args["execution_id"]; id != "" {
+		if exec, err = sm.GetExecution(session.ID, id); err != nil {
+			return "", nil, err
+		}
+	} else {
+		for i := len(session.State.History) - 1; i >= 0; i-- {
+			if !executionPassed(&session.State.History[i]) {
+				e := session.State.History[i]
+				exec = &e
+				break
+			}
+		}
+		if exec == nil {
+			return "", nil, fmt.Errorf("session %s has no failed executions; pass execution_id", session.ID)
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "This %s execution (%s) in session %s did not do what was expected. ", session.Language, exec.ID, session.ID)
+	b.WriteString("Explain the cause of the failure and suggest a corrected version of the code.\n\n")
+	fmt.Fprintf(&b, "Code:\n```%s\n%s\n```\n\n", session.Language, exec.Code)
+	fmt.Fprintf(&b, "Exit code: %d", exec.ExitCode)
+	if exec.ExpectedExitCode != nil {
+		fmt.Fprintf(&b, " (expected %d)", *exec.ExpectedExitCode)
+	}
+	b.WriteString("\n")
+	if exec.Status != "" {
+		fmt.Fprintf(&b, "Status: %s (%s)\n", exec.Status, statusSummary(exec.Status, defaultLocale))
+	}
+	if exec.CompileOutput != "" {
+		fmt.Fprintf(&b, "\nCompiler output:\n```\n%s\n```\n", exec.CompileOutput)
+	}
+	fmt.Fprintf(&b, "\nStdout:\n```\n%s\n```\n", exec.Output)
+	if exec.Stderr != "" {
+		fmt.Fprintf(&b, "\nStderr:\n```\n%s\n```\n", exec.Stderr)
+	}
+	if len(session.State.Env) > 0 {
+		// Names only: values may be secrets
+		names := make([]string, 0, len(session.State.Env))
+		for name := range session.State.Env {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Fprintf(&b, "\nSession environment variables: %s\n", strings.Join(names, ", "))
+	}
+	b.WriteString("\nThe session log leading up to the failure follows.")
+
+	excerpt, err := logExcerpt(ctx, session.ID, mcpPromptLogLines)
+	if err != nil {
+		return "", nil, err
+	}
+	return b.String(), []interface{}{excerpt}, nil
+}
+
+func buildSummarizePrompt(ctx context.Context, args map[string]string) (string, []interface{}, error) {
+	sm := sessionsFor(ctx)
+	session, err := sm.GetSession(args["session_id"])
+	if err != nil {
+		return "", nil, err
+	}
+
+	lines := mcpPromptLogLines
+	if v := args["lines"]; v != "" {
+		if lines, err = strconv.Atoi(v); err != nil || lines < 0 {
+			return "", nil, fmt.Errorf("invalid lines: %s", v)
+		}
+	}
+
+	report, err := sm.BuildReport(*session)
+	if err != nil {
+		return "", nil, err
+	}
+	stats, _ := json.MarshalIndent(report, "", "  ")
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Summarize the activity in %s session %s", session.Language, sessionLabel(session))
+	b.WriteString(": what was attempted, what worked, what failed and why, and what remains unresolved. Keep it brief.\n\n")
+	fmt.Fprintf(&b, "Session statistics:\n```json\n%s\n```\n\n", stats)
+	if lines > 0 {
+		fmt.Fprintf(&b, "The last %d lines of the session log follow.", lines)
+	} else {
+		b.WriteString("The session log follows.")
+	}
+
+	excerpt, err := logExcerpt(ctx, session.ID, lines)
+	if err != nil {
+		return "", nil, err
+	}
+	return b.String(), []interface{}{excerpt}, nil
+}
+
+func buildReviewPrompt(ctx context.Context, args map[string]string) (string, []interface{}, error) {
+	session, err := sessionsFor(ctx).GetSession(args["session_id"])
+	if err != nil {
+		return "", nil, err
+	}
+	preview, _ := json.MarshalIndent(analyzeCode(session.Language, args["code"]), "", "  ")
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Review this %s code before it runs in session %s. ", session.Language, session.ID)
+	b.WriteString("Describe what it does, point out anything that reaches the network, writes files, starts processes or evaluates dynamic code, and say whether it is safe to run.\n\n")
+	fmt.Fprintf(&b, "Code:\n```%s\n%s\n```\n\n", session.Language, args["code"])
+	fmt.Fprintf(&b, "Static analysis (pattern-based; it may miss or over-report indicators):\n```json\n%s\n```\n", preview)
+	return b.String(), nil, nil
+}
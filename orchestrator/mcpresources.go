@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"path"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// MCP resource URIs:
+//
+//	j0://sessions/{id}/log           the session log
+//	j0://sessions/{id}/files/{path}  a file in the session workspace
+const mcpResourcePrefix = "j0://sessions/"
+
+// mcpResourcePageSize bounds a resources/list page
+const mcpResourcePageSize = 100
+
+// rpcResourceNotFound is the MCP error code for unknown resources
+const rpcResourceNotFound = -32002
+
+// mcpResource describes a resource for resources/list
+type mcpResource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+	Size        int64  `json:"size,omitempty"`
+}
+
+func sessionLogURI(sessionID string) string {
+	return mcpResourcePrefix + sessionID + "/log"
+}
+
+func sessionFileURI(sessionID, rel string) string {
+	return mcpResourcePrefix + sessionID + "/files/" + rel
+}
+
+// parseResourceURI splits a resource URI into its session ID and, for
+// workspace files, the file path
+func parseResourceURI(uri string) (sessionID, file string, isLog bool, err error) {
+	rest, ok := strings.CutPrefix(uri, mcpResourcePrefix)
+	if !ok {
+		return "", "", false, fmt.Errorf("unknown resource URI: %s", uri)
+	}
+	sessionID, kind, _ := strings.Cut(rest, "/")
+	switch {
+	case sessionID == "":
+	case kind == "log":
+		return sessionID, "", true, nil
+	case strings.HasPrefix(kind, "files/") && len(kind) > len("files/"):
+		return sessionID, strings.TrimPrefix(kind, "files/"), false, nil
+	}
+	return "", "", false, fmt.Errorf("unknown resource URI: %s", uri)
+}
+
+// fileMimeType guesses a workspace file's type from its extension
+func fileMimeType(name string) string {
+	if t := mime.TypeByExtension(path.Ext(name)); t != "" {
+		return t
+	}
+	return "text/plain"
+}
+
+// mcpListResources serves resources/list: the log and workspace files of
+// every session the caller can see, in pages of mcpResourcePageSize
+func mcpListResources(ctx context.Context, params json.RawMessage) (interface{}, *rpcError) {
+	var req struct {
+		Cursor string `json:"cursor"`
+	}
+	json.Unmarshal(params, &req)
+	offset := 0
+	if req.Cursor != "" {
+		n, err := strconv.Atoi(req.Cursor)
+		if err != nil || n < 0 {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: "invalid cursor"}
+		}
+		offset = n
+	}
+
+	sm := sessionsFor(ctx)
+	resources := []mcpResource{}
+	for _, s := range visibleSessions(ctx, sm.ListSessions()) {
+		resources = append(resources, mcpResource{
+			URI:         sessionLogURI(s.ID),
+			Name:        s.ID + ".log",
+			Title:       fmt.Sprintf("Log of %s (%s)", sessionLabel(s), s.Language),
+			Description: "Code and output of every execution in the session",
+			MimeType:    "text/plain",
+		})
+		files, err := sm.ListFiles(s.ID)
+		if err != nil {
+			continue
+		}
+		for _, f := range files {
+			resources = append(resources, mcpResource{
+				URI:      sessionFileURI(s.ID, f.Path),
+				Name:     f.Path,
+				Title:    fmt.Sprintf("%s in %s", f.Path, sessionLabel(s)),
+				MimeType: fileMimeType(f.Path),
+				Size:     f.Size,
+			})
+		}
+	}
+
+	result := map[string]interface{}{}
+	if offset > len(resources) {
+		offset = len(resources)
+	}
+	end := offset + mcpResourcePageSize
+	if end < len(resources) {
+		result["nextCursor"] = strconv.Itoa(end)
+	} else {
+		end = len(resources)
+	}
+	result["resources"] = resources[offset:end]
+	return result, nil
+}
+
+// sessionLabel is a session's name, or its ID when unnamed
+func sessionLabel(s *Session) string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return s.ID
+}
+
+// mcpResourceTemplates serves resources/templates/list
+func mcpResourceTemplates() interface{} {
+	return map[string]interface{}{
+		"resourceTemplates": []map[string]string{
+			{
+				"uriTemplate": mcpResourcePrefix + "{session_id}/log",
+				"name":        "session-log",
+				"description": "A session's log: code and output of every execution",
+				"mimeType":    "text/plain",
+			},
+			{
+				"uriTemplate": mcpResourcePrefix + "{session_id}/files/{path}",
+				"name":        "session-file",
+				"description": "A file in a session's workspace",
+			},
+		},
+	}
+}
+
+// mcpReadResource serves resources/read. Text is returned as text, other
+// files base64-encoded as a blob.
+func mcpReadResource(ctx context.Context, params json.RawMessage) (interface{}, *rpcError) {
+	var req struct {
+		URI string `json:"uri"`
+	}
+	if err := json.Unmarshal(params, &req); err != nil || req.URI == "" {
+		return nil, &rpcError{Code: rpcInvalidParams, Message: "uri is required"}
+	}
+	sessionID, file, isLog, err := parseResourceURI(req.URI)
+	if err != nil {
+		return nil, &rpcError{Code: rpcResourceNotFound, Message: err.Error()}
+	}
+	// Other callers' sessions are reported as missing
+	if err := authorizeSession(ctx, sessionID); err != nil {
+		return nil, &rpcError{Code: rpcResourceNotFound, Message: "resource not found: " + req.URI}
+	}
+
+	content := map[string]string{"uri": req.URI}
+	if isLog {
+		log, err := sessionsFor(ctx).GetLog(sessionID, 0)
+		if err != nil {
+			return nil, &rpcError{Code: rpcResourceNotFound, Message: err.Error()}
+		}
+		content["mimeType"] = "text/plain"
+		content["text"] = log
+	} else {
+		data, err := sessionsFor(ctx).ReadFile(sessionID, file)
+		if err != nil {
+			return nil, &rpcError{Code: rpcResourceNotFound, Message: err.Error()}
+		}
+		content["mimeType"] = fileMimeType(file)
+		if utf8.Valid(data) {
+			content["text"] = string(data)
+		} else {
+			content["mimeType"] = "application/octet-stream"
+			content["blob"] = base64.StdEncoding.EncodeToString(data)
+		}
+	}
+	return map[string]interface{}{"contents": []map[string]string{content}}, nil
+}
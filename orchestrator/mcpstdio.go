@@ -0,0 +1,385 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/spf13/cobra"
+)
+
+// MCP protocol versions this server speaks, newest first. A client asking
+// for another version gets the newest one and decides whether to continue.
+var mcpProtocolVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+// JSON-RPC error codes
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+)
+
+// rpcMessage is a JSON-RPC 2.0 request, notification or response
+type rpcMessage struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  interface{}      `json:"result,omitempty"`
+	Error   *rpcError        `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// mcpStdioServer serves MCP over newline-delimited JSON-RPC. Requests are
+// handled concurrently so pings and cancellations get through while an
+// execution runs.
+type mcpStdioServer struct {
+	ctx   context.Context
+	out   io.Writer
+	state mcpState
+
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	// pending maps request IDs to their cancel functions
+	pending map[string]context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// Serve reads messages until in is closed
+func (s *mcpStdioServer) Serve(in io.Reader) error {
+	s.pending = map[string]context.CancelFunc{}
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var msg rpcMessage
+		if err := json.Unmarshal(line, &msg); err != nil {
+			s.reply(nil, nil, &rpcError{Code: rpcParseError, Message: "parse error: " + err.Error()})
+			continue
+		}
+		if msg.Method == "" {
+			// Responses to requests we never send are ignored
+			if msg.Result == nil && msg.Error == nil {
+				s.reply(msg.ID, nil, &rpcError{Code: rpcInvalidRequest, Message: "invalid request"})
+			}
+			continue
+		}
+		if msg.JSONRPC != "2.0" {
+			if msg.ID != nil {
+				s.reply(msg.ID, nil, &rpcError{Code: rpcInvalidRequest, Message: "jsonrpc must be \"2.0\""})
+			}
+			continue
+		}
+
+		if msg.ID == nil {
+			s.notification(msg)
+			continue
+		}
+
+		ctx, cancel := context.WithCancel(s.ctx)
+		key := string(*msg.ID)
+		s.mu.Lock()
+		s.pending[key] = cancel
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			result, rerr := handleMCPRequest(ctx, &s.state, msg, s.send)
+
+			s.mu.Lock()
+			delete(s.pending, key)
+			s.mu.Unlock()
+			cancelled := ctx.Err() != nil
+			cancel()
+
+			// Cancelled requests get no response
+			if !cancelled {
+				s.reply(msg.ID, result, rerr)
+			}
+		}()
+	}
+
+	s.wg.Wait()
+	return scanner.Err()
+}
+
+// notification handles messages that expect no response
+func (s *mcpStdioServer) notification(msg rpcMessage) {
+	if msg.Method != "notifications/cancelled" {
+		return
+	}
+	var params struct {
+		RequestID json.RawMessage `json:"requestId"`
+	}
+	if json.Unmarshal(msg.Params, &params) != nil {
+		return
+	}
+	s.mu.Lock()
+	if cancel, ok := s.pending[string(params.RequestID)]; ok {
+		cancel()
+	}
+	s.mu.Unlock()
+}
+
+func (s *mcpStdioServer) reply(id *json.RawMessage, result interface{}, rerr *rpcError) {
+	s.send(rpcResponse(id, result, rerr))
+}
+
+// send writes one message per line
+func (s *mcpStdioServer) send(msg rpcMessage) {
+	data, _ := json.Marshal(msg)
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.out.Write(append(data, '\n'))
+}
+
+// mcpLogLevels ranks the syslog-style levels of notifications/message
+var mcpLogLevels = map[string]int{
+	"debug": 0, "info": 1, "notice": 2, "warning": 3,
+	"error": 4, "critical": 5, "alert": 6, "emergency": 7,
+}
+
+// mcpState is per-connection protocol state shared by the stdio and HTTP
+// transports
+type mcpState struct {
+	mu       sync.Mutex
+	logLevel string
+	// protocolVersion is negotiated by initialize
+	protocolVersion string
+}
+
+// mcpCall carries what a tool invocation needs to notify its client
+type mcpCall struct {
+	state  *mcpState
+	notify func(rpcMessage)
+
+	// progressToken is the request's _meta.progressToken; progress
+	// notifications are only sent when the client supplied one
+	progressToken json.RawMessage
+	mu            sync.Mutex
+	progress      int
+}
+
+type mcpCallKey struct{}
+
+// mcpLog sends a notifications/message to the MCP client behind ctx if it
+// asked for messages at level, and is a no-op elsewhere
+func mcpLog(ctx context.Context, level string, data interface{}) {
+	call, ok := ctx.Value(mcpCallKey{}).(*mcpCall)
+	if !ok {
+		return
+	}
+	call.state.mu.Lock()
+	threshold := call.state.logLevel
+	call.state.mu.Unlock()
+	if threshold == "" || mcpLogLevels[level] < mcpLogLevels[threshold] {
+		return
+	}
+
+	params, _ := json.Marshal(map[string]interface{}{"level": level, "logger": "j0", "data": data})
+	call.notify(rpcMessage{JSONRPC: "2.0", Method: "notifications/message", Params: params})
+}
+
+// mcpProgress sends a notifications/progress for the tool call behind ctx
+// if its client asked for progress, and is a no-op elsewhere. Progress
+// counts the steps reported so far; the total isn't known in advance.
+func mcpProgress(ctx context.Context, message string) {
+	call, ok := ctx.Value(mcpCallKey{}).(*mcpCall)
+	if !ok || len(call.progressToken) == 0 {
+		return
+	}
+	call.mu.Lock()
+	call.progress++
+	progress := call.progress
+	call.mu.Unlock()
+
+	params, _ := json.Marshal(map[string]interface{}{
+		"progressToken": call.progressToken,
+		"progress":      progress,
+		"message":       message,
+	})
+	call.notify(rpcMessage{JSONRPC: "2.0", Method: "notifications/progress", Params: params})
+}
+
+// handleMCPRequest answers an MCP request. notify delivers notifications
+// related to the request (execution progress) to the client.
+func handleMCPRequest(ctx context.Context, state *mcpState, msg rpcMessage, notify func(rpcMessage)) (interface{}, *rpcError) {
+	switch msg.Method {
+	case "initialize":
+		var params struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		json.Unmarshal(msg.Params, &params)
+
+		version := mcpProtocolVersions[0]
+		for _, v := range mcpProtocolVersions {
+			if v == params.ProtocolVersion {
+				version = v
+			}
+		}
+		state.mu.Lock()
+		state.protocolVersion = version
+		state.mu.Unlock()
+
+		result := map[string]interface{}{
+			"protocolVersion": version,
+			"capabilities": map[string]interface{}{
+				"tools":     map[string]interface{}{"listChanged": false},
+				"resources": map[string]interface{}{"subscribe": false, "listChanged": false},
+				"prompts":   map[string]interface{}{"listChanged": false},
+				"logging":   map[string]interface{}{},
+			},
+			"serverInfo": map[string]string{"name": "j0", "version": "1.0.0"},
+		}
+		if deploymentBanner != "" {
+			result["instructions"] = deploymentBanner
+		}
+		return result, nil
+
+	case "ping":
+		return struct{}{}, nil
+
+	case "logging/setLevel":
+		var params struct {
+			Level string `json:"level"`
+		}
+		json.Unmarshal(msg.Params, &params)
+		if _, ok := mcpLogLevels[params.Level]; !ok {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: "invalid level: " + params.Level}
+		}
+		state.mu.Lock()
+		state.logLevel = params.Level
+		state.mu.Unlock()
+		return struct{}{}, nil
+
+	case "tools/list":
+		tools := MCPTools()
+		list := make([]map[string]interface{}, len(tools))
+		for i, t := range tools {
+			list[i] = map[string]interface{}{
+				"name":        t.Name,
+				"description": t.Description,
+				"inputSchema": t.InputSchema,
+			}
+		}
+		return map[string]interface{}{"tools": list}, nil
+
+	case "resources/list":
+		return mcpListResources(ctx, msg.Params)
+
+	case "resources/templates/list":
+		return mcpResourceTemplates(), nil
+
+	case "resources/read":
+		return mcpReadResource(ctx, msg.Params)
+
+	case "prompts/list":
+		return mcpListPrompts(), nil
+
+	case "prompts/get":
+		return mcpGetPrompt(ctx, msg.Params)
+
+	case "tools/call":
+		params, err := mcpCallParams(msg)
+		if err != nil {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+		}
+		invoke, ok := mcpInvokers[params.Name]
+		if !ok {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: "unknown tool: " + params.Name}
+		}
+
+		// Tool failures are results the model can read, not protocol errors
+		ctx = context.WithValue(ctx, mcpCallKey{}, &mcpCall{state: state, notify: notify, progressToken: params.Meta.ProgressToken})
+		result, err := invoke(ctx, params.Arguments)
+		if err != nil {
+			return mcpToolResult(err.Error(), nil, true), nil
+		}
+		data, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return mcpToolResult(err.Error(), nil, true), nil
+		}
+		return mcpToolResult(string(data), result, false), nil
+	}
+	return nil, &rpcError{Code: rpcMethodNotFound, Message: "method not found: " + msg.Method}
+}
+
+// mcpToolCall is the params of tools/call
+type mcpToolCall struct {
+	Name      string                 `json:"name"`
+	Arguments map[string]interface{} `json:"arguments"`
+	Meta      struct {
+		ProgressToken json.RawMessage `json:"progressToken"`
+	} `json:"_meta"`
+}
+
+func mcpCallParams(msg rpcMessage) (mcpToolCall, error) {
+	var params mcpToolCall
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		return params, err
+	}
+	if params.Arguments == nil {
+		params.Arguments = map[string]interface{}{}
+	}
+	return params, nil
+}
+
+// mcpToolResult builds a tools/call result. JSON objects are also returned
+// as structured content for clients that use it.
+func mcpToolResult(text string, structured interface{}, isError bool) map[string]interface{} {
+	result := map[string]interface{}{
+		"content": []map[string]string{{"type": "text", "text": text}},
+		"isError": isError,
+	}
+	if structured != nil {
+		data, _ := json.Marshal(structured)
+		var obj map[string]interface{}
+		if json.Unmarshal(data, &obj) == nil {
+			result["structuredContent"] = obj
+		}
+	}
+	return result
+}
+
+// mcpServeCmd runs an MCP server on stdin/stdout for MCP hosts that launch
+// j0 as a subprocess
+var mcpServeCmd = &cobra.Command{
+	Use:   "mcp-serve",
+	Short: "Serve the MCP tools over stdio (JSON-RPC)",
+	Long: `Speak the Model Context Protocol over stdin/stdout so MCP hosts can
+launch j0 directly. Logs go to stderr; stdout carries only protocol messages.
+
+Example host configuration:
+  {"command": "j0", "args": ["--data-dir", "/var/lib/j0", "mcp-serve"]}`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		server := &mcpStdioServer{ctx: cmd.Context(), out: os.Stdout}
+
+		// Reading stdin can't be interrupted, so stop waiting for it
+		// once the context is cancelled
+		done := make(chan error, 1)
+		go func() { done <- server.Serve(os.Stdin) }()
+		select {
+		case err := <-done:
+			if err != nil {
+				return fmt.Errorf("reading stdin: %w", err)
+			}
+		case <-cmd.Context().Done():
+		}
+		return nil
+	},
+}
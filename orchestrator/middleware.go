@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// requestIDHeader carries the request ID in both directions
+const requestIDHeader = "X-Request-ID"
+
+// validRequestID limits client-supplied IDs to safe, loggable values
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+type requestIDKey struct{}
+
+// requestIDFromContext returns the request ID assigned by withRequestID
+func requestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+type requestActorKey struct{}
+type principalKey struct{}
+
+// authenticatedAs attributes the rest of the request to an authenticated
+// principal, including the request log line written by withRequestID
+func authenticatedAs(ctx context.Context, principal string) context.Context {
+	if logged, ok := ctx.Value(requestActorKey{}).(*string); ok {
+		*logged = principal
+	}
+	ctx = withActor(ctx, principal)
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// principalFromContext returns the authenticated identity, "" for
+// anonymous requests and the CLI
+func principalFromContext(ctx context.Context) string {
+	p, _ := ctx.Value(principalKey{}).(string)
+	return p
+}
+
+// statusRecorder captures the response status while keeping streaming
+// (SSE) and connection upgrades (WebSocket) working
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response does not support hijacking")
+	}
+	r.status = http.StatusSwitchingProtocols
+	return h.Hijack()
+}
+
+// withRequestID assigns each request an ID (reusing a valid X-Request-ID
+// from the client), echoes it in the response, appends it to plain-text
+// error bodies and logs the request as structured JSON
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = generateID("req")
+		}
+		w.Header().Set(requestIDHeader, id)
+
+		ctx := context.WithValue(r.Context(), requestIDKey{}, id)
+		actor := anonymousActor(r)
+		ctx = context.WithValue(ctx, requestActorKey{}, &actor)
+		ctx = withActor(ctx, actor)
+		if cn := clientCertActor(r); cn != "" {
+			ctx = authenticatedAs(ctx, cn)
+		}
+
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		// http.Error bodies are plain text; tag them so users can quote the ID
+		if rec.status >= 400 && strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain") {
+			fmt.Fprintf(rec, "request_id: %s\n", id)
+		}
+
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		level := slog.LevelInfo
+		if status >= 500 {
+			level = slog.LevelError
+		}
+		slog.LogAttrs(r.Context(), level, "request",
+			slog.String("request_id", id),
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", status),
+			slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("remote", r.RemoteAddr),
+			slog.String("actor", actor),
+		)
+	})
+}
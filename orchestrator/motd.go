@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// maxInitScript bounds a session init script
+const maxInitScript = 64 << 10
+
+// SessionInit is a session's init script and the cached output of its
+// last run. The output is the session's MOTD, replayed to clients when
+// they attach so they see what's installed and what state the session is in.
+type SessionInit struct {
+	Script      string    `json:"script"`
+	ExecutionID string    `json:"execution_id,omitempty"`
+	Output      string    `json:"output"`
+	Stderr      string    `json:"stderr,omitempty"`
+	ExitCode    int       `json:"exit_code"`
+	RanAt       time.Time `json:"ran_at"`
+	// Error is set when the script couldn't be run at all
+	Error string `json:"error,omitempty"`
+}
+
+// setInit stores the result of a session's init script
+func (sm *SessionManager) setInit(sessionID string, init *SessionInit) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	session, ok := sm.sessions[sessionID]
+	if !ok {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+	session.Init = init
+	return sm.saveSession(session)
+}
+
+// runSessionInit runs script as an ordinary execution in the session and
+// caches its output as the session's MOTD. A script that fails to run is
+// recorded with its error rather than failing the caller.
+func runSessionInit(ctx context.Context, sessionID, script string) (*SessionInit, error) {
+	if len(script) > maxInitScript {
+		return nil, fmt.Errorf("init script exceeds %d bytes", maxInitScript)
+	}
+
+	init := &SessionInit{Script: script, RanAt: time.Now()}
+	exec, err := runExecution(ctx, sessionID, ExecRequest{Code: script})
+	if err != nil {
+		init.Error = err.Error()
+	} else {
+		init.ExecutionID = exec.ID
+		init.Output = exec.Output
+		init.Stderr = exec.Stderr
+		init.ExitCode = exec.ExitCode
+	}
+
+	if err := sessionsFor(ctx).setInit(sessionID, init); err != nil {
+		return nil, err
+	}
+	audit(ctx, "session.init", sessionID, map[string]interface{}{
+		"execution_id": init.ExecutionID,
+		"exit_code":    init.ExitCode,
+		"error":        init.Error,
+	})
+	return init, nil
+}
+
+// motdMessage is the WebSocket message replaying a session's MOTD
+func motdMessage(init *SessionInit) map[string]interface{} {
+	msg := map[string]interface{}{
+		"type":      "motd",
+		"output":    init.Output,
+		"exit_code": init.ExitCode,
+		"ran_at":    init.RanAt,
+	}
+	if init.Stderr != "" {
+		msg["stderr"] = init.Stderr
+	}
+	if init.Error != "" {
+		msg["error"] = init.Error
+	}
+	return msg
+}
+
+// handleGetMOTD serves GET /sessions/{id}/motd, the cached init output
+func handleGetMOTD(w http.ResponseWriter, r *http.Request) {
+	session, err := sessionsFor(r.Context()).GetSession(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if session.Init == nil {
+		http.Error(w, "session has no init script", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(session.Init)
+}
+
+// handleRunInit serves POST /sessions/{id}/init. It sets a new init
+// script when one is given, or reruns the current one to refresh the MOTD.
+func handleRunInit(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	session, err := sessionsFor(r.Context()).GetSession(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	var req struct {
+		Script string `json:"script,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Script == "" && session.Init != nil {
+		req.Script = session.Init.Script
+	}
+	if req.Script == "" {
+		http.Error(w, "script is required", http.StatusBadRequest)
+		return
+	}
+
+	init, err := runSessionInit(r.Context(), id, req.Script)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(init)
+}
+
+// printMOTD writes a session's cached init output for a terminal
+func printMOTD(w io.Writer, init *SessionInit) {
+	if init.Error != "" {
+		fmt.Fprintf(w, "Init script failed: %s\n", init.Error)
+		return
+	}
+	fmt.Fprint(w, init.Output)
+	if init.Output != "" && !strings.HasSuffix(init.Output, "\n") {
+		fmt.Fprintln(w)
+	}
+	if init.Stderr != "" {
+		fmt.Fprint(w, init.Stderr)
+	}
+	if init.ExitCode != 0 {
+		fmt.Fprintf(w, "(init exited with code %d)\n", init.ExitCode)
+	}
+}
+
+var sessionsMOTDCmd = &cobra.Command{
+	Use:   "motd <session-id>",
+	Short: "Show a session's cached init output",
+	Long: `Show the output of a session's init script, as replayed to clients
+when they attach.
+
+Examples:
+  j0 sessions motd sess-1a2b3c4d
+  j0 sessions motd sess-1a2b3c4d --refresh`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		session, err := sessionManager.GetSession(args[0])
+		if err != nil {
+			return err
+		}
+		if session.Init == nil {
+			return fmt.Errorf("session %s has no init script", session.ID)
+		}
+
+		init := session.Init
+		if refresh, _ := cmd.Flags().GetBool("refresh"); refresh {
+			if init, err = runSessionInit(cmd.Context(), session.ID, init.Script); err != nil {
+				return err
+			}
+		}
+		printMOTD(os.Stdout, init)
+		return nil
+	},
+}
+
+func init() {
+	sessionsMOTDCmd.Flags().Bool("refresh", false, "Rerun the init script before showing its output")
+	sessionsCmd.AddCommand(sessionsMOTDCmd)
+}
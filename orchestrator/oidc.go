@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// oidcAuth validates bearer JWTs from an OIDC issuer; nil disables it
+var oidcAuth *OIDCAuth
+
+// OIDCAuth verifies RS256 ID/access tokens issued by an OIDC provider
+type OIDCAuth struct {
+	Issuer   string
+	Audience string
+	// RolesClaim is a (dotted) claim path holding the caller's roles, e.g.
+	// "roles" or "realm_access.roles"
+	RolesClaim string
+	// AdminRole grants access to every user's sessions
+	AdminRole string
+	// ReadOnlyRole limits users to listing sessions and reading logs
+	ReadOnlyRole string
+
+	keys *JWKS
+}
+
+// NewOIDCAuth configures token validation. Without jwksURL the key set is
+// located through the issuer's discovery document.
+func NewOIDCAuth(issuer, audience, jwksURL, rolesClaim, adminRole, readOnlyRole string) (*OIDCAuth, error) {
+	issuer = strings.TrimRight(issuer, "/")
+	if jwksURL == "" {
+		var err error
+		if jwksURL, err = discoverJWKS(issuer); err != nil {
+			return nil, err
+		}
+	}
+
+	return &OIDCAuth{
+		Issuer:       issuer,
+		Audience:     audience,
+		RolesClaim:   rolesClaim,
+		AdminRole:    adminRole,
+		ReadOnlyRole: readOnlyRole,
+		keys:         NewJWKS(jwksURL),
+	}, nil
+}
+
+// discoverJWKS reads jwks_uri from <issuer>/.well-known/openid-configuration
+func discoverJWKS(issuer string) (string, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(issuer + "/.well-known/openid-configuration")
+	if err != nil {
+		return "", fmt.Errorf("OIDC discovery failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("OIDC discovery failed: %s", resp.Status)
+	}
+
+	var doc struct {
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return "", fmt.Errorf("invalid OIDC discovery document: %w", err)
+	}
+	if doc.JWKSURI == "" {
+		return "", fmt.Errorf("OIDC discovery document has no jwks_uri")
+	}
+	return doc.JWKSURI, nil
+}
+
+// Authenticate verifies a token and returns its subject and roles
+func (o *OIDCAuth) Authenticate(token string) (string, []string, error) {
+	claims, err := verifyJWT(token, o.keys)
+	if err != nil {
+		return "", nil, err
+	}
+	if strings.TrimRight(claims.String("iss"), "/") != o.Issuer {
+		return "", nil, fmt.Errorf("unexpected issuer: %s", claims.String("iss"))
+	}
+	if o.Audience != "" && !claims.Audience(o.Audience) {
+		return "", nil, fmt.Errorf("token audience does not include %s", o.Audience)
+	}
+	sub := claims.String("sub")
+	if sub == "" {
+		return "", nil, fmt.Errorf("token has no sub claim")
+	}
+	return sub, claimRoles(claims, o.RolesClaim), nil
+}
+
+// Role maps a user's token roles to a j0 role. Users with neither the admin
+// nor the read-only role are operators.
+func (o *OIDCAuth) Role(roles []string) string {
+	role := RoleOperator
+	for _, r := range roles {
+		switch r {
+		case o.AdminRole:
+			return RoleAdmin
+		case o.ReadOnlyRole:
+			role = RoleReadOnly
+		}
+	}
+	return role
+}
+
+// claimRoles reads a roles claim given as an array or a space-separated string
+func claimRoles(claims JWTClaims, path string) []string {
+	var v interface{} = map[string]interface{}(claims)
+	for _, key := range strings.Split(path, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[key]
+	}
+
+	switch roles := v.(type) {
+	case string:
+		return strings.Fields(roles)
+	case []interface{}:
+		var out []string
+		for _, r := range roles {
+			if s, ok := r.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// looksLikeJWT distinguishes JWTs from opaque API keys
+func looksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+type ownerScopeKey struct{}
+
+// withOwnerScope limits the request to sessions owned by its principal
+func withOwnerScope(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ownerScopeKey{}, true)
+}
+
+// canAccessSession reports whether the caller may see the session. Only
+// requests from non-admin users are scoped to their own sessions.
+func canAccessSession(ctx context.Context, session *Session) bool {
+	if scoped, _ := ctx.Value(ownerScopeKey{}).(bool); !scoped {
+		return true
+	}
+	return session.Owner == principalFromContext(ctx)
+}
+
+// visibleSessions filters sessions to those the caller may see
+func visibleSessions(ctx context.Context, sessions []*Session) []*Session {
+	visible := make([]*Session, 0, len(sessions))
+	for _, s := range sessions {
+		if canAccessSession(ctx, s) {
+			visible = append(visible, s)
+		}
+	}
+	return visible
+}
+
+// authorizeSession returns the same error as a missing session when the
+// caller may not access it, so other users' session IDs are not revealed
+func authorizeSession(ctx context.Context, sessionID string) error {
+	session, err := sessionsFor(ctx).GetSession(sessionID)
+	if err != nil {
+		return err
+	}
+	if !canAccessSession(ctx, session) {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+	return nil
+}
+
+// withSessionOwnership rejects /sessions/{id}/... requests for sessions the
+// caller doesn't own
+func withSessionOwnership(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rest, ok := strings.CutPrefix(r.URL.Path, "/sessions/")
+		if ok {
+			id, _, _ := strings.Cut(rest, "/")
+			session, err := sessionsFor(r.Context()).GetSession(id)
+			if err == nil && !canAccessSession(r.Context(), session) {
+				http.Error(w, "session not found: "+id, http.StatusNotFound)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
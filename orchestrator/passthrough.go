@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Headers clients use to supply their own Judge0 credentials. They are
+// forwarded to Judge0 as X-Auth-Token and X-Auth-User.
+const (
+	judge0TokenHeader = "X-Judge0-Auth-Token"
+	judge0UserHeader  = "X-Judge0-Auth-User"
+)
+
+// Judge0 auth passthrough modes
+const (
+	PassthroughOff      = "off"
+	PassthroughOptional = "optional"
+	PassthroughRequired = "required"
+)
+
+// judge0AuthPassthrough is set from --judge0-auth-passthrough
+var judge0AuthPassthrough = PassthroughOff
+
+// Environment variables that default --judge0-auth-token and --judge0-auth-user
+const (
+	judge0AuthTokenEnv = "JUDGE0_AUTH_TOKEN"
+	judge0AuthUserEnv  = "JUDGE0_AUTH_USER"
+)
+
+// judge0Auth holds the orchestrator's own credentials for a secured Judge0,
+// from --judge0-auth-token and --judge0-auth-user
+var judge0Auth Judge0Credentials
+
+// errJudge0CredentialsRequired is returned for executions without client
+// credentials when passthrough is required
+var errJudge0CredentialsRequired = errors.New("this deployment requires your own Judge0 credentials; send " + judge0TokenHeader)
+
+// Judge0Credentials are a client's own Judge0 credentials
+type Judge0Credentials struct {
+	Token string
+	User  string
+}
+
+type judge0CredentialsKey struct{}
+
+// judge0CredentialsFromContext returns the credentials supplied with the
+// request, or nil outside passthrough mode
+func judge0CredentialsFromContext(ctx context.Context) *Judge0Credentials {
+	creds, _ := ctx.Value(judge0CredentialsKey{}).(*Judge0Credentials)
+	return creds
+}
+
+func validatePassthroughMode(mode string) error {
+	switch mode {
+	case PassthroughOff, PassthroughOptional, PassthroughRequired:
+		return nil
+	}
+	return fmt.Errorf("invalid --judge0-auth-passthrough: %s (want off, optional or required)", mode)
+}
+
+// withJudge0Passthrough attaches the client's Judge0 credentials to the
+// request context. Outside passthrough mode the headers are ignored so
+// clients can't borrow the backend's trust by accident.
+func withJudge0Passthrough(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if judge0AuthPassthrough == PassthroughOff {
+			next.ServeHTTP(w, r)
+			return
+		}
+		creds := &Judge0Credentials{
+			Token: r.Header.Get(judge0TokenHeader),
+			User:  r.Header.Get(judge0UserHeader),
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), judge0CredentialsKey{}, creds)))
+	})
+}
+
+// checkJudge0Credentials rejects API executions without client credentials
+// when passthrough is required. CLI and internal executions carry no
+// request credentials and use the orchestrator's own.
+func checkJudge0Credentials(ctx context.Context) error {
+	creds := judge0CredentialsFromContext(ctx)
+	if judge0AuthPassthrough == PassthroughRequired && creds != nil && creds.Token == "" {
+		return errJudge0CredentialsRequired
+	}
+	return nil
+}
+
+// setJudge0Auth adds the orchestrator's Judge0 credentials to a Judge0
+// request. Passthrough credentials supplied with the request replace them.
+func setJudge0Auth(ctx context.Context, req *http.Request) {
+	setRapidAPIHeaders(req)
+	creds := judge0CredentialsFromContext(ctx)
+	if creds == nil || creds.Token == "" {
+		creds = &judge0Auth
+	}
+	if creds.Token != "" {
+		req.Header.Set("X-Auth-Token", creds.Token)
+	}
+	if creds.User != "" {
+		req.Header.Set("X-Auth-User", creds.User)
+	}
+}
+
+// BackendAuthError is returned when Judge0 rejects the credentials sent
+// with a submission
+type BackendAuthError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *BackendAuthError) Error() string {
+	return fmt.Sprintf("judge0 rejected the credentials (%d): %s", e.StatusCode, e.Body)
+}
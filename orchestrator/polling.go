@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// defaultExecTimeout bounds how long a submission is waited for when no
+// total deadline is given (--exec-timeout)
+var defaultExecTimeout = 15 * time.Second
+
+// PollStrategy controls how often a submission's status is requested.
+// The delay starts at Interval and is multiplied by Backoff after each
+// poll, up to MaxInterval.
+type PollStrategy struct {
+	Interval    time.Duration
+	MaxInterval time.Duration
+	Backoff     float64
+	// MaxAttempts fails the wait after this many polls (0 = until the deadline)
+	MaxAttempts int
+}
+
+// pollStrategy is set by the --poll-* flags
+var pollStrategy = PollStrategy{
+	Interval:    500 * time.Millisecond,
+	MaxInterval: 5 * time.Second,
+	Backoff:     1,
+}
+
+// Validate rejects settings that would poll in a tight loop or never
+func (p PollStrategy) Validate() error {
+	if p.Interval <= 0 {
+		return fmt.Errorf("invalid --poll-interval: %s", p.Interval)
+	}
+	if p.Backoff < 1 {
+		return fmt.Errorf("invalid --poll-backoff: %g (want 1 or more)", p.Backoff)
+	}
+	if p.MaxInterval < p.Interval {
+		return fmt.Errorf("invalid --poll-max-interval: %s is below --poll-interval", p.MaxInterval)
+	}
+	if p.MaxAttempts < 0 {
+		return fmt.Errorf("invalid --poll-max-attempts: %d", p.MaxAttempts)
+	}
+	return nil
+}
+
+// delay returns how long to wait after the given poll (0-based)
+func (p PollStrategy) delay(attempt int) time.Duration {
+	d := float64(p.Interval)
+	for i := 0; i < attempt && d < float64(p.MaxInterval); i++ {
+		d *= p.Backoff
+	}
+	return min(time.Duration(d), p.MaxInterval)
+}
+
+// judge0Wait submits with Judge0's synchronous wait=true mode when
+// nothing needs polling (--judge0-wait)
+var judge0Wait bool
+
+// errWaitRejected is returned when a backend has wait=true disabled
+// (ENABLE_WAIT_RESULT=false)
+var errWaitRejected = errors.New("judge0 rejected wait=true")
+
+// waitRejected remembers backends that rejected wait=true, so later
+// submissions go straight to polling
+var waitRejected sync.Map // base URL -> true
+
+// useWait reports whether a submission to baseURL should use wait=true
+func useWait(baseURL string, opts WaitOptions, timeout, httpTimeout time.Duration, callbacks bool) bool {
+	if !judge0Wait || callbacks || opts.QueueTimeout > 0 || timeout > httpTimeout {
+		return false
+	}
+	_, rejected := waitRejected.Load(baseURL)
+	return !rejected
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// withPollStrategy sets the --poll-* flags for the test
+func withPollStrategy(t *testing.T, p PollStrategy) {
+	t.Helper()
+	saved := pollStrategy
+	pollStrategy = p
+	t.Cleanup(func() { pollStrategy = saved })
+}
+
+func TestPollStrategyValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy PollStrategy
+		err      string
+	}{
+		{name: "default", strategy: pollStrategy},
+		{name: "zero interval", strategy: PollStrategy{MaxInterval: time.Second, Backoff: 1}, err: "--poll-interval"},
+		{name: "shrinking backoff", strategy: PollStrategy{Interval: time.Second, MaxInterval: time.Second, Backoff: 0.5}, err: "--poll-backoff"},
+		{name: "max below interval", strategy: PollStrategy{Interval: time.Second, MaxInterval: time.Millisecond, Backoff: 1}, err: "--poll-max-interval"},
+		{name: "negative attempts", strategy: PollStrategy{Interval: time.Second, MaxInterval: time.Second, Backoff: 1, MaxAttempts: -1}, err: "--poll-max-attempts"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.strategy.Validate()
+			if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("Validate = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestPollStrategyDelay(t *testing.T) {
+	p := PollStrategy{Interval: 100 * time.Millisecond, MaxInterval: time.Second, Backoff: 2}
+	want := []time.Duration{100, 200, 400, 800, 1000, 1000}
+	for i, w := range want {
+		if got := p.delay(i); got != w*time.Millisecond {
+			t.Errorf("delay(%d) = %s, want %s", i, got, w*time.Millisecond)
+		}
+	}
+	if got := (PollStrategy{Interval: time.Second, MaxInterval: time.Second, Backoff: 1}).delay(50); got != time.Second {
+		t.Errorf("fixed delay = %s", got)
+	}
+}
+
+func TestPollMaxAttempts(t *testing.T) {
+	withPollStrategy(t, PollStrategy{Interval: time.Millisecond, MaxInterval: time.Millisecond, Backoff: 1, MaxAttempts: 3})
+
+	var polls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		polls.Add(1)
+		w.Write([]byte(`{"token":"tok","status":{"id":2,"description":"Processing"}}`))
+	}))
+	defer srv.Close()
+
+	c := NewJudge0Client(srv.URL)
+	_, err := c.waitForResult(context.Background(), "tok", time.Now(), WaitOptions{Timeout: 10 * time.Second}, nil)
+	var deadline *DeadlineError
+	if !errors.As(err, &deadline) || deadline.Kind != DeadlinePolls {
+		t.Fatalf("error = %v, want a poll limit", err)
+	}
+	if polls.Load() != 3 {
+		t.Fatalf("polled %d times, want 3", polls.Load())
+	}
+}
+
+func TestUseWait(t *testing.T) {
+	saved := judge0Wait
+	defer func() { judge0Wait = saved }()
+	judge0Wait = true
+	const url = "http://judge0.test"
+	defer waitRejected.Delete(url)
+
+	tests := []struct {
+		name      string
+		opts      WaitOptions
+		timeout   time.Duration
+		callbacks bool
+		want      bool
+	}{
+		{name: "fast", timeout: 5 * time.Second, want: true},
+		{name: "longer than the HTTP timeout", timeout: time.Minute, want: false},
+		{name: "queue timeout", opts: WaitOptions{QueueTimeout: time.Second}, timeout: 5 * time.Second, want: false},
+		{name: "callbacks", timeout: 5 * time.Second, callbacks: true, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := useWait(url, tt.opts, tt.timeout, 30*time.Second, tt.callbacks); got != tt.want {
+				t.Fatalf("useWait = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	waitRejected.Store(url, true)
+	if useWait(url, WaitOptions{}, 5*time.Second, 30*time.Second, false) {
+		t.Fatal("wait=true used on a backend that rejected it")
+	}
+}
+
+func TestSubmitFallsBackWhenWaitRejected(t *testing.T) {
+	saved := judge0Wait
+	defer func() { judge0Wait = saved }()
+	judge0Wait = true
+	withPollStrategy(t, PollStrategy{Interval: time.Millisecond, MaxInterval: time.Millisecond, Backoff: 1})
+
+	var waits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			if r.URL.Query().Get("wait") == "true" {
+				waits.Add(1)
+				http.Error(w, `{"error":"wait not allowed"}`, http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"token":"tok"}`))
+			return
+		}
+		w.Write([]byte(`{"token":"tok","status":{"id":3,"description":"Accepted"}}`))
+	}))
+	defer srv.Close()
+	defer waitRejected.Delete(srv.URL)
+
+	c := NewJudge0Client(srv.URL)
+	for i := 0; i < 2; i++ {
+		result, err := c.Submit(context.Background(), NewSubmission("echo hi", LanguageBash, ""), WaitOptions{Timeout: 5 * time.Second})
+		if err != nil || result.Status.ID != 3 {
+			t.Fatalf("submission %d: %+v, %v", i, result, err)
+		}
+	}
+	if waits.Load() != 1 {
+		t.Fatalf("wait=true tried %d times, want once", waits.Load())
+	}
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Preview risk levels
+const (
+	RiskLow    = "low"
+	RiskMedium = "medium"
+	RiskHigh   = "high"
+)
+
+// Preview is a static summary of what code is likely to do, for approval
+// workflows and cautious agents to inspect before running it. It is based
+// on pattern matching: it flags intent, it doesn't prove safety.
+type Preview struct {
+	Language string   `json:"language"`
+	Lines    int      `json:"lines"`
+	Imports  []string `json:"imports"`
+
+	Network     []PreviewFinding `json:"network"`
+	FileWrites  []PreviewFinding `json:"file_writes"`
+	Processes   []PreviewFinding `json:"processes"`
+	Syscalls    []PreviewFinding `json:"syscalls"`
+	DynamicCode []PreviewFinding `json:"dynamic_code"`
+
+	// Risk is high for network, process or low-level system access,
+	// medium for file writes and dynamic code, and low otherwise
+	Risk string `json:"risk"`
+	// Supported is false when no rules exist for the language; only the
+	// line count is filled in then
+	Supported bool `json:"supported"`
+}
+
+// PreviewFinding is a line that matched an indicator
+type PreviewFinding struct {
+	Line      int    `json:"line"`
+	Indicator string `json:"indicator"`
+	Text      string `json:"text"`
+}
+
+// previewRules are the indicators for one language
+type previewRules struct {
+	// comment starts a line comment; matching lines are skipped
+	comment string
+	imports []*regexp.Regexp
+
+	network, fileWrites, processes, syscalls, dynamicCode *regexp.Regexp
+}
+
+// maxFindingText truncates the source line shown with a finding
+const maxFindingText = 160
+
+var previewRuleSets = map[int]*previewRules{
+	LanguagePython3: {
+		comment: "#",
+		imports: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*import\s+([\w.]+(?:\s*,\s*[\w.]+)*)`),
+			regexp.MustCompile(`^\s*from\s+([\w.]+)\s+import\b`),
+			regexp.MustCompile(`__import__\(\s*['"]([\w.]+)['"]`),
+			regexp.MustCompile(`importlib\.import_module\(\s*['"]([\w.]+)['"]`),
+		},
+		network:     regexp.MustCompile(`\bsocket\.(socket|create_connection|getaddrinfo)\b|\burlopen\(|\burllib\.request\b|\brequests\.(get|post|put|patch|delete|head|request|Session)\b|\bhttp\.client\b|\bhttpx\.|\baiohttp\.|\b(ftplib|smtplib|telnetlib|paramiko)\b`),
+		fileWrites:  regexp.MustCompile(`\bopen\([^)]*['"](?:[wax]|r\+)[bt+]*['"]|\.write_(text|bytes)\(|\bos\.(remove|unlink|rmdir|removedirs|rename|replace|makedirs|mkdir|chmod|chown|truncate)\(|\bshutil\.(rmtree|move|copy\w*)\(`),
+		processes:   regexp.MustCompile(`\bsubprocess\.\w+|\bos\.(system|popen|exec\w*|spawn\w*|fork|kill|killpg)\(|\bpty\.spawn\(|\bmultiprocessing\.Process\(`),
+		syscalls:    regexp.MustCompile(`\bctypes\b|\bcffi\b|\bos\.(setuid|setgid|chroot|setrlimit)\(|\bresource\.setrlimit\(`),
+		dynamicCode: regexp.MustCompile(`\b(eval|exec|compile)\(|\bpickle\.loads?\(|\bmarshal\.loads\(`),
+	},
+	LanguageJavaScript: {
+		comment: "//",
+		imports: []*regexp.Regexp{
+			regexp.MustCompile(`\brequire\(\s*['"]([^'"]+)['"]\s*\)`),
+			regexp.MustCompile(`^\s*import\s+[^'"]*?\bfrom\s+['"]([^'"]+)['"]`),
+			regexp.MustCompile(`^\s*import\s+['"]([^'"]+)['"]`),
+			regexp.MustCompile(`\bimport\(\s*['"]([^'"]+)['"]\s*\)`),
+		},
+		network:     regexp.MustCompile(`\bfetch\(|\bhttps?\.(request|get|createServer)\(|\bnet\.(connect|createConnection|createServer|Socket)\b|\bdgram\.|\btls\.connect\(|\bnew WebSocket\(|\bXMLHttpRequest\b|\baxios\b`),
+		fileWrites:  regexp.MustCompile(`\bfs\w*\.(writeFile|appendFile|unlink|rm|rmdir|mkdir|rename|createWriteStream|copyFile|cp|chmod|chown|truncate|symlink)(Sync)?\(`),
+		processes:   regexp.MustCompile(`\bchild_process\b|\b(exec|execSync|execFile|execFileSync|spawn|spawnSync|fork)\(|\bprocess\.kill\(|\bworker_threads\b`),
+		syscalls:    regexp.MustCompile(`\bprocess\.(binding|dlopen|setuid|setgid|chdir)\(|\bffi-napi\b`),
+		dynamicCode: regexp.MustCompile(`\beval\(|\bnew Function\(|\bvm\.(runIn\w+|Script|compileFunction)\b`),
+	},
+	LanguageRuby: {
+		comment: "#",
+		imports: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*(?:require|require_relative|load)\s*\(?\s*['"]([^'"]+)['"]`),
+		},
+		network:     regexp.MustCompile(`\bNet::(HTTP|FTP|SMTP)\b|\b(TCP|UDP|UNIX)(Socket|Server)\b|\bSocket\.|\bURI\.open\b|\bopen-uri\b|\b(HTTParty|Faraday|RestClient)\b`),
+		fileWrites:  regexp.MustCompile(`\bFile\.(write|delete|unlink|rename|chmod|chown|symlink|truncate)\b|\bFile\.open\([^)]*['"][wa]\+?b?['"]|\bFileUtils\.\w+|\bIO\.write\b|\bDir\.(mkdir|rmdir|delete)\b`),
+		processes:   regexp.MustCompile("`[^`]*`|%x[({\\[]|\\b(system|exec|spawn|fork)\\b\\s*[(\"']|\\bProcess\\.(spawn|kill|fork)\\b|\\bOpen3\\.|\\bIO\\.popen\\b"),
+		syscalls:    regexp.MustCompile(`\bsyscall\b|\bFiddle\b|\bProcess::Sys\b|\bProcess\.setrlimit\b`),
+		dynamicCode: regexp.MustCompile(`\b(eval|instance_eval|class_eval|module_eval|binding\.eval)\b|\bMarshal\.load\b|\bsend\(`),
+	},
+	LanguageGo: {
+		comment: "//",
+		imports: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*import\s+(?:[\w.]+\s+)?"([^"]+)"`),
+		},
+		network:    regexp.MustCompile(`\bnet\.(Dial|Listen|Resolve|LookupHost|LookupIP)\w*\(|\bhttp\.(Get|Post|PostForm|Head|NewRequest\w*|ListenAndServe\w*|DefaultClient)\b|\btls\.Dial\(`),
+		fileWrites: regexp.MustCompile(`\bos\.(Create|WriteFile|Remove|RemoveAll|Rename|Mkdir|MkdirAll|MkdirTemp|CreateTemp|Chmod|Chown|Symlink|Link|Truncate)\(|\bos\.OpenFile\(|\bioutil\.(WriteFile|TempFile|TempDir)\(`),
+		processes:  regexp.MustCompile(`\bexec\.Command(Context)?\(|\bos\.(StartProcess|Exit)\(|\bsyscall\.(Exec|ForkExec|Kill)\(`),
+		syscalls:   regexp.MustCompile(`\b(syscall|unix)\.[A-Z]\w*\(|\bunsafe\.Pointer\b|\bplugin\.Open\(`),
+	},
+	LanguageRust: {
+		comment: "//",
+		imports: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*(?:pub\s+)?use\s+([\w:]+)`),
+			regexp.MustCompile(`^\s*extern\s+crate\s+(\w+)`),
+		},
+		network:    regexp.MustCompile(`\b(TcpStream|TcpListener|UdpSocket)\b|\bstd::net\b|\breqwest::|\bhyper::|\bToSocketAddrs\b`),
+		fileWrites: regexp.MustCompile(`\bFile::create\(|\bOpenOptions\b|\bfs::(write|remove_file|remove_dir\w*|rename|create_dir\w*|copy|hard_link|set_permissions)\(`),
+		processes:  regexp.MustCompile(`\bCommand::new\(|\bprocess::Command\b|\bprocess::(exit|abort)\(`),
+		syscalls:   regexp.MustCompile(`\bunsafe\s*\{|\blibc::|\b(asm|global_asm)!|\bextern\s+"C"`),
+	},
+	LanguageC:   cFamilyRules,
+	LanguageCPP: cFamilyRules,
+	LanguageBash: {
+		comment: "#",
+		imports: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*(?:source|\.)\s+(\S+)`),
+		},
+		network:     regexp.MustCompile(`(?:^|[;&|(\s])(curl|wget|nc|ncat|netcat|socat|ssh|scp|sftp|rsync|telnet|ftp|ping|dig|nslookup|host)\b|/dev/(tcp|udp)/`),
+		fileWrites:  regexp.MustCompile(`(?:^|[;&|(]|\$\()\s*(?:sudo\s+)?(rm|mv|cp|touch|mkdir|rmdir|chmod|chown|dd|tee|truncate|ln|install|shred)\b|(?:^|[^<>&=0-9])[0-9]?>>?\s*[^&\s|;)]+`),
+		processes:   regexp.MustCompile(`(?:^|[;&|(]|\$\()\s*(?:sudo\s+)?(kill|pkill|killall|nohup|setsid|disown|exec|crontab|at)\b|[^&]&\s*$`),
+		syscalls:    regexp.MustCompile(`(?:^|[;&|(]|\$\()\s*(sudo|su|chroot|mount|umount|insmod|modprobe|iptables|sysctl|ulimit|nsenter|unshare)\b`),
+		dynamicCode: regexp.MustCompile(`(?:^|[;&|(]|\$\()\s*eval\b|\|\s*(ba|z)?sh\b`),
+	},
+}
+
+var cFamilyRules = &previewRules{
+	comment: "//",
+	imports: []*regexp.Regexp{
+		regexp.MustCompile(`^\s*#\s*include\s*[<"]([^>"]+)[>"]`),
+	},
+	network:    regexp.MustCompile(`\b(socket|connect|bind|listen|accept|getaddrinfo|gethostbyname|sendto|recvfrom)\s*\(`),
+	fileWrites: regexp.MustCompile(`\bfopen\s*\([^)]*"[wa]\+?b?"|\b(remove|unlink|rename|rmdir|mkdir|chmod|chown|creat|truncate|ftruncate|symlink|link)\s*\(|\bopen\s*\([^)]*O_(WRONLY|RDWR|CREAT|TRUNC|APPEND)|\bofstream\b|\bstd::filesystem::(remove\w*|rename|create_director\w*|copy\w*)`),
+	processes:  regexp.MustCompile(`\b(system|popen|fork|vfork|execl|execlp|execle|execv|execvp|execve|posix_spawn\w*|kill|daemon)\s*\(`),
+	syscalls:   regexp.MustCompile(`\bsyscall\s*\(|\b(ptrace|mmap|mprotect|setuid|setgid|seteuid|chroot|ioctl|dlopen|prctl|unshare|setrlimit)\s*\(|\b__asm__\b|\basm\s*(volatile\s*)?\(`),
+}
+
+// goImportLine matches a path inside an import ( ... ) block
+var goImportLine = regexp.MustCompile(`^\s*(?:[\w.]+\s+)?"([^"]+)"`)
+
+// analyzeCode builds the preview for code in a session language
+func analyzeCode(language, code string) *Preview {
+	lines := strings.Split(strings.ReplaceAll(code, "\r\n", "\n"), "\n")
+	preview := &Preview{
+		Language:    language,
+		Lines:       len(lines),
+		Imports:     []string{},
+		Network:     []PreviewFinding{},
+		FileWrites:  []PreviewFinding{},
+		Processes:   []PreviewFinding{},
+		Syscalls:    []PreviewFinding{},
+		DynamicCode: []PreviewFinding{},
+		Risk:        RiskLow,
+	}
+
+	langID, err := GetLanguageID(language)
+	if err != nil {
+		return preview
+	}
+	rules, ok := previewRuleSets[langID]
+	if !ok {
+		return preview
+	}
+	preview.Supported = true
+
+	imports := map[string]bool{}
+	inGoImports := false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+		if strings.HasPrefix(trimmed, rules.comment) {
+			continue
+		}
+
+		if langID == LanguageGo {
+			switch {
+			case strings.HasPrefix(trimmed, "import ("):
+				inGoImports = true
+				continue
+			case inGoImports && trimmed == ")":
+				inGoImports = false
+				continue
+			case inGoImports:
+				if m := goImportLine.FindStringSubmatch(line); m != nil {
+					imports[m[1]] = true
+				}
+				continue
+			}
+		}
+		for _, re := range rules.imports {
+			for _, m := range re.FindAllStringSubmatch(line, -1) {
+				for _, name := range strings.Split(m[1], ",") {
+					if name = strings.TrimSpace(name); name != "" {
+						imports[name] = true
+					}
+				}
+			}
+		}
+
+		preview.Network = appendFinding(preview.Network, rules.network, i+1, line)
+		preview.FileWrites = appendFinding(preview.FileWrites, rules.fileWrites, i+1, line)
+		preview.Processes = appendFinding(preview.Processes, rules.processes, i+1, line)
+		preview.Syscalls = appendFinding(preview.Syscalls, rules.syscalls, i+1, line)
+		preview.DynamicCode = appendFinding(preview.DynamicCode, rules.dynamicCode, i+1, line)
+	}
+
+	for name := range imports {
+		preview.Imports = append(preview.Imports, name)
+	}
+	sort.Strings(preview.Imports)
+
+	switch {
+	case len(preview.Network) > 0 || len(preview.Processes) > 0 || len(preview.Syscalls) > 0:
+		preview.Risk = RiskHigh
+	case len(preview.FileWrites) > 0 || len(preview.DynamicCode) > 0:
+		preview.Risk = RiskMedium
+	}
+	return preview
+}
+
+// appendFinding records line if it matches re; harmless redirections in
+// shell code (to /dev/null and the standard streams) are ignored
+func appendFinding(findings []PreviewFinding, re *regexp.Regexp, lineNo int, line string) []PreviewFinding {
+	if re == nil {
+		return findings
+	}
+	for _, match := range re.FindAllString(line, -1) {
+		indicator := strings.TrimSpace(strings.TrimLeft(match, ";&|($"))
+		if strings.Contains(indicator, ">") && strings.Contains(indicator, "/dev/") && !strings.Contains(indicator, "/dev/tcp") && !strings.Contains(indicator, "/dev/udp") {
+			continue
+		}
+		text := strings.TrimSpace(line)
+		if len(text) > maxFindingText {
+			text = text[:maxFindingText] + "..."
+		}
+		return append(findings, PreviewFinding{Line: lineNo, Indicator: indicator, Text: text})
+	}
+	return findings
+}
+
+// handlePreview serves POST /sessions/{id}/preview: the static preview of
+// code in the session's language, without running it
+func handlePreview(w http.ResponseWriter, r *http.Request) {
+	session, err := sessionsFor(r.Context()).GetSession(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Code == "" {
+		http.Error(w, "code is required", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(analyzeCode(session.Language, req.Code))
+}
+
+// printPreview writes a human-readable preview
+func printPreview(w io.Writer, p *Preview) {
+	if !p.Supported {
+		fmt.Fprintf(w, "[preview] no static analysis rules for %s\n", p.Language)
+		return
+	}
+	fmt.Fprintf(w, "[preview] risk: %s\n", p.Risk)
+	if len(p.Imports) > 0 {
+		fmt.Fprintf(w, "[preview] imports: %s\n", strings.Join(p.Imports, ", "))
+	}
+	for _, group := range []struct {
+		name     string
+		findings []PreviewFinding
+	}{
+		{"network", p.Network},
+		{"file write", p.FileWrites},
+		{"process", p.Processes},
+		{"syscall", p.Syscalls},
+		{"dynamic code", p.DynamicCode},
+	} {
+		for _, f := range group.findings {
+			fmt.Fprintf(w, "[preview] %s: line %d: %s\n", group.name, f.Line, f.Text)
+		}
+	}
+}
+
+var previewCmd = &cobra.Command{
+	Use:   "preview <session-id> <code>",
+	Short: "Summarize what code would do without running it",
+	Long: `Statically analyze code in a session's language and report the modules
+it imports and any network, file write, process, low-level system and
+dynamic code indicators, with an overall risk level. Nothing is executed.
+
+The analysis is pattern-based: it surfaces intent for review, it does not
+prove that code is safe.
+
+Examples:
+  j0 preview sess-abc123 "import os; os.system('ls')"
+  j0 preview sess-abc123 "$(cat script.py)" --json`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		session, err := sessionManager.GetSession(args[0])
+		if err != nil {
+			return err
+		}
+		preview := analyzeCode(session.Language, args[1])
+
+		if jsonOut, _ := cmd.Flags().GetBool("json"); jsonOut {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(preview)
+		}
+		printPreview(os.Stdout, preview)
+		return nil
+	},
+}
+
+func init() {
+	previewCmd.Flags().Bool("json", false, "Output as JSON")
+}
@@ -0,0 +1,537 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// Problem is a named set of test cases that executions can be graded against
+type Problem struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	TestCases []TestCase `json:"test_cases"`
+
+	// StdinTemplates are named stdin inputs selectable by sessions on this problem
+	StdinTemplates map[string]string `json:"stdin_templates,omitempty"`
+}
+
+// TestCase is a single input/expected output pair
+type TestCase struct {
+	Name           string `json:"name"`
+	Input          string `json:"input"`
+	ExpectedOutput string `json:"expected_output"`
+}
+
+// ImportReport summarizes a test case import (or dry run)
+type ImportReport struct {
+	DryRun   bool       `json:"dry_run"`
+	Imported int        `json:"imported"`
+	Valid    []string   `json:"valid"`
+	Errors   []string   `json:"errors,omitempty"`
+	Cases    []TestCase `json:"-"`
+}
+
+// ProblemStore persists problems under <data-dir>/problems
+type ProblemStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewProblemStore creates the problems directory if needed
+func NewProblemStore(dataDir string) (*ProblemStore, error) {
+	dir := filepath.Join(dataDir, "problems")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create problems directory: %w", err)
+	}
+	return &ProblemStore{dir: dir}, nil
+}
+
+// Create adds a new empty problem
+func (ps *ProblemStore) Create(name string) (*Problem, error) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	now := time.Now()
+	problem := &Problem{
+		ID:        generateID("prob"),
+		Name:      name,
+		CreatedAt: now,
+		UpdatedAt: now,
+		TestCases: []TestCase{},
+	}
+
+	if err := ps.save(problem); err != nil {
+		return nil, err
+	}
+	return problem, nil
+}
+
+// Get loads a problem by ID
+func (ps *ProblemStore) Get(id string) (*Problem, error) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	return ps.load(id)
+}
+
+// List returns all problems
+func (ps *ProblemStore) List() ([]*Problem, error) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	entries, err := os.ReadDir(ps.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	problems := []*Problem{}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		problem, err := ps.load(strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil {
+			continue
+		}
+		problems = append(problems, problem)
+	}
+	return problems, nil
+}
+
+// Import parses test cases in the given format ("zip", "csv" or "json"),
+// validates them and, unless dryRun is set, appends them to the problem.
+func (ps *ProblemStore) Import(problemID, format string, data []byte, dryRun bool) (*ImportReport, error) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	problem, err := ps.load(problemID)
+	if err != nil {
+		return nil, err
+	}
+
+	var report *ImportReport
+	switch format {
+	case "zip":
+		report, err = parseZipTestCases(data)
+	case "csv":
+		report, err = parseCSVTestCases(data)
+	case "json":
+		report, err = parseJSONTestCases(data)
+	default:
+		return nil, fmt.Errorf("unsupported import format: %s", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Reject names that collide with existing test cases
+	existing := make(map[string]bool)
+	for _, tc := range problem.TestCases {
+		existing[tc.Name] = true
+	}
+	cases := report.Cases[:0]
+	report.Valid = nil
+	for _, tc := range report.Cases {
+		if existing[tc.Name] {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: test case already exists", tc.Name))
+			continue
+		}
+		existing[tc.Name] = true
+		cases = append(cases, tc)
+		report.Valid = append(report.Valid, tc.Name)
+	}
+	report.Cases = cases
+	report.DryRun = dryRun
+
+	if dryRun || len(report.Errors) > 0 {
+		return report, nil
+	}
+
+	problem.TestCases = append(problem.TestCases, report.Cases...)
+	problem.UpdatedAt = time.Now()
+	if err := ps.save(problem); err != nil {
+		return nil, err
+	}
+	report.Imported = len(report.Cases)
+	return report, nil
+}
+
+func (ps *ProblemStore) load(id string) (*Problem, error) {
+	data, err := os.ReadFile(filepath.Join(ps.dir, filepath.Base(id)+".json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("problem not found: %s", id)
+		}
+		return nil, err
+	}
+
+	var problem Problem
+	if err := json.Unmarshal(data, &problem); err != nil {
+		return nil, err
+	}
+	return &problem, nil
+}
+
+func (ps *ProblemStore) save(problem *Problem) error {
+	data, err := json.MarshalIndent(problem, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(ps.dir, problem.ID+".json"), data, 0644)
+}
+
+// parseZipTestCases pairs input and output files inside an archive.
+// Accepted layouts: "<name>.in"/"<name>.out" side by side, or
+// "input/<name>"/"output/<name>" directories (optionally nested in a top folder).
+func parseZipTestCases(data []byte) (*ImportReport, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid zip archive: %w", err)
+	}
+
+	inputs := make(map[string]string)
+	outputs := make(map[string]string)
+
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+
+		name := path.Clean(f.Name)
+		dir, base := path.Split(name)
+		ext := path.Ext(base)
+		dirName := path.Base(strings.TrimSuffix(dir, "/"))
+
+		var target map[string]string
+		var key string
+		switch {
+		case ext == ".in":
+			target, key = inputs, path.Join(dir, strings.TrimSuffix(base, ext))
+		case ext == ".out" || ext == ".ans":
+			target, key = outputs, path.Join(dir, strings.TrimSuffix(base, ext))
+		case dirName == "input":
+			target, key = inputs, path.Join(path.Dir(strings.TrimSuffix(dir, "/")), strings.TrimSuffix(base, ext))
+		case dirName == "output":
+			target, key = outputs, path.Join(path.Dir(strings.TrimSuffix(dir, "/")), strings.TrimSuffix(base, ext))
+		default:
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		target[key] = string(content)
+	}
+
+	report := &ImportReport{}
+	names := make([]string, 0, len(inputs))
+	for key := range inputs {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+
+	for _, key := range names {
+		output, ok := outputs[key]
+		if !ok {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: missing output file", key))
+			continue
+		}
+		report.Cases = append(report.Cases, TestCase{
+			Name:           path.Base(key),
+			Input:          inputs[key],
+			ExpectedOutput: output,
+		})
+	}
+	for key := range outputs {
+		if _, ok := inputs[key]; !ok {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: missing input file", key))
+		}
+	}
+
+	if len(report.Cases) == 0 && len(report.Errors) == 0 {
+		report.Errors = append(report.Errors, "archive contains no test cases")
+	}
+	return report, nil
+}
+
+// parseCSVTestCases reads rows of input,expected_output with an optional name column.
+// The first row is treated as a header when it names the columns.
+func parseCSVTestCases(data []byte) (*ImportReport, error) {
+	rows, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid csv: %w", err)
+	}
+
+	inputCol, outputCol, nameCol := 0, 1, -1
+	if len(rows) > 0 {
+		header := make(map[string]int)
+		for i, col := range rows[0] {
+			header[strings.ToLower(strings.TrimSpace(col))] = i
+		}
+		in, hasIn := header["input"]
+		out, hasOut := header["expected_output"]
+		if !hasOut {
+			out, hasOut = header["output"]
+		}
+		if hasIn && hasOut {
+			inputCol, outputCol = in, out
+			if n, ok := header["name"]; ok {
+				nameCol = n
+			}
+			rows = rows[1:]
+		}
+	}
+
+	report := &ImportReport{}
+	for i, row := range rows {
+		name := fmt.Sprintf("case-%d", i+1)
+		if nameCol >= 0 && nameCol < len(row) && row[nameCol] != "" {
+			name = row[nameCol]
+		}
+		if inputCol >= len(row) || outputCol >= len(row) {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: row %d has too few columns", name, i+1))
+			continue
+		}
+		report.Cases = append(report.Cases, TestCase{
+			Name:           name,
+			Input:          row[inputCol],
+			ExpectedOutput: row[outputCol],
+		})
+	}
+	return report, nil
+}
+
+// parseJSONTestCases reads an array of {name, input, expected_output} objects
+func parseJSONTestCases(data []byte) (*ImportReport, error) {
+	var cases []TestCase
+	if err := json.Unmarshal(data, &cases); err != nil {
+		return nil, fmt.Errorf("invalid json: %w", err)
+	}
+
+	report := &ImportReport{}
+	for i, tc := range cases {
+		if tc.Name == "" {
+			tc.Name = fmt.Sprintf("case-%d", i+1)
+		}
+		report.Cases = append(report.Cases, tc)
+	}
+	return report, nil
+}
+
+// HTTP handlers
+
+func handleCreateProblem(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	problem, err := problemStore.Create(req.Name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	audit(r.Context(), "problem.create", "", map[string]interface{}{"problem_id": problem.ID, "name": problem.Name})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(problem)
+}
+
+func handleListProblems(w http.ResponseWriter, r *http.Request) {
+	problems, err := problemStore.List()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(problems)
+}
+
+func handleGetProblem(w http.ResponseWriter, r *http.Request) {
+	problem, err := problemStore.Get(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(problem)
+}
+
+// handleImportTestCases accepts a zip, csv or json body.
+// The format comes from ?format= or is inferred from the Content-Type.
+func handleImportTestCases(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = importFormatFromContentType(r.Header.Get("Content-Type"))
+	}
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, 64<<20))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report, err := problemStore.Import(r.PathValue("id"), format, data, dryRun)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !dryRun && len(report.Errors) == 0 {
+		audit(r.Context(), "problem.import", "", map[string]interface{}{
+			"problem_id": r.PathValue("id"),
+			"format":     format,
+			"imported":   report.Imported,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if len(report.Errors) > 0 {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+// importFormatFromContentType maps a media type to an import format
+func importFormatFromContentType(contentType string) string {
+	switch {
+	case strings.Contains(contentType, "zip"):
+		return "zip"
+	case strings.Contains(contentType, "csv"):
+		return "csv"
+	default:
+		return "json"
+	}
+}
+
+// CLI commands
+
+var problemsCmd = &cobra.Command{
+	Use:   "problems",
+	Short: "Manage problems and their test cases",
+}
+
+func init() {
+	problemsCmd.AddCommand(problemsCreateCmd)
+	problemsCmd.AddCommand(problemsListCmd)
+	problemsCmd.AddCommand(problemsImportCmd)
+}
+
+var problemsCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create a new problem",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		problem, err := problemStore.Create(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Created problem: %s (%s)\n", problem.ID, problem.Name)
+		return nil
+	},
+}
+
+var problemsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List problems",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		problems, err := problemStore.List()
+		if err != nil {
+			return err
+		}
+
+		if len(problems) == 0 {
+			fmt.Println("No problems found.")
+			return nil
+		}
+
+		fmt.Printf("%-15s %-8s %s\n", "ID", "CASES", "NAME")
+		fmt.Println(strings.Repeat("-", 50))
+		for _, p := range problems {
+			fmt.Printf("%-15s %-8d %s\n", p.ID, len(p.TestCases), p.Name)
+		}
+		return nil
+	},
+}
+
+var problemsImportCmd = &cobra.Command{
+	Use:   "import <problem-id> <file>",
+	Short: "Import test cases from a zip, csv or json file",
+	Long: `Import test cases into a problem.
+
+Zip archives may contain <name>.in/<name>.out pairs or input/ and output/
+directories with matching file names. CSV files need input and
+expected_output columns (with an optional name column). JSON files hold an
+array of {"name", "input", "expected_output"} objects.
+
+Examples:
+  j0 problems import prob-1a2b3c4d tests.zip --dry-run
+  j0 problems import prob-1a2b3c4d cases.csv`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		format, _ := cmd.Flags().GetString("format")
+		if format == "" {
+			format = strings.TrimPrefix(filepath.Ext(args[1]), ".")
+		}
+
+		data, err := os.ReadFile(args[1])
+		if err != nil {
+			return err
+		}
+
+		report, err := problemStore.Import(args[0], format, data, dryRun)
+		if err != nil {
+			return err
+		}
+
+		for _, name := range report.Valid {
+			fmt.Printf("ok      %s\n", name)
+		}
+		for _, msg := range report.Errors {
+			fmt.Printf("error   %s\n", msg)
+		}
+
+		if len(report.Errors) > 0 {
+			return fmt.Errorf("%d validation errors, nothing imported", len(report.Errors))
+		}
+		if dryRun {
+			fmt.Printf("Dry run: %d test cases would be imported.\n", len(report.Valid))
+		} else {
+			fmt.Printf("Imported %d test cases.\n", report.Imported)
+		}
+		return nil
+	},
+}
+
+func init() {
+	problemsImportCmd.Flags().Bool("dry-run", false, "Validate without importing")
+	problemsImportCmd.Flags().String("format", "", "Input format: zip, csv or json (default: from file extension)")
+}
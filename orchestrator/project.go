@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// projectManifestName marks a workspace as a multi-file project
+const projectManifestName = "j0.project.json"
+
+// LanguageMultiFile is Judge0's "Multi-file program" language: the
+// additional_files archive provides compile and run scripts
+const LanguageMultiFile = 89
+
+// Judge0 status ID reported when compilation fails
+const statusCompilationError = 6
+
+// compileErrorExitCode is the exit code recorded for executions that
+// failed to compile; Judge0 reports none since nothing ran
+const compileErrorExitCode = 1
+
+// Execution phases; PhaseCompile marks executions that failed to compile,
+// and project builds that compiled are PhaseRun
+const (
+	PhaseCompile = "compile"
+	PhaseRun     = "run"
+)
+
+// projectBinary is the executable produced by the compile script
+const projectBinary = "j0main"
+
+// ProjectManifest describes how to build a multi-file project
+type ProjectManifest struct {
+	// Entrypoint is the main source file; request code replaces its contents
+	Entrypoint string `json:"entrypoint,omitempty"`
+	// Sources are glob patterns of files to compile (default: every file
+	// with the language's extensions)
+	Sources []string `json:"sources,omitempty"`
+	// BuildFlags are passed to the compiler
+	BuildFlags []string `json:"build_flags,omitempty"`
+	// Args are passed to the program
+	Args []string `json:"args,omitempty"`
+}
+
+// projectToolchain knows how to compile a project in the Judge0 image
+type projectToolchain struct {
+	entrypoint string
+	extensions []string
+	// topLevelOnly limits default sources to the workspace root (go build
+	// takes files of a single package)
+	topLevelOnly bool
+	compile      func(flags, sources []string, entrypoint string) string
+}
+
+// projectToolchains holds compile commands for the compilers installed in
+// the Judge0 image, keyed by language ID
+var projectToolchains = map[int]projectToolchain{
+	LanguageGo: {
+		entrypoint:   "main.go",
+		extensions:   []string{".go"},
+		topLevelOnly: true,
+		compile: func(flags, sources []string, _ string) string {
+			return "GOCACHE=/tmp/.cache/go-build /usr/local/go-1.13.5/bin/go build " +
+				shellJoin(flags) + " -o " + projectBinary + " " + shellJoin(sources)
+		},
+	},
+	LanguageC: {
+		entrypoint: "main.c",
+		extensions: []string{".c"},
+		compile: func(flags, sources []string, _ string) string {
+			return "/usr/local/gcc-9.2.0/bin/gcc " + shellJoin(flags) + " -o " + projectBinary + " " + shellJoin(sources) + " -lm"
+		},
+	},
+	LanguageCPP: {
+		entrypoint: "main.cpp",
+		extensions: []string{".cpp", ".cc", ".cxx"},
+		compile: func(flags, sources []string, _ string) string {
+			return "/usr/local/gcc-9.2.0/bin/g++ " + shellJoin(flags) + " -o " + projectBinary + " " + shellJoin(sources)
+		},
+	},
+	LanguageRust: {
+		entrypoint: "main.rs",
+		extensions: []string{".rs"},
+		// rustc follows `mod` declarations from the crate root itself
+		compile: func(flags, _ []string, entrypoint string) string {
+			return "/usr/local/rust-1.40.0/bin/rustc " + shellJoin(flags) + " -o " + projectBinary + " " + shellQuote(entrypoint)
+		},
+	},
+}
+
+// LoadProjectManifest returns the workspace's project manifest, or nil if
+// the workspace is not a project
+func (sm *SessionManager) LoadProjectManifest(sessionID string) (*ProjectManifest, error) {
+	data, err := os.ReadFile(filepath.Join(sm.WorkspaceDir(sessionID), projectManifestName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var m ProjectManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", projectManifestName, err)
+	}
+	return &m, nil
+}
+
+// buildProjectSubmission bundles the workspace with compile and run scripts
+// for Judge0's multi-file language. Non-empty code replaces the entrypoint.
+func (sm *SessionManager) buildProjectSubmission(session *Session, langID int, m *ProjectManifest, code, stdin string) (Judge0Submission, error) {
+	tc, ok := projectToolchains[langID]
+	if !ok {
+		return Judge0Submission{}, fmt.Errorf("%s projects are not supported (go, c, cpp and rust are)", session.Language)
+	}
+
+	entrypoint := m.Entrypoint
+	if entrypoint == "" {
+		entrypoint = tc.entrypoint
+	}
+	entrypoint = path.Clean("/" + filepath.ToSlash(entrypoint))[1:]
+
+	root := sm.WorkspaceDir(session.ID)
+	files, err := listDirFiles(root)
+	if err != nil {
+		return Judge0Submission{}, err
+	}
+
+	extra := map[string][]byte{}
+	if strings.TrimSpace(code) != "" {
+		extra[entrypoint] = []byte(code)
+	}
+
+	sources, err := projectSources(files, extra, m.Sources, tc)
+	if err != nil {
+		return Judge0Submission{}, err
+	}
+	if len(sources) == 0 {
+		return Judge0Submission{}, fmt.Errorf("project has no %s sources", strings.Join(tc.extensions, "/"))
+	}
+
+	extra["compile"] = []byte("#!/bin/bash\nset -e\n" + tc.compile(m.BuildFlags, sources, entrypoint) + "\n")
+
+	env, err := sm.executionEnv(session)
+	if err != nil {
+		return Judge0Submission{}, err
+	}
+	var run strings.Builder
+	run.WriteString("#!/bin/bash\n")
+	for _, k := range sortedEnvKeys(env) {
+		if !validEnvName.MatchString(k) {
+			continue
+		}
+		fmt.Fprintf(&run, "export %s=%s\n", k, shellQuote(env[k]))
+	}
+	fmt.Fprintf(&run, "exec ./%s %s\n", projectBinary, shellJoin(m.Args))
+	extra["run"] = []byte(run.String())
+
+	archive, err := workspaceZip(root, extra)
+	if err != nil {
+		return Judge0Submission{}, err
+	}
+
+	sub := NewSubmission("", LanguageMultiFile, stdin)
+	sub.AdditionalFiles = base64.StdEncoding.EncodeToString(archive)
+	return sub, nil
+}
+
+// projectSources expands the manifest's source patterns, or picks every file
+// with the toolchain's extensions
+func projectSources(files []WorkspaceFile, extra map[string][]byte, patterns []string, tc projectToolchain) ([]string, error) {
+	paths := map[string]bool{}
+	for _, f := range files {
+		paths[f.Path] = true
+	}
+	for p := range extra {
+		paths[p] = true
+	}
+
+	var sources []string
+	for p := range paths {
+		if len(patterns) > 0 {
+			for _, pattern := range patterns {
+				ok, err := path.Match(pattern, p)
+				if err != nil {
+					return nil, fmt.Errorf("invalid source pattern %q: %w", pattern, err)
+				}
+				if ok {
+					sources = append(sources, p)
+					break
+				}
+			}
+			continue
+		}
+
+		if tc.topLevelOnly && strings.Contains(p, "/") {
+			continue
+		}
+		for _, ext := range tc.extensions {
+			if strings.HasSuffix(p, ext) {
+				sources = append(sources, p)
+				break
+			}
+		}
+	}
+	sort.Strings(sources)
+	return sources, nil
+}
+
+// shellQuote quotes s for a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// shellJoin quotes and joins arguments
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		quoted[i] = shellQuote(a)
+	}
+	return strings.Join(quoted, " ")
+}
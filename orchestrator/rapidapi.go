@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// rapidAPIKeyEnv defaults --rapidapi-key
+const rapidAPIKeyEnv = "RAPIDAPI_KEY"
+
+// defaultRapidAPIHost is the hosted Judge0 CE listing on RapidAPI
+const defaultRapidAPIHost = "judge0-ce.p.rapidapi.com"
+
+// rapidAPIKey and rapidAPIHost are set from --rapidapi-key and
+// --rapidapi-host. With a key, Judge0 requests carry the RapidAPI headers
+// and go to https://<host> unless --judge0-url is given.
+var (
+	rapidAPIKey  string
+	rapidAPIHost = defaultRapidAPIHost
+)
+
+// rapidAPIURL is the Judge0 base URL for RapidAPI mode
+func rapidAPIURL() string {
+	return "https://" + rapidAPIHost
+}
+
+// setRapidAPIHeaders adds the RapidAPI key and host to a Judge0 request
+func setRapidAPIHeaders(req *http.Request) {
+	if rapidAPIKey == "" {
+		return
+	}
+	req.Header.Set("X-RapidAPI-Key", rapidAPIKey)
+	req.Header.Set("X-RapidAPI-Host", rapidAPIHost)
+}
+
+// rateLimitBackoff is how long polling waits after a 429 that doesn't say
+// when to retry
+const rateLimitBackoff = 2 * time.Second
+
+// RateLimitError is returned when Judge0, or RapidAPI in front of it,
+// answers 429 Too Many Requests
+type RateLimitError struct {
+	// RetryAfter is from the Retry-After header; zero when it wasn't sent
+	RetryAfter time.Duration
+	// Remaining is RapidAPI's X-RateLimit-Requests-Remaining, or -1
+	Remaining int
+	Body      string
+}
+
+func (e *RateLimitError) Error() string {
+	msg := "judge0 rate limit exceeded"
+	if e.RetryAfter > 0 {
+		msg += fmt.Sprintf("; retry after %s", e.RetryAfter)
+	}
+	if e.Body != "" {
+		msg += ": " + e.Body
+	}
+	return msg
+}
+
+// newRateLimitError reads the rate-limit headers of a 429 response
+func newRateLimitError(resp *http.Response, body []byte) *RateLimitError {
+	e := &RateLimitError{Remaining: -1, Body: string(body)}
+	if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs > 0 {
+		e.RetryAfter = time.Duration(secs) * time.Second
+	}
+	if n, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Requests-Remaining")); err == nil {
+		e.Remaining = n
+	}
+	return e
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Per-client limits (requests per minute) on the expensive endpoints;
+// 0 disables them
+var (
+	executeRateLimit int
+	createRateLimit  int
+)
+
+// executeLimiter and createLimiter are set up by serve
+var executeLimiter, createLimiter *ClientLimiter
+
+// maxLimiterBuckets bounds memory used by clients that went away
+const maxLimiterBuckets = 10000
+
+// ClientLimiter keeps a token bucket per client (API key, user or address)
+type ClientLimiter struct {
+	mu      sync.Mutex
+	limit   int
+	buckets map[string]*keyLimiter
+}
+
+// NewClientLimiter allows limit requests per minute per client; it returns
+// nil (no limiting) when limit is 0
+func NewClientLimiter(limit int) *ClientLimiter {
+	if limit <= 0 {
+		return nil
+	}
+	return &ClientLimiter{limit: limit, buckets: make(map[string]*keyLimiter)}
+}
+
+// bucket returns the client's bucket, creating a full one on first use
+func (c *ClientLimiter) bucket(client string) *keyLimiter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	b, ok := c.buckets[client]
+	if !ok {
+		if len(c.buckets) >= maxLimiterBuckets {
+			c.buckets = make(map[string]*keyLimiter)
+		}
+		b = &keyLimiter{limit: c.limit, tokens: float64(c.limit), last: time.Now()}
+		c.buckets[client] = b
+	}
+	return b
+}
+
+// rateLimitClient identifies the caller: its authenticated principal, or
+// its address for anonymous requests
+func rateLimitClient(r *http.Request) string {
+	if p := principalFromContext(r.Context()); p != "" {
+		return p
+	}
+	return anonymousActor(r)
+}
+
+// take spends one of the client's tokens. It returns the tokens left, the
+// seconds until the bucket is full again and, when denied, how long until
+// the next token.
+func (c *ClientLimiter) take(client string) (bool, int, float64, time.Duration) {
+	b := c.bucket(client)
+	allowed, wait := b.allow()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return allowed, int(b.tokens), (float64(b.limit) - b.tokens) * 60 / float64(b.limit), wait
+}
+
+// Allow takes a token for the request's client and sets the RateLimit-*
+// headers. When the bucket is empty it writes a 429 and returns false.
+func (c *ClientLimiter) Allow(w http.ResponseWriter, r *http.Request) bool {
+	if c == nil {
+		return true
+	}
+
+	allowed, remaining, reset, wait := c.take(rateLimitClient(r))
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(c.limit))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(remaining))
+	w.Header().Set("RateLimit-Reset", strconv.Itoa(int(math.Ceil(reset))))
+	w.Header().Set("RateLimit-Policy", strconv.Itoa(c.limit)+";w=60")
+
+	if !allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		http.Error(w, rateLimitMessage(wait), http.StatusTooManyRequests)
+		return false
+	}
+	return true
+}
+
+// AllowClient is Allow for callers without a response to decorate, such as
+// WebSocket messages. It returns an error when the client is over its limit.
+func (c *ClientLimiter) AllowClient(r *http.Request) error {
+	if c == nil {
+		return nil
+	}
+	if allowed, _, _, wait := c.take(rateLimitClient(r)); !allowed {
+		return errors.New(rateLimitMessage(wait))
+	}
+	return nil
+}
+
+func rateLimitMessage(wait time.Duration) string {
+	return "rate limit exceeded, retry in " + wait.Round(time.Second).String()
+}
+
+// Remaining reports the client's tokens without taking one
+func (c *ClientLimiter) Remaining(client string) int {
+	return c.bucket(client).available()
+}
+
+// rateLimited wraps a handler with a per-client limiter
+func rateLimited(c *ClientLimiter, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if c.Allow(w, r) {
+			next(w, r)
+		}
+	}
+}
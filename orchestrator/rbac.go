@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Roles granted to API keys and OIDC users
+const (
+	// RoleAdmin may do anything, including closing other users' sessions
+	RoleAdmin = "admin"
+	// RoleOperator creates sessions, runs code and manages its own sessions
+	RoleOperator = "operator"
+	// RoleReadOnly may list sessions and read logs, executions and files
+	RoleReadOnly = "read-only"
+)
+
+// roleAnonymous marks unauthenticated requests to public routes while
+// auth is configured; it ranks below every role
+const roleAnonymous = "anonymous"
+
+// roleRank orders roles by privilege
+var roleRank = map[string]int{
+	RoleReadOnly: 1,
+	RoleOperator: 2,
+	RoleAdmin:    3,
+}
+
+// validateRole checks a configured role; empty means operator
+func validateRole(role string) error {
+	if role == "" {
+		return nil
+	}
+	if _, ok := roleRank[role]; !ok {
+		return fmt.Errorf("invalid role %q (want admin, operator or read-only)", role)
+	}
+	return nil
+}
+
+// mcpReadOnlyTools are the MCP tools a read-only caller may invoke
+var mcpReadOnlyTools = map[string]bool{
+	"j0_get_session":     true,
+	"j0_get_environment": true,
+	"j0_list_sessions":   true,
+	"j0_get_log":         true,
+	"j0_get_output":      true,
+	"j0_preview":         true,
+	"j0_list_languages":  true,
+	"j0_read_file":       true,
+	"j0_list_files":      true,
+}
+
+type roleKey struct{}
+
+// withRole records the authenticated caller's role
+func withRole(ctx context.Context, role string) context.Context {
+	if role == "" {
+		role = RoleOperator
+	}
+	return context.WithValue(ctx, roleKey{}, role)
+}
+
+// roleFromContext returns the caller's role. The CLI and requests served
+// with auth disabled are treated as admin; withAuth marks anonymous
+// requests while auth is configured.
+func roleFromContext(ctx context.Context) string {
+	if role, ok := ctx.Value(roleKey{}).(string); ok {
+		return role
+	}
+	return RoleAdmin
+}
+
+// hasRole reports whether the caller's role is at least want
+func hasRole(ctx context.Context, want string) bool {
+	return roleRank[roleFromContext(ctx)] >= roleRank[want]
+}
+
+// authorizeClose lets admins close any session and operators only their own
+func authorizeClose(ctx context.Context, session *Session) error {
+	if hasRole(ctx, RoleAdmin) {
+		return nil
+	}
+	if !hasRole(ctx, RoleOperator) {
+		return fmt.Errorf("read-only credentials can't close sessions")
+	}
+	if session.Owner == "" || session.Owner != principalFromContext(ctx) {
+		return fmt.Errorf("only admins can close other users' sessions")
+	}
+	return nil
+}
+
+// withRBAC limits read-only callers to reads. MCP invocations are checked
+// per tool by handleMCPInvoke and handleMCPPost; /graphql only serves
+// queries and code previews only analyze, whatever the method. Public
+// routes authorize their callers themselves.
+func withRBAC(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hasRole(r.Context(), RoleOperator) || !requiresAuth(r.Method, r.URL.Path) || r.URL.Path == "/mcp/invoke" || r.URL.Path == "/mcp" || r.URL.Path == "/graphql" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		read := r.Method == http.MethodGet || r.Method == http.MethodHead || strings.HasSuffix(r.URL.Path, "/preview")
+		// The WebSocket endpoint executes code despite being a GET
+		if !read || strings.HasSuffix(r.URL.Path, "/ws") {
+			http.Error(w, "read-only credentials can't "+r.Method+" "+r.URL.Path, http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
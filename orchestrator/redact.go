@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// redactedText replaces masked values
+const redactedText = "[REDACTED]"
+
+// minRedactLength keeps short values, which would mask unrelated text,
+// from being redacted
+const minRedactLength = 4
+
+// defaultRedactPatterns mask well-known credential formats
+var defaultRedactPatterns = []string{
+	`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`,    // AWS access key IDs
+	`\bgh[pousr]_[A-Za-z0-9]{36,}\b`,   // GitHub tokens
+	`\bxox[abprs]-[A-Za-z0-9-]{10,}\b`, // Slack tokens
+	`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`,
+}
+
+var (
+	// redactPatternSpecs is set from --redact-pattern
+	redactPatternSpecs []string
+	// redactPatterns are masked in every session's output
+	redactPatterns []*regexp.Regexp
+)
+
+// compileRedactPatterns validates --redact-pattern
+func compileRedactPatterns(specs []string) ([]*regexp.Regexp, error) {
+	patterns := make([]*regexp.Regexp, 0, len(specs))
+	for _, spec := range specs {
+		if spec == "" {
+			continue
+		}
+		re, err := regexp.Compile(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --redact-pattern %q: %w", spec, err)
+		}
+		patterns = append(patterns, re)
+	}
+	return patterns, nil
+}
+
+// redactor masks a session's secret env var values and the configured
+// patterns
+type redactor struct {
+	values  []string
+	secrets map[string]bool
+}
+
+// redactor returns a session's redactor. Secrets that can't be decrypted
+// are left to the patterns; executions fail without them anyway.
+func (sm *SessionManager) redactor(s *Session) *redactor {
+	r := &redactor{secrets: map[string]bool{}}
+	for name, sealed := range s.State.Secrets {
+		r.secrets[name] = true
+		if value, err := decryptSecret(sm.sealingKey(), s.ID, name, sealed); err == nil && len(value) >= minRedactLength {
+			r.values = append(r.values, value)
+		}
+	}
+	// Longest first, so a secret containing another is masked whole
+	sort.Slice(r.values, func(i, j int) bool { return len(r.values[i]) > len(r.values[j]) })
+	return r
+}
+
+// redact masks secrets and pattern matches in text
+func (r *redactor) redact(text string) string {
+	if text == "" {
+		return text
+	}
+	for _, value := range r.values {
+		text = strings.ReplaceAll(text, value, redactedText)
+	}
+	for _, re := range redactPatterns {
+		text = re.ReplaceAllLiteralString(text, redactedText)
+	}
+	return text
+}
+
+// redactEnv masks the values of secret variables and pattern matches in
+// the others
+func (r *redactor) redactEnv(env map[string]string) {
+	for name, value := range env {
+		if r.secrets[name] {
+			env[name] = redactedText
+		} else {
+			env[name] = r.redact(value)
+		}
+	}
+}
+
+// redactExecution masks what an execution recorded before it reaches the
+// history, the session log and API responses. Raw transcripts are stored
+// byte-exact and masked when served.
+func (r *redactor) redactExecution(exec *Execution) {
+	exec.Code = r.redact(exec.Code)
+	exec.Output = r.redact(exec.Output)
+	exec.Stderr = r.redact(exec.Stderr)
+	exec.CompileOutput = r.redact(exec.CompileOutput)
+	exec.Stdin = r.redact(exec.Stdin)
+	for _, step := range exec.Trace {
+		for k, v := range step.Locals {
+			step.Locals[k] = r.redact(v)
+		}
+	}
+	r.redactEnv(exec.ExportedEnv)
+	r.redactEnv(exec.CapturedEnv)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// ReplayResult pairs a re-run execution with the original for comparison
+type ReplayResult struct {
+	Original      *Execution `json:"original"`
+	Replay        *Execution `json:"replay"`
+	OutputMatches bool       `json:"output_matches"`
+	ExitMatches   bool       `json:"exit_code_matches"`
+}
+
+// replayExecution re-submits a prior execution's code and stdin with the
+// session's current environment and records the result as a new execution
+func replayExecution(ctx context.Context, sessionID, execID string) (*ReplayResult, error) {
+	original, err := sessionsFor(ctx).GetExecution(sessionID, execID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Stdin is only kept in the raw transcript, as is code that had
+	// secrets redacted from history
+	stdin, _ := sessionsFor(ctx).RawTranscript(sessionID, execID, "stdin")
+	code := original.Code
+	if raw, err := sessionsFor(ctx).RawTranscript(sessionID, execID, "code"); err == nil {
+		code = string(raw)
+	}
+
+	replay, err := runExecution(ctx, sessionID, ExecRequest{
+		Code:             code,
+		Stdin:            string(stdin),
+		Trace:            len(original.Trace) > 0,
+		ReplayOf:         original.ID,
+		ExpectedExitCode: original.ExpectedExitCode,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ReplayResult{
+		Original:      original,
+		Replay:        replay,
+		OutputMatches: replay.Output == original.Output,
+		ExitMatches:   replay.ExitCode == original.ExitCode,
+	}, nil
+}
+
+// handleRerunExecution serves POST /sessions/{id}/executions/{exec_id}/rerun
+func handleRerunExecution(w http.ResponseWriter, r *http.Request) {
+	sessionID, execID := r.PathValue("id"), r.PathValue("exec_id")
+	if _, err := sessionsFor(r.Context()).GetExecution(sessionID, execID); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	result, err := replayExecution(r.Context(), sessionID, execID)
+	if err != nil {
+		writeExecuteError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// replayCmd re-runs a previous execution
+var replayCmd = &cobra.Command{
+	Use:   "replay <session-id> <exec-id>",
+	Short: "Re-run a previous execution",
+	Long: `Re-submit a prior execution's code and stdin with the session's current
+environment. The new execution is recorded with a link to the original and
+the two results are compared.
+
+Examples:
+  j0 replay sess-abc123 exec-9f8e7d6c
+  j0 replay sess-abc123 exec-9f8e7d6c --json`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		result, err := replayExecution(cmd.Context(), args[0], args[1])
+		if err != nil {
+			return fmt.Errorf("replay failed: %w", err)
+		}
+
+		jsonOut, _ := cmd.Flags().GetBool("json")
+		if jsonOut {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(result)
+		}
+
+		if result.Replay.Output != "" {
+			fmt.Print(result.Replay.Output)
+		}
+		if result.Replay.Stderr != "" {
+			fmt.Fprintf(os.Stderr, "%s", result.Replay.Stderr)
+		}
+
+		fmt.Fprintf(os.Stderr, "[replay %s of %s] output matches: %t, exit code %d (was %d)\n",
+			result.Replay.ID, result.Original.ID, result.OutputMatches,
+			result.Replay.ExitCode, result.Original.ExitCode)
+
+		return nil
+	},
+}
+
+func init() {
+	replayCmd.Flags().Bool("json", false, "Output as JSON")
+}
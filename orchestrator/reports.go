@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// EventSessionReport delivers a SessionReport through the webhook notifier
+const EventSessionReport = "session.report"
+
+// Close report delivery modes for --close-report
+const (
+	ReportArtifact = "artifact"
+	ReportWebhook  = "webhook"
+	ReportBoth     = "both"
+)
+
+// closeReportMode selects how reports are delivered when sessions close;
+// empty disables them
+var closeReportMode string
+
+// maxNotableFailures caps the failures listed in a report
+const maxNotableFailures = 5
+
+// SessionReport summarizes a session's activity
+type SessionReport struct {
+	SessionID   string    `json:"session_id"`
+	Name        string    `json:"name,omitempty"`
+	Language    string    `json:"language"`
+	Status      string    `json:"status"`
+	CreatedAt   time.Time `json:"created_at"`
+	GeneratedAt time.Time `json:"generated_at"`
+
+	Executions int `json:"executions"`
+	Passed     int `json:"passed"`
+	Failed     int `json:"failed"`
+
+	TotalRuntimeMs float64 `json:"total_runtime_ms"`
+	TotalCPUTime   float64 `json:"total_cpu_time"`
+
+	NotableFailures []ReportFailure `json:"notable_failures,omitempty"`
+
+	// ExecutionsFile is the full execution history (JSONL)
+	ExecutionsFile string `json:"executions_file"`
+	// Archive is the exam archive, when the session has one
+	Archive string `json:"archive,omitempty"`
+}
+
+// ReportFailure is a failed execution listed in a report
+type ReportFailure struct {
+	ExecutionID string    `json:"execution_id"`
+	Time        time.Time `json:"time"`
+	ExitCode    int       `json:"exit_code"`
+	Stderr      string    `json:"stderr,omitempty"`
+}
+
+// executionPassed uses the expected exit code when one was set, otherwise
+// a zero exit
+func executionPassed(e *Execution) bool {
+	if e.Passed != nil {
+		return *e.Passed
+	}
+	return e.ExitCode == 0
+}
+
+// stderrExcerpt keeps report payloads small
+func stderrExcerpt(s string) string {
+	const max = 500
+	if len(s) <= max {
+		return s
+	}
+	return s[:max] + "..."
+}
+
+// BuildReport summarizes a session from its full JSONL execution history
+func (sm *SessionManager) BuildReport(session Session) (*SessionReport, error) {
+	executions, err := readJSONLExecutions(sm.ExecutionsFile(&session))
+	if err != nil {
+		return nil, err
+	}
+
+	report := &SessionReport{
+		SessionID:      session.ID,
+		Name:           session.Name,
+		Language:       session.Language,
+		Status:         session.Status,
+		CreatedAt:      session.CreatedAt,
+		GeneratedAt:    time.Now(),
+		Executions:     len(executions),
+		ExecutionsFile: sm.ExecutionsFile(&session),
+	}
+	if session.Exam != nil {
+		report.Archive = filepath.Join(sm.dataDir, "archives", session.ID+".tar.gz")
+	}
+
+	// Most recent failures are the most useful, so walk backwards
+	for i := len(executions) - 1; i >= 0; i-- {
+		e := &executions[i]
+		report.TotalRuntimeMs += e.Duration
+		report.TotalCPUTime += e.CPUTime
+
+		if executionPassed(e) {
+			report.Passed++
+			continue
+		}
+		report.Failed++
+		if len(report.NotableFailures) < maxNotableFailures {
+			report.NotableFailures = append(report.NotableFailures, ReportFailure{
+				ExecutionID: e.ID,
+				Time:        e.Time,
+				ExitCode:    e.ExitCode,
+				Stderr:      stderrExcerpt(e.Stderr),
+			})
+		}
+	}
+
+	return report, nil
+}
+
+// reportPath is where close reports are stored as artifacts
+func (sm *SessionManager) reportPath(sessionID string) string {
+	return filepath.Join(sm.dataDir, "reports", filepath.Base(sessionID)+".json")
+}
+
+// saveReport writes a report artifact
+func (sm *SessionManager) saveReport(report *SessionReport) error {
+	path := sm.reportPath(report.SessionID)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// reportOnClose returns a subscriber that generates a report for every
+// closed session and delivers it according to mode
+func reportOnClose(sm *SessionManager, mode string, notifier *WebhookNotifier) func(SessionEvent) {
+	return func(event SessionEvent) {
+		if event.Type != EventSessionClosed {
+			return
+		}
+
+		session, err := sm.GetSession(event.Session.ID)
+		if err != nil {
+			return
+		}
+		report, err := sm.BuildReport(*session)
+		if err != nil {
+			log.Printf("Warning: failed to build report for %s: %v", event.Session.ID, err)
+			return
+		}
+
+		if mode == ReportArtifact || mode == ReportBoth {
+			if err := sm.saveReport(report); err != nil {
+				log.Printf("Warning: failed to save report for %s: %v", event.Session.ID, err)
+			}
+		}
+		if (mode == ReportWebhook || mode == ReportBoth) && notifier != nil {
+			notifier.Notify(SessionEvent{
+				Type:    EventSessionReport,
+				Time:    report.GeneratedAt,
+				Session: event.Session,
+				Detail:  map[string]interface{}{"report": report},
+			})
+		}
+	}
+}
+
+// validateReportMode checks the --close-report value
+func validateReportMode(mode string) error {
+	switch mode {
+	case "", ReportArtifact:
+		return nil
+	case ReportWebhook, ReportBoth:
+		if len(webhookURLs) == 0 {
+			return fmt.Errorf("--close-report %s requires --webhook-url", mode)
+		}
+		return nil
+	}
+	return fmt.Errorf("invalid --close-report %q (want artifact, webhook or both)", mode)
+}
+
+// handleSessionReport serves GET /sessions/{id}/report: the stored close
+// report if there is one, otherwise a report generated now
+func handleSessionReport(w http.ResponseWriter, r *http.Request) {
+	sm := sessionsFor(r.Context())
+	session, err := sm.GetSession(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if data, err := os.ReadFile(sm.reportPath(session.ID)); err == nil {
+		w.Write(data)
+		return
+	}
+
+	report, err := sm.BuildReport(*session)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,512 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+)
+
+// ExecRequest describes a single execution within a session
+type ExecRequest struct {
+	Code  string
+	Stdin string
+
+	// StdinTemplate names a session or problem template used as stdin,
+	// rendered with StdinParams
+	StdinTemplate string
+	StdinParams   map[string]string
+
+	// Trace runs the code under a line tracer (Python only)
+	Trace      bool
+	TraceLimit int
+
+	// Format overrides --format-code for this execution when set
+	Format *bool
+
+	// ReplayOf links the execution to the one it re-runs
+	ReplayOf string
+
+	// ExpectedExitCode marks the execution passed only if it exits with this code
+	ExpectedExitCode *int
+
+	// Preview records a static analysis of the code with the execution
+	Preview bool
+
+	// CaptureEnv stores parts of a successful execution's stdout as session
+	// env vars, keyed by variable name (see captureRule for the specs)
+	CaptureEnv map[string]string
+
+	// Params are optional Judge0 limits and settings for the submission
+	Params SubmissionParams
+
+	// QueueTimeout fails the execution if Judge0 hasn't started it in time;
+	// Timeout bounds the whole execution (see WaitOptions)
+	QueueTimeout time.Duration
+	Timeout      time.Duration
+
+	// OnStart is called once the execution has been accepted
+	OnStart func(execID string)
+	// OnStatus is called with the Judge0 status after every poll
+	OnStatus func(Status)
+	// OnSlotWait is called when the execution has to wait for a slot under
+	// its language's concurrency cap, with the number of executions ahead
+	OnSlotWait func(ahead int)
+}
+
+// runExecution executes code in a session and records it in the session history.
+// It is shared by the HTTP, CLI and MCP paths so they enforce the same rules.
+func runExecution(ctx context.Context, sessionID string, req ExecRequest) (exec *Execution, err error) {
+	ctx, span := startSpan(ctx, "execution", spanKindInternal)
+	defer func() {
+		span.SetError(err)
+		span.Finish()
+	}()
+	span.SetAttr("session.id", sessionID)
+
+	session, err := sessionsFor(ctx).GetSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	span.SetAttr("session.language", session.Language)
+
+	if err := checkJudge0Credentials(ctx); err != nil {
+		return nil, err
+	}
+	if err := checkExecutionsOpen(time.Now()); err != nil {
+		return nil, err
+	}
+	req = session.State.Defaults.applyRequest(req)
+	if err := validateSubmissionParams(ctx, req.Params); err != nil {
+		return nil, err
+	}
+
+	// Get language ID
+	langID, err := GetLanguageID(session.Language)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Trace && !supportsTrace(session.Language) {
+		return nil, &InvalidSubmissionError{Param: "trace", Reason: "not supported for " + session.Language}
+	}
+
+	captures, err := parseCaptureEnv(req.CaptureEnv)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.StdinTemplate != "" {
+		if req.Stdin != "" {
+			return nil, fmt.Errorf("stdin and stdin_template are mutually exclusive")
+		}
+		if req.Stdin, err = resolveStdinTemplate(session, req.StdinTemplate, req.StdinParams); err != nil {
+			return nil, err
+		}
+	}
+
+	_, beginSpan := startSpan(ctx, "session.begin_execution", spanKindInternal)
+	execID, err := sessionsFor(ctx).BeginExecution(sessionID)
+	beginSpan.SetError(err)
+	beginSpan.Finish()
+	if err != nil {
+		return nil, err
+	}
+	defer sessionsFor(ctx).EndExecution(sessionID, execID)
+	span.SetAttr("execution.id", execID)
+
+	if req.OnStart != nil {
+		req.OnStart(execID)
+	}
+
+	// Vendor dependencies from workspace manifests before the first run
+	// and whenever the manifest changes
+	depsInstalled := false
+	if installDeps {
+		depsInstalled, err = sessionsFor(ctx).ensureDependencies(ctx, session, langID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Formatting failures (usually syntax errors) fall through so the
+	// backend reports them against the original code
+	formatted := false
+	if (req.Format == nil && formatCodeDefault) || (req.Format != nil && *req.Format) {
+		var ferr error
+		req.Code, formatted, ferr = formatCode(ctx, session.Language, req.Code)
+		if ferr != nil {
+			log.Printf("Warning: formatter failed for %s: %v", execID, ferr)
+		}
+	}
+
+	var preview *Preview
+	if req.Preview {
+		preview = analyzeCode(session.Language, req.Code)
+	}
+
+	workspace := sessionsFor(ctx).WorkspaceDir(sessionID)
+
+	// Workspaces with a project manifest are built as a whole
+	manifest, err := sessionsFor(ctx).LoadProjectManifest(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	var submission Judge0Submission
+	stateful := false
+	if manifest != nil {
+		if req.Trace {
+			return nil, &InvalidSubmissionError{Param: "trace", Reason: "not supported for projects"}
+		}
+		if langID == LanguageGo && hasVendorDir(workspace) {
+			withVendor := *manifest
+			withVendor.BuildFlags = append([]string{"-mod=vendor"}, manifest.BuildFlags...)
+			manifest = &withVendor
+		}
+		submission, err = sessionsFor(ctx).buildProjectSubmission(session, langID, manifest, req.Code, req.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to package project: %w", err)
+		}
+	} else {
+		code := req.Code
+		if req.Trace {
+			code = wrapPythonTrace(code, req.TraceLimit)
+		} else if harness := statefulHarnesses[langID]; session.Stateful && harness != nil {
+			state, err := sessionsFor(ctx).interpreterState(session)
+			if err != nil {
+				return nil, err
+			}
+			code = harness(code, state)
+			stateful = true
+		}
+
+		// Bash reports its exports and files back so the next execution
+		// sees them
+		if langID == LanguageBash {
+			code = bashPrelude(capturesBashExports(session, langID), true) + code
+		}
+
+		// Prepare code with environment variables
+		env, err := sessionsFor(ctx).executionEnv(session)
+		if err != nil {
+			return nil, err
+		}
+		fullCode := dependencyPrelude(langID, workspace) + prepareCodeWithEnv(code, env, session.Language, langID)
+
+		submission = NewSubmission(fullCode, langID, req.Stdin)
+		if session.LanguageID != 0 {
+			submission.LanguageID = session.LanguageID
+		}
+		if langID == LanguageGo && hasVendorDir(workspace) {
+			submission.CompilerOptions = "-mod=vendor"
+		}
+
+		// Bash writes its files back, so nothing else may change the
+		// workspace until this execution has been restored and saved
+		if langID == LanguageBash {
+			defer sessionsFor(ctx).lockWorkspace(sessionID)()
+		}
+
+		// Workspace files are unpacked next to the program by Judge0
+		files, err := sessionsFor(ctx).WorkspaceArchive(sessionID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to package workspace: %w", err)
+		}
+		submission.AdditionalFiles = files
+	}
+
+	// Older backends may lack the language; run under a configured fallback
+	var fallback *LanguageFallback
+	submission.LanguageID, fallback = resolveLanguageFallback(ctx, submission.LanguageID)
+	if fallback != nil {
+		span.SetAttr("language.fallback_id", fallback.UsedID)
+	}
+
+	session.State.Defaults.applySubmission(&submission)
+	req.Params.apply(&submission)
+
+	if session.Exam != nil {
+		// Exam sessions never get network access
+		disabled := false
+		submission.EnableNetwork = &disabled
+	}
+
+	// Wait for a slot under the language's concurrency cap; the wait
+	// counts against the execution's deadlines
+	release, waited, err := submissionLimiter.acquire(ctx, langID, req.QueueTimeout, req.OnSlotWait)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	span.SetAttr("queue.language_wait_ms", waited.Milliseconds())
+	wait := WaitOptions{QueueTimeout: req.QueueTimeout, Timeout: req.Timeout, OnStatus: req.OnStatus}
+	if wait.QueueTimeout > 0 {
+		wait.QueueTimeout = max(wait.QueueTimeout-waited, time.Millisecond)
+	}
+	if wait.Timeout > 0 {
+		wait.Timeout = max(wait.Timeout-waited, time.Millisecond)
+	}
+
+	// Execute
+	startTime := time.Now()
+	result, err := judge0Client.Submit(ctx, submission, wait)
+	if err != nil {
+		return nil, err
+	}
+	duration := time.Since(startTime).Seconds() * 1000
+
+	var exported map[string]string
+	var unexported []string
+	var workdir []byte
+	var workdirErr error
+	if manifest == nil && langID == LanguageBash {
+		if capturesBashExports(session, langID) {
+			result.Stdout, exported, unexported = extractExportedEnv(result.Stdout)
+		}
+		result.Stdout, workdir, workdirErr = extractWorkdir(result.Stdout)
+	}
+
+	var state string
+	var unkept []string
+	if stateful {
+		result.Stdout, state, unkept, stateful = extractInterpreterState(result.Stdout)
+	}
+
+	var trace []TraceStep
+	if req.Trace {
+		result.Stdout, trace = extractTrace(result.Stdout)
+	}
+
+	// Record execution
+	exec = &Execution{
+		ID:       execID,
+		Code:     req.Code,
+		Output:   result.Stdout,
+		Stderr:   result.Stderr,
+		ExitCode: result.ExitCode,
+		Status:   result.Status.Code,
+		Time:     startTime,
+
+		StatusDescription: result.Status.Description,
+		CompileOutput:     result.CompileOutput,
+
+		Duration: duration,
+		CPUTime:  parseJudge0Time(result.Time),
+		Memory:   result.Memory,
+		Trace:    trace,
+		ReplayOf: req.ReplayOf,
+		Stdin:    req.Stdin,
+		Fallback: fallback,
+
+		Warnings:  limitWarnings(submission, parseJudge0Time(result.Time), result.Memory),
+		Formatted: formatted,
+		Preview:   preview,
+
+		DependenciesInstalled: depsInstalled,
+	}
+
+	if manifest != nil {
+		exec.Project = true
+		exec.Phase = PhaseRun
+	}
+	if result.Status.ID == statusCompilationError {
+		exec.Phase = PhaseCompile
+		if exec.ExitCode == 0 {
+			exec.ExitCode = compileErrorExitCode
+		}
+	}
+
+	if req.ExpectedExitCode != nil {
+		passed := exec.ExitCode == *req.ExpectedExitCode
+		exec.ExpectedExitCode = req.ExpectedExitCode
+		exec.Passed = &passed
+	}
+
+	// State is kept even when the code fails, as in a REPL
+	if stateful {
+		exec.StateErrors = stateErrors(state, unkept)
+		if len(state) <= maxInterpreterState {
+			if err := sessionsFor(ctx).SetInterpreterState(sessionID, state); err != nil {
+				log.Printf("Warning: failed to save interpreter state for %s: %v", execID, err)
+			}
+		}
+	}
+
+	// Files are kept even when the code fails, as in a shell
+	if workdirErr != nil {
+		exec.StateErrors = append(exec.StateErrors, workdirErr.Error())
+	} else if workdir != nil {
+		if err := sessionsFor(ctx).restoreWorkdir(sessionID, workdir); err != nil {
+			exec.StateErrors = append(exec.StateErrors, fmt.Sprintf("workspace not kept: %v", err))
+		}
+	}
+
+	// Exports apply whether or not the code succeeded, as in a shell
+	if len(exported) > 0 || len(unexported) > 0 {
+		removed, err := sessionsFor(ctx).mergeExportedEnv(sessionID, exported, unexported)
+		if err != nil {
+			log.Printf("Warning: failed to save exported env for %s: %v", execID, err)
+		} else {
+			if len(exported) > 0 {
+				exec.ExportedEnv = exported
+			}
+			exec.UnsetEnv = removed
+		}
+	}
+
+	if len(captures) > 0 {
+		if executionPassed(exec) {
+			exec.CapturedEnv, exec.CaptureErrors = sessionsFor(ctx).captureEnv(sessionID, captures, exec.Output)
+		} else {
+			exec.CaptureErrors = []string{"capture_env skipped: execution failed"}
+		}
+	}
+
+	// Secrets and credential patterns never reach the history, log or
+	// response; the raw transcript below keeps the exact bytes, and the
+	// unredacted code is kept beside it for reruns
+	sessionsFor(ctx).redactor(session).redactExecution(exec)
+	if exec.Code != req.Code {
+		if err := sessionsFor(ctx).SaveRawCode(sessionID, execID, []byte(req.Code)); err != nil {
+			log.Printf("Warning: failed to save raw code: %v", err)
+		}
+	}
+
+	_, addSpan := startSpan(ctx, "session.add_execution", spanKindInternal)
+	if err := sessionsFor(ctx).AddExecution(sessionID, *exec); err != nil {
+		addSpan.SetError(err)
+		log.Printf("Warning: failed to record execution: %v", err)
+	}
+	addSpan.Finish()
+
+	if err := sessionsFor(ctx).SaveRawTranscript(sessionID, execID, []byte(req.Stdin), []byte(result.Stdout)); err != nil {
+		log.Printf("Warning: failed to save raw transcript: %v", err)
+	}
+
+	detail := map[string]interface{}{
+		"execution_id": exec.ID,
+		"code_sha256":  sha256Hex([]byte(req.Code)),
+		"stdin_bytes":  len(req.Stdin),
+		"exit_code":    exec.ExitCode,
+		"replay_of":    exec.ReplayOf,
+	}
+	if preview != nil {
+		detail["preview_risk"] = preview.Risk
+	}
+	// Record whose Judge0 credentials ran the code, never the token itself
+	if creds := judge0CredentialsFromContext(ctx); creds != nil && creds.Token != "" {
+		detail["judge0_auth"] = "passthrough"
+		if creds.User != "" {
+			detail["judge0_user"] = creds.User
+		}
+	}
+	audit(ctx, "session.execute", sessionID, detail)
+
+	if sessionBudgets.Enabled() {
+		sessionsFor(ctx).EnforceBudget(sessionID, sessionBudgets)
+	}
+
+	return exec, nil
+}
+
+// errorCode returns a machine-readable code for execution errors that
+// clients commonly branch on, or "" for other errors
+func errorCode(err error) string {
+	var deadline *DeadlineError
+	if errors.As(err, &deadline) {
+		return deadline.Kind
+	}
+	var backendAuth *BackendAuthError
+	var limited *RateLimitError
+	var closed *ExecutionsClosedError
+	var unavailable *BackendsUnavailableError
+	switch {
+	case errors.As(err, &closed):
+		return closed.Code
+	case errors.As(err, &unavailable):
+		return "judge0_unavailable"
+	case errors.As(err, &limited):
+		return "judge0_rate_limited"
+	case errors.Is(err, errJudge0CredentialsRequired):
+		return "judge0_credentials_required"
+	case errors.As(err, &backendAuth):
+		return "judge0_auth_rejected"
+	}
+	return ""
+}
+
+// parseJudge0Time converts Judge0's time string (seconds) to a float
+func parseJudge0Time(value string) float64 {
+	t, _ := strconv.ParseFloat(value, 64)
+	return t
+}
+
+// executionResponse builds the API representation of an execution, with
+// the status summary in locale
+func executionResponse(exec *Execution, locale string) map[string]interface{} {
+	resp := map[string]interface{}{
+		"id":        exec.ID,
+		"stdout":    exec.Output,
+		"stderr":    exec.Stderr,
+		"exit_code": exec.ExitCode,
+		"time_ms":   exec.Duration,
+		"trace":     exec.Trace,
+	}
+	if exec.Status != "" {
+		resp["status"] = exec.Status
+		resp["status_summary"] = statusSummary(exec.Status, locale)
+	}
+	if exec.StatusDescription != "" {
+		resp["status_description"] = exec.StatusDescription
+	}
+	if exec.CPUTime > 0 {
+		resp["cpu_time"] = exec.CPUTime
+	}
+	if exec.Memory > 0 {
+		resp["memory_kb"] = exec.Memory
+	}
+	if exec.CompileOutput != "" {
+		resp["compile_output"] = exec.CompileOutput
+	}
+	if exec.Passed != nil {
+		resp["expected_exit_code"] = *exec.ExpectedExitCode
+		resp["passed"] = *exec.Passed
+	}
+	if len(exec.Warnings) > 0 {
+		resp["warnings"] = exec.Warnings
+	}
+	if exec.Fallback != nil {
+		resp["language_fallback"] = exec.Fallback
+	}
+	if exec.DependenciesInstalled {
+		resp["dependencies_installed"] = true
+	}
+	if len(exec.ExportedEnv) > 0 {
+		resp["exported_env"] = exec.ExportedEnv
+	}
+	if len(exec.UnsetEnv) > 0 {
+		resp["unset_env"] = exec.UnsetEnv
+	}
+	if len(exec.StateErrors) > 0 {
+		resp["state_errors"] = exec.StateErrors
+	}
+	if len(exec.CapturedEnv) > 0 {
+		resp["captured_env"] = exec.CapturedEnv
+	}
+	if len(exec.CaptureErrors) > 0 {
+		resp["capture_errors"] = exec.CaptureErrors
+	}
+	if exec.Preview != nil {
+		resp["preview"] = exec.Preview
+	}
+	if exec.Phase != "" {
+		resp["phase"] = exec.Phase
+	}
+	if exec.Phase == PhaseCompile {
+		resp["compile_error"] = true
+	}
+	return resp
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// secretKeyEnv names the secret key file and secretKeyCommandEnv the key
+// command when the flags aren't set
+const (
+	secretKeyEnv        = "J0_SECRET_KEY"
+	secretKeyCommandEnv = "J0_SECRET_KEY_COMMAND"
+)
+
+// secretPrefix versions encrypted values
+const secretPrefix = "v1:"
+
+var (
+	// secretKeyFile is set from --secret-key
+	secretKeyFile string
+	// secretKeyCommand is set from --secret-key-command
+	secretKeyCommand string
+	// secretKey encrypts secret env vars; nil disables them
+	secretKey []byte
+)
+
+// loadSecretKey reads a hex-encoded AES-256 key from a file, as written by
+// j0 env keygen
+func loadSecretKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secret key: %w", err)
+	}
+	return parseSecretKey(path, data)
+}
+
+// loadSecretKeyFromCommand runs command with sh and reads the key from its
+// stdout, so the key can be fetched from a KMS or secret manager, e.g.
+// "aws kms decrypt --ciphertext-blob fileb://j0.key.enc --query Plaintext
+// --output text | base64 -d | xxd -p -c 64"
+func loadSecretKeyFromCommand(ctx context.Context, command string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	c := exec.CommandContext(ctx, "sh", "-c", command)
+	c.Stderr = &stderr
+	out, err := c.Output()
+	if err != nil {
+		return nil, fmt.Errorf("secret key command failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return parseSecretKey("from --secret-key-command", out)
+}
+
+func parseSecretKey(source string, data []byte) ([]byte, error) {
+	key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("invalid secret key %s: want a hex-encoded 32-byte AES-256 key", source)
+	}
+	return key, nil
+}
+
+// configureSecretKey loads the key from --secret-key or
+// --secret-key-command; with neither, secret env vars are disabled
+func configureSecretKey(ctx context.Context) error {
+	var err error
+	switch {
+	case secretKeyFile != "" && secretKeyCommand != "":
+		return fmt.Errorf("--secret-key and --secret-key-command are mutually exclusive")
+	case secretKeyFile != "":
+		secretKey, err = loadSecretKey(secretKeyFile)
+	case secretKeyCommand != "":
+		secretKey, err = loadSecretKeyFromCommand(ctx, secretKeyCommand)
+	}
+	return err
+}
+
+// errNoSecretKey is returned when secrets are used without a key
+var errNoSecretKey = fmt.Errorf("secret env vars need --secret-key or --secret-key-command (or %s/%s)", secretKeyEnv, secretKeyCommandEnv)
+
+// secretAAD binds a ciphertext to its session and variable, so it can't be
+// copied to another one
+func secretAAD(sessionID, name string) []byte {
+	return []byte("j0 secret env\x00" + sessionID + "\x00" + name)
+}
+
+// sealingKey returns the key for the manager's secrets: the tenant's own
+// key, or the one from --secret-key
+func (sm *SessionManager) sealingKey() []byte {
+	if sm.secretKey != nil {
+		return sm.secretKey
+	}
+	return secretKey
+}
+
+func secretAEAD(key []byte) (cipher.AEAD, error) {
+	if key == nil {
+		return nil, errNoSecretKey
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptSecret seals value with AES-256-GCM
+func encryptSecret(key []byte, sessionID, name, value string) (string, error) {
+	aead, err := secretAEAD(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(value), secretAAD(sessionID, name))
+	return secretPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decryptSecret opens a value sealed by encryptSecret
+func decryptSecret(key []byte, sessionID, name, sealed string) (string, error) {
+	aead, err := secretAEAD(key)
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(sealed, secretPrefix))
+	if err != nil || !strings.HasPrefix(sealed, secretPrefix) || len(data) < aead.NonceSize() {
+		return "", fmt.Errorf("secret %s is corrupt", name)
+	}
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, ciphertext, secretAAD(sessionID, name))
+	if err != nil {
+		return "", fmt.Errorf("secret %s can't be decrypted with the configured key", name)
+	}
+	return string(plain), nil
+}
+
+// executionEnv returns the variables executions see: the plain ones plus
+// the decrypted secrets
+func (sm *SessionManager) executionEnv(s *Session) (map[string]string, error) {
+	if len(s.State.Secrets) == 0 {
+		return s.State.Env, nil
+	}
+	env := make(map[string]string, len(s.State.Env)+len(s.State.Secrets))
+	for k, v := range s.State.Env {
+		env[k] = v
+	}
+	for k, sealed := range s.State.Secrets {
+		v, err := decryptSecret(sm.sealingKey(), s.ID, k, sealed)
+		if err != nil {
+			return nil, err
+		}
+		env[k] = v
+	}
+	return env, nil
+}
+
+// SetSecretEnv stores an environment variable encrypted; it replaces a
+// plain variable of the same name
+func (sm *SessionManager) SetSecretEnv(sessionID, key, value string) error {
+	if err := checkEnvName(key); err != nil {
+		return err
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	session, ok := sm.sessions[sessionID]
+	if !ok {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+	if session.Exam != nil {
+		return fmt.Errorf("environment changes are not allowed in exam sessions")
+	}
+
+	sealed, err := encryptSecret(sm.sealingKey(), sessionID, key, value)
+	if err != nil {
+		return err
+	}
+	if session.State.Secrets == nil {
+		session.State.Secrets = map[string]string{}
+	}
+	session.State.Secrets[key] = sealed
+	delete(session.State.Env, key)
+	session.UpdatedAt = time.Now()
+	return sm.saveSession(session)
+}
+
+var envKeygenCmd = &cobra.Command{
+	Use:   "keygen <key-file>",
+	Short: "Generate a key for encrypting secret env vars",
+	Long: `Write a new AES-256 key to <key-file>. Start j0 with --secret-key
+<key-file> (or ` + secretKeyEnv + `) to store env vars set with --secret
+encrypted. Keep the key: secrets can't be recovered without it.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(args[0], os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			return fmt.Errorf("failed to create key file: %w", err)
+		}
+		defer f.Close()
+		_, err = fmt.Fprintln(f, hex.EncodeToString(key))
+		return err
+	},
+}
+
+func init() {
+	envCmd.AddCommand(envKeygenCmd)
+}
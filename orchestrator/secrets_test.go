@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestDecryptSecret(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	otherKey := bytes.Repeat([]byte{2}, 32)
+
+	sealed, err := encryptSecret(key, "sess-1", "API_TOKEN", "hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	flipped := func() string {
+		data, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(sealed, secretPrefix))
+		data[len(data)-1] ^= 1
+		return secretPrefix + base64.StdEncoding.EncodeToString(data)
+	}()
+
+	tests := []struct {
+		name      string
+		key       []byte
+		sessionID string
+		varName   string
+		sealed    string
+		want      string // error substring, "" for success
+	}{
+		{name: "round trip", key: key, sessionID: "sess-1", varName: "API_TOKEN", sealed: sealed},
+		{
+			name: "copied to another session", key: key, sessionID: "sess-2", varName: "API_TOKEN", sealed: sealed,
+			want: "can't be decrypted",
+		},
+		{
+			name: "copied to another variable", key: key, sessionID: "sess-1", varName: "OTHER", sealed: sealed,
+			want: "can't be decrypted",
+		},
+		{
+			name: "another tenant's key", key: otherKey, sessionID: "sess-1", varName: "API_TOKEN", sealed: sealed,
+			want: "can't be decrypted",
+		},
+		{
+			name: "tampered ciphertext", key: key, sessionID: "sess-1", varName: "API_TOKEN", sealed: flipped,
+			want: "can't be decrypted",
+		},
+		{
+			name: "missing version prefix", key: key, sessionID: "sess-1", varName: "API_TOKEN", sealed: strings.TrimPrefix(sealed, secretPrefix),
+			want: "is corrupt",
+		},
+		{
+			name: "truncated", key: key, sessionID: "sess-1", varName: "API_TOKEN", sealed: secretPrefix + "AAAA",
+			want: "is corrupt",
+		},
+		{
+			name: "no key", sessionID: "sess-1", varName: "API_TOKEN", sealed: sealed,
+			want: "secret env vars need --secret-key",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := decryptSecret(tt.key, tt.sessionID, tt.varName, tt.sealed)
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("decryptSecret: %v", err)
+				}
+				if value != "hunter2" {
+					t.Fatalf("value = %q, want hunter2", value)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("decryptSecret error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncryptSecretUsesFreshNonces(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	a, err := encryptSecret(key, "sess-1", "API_TOKEN", "hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := encryptSecret(key, "sess-1", "API_TOKEN", "hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == b {
+		t.Fatal("sealing the same value twice gave the same ciphertext")
+	}
+}
@@ -1,12 +1,17 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
+	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 )
@@ -20,13 +25,71 @@ type Session struct {
 	UpdatedAt time.Time    `json:"updated_at"`
 	State     SessionState `json:"state"`
 	LogFile   string       `json:"log_file"`
+	LogURL    string       `json:"log_url,omitempty"`
 	Status    string       `json:"status"` // "active", "paused", "closed"
+	Strict    bool         `json:"strict,omitempty"`
+	// Stateful sessions carry interpreter state between executions (see
+	// interpstate.go)
+	Stateful bool `json:"stateful,omitempty"`
+	// Owner is the authenticated identity that created the session
+	Owner string `json:"owner,omitempty"`
+	// Version and LanguageID are set when the session is pinned to a
+	// specific backend version of its language
+	Version    string `json:"version,omitempty"`
+	LanguageID int    `json:"language_id,omitempty"`
+
+	// LastActivity is bumped by executions and heartbeats; idle reaping uses it
+	LastActivity time.Time `json:"last_activity"`
+
+	// Metadata holds integration-specific attributes (e.g. LTI context)
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// StdinTemplates are named stdin inputs selectable in execute requests
+	StdinTemplates map[string]string `json:"stdin_templates,omitempty"`
+	// Exam is set for time-boxed exam sessions
+	Exam *ExamSettings `json:"exam,omitempty"`
+	// Usage tracks resources consumed, checked against orchestrator budgets
+	Usage SessionUsage `json:"usage"`
+	// Init is the session's init script and its cached output (see motd.go)
+	Init *SessionInit `json:"init,omitempty"`
+	// Inventory caches the runtime's installed packages (see inventory.go)
+	Inventory *EnvironmentInventory `json:"inventory,omitempty"`
+}
+
+// SessionOptions holds optional settings applied at session creation
+type SessionOptions struct {
+	// Strict rejects executions while another one is in flight
+	Strict bool
+	// Stateful carries interpreter state between executions
+	Stateful bool
+	// Metadata is copied onto the session
+	Metadata map[string]string
+	// ExamDuration creates a time-boxed exam session when non-zero
+	ExamDuration time.Duration
+	// Owner records the authenticated creator
+	Owner string
+	// Language pins the session to a backend language version (see resolveLanguageVersion)
+	Language *LanguageInfo
 }
 
 // SessionState holds persistent state between executions
 type SessionState struct {
-	Env     map[string]string `json:"env"`
-	History []Execution       `json:"history"`
+	Env map[string]string `json:"env"`
+	// Secrets are env vars stored encrypted (see secrets.go); a name is in
+	// Env or Secrets, never both
+	Secrets map[string]string `json:"secrets,omitempty"`
+	// History is loaded from the append-only JSONL sidecar and is not
+	// written to the session JSON
+	History []Execution `json:"history"`
+
+	// Archived counts executions pruned from History; they remain in the JSONL sidecar
+	Archived int `json:"archived,omitempty"`
+
+	// Defaults are submission settings every execution inherits
+	Defaults *SessionDefaults `json:"defaults,omitempty"`
+
+	// Interpreter is the encoded interpreter state of stateful sessions,
+	// sealed like Secrets when a secret key is configured
+	Interpreter string `json:"interpreter,omitempty"`
 }
 
 // Execution represents a single code execution within a session
@@ -38,13 +101,140 @@ type Execution struct {
 	ExitCode int       `json:"exit_code"`
 	Time     time.Time `json:"time"`
 	Duration float64   `json:"duration_ms"`
+	CPUTime  float64   `json:"cpu_time,omitempty"`  // seconds, as reported by Judge0
+	Memory   int       `json:"memory_kb,omitempty"` // peak memory in KB
+
+	// Status is the stable code of the final Judge0 status and
+	// StatusDescription Judge0's own description of it, e.g.
+	// "Runtime Error (SIGSEGV)"
+	Status            string `json:"status,omitempty"`
+	StatusDescription string `json:"status_description,omitempty"`
+
+	// Trace holds the line-by-line trace of a trace-mode execution
+	Trace []TraceStep `json:"trace,omitempty"`
+	// Warnings flag resources used close to their limits
+	Warnings []LimitWarning `json:"warnings,omitempty"`
+
+	// Project is set when the workspace was built from its project manifest.
+	// Phase is "compile" when the code failed to compile, with a non-zero
+	// ExitCode, and "run" for project builds that compiled.
+	Project bool   `json:"project,omitempty"`
+	Phase   string `json:"phase,omitempty"`
+	// CompileOutput is the compiler's output for compiled languages
+	CompileOutput string `json:"compile_output,omitempty"`
+
+	// DependenciesInstalled is set when this execution (re)installed the
+	// workspace's dependency manifest first
+	DependenciesInstalled bool `json:"dependencies_installed,omitempty"`
+
+	// ExportedEnv holds variables a bash execution exported or changed and
+	// UnsetEnv the session variables it unexported; both are merged into
+	// the session env
+	ExportedEnv map[string]string `json:"exported_env,omitempty"`
+	UnsetEnv    []string          `json:"unset_env,omitempty"`
+
+	// StateErrors explains state (interpreter globals or workspace files)
+	// the execution didn't carry over to the next one
+	StateErrors []string `json:"state_errors,omitempty"`
+
+	// CapturedEnv holds session env vars set from this execution's output;
+	// CaptureErrors explains captures that didn't apply
+	CapturedEnv   map[string]string `json:"captured_env,omitempty"`
+	CaptureErrors []string          `json:"capture_errors,omitempty"`
+
+	// Formatted is set when Code was normalized by a formatter before running
+	Formatted bool `json:"formatted,omitempty"`
+	// Preview is the static analysis requested with the execution
+	Preview *Preview `json:"preview,omitempty"`
+	// ReplayOf is the ID of the execution this one re-ran
+	ReplayOf string `json:"replay_of,omitempty"`
+	// Fallback is set when the session's language was missing from the
+	// backend and a configured fallback ran instead
+	Fallback *LanguageFallback `json:"language_fallback,omitempty"`
+	// Stdin is echoed into the session log; like replay, it isn't persisted
+	// beyond the raw transcript
+	Stdin string `json:"-"`
+
+	// ExpectedExitCode and Passed are set when the caller asserted an exit code
+	ExpectedExitCode *int  `json:"expected_exit_code,omitempty"`
+	Passed           *bool `json:"passed,omitempty"`
+}
+
+// executionSummary is the exit line of an execution's log entry
+func executionSummary(exec Execution) string {
+	summary := fmt.Sprintf("exit: %d", exec.ExitCode)
+	if exec.StatusDescription != "" {
+		summary += ", status: " + exec.StatusDescription
+	}
+	summary += fmt.Sprintf(", duration: %.2fms", exec.Duration)
+	if exec.CPUTime > 0 {
+		summary += fmt.Sprintf(", cpu: %.3fs", exec.CPUTime)
+	}
+	if exec.Memory > 0 {
+		summary += fmt.Sprintf(", memory: %dKB", exec.Memory)
+	}
+	return summary
+}
+
+// InFlightError is returned when a strict session already has a pending execution
+type InFlightError struct {
+	SessionID   string
+	ExecutionID string
+}
+
+func (e *InFlightError) Error() string {
+	return fmt.Sprintf("session %s already has an execution in flight: %s", e.SessionID, e.ExecutionID)
+}
+
+// Session lifecycle event types
+const (
+	EventSessionCreated = "session.created"
+	EventSessionClosed  = "session.closed"
+	EventBudgetExceeded = "session.budget_exceeded"
+)
+
+// SessionEvent describes a session lifecycle change delivered to subscribers
+type SessionEvent struct {
+	Type    string                 `json:"event"`
+	Time    time.Time              `json:"time"`
+	Session SessionSummary         `json:"session"`
+	Detail  map[string]interface{} `json:"detail,omitempty"`
+}
+
+// SessionSummary identifies a session in events sent to external systems;
+// env vars, code and output are never included
+type SessionSummary struct {
+	ID        string    `json:"id"`
+	Language  string    `json:"language"`
+	Status    string    `json:"status"`
+	Owner     string    `json:"owner,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 // SessionManager handles session CRUD operations
 type SessionManager struct {
-	sessions map[string]*Session
-	dataDir  string
-	mu       sync.RWMutex
+	sessions    map[string]*Session
+	inflight    map[string][]string // session ID -> pending execution IDs
+	subscribers []func(SessionEvent)
+	events      []SessionEvent // emitted, awaiting unlockAndDispatch
+	logSink     LogSink
+	dataDir     string
+	mu          sync.RWMutex
+	// secretKey encrypts the sessions' secret env vars; nil uses
+	// --secret-key
+	secretKey []byte
+	// admit, when set, may refuse a new session given how many sessions
+	// and active sessions the manager holds; it runs under mu
+	admit func(sessions, active int) error
+	// workspaceLocks serialize changes to each session's workspace (see
+	// lockWorkspace)
+	workspaceLocks map[string]*sync.Mutex
+
+	// Strict applies strict sequential execution to every session
+	Strict bool
+	// maxHistory caps executions kept in memory (0 keeps everything)
+	maxHistory int
 }
 
 // NewSessionManager creates a new session manager
@@ -60,6 +250,8 @@ func NewSessionManager(dataDir string) (*SessionManager, error) {
 
 	sm := &SessionManager{
 		sessions: make(map[string]*Session),
+		inflight: make(map[string][]string),
+		logSink:  FileLogSink{},
 		dataDir:  dataDir,
 	}
 
@@ -71,6 +263,87 @@ func NewSessionManager(dataDir string) (*SessionManager, error) {
 	return sm, nil
 }
 
+// SetLogSink replaces the log storage backend and restores any local logs
+// missing from a previous run (e.g. after a container restart)
+func (sm *SessionManager) SetLogSink(sink LogSink) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	sm.logSink = sink
+	for _, session := range sm.sessions {
+		if err := sink.Restore(session); err != nil {
+			log.Printf("Warning: failed to restore log for %s: %v", session.ID, err)
+		}
+	}
+}
+
+// SetMaxHistory caps the executions kept in memory for each session.
+// Older executions are dropped from history but stay in the JSONL sidecar.
+func (sm *SessionManager) SetMaxHistory(n int) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	sm.maxHistory = n
+	for _, session := range sm.sessions {
+		sm.pruneHistory(session)
+	}
+}
+
+// pruneHistory trims a session's history to maxHistory entries and reports
+// whether anything was removed. Callers must hold sm.mu.
+func (sm *SessionManager) pruneHistory(session *Session) bool {
+	excess := len(session.State.History) - sm.maxHistory
+	if sm.maxHistory <= 0 || excess <= 0 {
+		return false
+	}
+
+	// Copy so the pruned executions can be garbage collected
+	kept := make([]Execution, sm.maxHistory)
+	copy(kept, session.State.History[excess:])
+	session.State.History = kept
+	session.State.Archived += excess
+	return true
+}
+
+// Subscribe registers a function called for every session lifecycle event.
+// Subscribers run after the manager is unlocked and may call back into it.
+func (sm *SessionManager) Subscribe(fn func(SessionEvent)) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.subscribers = append(sm.subscribers, fn)
+}
+
+// emit queues an event for the subscribers. Callers hold sm.mu and
+// release it with unlockAndDispatch.
+func (sm *SessionManager) emit(eventType string, session *Session, detail map[string]interface{}) {
+	sm.events = append(sm.events, SessionEvent{
+		Type: eventType,
+		Time: time.Now(),
+		Session: SessionSummary{
+			ID:        session.ID,
+			Language:  session.Language,
+			Status:    session.Status,
+			Owner:     session.Owner,
+			CreatedAt: session.CreatedAt,
+			UpdatedAt: session.UpdatedAt,
+		},
+		Detail: detail,
+	})
+}
+
+// unlockAndDispatch releases sm.mu and delivers the queued events
+func (sm *SessionManager) unlockAndDispatch() {
+	events, subscribers := sm.events, sm.subscribers
+	sm.events = nil
+	sm.mu.Unlock()
+
+	for _, event := range events {
+		for _, fn := range subscribers {
+			fn(event)
+		}
+	}
+}
+
 // generateID creates a random session ID
 func generateID(prefix string) string {
 	bytes := make([]byte, 4)
@@ -79,31 +352,58 @@ func generateID(prefix string) string {
 }
 
 // CreateSession creates a new session
-func (sm *SessionManager) CreateSession(language, name string) (*Session, error) {
+func (sm *SessionManager) CreateSession(language, name string, opts SessionOptions) (*Session, error) {
 	sm.mu.Lock()
-	defer sm.mu.Unlock()
+	defer sm.unlockAndDispatch()
+
+	if sm.admit != nil {
+		active := 0
+		for _, s := range sm.sessions {
+			if s.Status == "active" {
+				active++
+			}
+		}
+		if err := sm.admit(len(sm.sessions), active); err != nil {
+			return nil, err
+		}
+	}
 
 	id := generateID("sess")
 	now := time.Now()
 
 	session := &Session{
-		ID:        id,
-		Name:      name,
-		Language:  language,
-		CreatedAt: now,
-		UpdatedAt: now,
+		ID:           id,
+		Name:         name,
+		Language:     language,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		LastActivity: now,
 		State: SessionState{
 			Env:     make(map[string]string),
 			History: []Execution{},
 		},
-		LogFile: filepath.Join(sm.dataDir, "logs", id+".log"),
-		Status:  "active",
+		LogFile:  filepath.Join(sm.dataDir, "logs", id+".log"),
+		Status:   "active",
+		Strict:   opts.Strict,
+		Stateful: opts.Stateful,
+		Metadata: opts.Metadata,
+		Owner:    opts.Owner,
+	}
+
+	if opts.Language != nil {
+		session.Version = opts.Language.Version
+		session.LanguageID = opts.Language.ID
+	}
+
+	if opts.ExamDuration > 0 {
+		session.Exam = &ExamSettings{EndsAt: now.Add(opts.ExamDuration)}
 	}
 
 	// Create log file
-	if err := os.WriteFile(session.LogFile, []byte{}, 0644); err != nil {
+	if err := sm.logSink.Create(session); err != nil {
 		return nil, fmt.Errorf("failed to create log file: %w", err)
 	}
+	session.LogURL = sm.logSink.Location(session)
 
 	sm.sessions[id] = session
 
@@ -112,6 +412,7 @@ func (sm *SessionManager) CreateSession(language, name string) (*Session, error)
 		return nil, fmt.Errorf("failed to save session: %w", err)
 	}
 
+	sm.emit(EventSessionCreated, session, nil)
 	return session, nil
 }
 
@@ -139,6 +440,173 @@ func (sm *SessionManager) ListSessions() []*Session {
 	return sessions
 }
 
+// FindSession returns the first session matching the predicate, or nil
+func (sm *SessionManager) FindSession(match func(*Session) bool) *Session {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	for _, s := range sm.sessions {
+		if match(s) {
+			return s
+		}
+	}
+	return nil
+}
+
+// FindExecution locates an execution by ID across all sessions
+func (sm *SessionManager) FindExecution(execID string) (*Session, *Execution, error) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	for _, s := range sm.sessions {
+		for i := range s.State.History {
+			if s.State.History[i].ID == execID {
+				exec := s.State.History[i]
+				return s, &exec, nil
+			}
+		}
+	}
+
+	// Fall back to executions pruned from history
+	for _, s := range sm.sessions {
+		if s.State.Archived == 0 {
+			continue
+		}
+		if exec := findJSONLExecution(sm.ExecutionsFile(s), execID); exec != nil {
+			return s, exec, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("execution not found: %s", execID)
+}
+
+// GetExecution returns an execution of a session, including pruned ones
+func (sm *SessionManager) GetExecution(sessionID, execID string) (*Execution, error) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	session, ok := sm.sessions[sessionID]
+	if !ok {
+		return nil, fmt.Errorf("session not found: %s", sessionID)
+	}
+
+	for i := range session.State.History {
+		if session.State.History[i].ID == execID {
+			exec := session.State.History[i]
+			return &exec, nil
+		}
+	}
+
+	if exec := findJSONLExecution(sm.ExecutionsFile(session), execID); exec != nil {
+		return exec, nil
+	}
+	return nil, fmt.Errorf("execution not found: %s", execID)
+}
+
+// ListExecutions returns a copy of a session's in-memory history, safe to
+// use while executions are added and pruned
+func (sm *SessionManager) ListExecutions(sessionID string) ([]Execution, error) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	session, ok := sm.sessions[sessionID]
+	if !ok {
+		return nil, fmt.Errorf("session not found: %s", sessionID)
+	}
+	history := make([]Execution, len(session.State.History))
+	copy(history, session.State.History)
+	return history, nil
+}
+
+// OpenExecutions opens a session's JSONL sidecar for reading. Only the
+// lines already written are read, so an execution appended meanwhile
+// can't show up half written; a missing sidecar reads as empty.
+func (sm *SessionManager) OpenExecutions(sessionID string) (io.ReadCloser, error) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	session, ok := sm.sessions[sessionID]
+	if !ok {
+		return nil, fmt.Errorf("session not found: %s", sessionID)
+	}
+	f, err := os.Open(sm.ExecutionsFile(session))
+	if os.IsNotExist(err) {
+		return io.NopCloser(strings.NewReader("")), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(f, info.Size()), f}, nil
+}
+
+// BeginExecution registers a pending execution and returns its ID.
+// Strict sessions reject the call while another execution is in flight.
+func (sm *SessionManager) BeginExecution(sessionID string) (string, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	session, ok := sm.sessions[sessionID]
+	if !ok {
+		return "", fmt.Errorf("session not found: %s", sessionID)
+	}
+
+	if session.Status != "active" {
+		return "", fmt.Errorf("session is not active: %s", session.Status)
+	}
+
+	if session.Exam != nil && time.Now().After(session.Exam.EndsAt) {
+		return "", fmt.Errorf("exam ended at %s", session.Exam.EndsAt.Format(time.RFC3339))
+	}
+
+	pending := sm.inflight[sessionID]
+	if (session.Strict || sm.Strict) && len(pending) > 0 {
+		return "", &InFlightError{SessionID: sessionID, ExecutionID: pending[0]}
+	}
+
+	id := generateID("exec")
+	sm.inflight[sessionID] = append(pending, id)
+	return id, nil
+}
+
+// EndExecution removes a pending execution registered by BeginExecution
+func (sm *SessionManager) EndExecution(sessionID, execID string) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	pending := sm.inflight[sessionID]
+	for i, id := range pending {
+		if id == execID {
+			pending = append(pending[:i], pending[i+1:]...)
+			break
+		}
+	}
+
+	if len(pending) == 0 {
+		delete(sm.inflight, sessionID)
+	} else {
+		sm.inflight[sessionID] = pending
+	}
+}
+
+// InFlightCount returns the number of executions currently running
+func (sm *SessionManager) InFlightCount() int {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	count := 0
+	for _, pending := range sm.inflight {
+		count += len(pending)
+	}
+	return count
+}
+
 // AddExecution records an execution in the session
 func (sm *SessionManager) AddExecution(sessionID string, exec Execution) error {
 	sm.mu.Lock()
@@ -149,29 +617,56 @@ func (sm *SessionManager) AddExecution(sessionID string, exec Execution) error {
 		return fmt.Errorf("session not found: %s", sessionID)
 	}
 
-	exec.ID = generateID("exec")
+	if exec.ID == "" {
+		exec.ID = generateID("exec")
+	}
 	session.State.History = append(session.State.History, exec)
+	session.Usage.MemorySeconds += float64(exec.Memory) / 1024 * exec.CPUTime
+	sm.pruneHistory(session)
+	if exec.DependenciesInstalled {
+		sm.invalidateInventory(session)
+	}
+
+	if session.Exam != nil {
+		if err := sm.recordExamExecution(session, exec); err != nil {
+			log.Printf("Warning: failed to write exam ledger for %s: %v", session.ID, err)
+		}
+	}
+
 	session.UpdatedAt = time.Now()
+	session.LastActivity = session.UpdatedAt
 
 	// Append to log file
-	logEntry := fmt.Sprintf("[%s] $ %s\n%s\n", exec.Time.Format(time.RFC3339), exec.Code, exec.Output)
+	logEntry := fmt.Sprintf("[%s] $ %s\n%s\n", exec.Time.Format(time.RFC3339), exec.Code, logOutput(exec))
+	if exec.CompileOutput != "" {
+		logEntry += fmt.Sprintf("[compile] %s\n", exec.CompileOutput)
+	}
 	if exec.Stderr != "" {
 		logEntry += fmt.Sprintf("[stderr] %s\n", exec.Stderr)
 	}
-	logEntry += fmt.Sprintf("[exit: %d, duration: %.2fms]\n\n", exec.ExitCode, exec.Duration)
+	logEntry += fmt.Sprintf("[%s]\n\n", executionSummary(exec))
 
-	f, err := os.OpenFile(session.LogFile, os.O_APPEND|os.O_WRONLY, 0644)
-	if err != nil {
-		return fmt.Errorf("failed to open log file: %w", err)
+	logOffset := logSize(session.LogFile)
+	if err := sm.logSink.Append(session, logEntry); err != nil {
+		log.Printf("Warning: failed to write log for %s: %v", session.ID, err)
+	} else if err := sm.chainLogEntry(session, logOffset, logEntry); err != nil {
+		log.Printf("Warning: failed to extend log hash chain for %s: %v", session.ID, err)
+	}
+
+	// Structured sidecar for tooling that shouldn't parse the text log
+	if err := appendJSONLine(sm.ExecutionsFile(session), exec); err != nil {
+		log.Printf("Warning: failed to write execution record for %s: %v", session.ID, err)
 	}
-	defer f.Close()
-	f.WriteString(logEntry)
 
 	return sm.saveSession(session)
 }
 
 // SetEnv sets an environment variable in the session
 func (sm *SessionManager) SetEnv(sessionID, key, value string) error {
+	if err := checkEnvName(key); err != nil {
+		return err
+	}
+
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
@@ -180,7 +675,12 @@ func (sm *SessionManager) SetEnv(sessionID, key, value string) error {
 		return fmt.Errorf("session not found: %s", sessionID)
 	}
 
+	if session.Exam != nil {
+		return fmt.Errorf("environment changes are not allowed in exam sessions")
+	}
+
 	session.State.Env[key] = value
+	delete(session.State.Secrets, key)
 	session.UpdatedAt = time.Now()
 
 	return sm.saveSession(session)
@@ -189,20 +689,34 @@ func (sm *SessionManager) SetEnv(sessionID, key, value string) error {
 // CloseSession marks a session as closed
 func (sm *SessionManager) CloseSession(id string) error {
 	sm.mu.Lock()
-	defer sm.mu.Unlock()
+	defer sm.unlockAndDispatch()
 
 	session, ok := sm.sessions[id]
 	if !ok {
 		return fmt.Errorf("session not found: %s", id)
 	}
 
-	session.Status = "closed"
+	return sm.setStatus(session, "closed")
+}
+
+// setStatus changes a session's status, persists it and emits a close
+// event when appropriate. Callers must hold sm.mu.
+func (sm *SessionManager) setStatus(session *Session, status string) error {
+	session.Status = status
 	session.UpdatedAt = time.Now()
 
-	return sm.saveSession(session)
+	if err := sm.saveSession(session); err != nil {
+		return err
+	}
+
+	if status == "closed" {
+		sm.checkpointOnClose(session)
+		sm.emit(EventSessionClosed, session, nil)
+	}
+	return nil
 }
 
-// GetLog returns the last N lines of a session's log
+// GetLog returns the last N lines of a session's log (the whole log if lines <= 0)
 func (sm *SessionManager) GetLog(sessionID string, lines int) (string, error) {
 	sm.mu.RLock()
 	session, ok := sm.sessions[sessionID]
@@ -212,23 +726,194 @@ func (sm *SessionManager) GetLog(sessionID string, lines int) (string, error) {
 		return "", fmt.Errorf("session not found: %s", sessionID)
 	}
 
-	content, err := os.ReadFile(session.LogFile)
+	if lines <= 0 {
+		content, err := os.ReadFile(session.LogFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read log file: %w", err)
+		}
+		return string(content), nil
+	}
+
+	content, err := tailLines(session.LogFile, lines)
 	if err != nil {
 		return "", fmt.Errorf("failed to read log file: %w", err)
 	}
+	return content, nil
+}
 
-	// TODO: Implement tail functionality for large logs
-	return string(content), nil
+// ExecutionsFile returns the JSONL sidecar holding one execution per line
+func (sm *SessionManager) ExecutionsFile(session *Session) string {
+	return strings.TrimSuffix(session.LogFile, ".log") + ".jsonl"
 }
 
-// saveSession persists a session to disk
+// appendJSONLine appends v as a single JSON line to path
+func appendJSONLine(path string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// findJSONLExecution scans an executions sidecar for an execution ID
+func findJSONLExecution(path, execID string) *Execution {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if !bytes.Contains(line, []byte(execID)) {
+			continue
+		}
+		var exec Execution
+		if err := json.Unmarshal(line, &exec); err == nil && exec.ID == execID {
+			return &exec
+		}
+	}
+	return nil
+}
+
+// LogPage is a byte range of a session log
+type LogPage struct {
+	Data       string `json:"data"`
+	Offset     int64  `json:"offset"`
+	NextOffset int64  `json:"next_offset"`
+	Size       int64  `json:"size"`
+}
+
+// GetLogPage returns up to limit bytes of a session's log starting at offset
+func (sm *SessionManager) GetLogPage(sessionID string, offset, limit int64) (*LogPage, error) {
+	sm.mu.RLock()
+	session, ok := sm.sessions[sessionID]
+	sm.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("session not found: %s", sessionID)
+	}
+
+	page, err := readLogRange(session.LogFile, offset, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read log file: %w", err)
+	}
+	return page, nil
+}
+
+// saveSession persists session metadata to disk. History lives in the
+// JSONL sidecar, so the file stays small no matter how many executions run.
 func (sm *SessionManager) saveSession(session *Session) error {
-	data, err := json.MarshalIndent(session, "", "  ")
+	stored := *session
+	stored.State.History = nil
+	stored.State.Archived = 0
+
+	data, err := json.MarshalIndent(&stored, "", "  ")
 	if err != nil {
 		return err
 	}
 
 	path := filepath.Join(sm.dataDir, session.ID+".json")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// loadHistory reads a session's history from its JSONL sidecar. Executions
+// still embedded in an older session JSON are first moved to the sidecar.
+func (sm *SessionManager) loadHistory(session *Session) error {
+	path := sm.ExecutionsFile(session)
+	history, err := readJSONLExecutions(path)
+	if err != nil {
+		return err
+	}
+
+	if len(session.State.History) > 0 {
+		known := make(map[string]bool, len(history))
+		for _, exec := range history {
+			known[exec.ID] = true
+		}
+		for _, exec := range session.State.History {
+			if known[exec.ID] {
+				continue
+			}
+			if err := appendJSONLine(path, exec); err != nil {
+				return err
+			}
+			history = append(history, exec)
+		}
+		if err := sm.saveSession(session); err != nil {
+			return err
+		}
+	}
+
+	if history == nil {
+		history = []Execution{}
+	}
+	session.State.History = history
+	session.State.Archived = 0
+	return nil
+}
+
+// readJSONLExecutions returns every execution in a sidecar; a missing file is empty
+func readJSONLExecutions(path string) ([]Execution, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var history []Execution
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var exec Execution
+		if err := json.Unmarshal(scanner.Bytes(), &exec); err != nil {
+			continue // skip a torn final line
+		}
+		history = append(history, exec)
+	}
+	return history, scanner.Err()
+}
+
+// stateDirName holds orchestrator state files apart from the session
+// records in the data directory root, which loadSessions reads as sessions
+const stateDirName = "state"
+
+// statePath returns the path of a state file in dataDir. A copy left in
+// the root by an older version is moved into place.
+func statePath(dataDir, name string) string {
+	path := filepath.Join(dataDir, stateDirName, name)
+	legacy := filepath.Join(dataDir, name)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if _, err := os.Stat(legacy); err == nil && os.MkdirAll(filepath.Dir(path), 0755) == nil {
+			os.Rename(legacy, path)
+		}
+	}
+	return path
+}
+
+// writeStateFile stores a state file in dataDir
+func writeStateFile(dataDir, name string, data []byte) error {
+	path := statePath(dataDir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
 	return os.WriteFile(path, data, 0644)
 }
 
@@ -257,6 +942,14 @@ func (sm *SessionManager) loadSessions() error {
 		if err := json.Unmarshal(data, &session); err != nil {
 			continue
 		}
+		if session.ID == "" {
+			log.Printf("Warning: skipping %s: not a session record", path)
+			continue
+		}
+
+		if err := sm.loadHistory(&session); err != nil {
+			log.Printf("Warning: failed to load history for %s: %v", session.ID, err)
+		}
 
 		sm.sessions[session.ID] = &session
 	}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestListExecutionsDuringWrites(t *testing.T) {
+	sm, session := newTestSession(t, "python", SessionOptions{})
+	sm.SetMaxHistory(3)
+
+	list := func(format string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/sessions/"+session.ID+"/executions?format="+format, nil)
+		r = r.WithContext(withTenant(r.Context(), "", sm))
+		r.SetPathValue("id", session.ID)
+		w := httptest.NewRecorder()
+		handleListExecutions(w, r)
+		return w
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(2)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 30; i++ {
+				sm.AddExecution(session.ID, Execution{Code: fmt.Sprintf("print(%d, %d)", g, i), Output: "x"})
+			}
+		}(g)
+		go func(format string) {
+			defer wg.Done()
+			for i := 0; i < 30; i++ {
+				w := list(format)
+				if w.Code != http.StatusOK {
+					t.Errorf("status = %d: %s", w.Code, w.Body)
+					return
+				}
+				if format == "" {
+					var history []Execution
+					if err := json.Unmarshal(w.Body.Bytes(), &history); err != nil || len(history) > 3 {
+						t.Errorf("history of %d executions, %v", len(history), err)
+					}
+					continue
+				}
+				scanner := bufio.NewScanner(w.Body)
+				for scanner.Scan() {
+					var exec Execution
+					if err := json.Unmarshal(scanner.Bytes(), &exec); err != nil {
+						t.Errorf("partial sidecar line %q: %v", scanner.Text(), err)
+					}
+				}
+			}
+		}([]string{"", "jsonl"}[g%2])
+	}
+	wg.Wait()
+
+	scanner := bufio.NewScanner(list("jsonl").Body)
+	lines := 0
+	for scanner.Scan() {
+		lines++
+	}
+	if lines != 120 {
+		t.Fatalf("sidecar has %d executions, want 120", lines)
+	}
+}
+
+func TestStrictSessionRejectsConcurrentExecution(t *testing.T) {
+	tests := []struct {
+		name          string
+		session, all  bool
+		wantConflicts bool
+	}{
+		{name: "default", wantConflicts: false},
+		{name: "strict session", session: true, wantConflicts: true},
+		{name: "--strict-sessions", all: true, wantConflicts: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sm, session := newTestSession(t, "python", SessionOptions{Strict: tt.session})
+			sm.Strict = tt.all
+
+			first, err := sm.BeginExecution(session.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			second, err := sm.BeginExecution(session.ID)
+			var inflight *InFlightError
+			if got := errors.As(err, &inflight); got != tt.wantConflicts {
+				t.Fatalf("second execution error = %v, want conflict %v", err, tt.wantConflicts)
+			}
+			if tt.wantConflicts {
+				if inflight.ExecutionID != first {
+					t.Fatalf("conflict names %s, want the pending %s", inflight.ExecutionID, first)
+				}
+				w := httptest.NewRecorder()
+				writeExecuteError(w, err)
+				if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), first) {
+					t.Fatalf("response %d %s, want 409 naming %s", w.Code, w.Body, first)
+				}
+			} else {
+				sm.EndExecution(session.ID, second)
+			}
+
+			// The session takes executions again once the pending one ends
+			sm.EndExecution(session.ID, first)
+			if sm.InFlightCount() != 0 {
+				t.Fatalf("%d executions still in flight", sm.InFlightCount())
+			}
+			next, err := sm.BeginExecution(session.ID)
+			if err != nil {
+				t.Fatalf("execution after the pending one ended: %v", err)
+			}
+			sm.EndExecution(session.ID, next)
+		})
+	}
+}
+
+func TestExecutionsSidecar(t *testing.T) {
+	sm, session := newTestSession(t, "python", SessionOptions{})
+	var ids []string
+	for i := 0; i < 3; i++ {
+		exec := Execution{ID: fmt.Sprintf("exec-%d", i), Code: fmt.Sprintf("print(%d)", i), Output: fmt.Sprintf("%d\n", i)}
+		if err := sm.AddExecution(session.ID, exec); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, exec.ID)
+	}
+
+	executions, err := readJSONLExecutions(sm.ExecutionsFile(session))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(executions) != len(ids) {
+		t.Fatalf("sidecar holds %d executions, want %d", len(executions), len(ids))
+	}
+	for i, exec := range executions {
+		if exec.ID != ids[i] || exec.Output != fmt.Sprintf("%d\n", i) {
+			t.Fatalf("sidecar line %d = %+v", i, exec)
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/sessions/"+session.ID+"/executions?format=jsonl", nil)
+	r = r.WithContext(withTenant(r.Context(), "", sm))
+	r.SetPathValue("id", session.ID)
+	w := httptest.NewRecorder()
+	handleListExecutions(w, r)
+	if ct := w.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	if got := strings.Count(w.Body.String(), "\n"); got != len(ids) {
+		t.Fatalf("endpoint streamed %d lines, want %d", got, len(ids))
+	}
+}
+
+func TestHistoryPruning(t *testing.T) {
+	dir := t.TempDir()
+	sm, err := NewSessionManager(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sm.SetMaxHistory(2)
+	session, err := sm.CreateSession("python", "", SessionOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if err := sm.AddExecution(session.ID, Execution{ID: fmt.Sprintf("exec-%d", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	history, _ := sm.ListExecutions(session.ID)
+	if len(history) != 2 || history[0].ID != "exec-3" || history[1].ID != "exec-4" {
+		t.Fatalf("history = %+v, want the last two executions", history)
+	}
+	if session.State.Archived != 3 {
+		t.Fatalf("archived = %d, want 3", session.State.Archived)
+	}
+
+	// Pruned executions are still found through the sidecar
+	if exec, err := sm.GetExecution(session.ID, "exec-0"); err != nil || exec.ID != "exec-0" {
+		t.Fatalf("pruned execution: %+v, %v", exec, err)
+	}
+	if _, exec, err := sm.FindExecution("exec-1"); err != nil || exec.ID != "exec-1" {
+		t.Fatalf("pruned execution by ID: %+v, %v", exec, err)
+	}
+	if _, err := sm.GetExecution(session.ID, "exec-9"); err == nil {
+		t.Fatal("unknown execution found")
+	}
+
+	// The history is reloaded from the sidecar and pruned again
+	reloaded, err := NewSessionManager(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if history, _ := reloaded.ListExecutions(session.ID); len(history) != 5 {
+		t.Fatalf("reloaded %d executions, want all 5", len(history))
+	}
+	reloaded.SetMaxHistory(2)
+	if history, _ := reloaded.ListExecutions(session.ID); len(history) != 2 || history[1].ID != "exec-4" {
+		t.Fatalf("reloaded history = %+v, want the last two executions", history)
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Snapshot is an immutable, anonymized public copy of an execution result
+type Snapshot struct {
+	ID        string     `json:"id"`
+	Language  string     `json:"language"`
+	Code      string     `json:"code"`
+	Output    string     `json:"output"`
+	Stderr    string     `json:"stderr,omitempty"`
+	ExitCode  int        `json:"exit_code"`
+	Duration  float64    `json:"duration_ms"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// SnapshotStore persists snapshots under <data-dir>/snapshots
+type SnapshotStore struct {
+	dir string
+}
+
+// NewSnapshotStore creates the snapshots directory if needed
+func NewSnapshotStore(dataDir string) (*SnapshotStore, error) {
+	dir := filepath.Join(dataDir, "snapshots")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create snapshots directory: %w", err)
+	}
+	return &SnapshotStore{dir: dir}, nil
+}
+
+// Publish creates a snapshot of an execution. Session identity is dropped
+// and values of the session's environment variables, its secrets and the
+// --redact-pattern matches are redacted.
+func (ss *SnapshotStore) Publish(session *Session, exec *Execution, r *redactor, ttl time.Duration) (*Snapshot, error) {
+	redact := func(text string) string {
+		for _, value := range session.State.Env {
+			if len(value) >= minRedactLength {
+				text = strings.ReplaceAll(text, value, redactedText)
+			}
+		}
+		return r.redact(text)
+	}
+
+	snapshot := &Snapshot{
+		ID:        shortID(8),
+		Language:  session.Language,
+		Code:      redact(exec.Code),
+		Output:    redact(exec.Output),
+		Stderr:    redact(exec.Stderr),
+		ExitCode:  exec.ExitCode,
+		Duration:  exec.Duration,
+		CreatedAt: time.Now(),
+	}
+	if ttl > 0 {
+		expires := snapshot.CreatedAt.Add(ttl)
+		snapshot.ExpiresAt = &expires
+	}
+
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	// O_EXCL keeps published snapshots immutable
+	f, err := os.OpenFile(filepath.Join(ss.dir, snapshot.ID+".json"), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		return nil, fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	return snapshot, nil
+}
+
+// Get loads a snapshot, removing it if it has expired
+func (ss *SnapshotStore) Get(id string) (*Snapshot, error) {
+	path := filepath.Join(ss.dir, filepath.Base(id)+".json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot not found: %s", id)
+	}
+
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, err
+	}
+
+	if snapshot.ExpiresAt != nil && time.Now().After(*snapshot.ExpiresAt) {
+		os.Remove(path)
+		return nil, fmt.Errorf("snapshot not found: %s", id)
+	}
+
+	return &snapshot, nil
+}
+
+// shortID returns a random base62 identifier of length n
+func shortID(n int) string {
+	const alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	b := make([]byte, n)
+	max := big.NewInt(int64(len(alphabet)))
+	for i := range b {
+		idx, _ := rand.Int(rand.Reader, max)
+		b[i] = alphabet[idx.Int64()]
+	}
+	return string(b)
+}
+
+// HTTP handlers
+
+func handlePublishExecution(w http.ResponseWriter, r *http.Request) {
+	session, exec, err := sessionsFor(r.Context()).FindExecution(r.PathValue("id"))
+	if err == nil && !canAccessSession(r.Context(), session) {
+		err = fmt.Errorf("execution not found: %s", r.PathValue("id"))
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	var req struct {
+		ExpiresIn string `json:"expires_in,omitempty"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	var ttl time.Duration
+	if req.ExpiresIn != "" {
+		ttl, err = time.ParseDuration(req.ExpiresIn)
+		if err != nil || ttl <= 0 {
+			http.Error(w, "invalid expires_in duration", http.StatusBadRequest)
+			return
+		}
+	}
+
+	snapshot, err := snapshotStore.Publish(session, exec, sessionsFor(r.Context()).redactor(session), ttl)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	audit(r.Context(), "snapshot.publish", session.ID, map[string]interface{}{
+		"snapshot_id":  snapshot.ID,
+		"execution_id": exec.ID,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":         snapshot.ID,
+		"url":        "/s/" + snapshot.ID,
+		"expires_at": snapshot.ExpiresAt,
+	})
+}
+
+func handleGetSnapshot(w http.ResponseWriter, r *http.Request) {
+	snapshot, err := snapshotStore.Get(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(snapshot)
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Stable status codes for Judge0 status IDs. Clients should switch on these
+// rather than on Judge0's descriptions, whose wording varies by version.
+var judge0StatusCodes = map[int]string{
+	1:  "IN_QUEUE",
+	2:  "PROCESSING",
+	3:  "ACCEPTED",
+	4:  "WRONG_ANSWER",
+	5:  "TIME_LIMIT_EXCEEDED",
+	6:  "COMPILATION_ERROR",
+	7:  "RUNTIME_ERROR_SIGSEGV",
+	8:  "RUNTIME_ERROR_SIGXFSZ",
+	9:  "RUNTIME_ERROR_SIGFPE",
+	10: "RUNTIME_ERROR_SIGABRT",
+	11: "RUNTIME_ERROR_NZEC",
+	12: "RUNTIME_ERROR_OTHER",
+	13: "INTERNAL_ERROR",
+	14: "EXEC_FORMAT_ERROR",
+}
+
+// statusUnknown is reported for status IDs newer than this table
+const statusUnknown = "UNKNOWN"
+
+// statusSummaries holds a human-friendly summary of each status code per
+// locale. English is complete and used for anything missing elsewhere.
+var statusSummaries = map[string]map[string]string{
+	"en": {
+		"IN_QUEUE":              "Waiting for a worker",
+		"PROCESSING":            "Running",
+		"ACCEPTED":              "Ran successfully",
+		"WRONG_ANSWER":          "Output did not match the expected output",
+		"TIME_LIMIT_EXCEEDED":   "Stopped: time limit exceeded",
+		"COMPILATION_ERROR":     "The code failed to compile",
+		"RUNTIME_ERROR_SIGSEGV": "Crashed: invalid memory access (segmentation fault)",
+		"RUNTIME_ERROR_SIGXFSZ": "Crashed: output file size limit exceeded",
+		"RUNTIME_ERROR_SIGFPE":  "Crashed: arithmetic error (e.g. division by zero)",
+		"RUNTIME_ERROR_SIGABRT": "Crashed: the program aborted",
+		"RUNTIME_ERROR_NZEC":    "Exited with a non-zero exit code",
+		"RUNTIME_ERROR_OTHER":   "Crashed with a runtime error",
+		"INTERNAL_ERROR":        "The execution backend failed; try again",
+		"EXEC_FORMAT_ERROR":     "The program could not be executed (invalid executable format)",
+		"UNKNOWN":               "Unknown status",
+	},
+	"es": {
+		"IN_QUEUE":              "En cola, esperando un ejecutor",
+		"PROCESSING":            "En ejecución",
+		"ACCEPTED":              "Se ejecutó correctamente",
+		"WRONG_ANSWER":          "La salida no coincide con la esperada",
+		"TIME_LIMIT_EXCEEDED":   "Detenido: se superó el límite de tiempo",
+		"COMPILATION_ERROR":     "El código no compiló",
+		"RUNTIME_ERROR_SIGSEGV": "Fallo: acceso inválido a memoria (violación de segmento)",
+		"RUNTIME_ERROR_SIGXFSZ": "Fallo: se superó el tamaño máximo de archivo",
+		"RUNTIME_ERROR_SIGFPE":  "Fallo: error aritmético (p. ej., división por cero)",
+		"RUNTIME_ERROR_SIGABRT": "Fallo: el programa abortó",
+		"RUNTIME_ERROR_NZEC":    "Terminó con un código de salida distinto de cero",
+		"RUNTIME_ERROR_OTHER":   "Fallo por un error en tiempo de ejecución",
+		"INTERNAL_ERROR":        "Falló el backend de ejecución; inténtalo de nuevo",
+		"EXEC_FORMAT_ERROR":     "No se pudo ejecutar el programa (formato de ejecutable inválido)",
+		"UNKNOWN":               "Estado desconocido",
+	},
+	"fr": {
+		"IN_QUEUE":              "En file d'attente",
+		"PROCESSING":            "En cours d'exécution",
+		"ACCEPTED":              "Exécution réussie",
+		"WRONG_ANSWER":          "La sortie ne correspond pas à la sortie attendue",
+		"TIME_LIMIT_EXCEEDED":   "Arrêté : limite de temps dépassée",
+		"COMPILATION_ERROR":     "Le code n'a pas pu être compilé",
+		"RUNTIME_ERROR_SIGSEGV": "Plantage : accès mémoire invalide (erreur de segmentation)",
+		"RUNTIME_ERROR_SIGXFSZ": "Plantage : taille maximale de fichier dépassée",
+		"RUNTIME_ERROR_SIGFPE":  "Plantage : erreur arithmétique (par ex. division par zéro)",
+		"RUNTIME_ERROR_SIGABRT": "Plantage : le programme a été interrompu",
+		"RUNTIME_ERROR_NZEC":    "Terminé avec un code de sortie non nul",
+		"RUNTIME_ERROR_OTHER":   "Plantage : erreur d'exécution",
+		"INTERNAL_ERROR":        "Le backend d'exécution a échoué ; réessayez",
+		"EXEC_FORMAT_ERROR":     "Le programme n'a pas pu être exécuté (format d'exécutable invalide)",
+		"UNKNOWN":               "Statut inconnu",
+	},
+	"de": {
+		"IN_QUEUE":              "In der Warteschlange",
+		"PROCESSING":            "Wird ausgeführt",
+		"ACCEPTED":              "Erfolgreich ausgeführt",
+		"WRONG_ANSWER":          "Die Ausgabe entspricht nicht der erwarteten Ausgabe",
+		"TIME_LIMIT_EXCEEDED":   "Abgebrochen: Zeitlimit überschritten",
+		"COMPILATION_ERROR":     "Der Code konnte nicht kompiliert werden",
+		"RUNTIME_ERROR_SIGSEGV": "Abgestürzt: ungültiger Speicherzugriff (Segmentation Fault)",
+		"RUNTIME_ERROR_SIGXFSZ": "Abgestürzt: maximale Dateigröße überschritten",
+		"RUNTIME_ERROR_SIGFPE":  "Abgestürzt: Rechenfehler (z. B. Division durch null)",
+		"RUNTIME_ERROR_SIGABRT": "Abgestürzt: das Programm wurde abgebrochen",
+		"RUNTIME_ERROR_NZEC":    "Mit einem Exit-Code ungleich null beendet",
+		"RUNTIME_ERROR_OTHER":   "Abgestürzt mit einem Laufzeitfehler",
+		"INTERNAL_ERROR":        "Das Ausführungs-Backend ist fehlgeschlagen; bitte erneut versuchen",
+		"EXEC_FORMAT_ERROR":     "Das Programm konnte nicht ausgeführt werden (ungültiges Programmformat)",
+		"UNKNOWN":               "Unbekannter Status",
+	},
+}
+
+// defaultLocale is used for summaries when the client doesn't ask for one
+var defaultLocale = "en"
+
+// statusCode maps a Judge0 status ID to its stable code
+func statusCode(id int) string {
+	if code, ok := judge0StatusCodes[id]; ok {
+		return code
+	}
+	return statusUnknown
+}
+
+// statusSummary describes a status code in locale, falling back to English
+func statusSummary(code, locale string) string {
+	if s, ok := statusSummaries[locale][code]; ok {
+		return s
+	}
+	if s, ok := statusSummaries["en"][code]; ok {
+		return s
+	}
+	return statusSummaries["en"][statusUnknown]
+}
+
+// matchLocale returns the supported locale for a tag such as "es-MX", or ""
+func matchLocale(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	base, _, _ := strings.Cut(strings.ReplaceAll(tag, "_", "-"), "-")
+	if _, ok := statusSummaries[base]; ok {
+		return base
+	}
+	return ""
+}
+
+// validateLocale checks the --locale flag
+func validateLocale(locale string) error {
+	if matchLocale(locale) == "" {
+		return fmt.Errorf("unsupported locale %q (supported: %s)", locale, strings.Join(supportedLocales(), ", "))
+	}
+	return nil
+}
+
+func supportedLocales() []string {
+	locales := make([]string, 0, len(statusSummaries))
+	for l := range statusSummaries {
+		locales = append(locales, l)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// requestLocale picks the first supported language from Accept-Language
+// (quality values are ignored; clients list preferred languages first)
+func requestLocale(r *http.Request) string {
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		tag, _, _ := strings.Cut(part, ";")
+		if locale := matchLocale(tag); locale != "" {
+			return locale
+		}
+	}
+	return matchLocale(defaultLocale)
+}
+
+// localized fills in the status summary for locale
+func (s Status) localized(locale string) Status {
+	if s.Code == "" {
+		s.Code = statusCode(s.ID)
+	}
+	s.Summary = statusSummary(s.Code, locale)
+	return s
+}
+
+// StatusInfo describes one status code for GET /statuses
+type StatusInfo struct {
+	ID      int    `json:"id,omitempty"`
+	Code    string `json:"code"`
+	Summary string `json:"summary"`
+}
+
+// handleListStatuses serves GET /statuses: every status code with its
+// summary in the requested locale
+func handleListStatuses(w http.ResponseWriter, r *http.Request) {
+	locale := requestLocale(r)
+	if l := r.URL.Query().Get("locale"); l != "" {
+		if locale = matchLocale(l); locale == "" {
+			http.Error(w, validateLocale(l).Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	ids := make([]int, 0, len(judge0StatusCodes))
+	for id := range judge0StatusCodes {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	statuses := make([]StatusInfo, 0, len(ids)+1)
+	for _, id := range ids {
+		code := judge0StatusCodes[id]
+		statuses = append(statuses, StatusInfo{ID: id, Code: code, Summary: statusSummary(code, locale)})
+	}
+	statuses = append(statuses, StatusInfo{Code: statusUnknown, Summary: statusSummary(statusUnknown, locale)})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Language", locale)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"locale":   locale,
+		"locales":  supportedLocales(),
+		"statuses": statuses,
+	})
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Judge0 captures stdout only, so input a program read never shows up in
+// its output and interactive-style transcripts read as if the prompts went
+// unanswered. The log puts the input back: echoed after the prompts that
+// consumed it when those can be found, otherwise as a labeled section.
+
+// promptPoints returns the offsets in stdout just past an input prompt: a
+// ':', '?' or '>' followed by a space that isn't the end of its line, or
+// that ends stdout. A program that prompts with input("Name: ") and then
+// prints leaves exactly that shape, since the user's newline isn't echoed.
+func promptPoints(stdout string) []int {
+	var points []int
+	for i := 0; i+1 < len(stdout); i++ {
+		if !strings.ContainsRune(":?>", rune(stdout[i])) || stdout[i+1] != ' ' {
+			continue
+		}
+		end := i + 2
+		if end == len(stdout) || stdout[end] != '\n' {
+			points = append(points, end)
+		}
+	}
+	return points
+}
+
+// interleaveStdin echoes each stdin line after the prompt that read it. It
+// reports false when the prompts don't pair up one-to-one with the input
+// lines, since a guess would misplace input.
+func interleaveStdin(stdout, stdin string) (string, bool) {
+	lines := strings.Split(strings.TrimSuffix(stdin, "\n"), "\n")
+	points := promptPoints(stdout)
+	if len(points) != len(lines) {
+		return "", false
+	}
+
+	var b strings.Builder
+	prev := 0
+	for i, p := range points {
+		b.WriteString(stdout[prev:p])
+		b.WriteString(lines[i])
+		b.WriteString("\n")
+		prev = p
+	}
+	b.WriteString(stdout[prev:])
+	return b.String(), true
+}
+
+// logOutput is an execution's stdout as written to the session log, with
+// its stdin echoed back in
+func logOutput(exec Execution) string {
+	if exec.Stdin == "" {
+		return exec.Output
+	}
+	if echoed, ok := interleaveStdin(exec.Output, exec.Stdin); ok {
+		return fmt.Sprintf("[stdin echoed at %d prompts]\n%s", strings.Count(strings.TrimSuffix(exec.Stdin, "\n"), "\n")+1, echoed)
+	}
+
+	var b strings.Builder
+	b.WriteString("[stdin]\n")
+	for _, line := range strings.Split(strings.TrimSuffix(exec.Stdin, "\n"), "\n") {
+		b.WriteString("< " + line + "\n")
+	}
+	b.WriteString("[stdout]\n")
+	b.WriteString(exec.Output)
+	return b.String()
+}
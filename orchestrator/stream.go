@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// sseWriter writes Server-Sent Events to an HTTP response
+type sseWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	started bool
+}
+
+// newSSEWriter returns an SSE writer, or an error if the response can't be flushed
+func newSSEWriter(w http.ResponseWriter) (*sseWriter, error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, fmt.Errorf("streaming not supported")
+	}
+	return &sseWriter{w: w, flusher: flusher}, nil
+}
+
+// start sends the SSE response headers once
+func (s *sseWriter) start() {
+	if s.started {
+		return
+	}
+	s.started = true
+
+	s.w.Header().Set("Content-Type", "text/event-stream")
+	s.w.Header().Set("Cache-Control", "no-cache")
+	s.w.Header().Set("Connection", "keep-alive")
+	s.w.WriteHeader(http.StatusOK)
+}
+
+// Event sends a named event with a JSON-encoded payload
+func (s *sseWriter) Event(name string, data interface{}) error {
+	return s.write("", name, data)
+}
+
+// EventWithID sends an event with an ID clients echo in Last-Event-ID
+// when they reconnect
+func (s *sseWriter) EventWithID(id int, name string, data interface{}) error {
+	return s.write(fmt.Sprintf("id: %d\n", id), name, data)
+}
+
+func (s *sseWriter) write(prefix, name string, data interface{}) error {
+	s.start()
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(s.w, "%sevent: %s\ndata: %s\n\n", prefix, name, payload); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+// handleExecuteStream runs an execution and reports its progress as SSE:
+// queued, status (every poll), stdout, stderr and finally done.
+func handleExecuteStream(w http.ResponseWriter, r *http.Request, sessionID string, req ExecRequest) {
+	sse, err := newSSEWriter(w)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	locale := requestLocale(r)
+	req.OnStart = func(execID string) {
+		sse.Event("queued", map[string]string{
+			"session_id":   sessionID,
+			"execution_id": execID,
+		})
+	}
+	req.OnStatus = func(status Status) {
+		sse.Event("status", status.localized(locale))
+	}
+
+	exec, err := runExecution(r.Context(), sessionID, req)
+	if err != nil {
+		if !sse.started {
+			writeExecuteError(w, err)
+			return
+		}
+		sse.Event("error", map[string]string{"error": err.Error(), "code": errorCode(err)})
+		return
+	}
+
+	if exec.Output != "" {
+		sse.Event("stdout", map[string]string{"data": exec.Output})
+	}
+	if exec.Stderr != "" {
+		sse.Event("stderr", map[string]string{"data": exec.Stderr})
+	}
+	sse.Event("done", executionResponse(exec, locale))
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// SubmissionParams are Judge0 submission settings a caller may set per
+// execution; unset ones are left to the backend's defaults
+type SubmissionParams struct {
+	WallTimeLimit                      *float64 `json:"wall_time_limit,omitempty"`
+	StackLimit                         *int     `json:"stack_limit,omitempty"`
+	MaxProcessesAndOrThreads           *int     `json:"max_processes_and_or_threads,omitempty"`
+	EnablePerProcessAndThreadTimeLimit *bool    `json:"enable_per_process_and_thread_time_limit,omitempty"`
+	MaxFileSize                        *int     `json:"max_file_size,omitempty"`
+	RedirectStderrToStdout             *bool    `json:"redirect_stderr_to_stdout,omitempty"`
+	NumberOfRuns                       *int     `json:"number_of_runs,omitempty"`
+}
+
+// InvalidSubmissionError is returned when submission parameters are out of
+// range or exceed what the backend allows
+type InvalidSubmissionError struct {
+	Param  string
+	Reason string
+}
+
+func (e *InvalidSubmissionError) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Param, e.Reason)
+}
+
+// submissionParamsFrom reads SubmissionParams from MCP tool arguments
+func submissionParamsFrom(args map[string]interface{}) (SubmissionParams, error) {
+	var p SubmissionParams
+	data, err := json.Marshal(args)
+	if err != nil {
+		return p, err
+	}
+	if err := json.Unmarshal(data, &p); err != nil {
+		return p, fmt.Errorf("invalid submission parameters: %w", err)
+	}
+	return p, nil
+}
+
+// numericLimits pairs each numeric parameter with the config_info key of
+// its backend maximum
+func (p SubmissionParams) numericLimits() []struct {
+	param, max string
+	value      *float64
+} {
+	num := func(v *int) *float64 {
+		if v == nil {
+			return nil
+		}
+		f := float64(*v)
+		return &f
+	}
+	return []struct {
+		param, max string
+		value      *float64
+	}{
+		{"wall_time_limit", "max_wall_time_limit", p.WallTimeLimit},
+		{"stack_limit", "max_stack_limit", num(p.StackLimit)},
+		{"max_processes_and_or_threads", "max_max_processes_and_or_threads", num(p.MaxProcessesAndOrThreads)},
+		{"max_file_size", "max_max_file_size", num(p.MaxFileSize)},
+		{"number_of_runs", "max_number_of_runs", num(p.NumberOfRuns)},
+	}
+}
+
+// Validate checks the parameters against the backend's config_info. A nil
+// config skips the backend maxima, leaving them to Judge0.
+func (p SubmissionParams) Validate(config map[string]interface{}) error {
+	for _, l := range p.numericLimits() {
+		if l.value == nil {
+			continue
+		}
+		if *l.value <= 0 {
+			return &InvalidSubmissionError{Param: l.param, Reason: "must be positive"}
+		}
+		if max, ok := config[l.max].(float64); ok && *l.value > max {
+			return &InvalidSubmissionError{Param: l.param, Reason: fmt.Sprintf("%g exceeds the backend maximum %g", *l.value, max)}
+		}
+	}
+	if p.EnablePerProcessAndThreadTimeLimit != nil && *p.EnablePerProcessAndThreadTimeLimit {
+		if allowed, ok := config["allow_enable_per_process_and_thread_time_limit"].(bool); ok && !allowed {
+			return &InvalidSubmissionError{Param: "enable_per_process_and_thread_time_limit", Reason: "the backend doesn't allow it"}
+		}
+	}
+	return nil
+}
+
+// IsZero reports whether no parameter is set
+func (p SubmissionParams) IsZero() bool {
+	return p == SubmissionParams{}
+}
+
+// apply sets the parameters on a submission
+func (p SubmissionParams) apply(sub *Judge0Submission) {
+	if p.WallTimeLimit != nil {
+		sub.WallTimeLimit = *p.WallTimeLimit
+	}
+	if p.StackLimit != nil {
+		sub.StackLimit = *p.StackLimit
+	}
+	if p.MaxProcessesAndOrThreads != nil {
+		sub.MaxProcessesAndOrThreads = *p.MaxProcessesAndOrThreads
+	}
+	if p.MaxFileSize != nil {
+		sub.MaxFileSize = *p.MaxFileSize
+	}
+	if p.NumberOfRuns != nil {
+		sub.NumberOfRuns = *p.NumberOfRuns
+	}
+	sub.EnablePerProcessAndThreadTimeLimit = p.EnablePerProcessAndThreadTimeLimit
+	sub.RedirectStderrToStdout = p.RedirectStderrToStdout
+}
+
+// configCacheTTL is how long the backend's config_info is reused
+const configCacheTTL = 5 * time.Minute
+
+var configCache struct {
+	mu      sync.Mutex
+	config  map[string]interface{}
+	fetched time.Time
+}
+
+// backendConfig returns Judge0's config_info, cached for configCacheTTL
+func backendConfig(ctx context.Context) (map[string]interface{}, error) {
+	configCache.mu.Lock()
+	defer configCache.mu.Unlock()
+
+	if configCache.config != nil && time.Since(configCache.fetched) < configCacheTTL {
+		return configCache.config, nil
+	}
+	config, err := judge0Client.ConfigInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	configCache.config = config
+	configCache.fetched = time.Now()
+	return config, nil
+}
+
+// validateSubmissionParams checks p against the backend's limits when its
+// config_info is available
+func validateSubmissionParams(ctx context.Context, p SubmissionParams) error {
+	if p.IsZero() {
+		return nil
+	}
+	config, _ := backendConfig(ctx)
+	return p.Validate(config)
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxStdinTemplateBytes bounds a single stored stdin template
+const maxStdinTemplateBytes = 8 << 20
+
+// templateParam matches {{name}} placeholders in stdin templates
+var templateParam = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.-]+)\s*\}\}`)
+
+// renderStdinTemplate substitutes {{name}} placeholders with params,
+// failing if any placeholder has no value
+func renderStdinTemplate(body string, params map[string]string) (string, error) {
+	var missing []string
+	rendered := templateParam.ReplaceAllStringFunc(body, func(m string) string {
+		name := templateParam.FindStringSubmatch(m)[1]
+		value, ok := params[name]
+		if !ok {
+			missing = append(missing, name)
+			return m
+		}
+		return value
+	})
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return "", &InvalidSubmissionError{Param: "stdin_params", Reason: "missing " + strings.Join(missing, ", ")}
+	}
+	return rendered, nil
+}
+
+// resolveStdinTemplate finds a named template on the session, then on the
+// session's problem (named templates first, then test case inputs)
+func resolveStdinTemplate(session *Session, name string, params map[string]string) (string, error) {
+	if body, ok := session.StdinTemplates[name]; ok {
+		return renderStdinTemplate(body, params)
+	}
+
+	if problemID := session.Metadata["problem_id"]; problemID != "" && problemStore != nil {
+		problem, err := problemStore.Get(problemID)
+		if err != nil {
+			return "", err
+		}
+		if body, ok := problem.StdinTemplates[name]; ok {
+			return renderStdinTemplate(body, params)
+		}
+		for _, tc := range problem.TestCases {
+			if tc.Name == name {
+				return renderStdinTemplate(tc.Input, params)
+			}
+		}
+	}
+
+	return "", &InvalidSubmissionError{Param: "stdin_template", Reason: "no template named " + name}
+}
+
+// SetStdinTemplate stores a named stdin template on a session
+func (sm *SessionManager) SetStdinTemplate(sessionID, name, body string) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	session, ok := sm.sessions[sessionID]
+	if !ok {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+
+	if session.StdinTemplates == nil {
+		session.StdinTemplates = make(map[string]string)
+	}
+	session.StdinTemplates[name] = body
+	session.UpdatedAt = time.Now()
+
+	return sm.saveSession(session)
+}
+
+// DeleteStdinTemplate removes a named stdin template from a session
+func (sm *SessionManager) DeleteStdinTemplate(sessionID, name string) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	session, ok := sm.sessions[sessionID]
+	if !ok {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+	if _, ok := session.StdinTemplates[name]; !ok {
+		return fmt.Errorf("stdin template not found: %s", name)
+	}
+
+	delete(session.StdinTemplates, name)
+	session.UpdatedAt = time.Now()
+
+	return sm.saveSession(session)
+}
+
+// SetStdinTemplate stores a named stdin template on a problem
+func (ps *ProblemStore) SetStdinTemplate(problemID, name, body string) error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	problem, err := ps.load(problemID)
+	if err != nil {
+		return err
+	}
+
+	if problem.StdinTemplates == nil {
+		problem.StdinTemplates = make(map[string]string)
+	}
+	problem.StdinTemplates[name] = body
+	problem.UpdatedAt = time.Now()
+
+	return ps.save(problem)
+}
+
+// readTemplateBody reads a raw template from a request body
+func readTemplateBody(r *http.Request) (string, error) {
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxStdinTemplateBytes+1))
+	if err != nil {
+		return "", err
+	}
+	if len(data) > maxStdinTemplateBytes {
+		return "", fmt.Errorf("template exceeds %d bytes", maxStdinTemplateBytes)
+	}
+	return string(data), nil
+}
+
+// HTTP handlers
+
+func handleListStdinTemplates(w http.ResponseWriter, r *http.Request) {
+	session, err := sessionsFor(r.Context()).GetSession(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	templates := session.StdinTemplates
+	if templates == nil {
+		templates = map[string]string{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(templates)
+}
+
+func handlePutStdinTemplate(w http.ResponseWriter, r *http.Request) {
+	body, err := readTemplateBody(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := sessionsFor(r.Context()).SetStdinTemplate(r.PathValue("id"), r.PathValue("name"), body); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	audit(r.Context(), "session.stdin_template_set", r.PathValue("id"), map[string]interface{}{"name": r.PathValue("name")})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleDeleteStdinTemplate(w http.ResponseWriter, r *http.Request) {
+	if err := sessionsFor(r.Context()).DeleteStdinTemplate(r.PathValue("id"), r.PathValue("name")); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	audit(r.Context(), "session.stdin_template_delete", r.PathValue("id"), map[string]interface{}{"name": r.PathValue("name")})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handlePutProblemStdinTemplate(w http.ResponseWriter, r *http.Request) {
+	body, err := readTemplateBody(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := problemStore.SetStdinTemplate(r.PathValue("id"), r.PathValue("name"), body); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	audit(r.Context(), "problem.stdin_template_set", "", map[string]interface{}{
+		"problem_id": r.PathValue("id"),
+		"name":       r.PathValue("name"),
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,599 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// tenantHeader selects the tenant for an HTTP request; requests without it
+// use the default (root) data directory
+const tenantHeader = "X-Tenant-ID"
+
+// validTenantID keeps tenant IDs usable as directory names
+var validTenantID = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// tenantRegistry holds the known tenants, nil until initialized
+var tenantRegistry *TenantRegistry
+
+// ErrUnknownTenant is returned for tenant IDs that were never created
+var ErrUnknownTenant = errors.New("unknown tenant")
+
+// TenantQuota limits what a tenant may consume; zero means unlimited
+type TenantQuota struct {
+	MaxSessions       int   `json:"max_sessions,omitempty"`
+	MaxActiveSessions int   `json:"max_active_sessions,omitempty"`
+	MaxDiskBytes      int64 `json:"max_disk_bytes,omitempty"`
+}
+
+// Tenant is an isolated organization with its own data root and key
+type Tenant struct {
+	ID        string      `json:"id"`
+	Name      string      `json:"name,omitempty"`
+	CreatedAt time.Time   `json:"created_at"`
+	Quota     TenantQuota `json:"quota"`
+}
+
+// TenantUsage is a tenant's current consumption against its quota
+type TenantUsage struct {
+	Sessions       int   `json:"sessions"`
+	ActiveSessions int   `json:"active_sessions"`
+	DiskBytes      int64 `json:"disk_bytes"`
+}
+
+// TenantRegistry stores tenants under <data-dir>/tenants/<id>/ and opens one
+// SessionManager per tenant rooted in <id>/data
+type TenantRegistry struct {
+	dir string
+
+	mu       sync.Mutex
+	tenants  map[string]*Tenant
+	managers map[string]*SessionManager
+	onOpen   []func(*SessionManager)
+	setup    func(string, *SessionManager) error
+}
+
+// NewTenantRegistry loads tenants from <data-dir>/tenants; setup configures
+// each tenant's SessionManager when it is first opened
+func NewTenantRegistry(dataDir string, setup func(string, *SessionManager) error) (*TenantRegistry, error) {
+	dir := filepath.Join(dataDir, "tenants")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create tenants directory: %w", err)
+	}
+
+	reg := &TenantRegistry{
+		dir:      dir,
+		tenants:  make(map[string]*Tenant),
+		managers: make(map[string]*SessionManager),
+		setup:    setup,
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name(), "tenant.json"))
+		if err != nil {
+			continue
+		}
+		var t Tenant
+		if err := json.Unmarshal(data, &t); err != nil {
+			return nil, fmt.Errorf("failed to load tenant %s: %w", entry.Name(), err)
+		}
+		reg.tenants[t.ID] = &t
+	}
+	return reg, nil
+}
+
+// Create registers a tenant and generates its encryption key
+func (tr *TenantRegistry) Create(id, name string, quota TenantQuota) (*Tenant, error) {
+	if !validTenantID.MatchString(id) {
+		return nil, fmt.Errorf("invalid tenant id %q (lowercase letters, digits, - and _)", id)
+	}
+
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	if _, exists := tr.tenants[id]; exists {
+		return nil, fmt.Errorf("tenant %s already exists", id)
+	}
+
+	if err := os.MkdirAll(filepath.Join(tr.dir, id), 0700); err != nil {
+		return nil, err
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(tr.keyPath(id), []byte(hex.EncodeToString(key)), 0600); err != nil {
+		return nil, fmt.Errorf("failed to write tenant key: %w", err)
+	}
+
+	t := &Tenant{ID: id, Name: name, CreatedAt: time.Now(), Quota: quota}
+	if err := tr.save(t); err != nil {
+		return nil, err
+	}
+	tr.tenants[id] = t
+	return t, nil
+}
+
+// Get returns a tenant by ID
+func (tr *TenantRegistry) Get(id string) (*Tenant, error) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	t, ok := tr.tenants[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownTenant, id)
+	}
+	return t, nil
+}
+
+// List returns all tenants ordered by ID
+func (tr *TenantRegistry) List() []*Tenant {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	tenants := make([]*Tenant, 0, len(tr.tenants))
+	for _, t := range tr.tenants {
+		tenants = append(tenants, t)
+	}
+	sort.Slice(tenants, func(i, j int) bool { return tenants[i].ID < tenants[j].ID })
+	return tenants
+}
+
+// SetQuota replaces a tenant's quota
+func (tr *TenantRegistry) SetQuota(id string, quota TenantQuota) (*Tenant, error) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	t, ok := tr.tenants[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownTenant, id)
+	}
+	t.Quota = quota
+	return t, tr.save(t)
+}
+
+// Key returns the tenant's 32-byte encryption key, which seals its
+// sessions' secret env vars
+func (tr *TenantRegistry) Key(id string) ([]byte, error) {
+	if _, err := tr.Get(id); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(tr.keyPath(id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tenant key: %w", err)
+	}
+	return parseSecretKey("for tenant "+id, data)
+}
+
+// Sessions returns the tenant's SessionManager, opening it on first use
+func (tr *TenantRegistry) Sessions(id string) (*SessionManager, error) {
+	tr.mu.Lock()
+	sm, ok := tr.managers[id]
+	tr.mu.Unlock()
+	if ok {
+		return sm, nil
+	}
+
+	key, err := tr.Key(id)
+	if err != nil {
+		return nil, err
+	}
+
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	if sm, ok := tr.managers[id]; ok {
+		return sm, nil
+	}
+
+	sm, err = NewSessionManager(filepath.Join(tr.dir, id, "data"))
+	if err != nil {
+		return nil, err
+	}
+	sm.secretKey = key
+	sm.admit = func(sessions, active int) error {
+		return tr.checkQuota(id, sessions, active)
+	}
+	if tr.setup != nil {
+		if err := tr.setup(id, sm); err != nil {
+			return nil, err
+		}
+	}
+	for _, fn := range tr.onOpen {
+		fn(sm)
+	}
+	tr.managers[id] = sm
+	return sm, nil
+}
+
+// OnOpen runs fn for every tenant SessionManager, including ones already open
+func (tr *TenantRegistry) OnOpen(fn func(*SessionManager)) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	tr.onOpen = append(tr.onOpen, fn)
+	for _, sm := range tr.managers {
+		fn(sm)
+	}
+}
+
+// Usage reports a tenant's sessions and disk consumption
+func (tr *TenantRegistry) Usage(id string) (TenantUsage, error) {
+	sm, err := tr.Sessions(id)
+	if err != nil {
+		return TenantUsage{}, err
+	}
+
+	var usage TenantUsage
+	for _, s := range sm.ListSessions() {
+		usage.Sessions++
+		if s.Status == "active" {
+			usage.ActiveSessions++
+		}
+	}
+	usage.DiskBytes = dirSize(filepath.Join(tr.dir, id))
+	return usage, nil
+}
+
+// QuotaError is returned when a tenant has used up one of its quotas
+type QuotaError struct {
+	Tenant   string
+	Resource string
+	Used     int64
+	Limit    int64
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("tenant %s quota exceeded: %d/%d %s", e.Tenant, e.Used, e.Limit, e.Resource)
+}
+
+// checkQuota returns an error when the tenant, holding sessions sessions of
+// which active are active, cannot create another one. Tenant
+// SessionManagers run it while creating a session, under their lock, so
+// concurrent creates can't overshoot the quota.
+func (tr *TenantRegistry) checkQuota(id string, sessions, active int) error {
+	t, err := tr.Get(id)
+	if err != nil {
+		return err
+	}
+
+	q := t.Quota
+	switch {
+	case q.MaxSessions > 0 && sessions >= q.MaxSessions:
+		return &QuotaError{Tenant: id, Resource: "sessions", Used: int64(sessions), Limit: int64(q.MaxSessions)}
+	case q.MaxActiveSessions > 0 && active >= q.MaxActiveSessions:
+		return &QuotaError{Tenant: id, Resource: "active sessions", Used: int64(active), Limit: int64(q.MaxActiveSessions)}
+	}
+	if q.MaxDiskBytes > 0 {
+		if used := dirSize(filepath.Join(tr.dir, id)); used >= q.MaxDiskBytes {
+			return &QuotaError{Tenant: id, Resource: "disk bytes", Used: used, Limit: q.MaxDiskBytes}
+		}
+	}
+	return nil
+}
+
+// createErrorStatus maps a CreateSession error to an HTTP status
+func createErrorStatus(err error) int {
+	var quota *QuotaError
+	if errors.As(err, &quota) {
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
+
+func (tr *TenantRegistry) keyPath(id string) string {
+	return filepath.Join(tr.dir, id, "tenant.key")
+}
+
+// save writes tenant.json; callers hold tr.mu
+func (tr *TenantRegistry) save(t *Tenant) error {
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(tr.dir, t.ID, "tenant.json"), data, 0600)
+}
+
+type tenantKey struct{}
+
+// tenantScope is the tenant a request operates on
+type tenantScope struct {
+	id       string
+	sessions *SessionManager
+}
+
+// withTenant returns ctx scoped to a tenant and its SessionManager
+func withTenant(ctx context.Context, tenantID string, sessions *SessionManager) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantScope{id: tenantID, sessions: sessions})
+}
+
+// tenantFromContext returns the tenant in ctx, "" for the default tenant
+func tenantFromContext(ctx context.Context) string {
+	scope, _ := ctx.Value(tenantKey{}).(tenantScope)
+	return scope.id
+}
+
+// sessionsFor returns the SessionManager for the tenant in ctx, or the
+// default one when no tenant is set
+func sessionsFor(ctx context.Context) *SessionManager {
+	if scope, ok := ctx.Value(tenantKey{}).(tenantScope); ok {
+		return scope.sessions
+	}
+	return sessionManager
+}
+
+type credentialTenantKey struct{}
+
+// withCredentialTenant records the tenant an API key is bound to
+func withCredentialTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, credentialTenantKey{}, tenantID)
+}
+
+// credentialTenant returns the tenant the caller's credential is bound to
+func credentialTenant(ctx context.Context) string {
+	id, _ := ctx.Value(credentialTenantKey{}).(string)
+	return id
+}
+
+// withTenantScope scopes requests to the tenant their API key is bound to
+// and rejects unknown tenants before any handler touches session data.
+// Only admins with unbound credentials may pick a tenant with X-Tenant-ID.
+func withTenantScope(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := credentialTenant(r.Context())
+		if header := r.Header.Get(tenantHeader); header != "" && id == "" {
+			if !hasRole(r.Context(), RoleAdmin) {
+				http.Error(w, "only admins can select a tenant with "+tenantHeader, http.StatusForbidden)
+				return
+			}
+			id = header
+		}
+		if id == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		sessions, err := tenantRegistry.Sessions(id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(withTenant(r.Context(), id, sessions)))
+	})
+}
+
+// SetupTenantEndpoints registers the tenant admin API
+func SetupTenantEndpoints(mux *http.ServeMux) {
+	mux.HandleFunc("GET /tenants", handleListTenants)
+	mux.HandleFunc("POST /tenants", handleCreateTenant)
+	mux.HandleFunc("GET /tenants/{tenant}", handleGetTenant)
+	mux.HandleFunc("PUT /tenants/{tenant}/quota", handleSetTenantQuota)
+	mux.HandleFunc("GET /tenants/{tenant}/sessions", handleListTenantSessions)
+}
+
+// authorizeTenantAdmin lets admins use the tenant API. Admin keys bound to
+// a tenant only see their own tenant and can't create tenants or change
+// quotas; tenantID is "" for requests not about one tenant.
+func authorizeTenantAdmin(ctx context.Context, tenantID string, write bool) error {
+	if !hasRole(ctx, RoleAdmin) {
+		return fmt.Errorf("only admins can manage tenants")
+	}
+	bound := credentialTenant(ctx)
+	if bound == "" {
+		return nil
+	}
+	if write {
+		return fmt.Errorf("credentials bound to tenant %s can't create tenants or change quotas", bound)
+	}
+	if tenantID != "" && tenantID != bound {
+		return fmt.Errorf("credentials are not valid for tenant %s", tenantID)
+	}
+	return nil
+}
+
+// tenantResponse combines a tenant with its current usage
+func tenantResponse(t *Tenant) map[string]interface{} {
+	resp := map[string]interface{}{
+		"id":         t.ID,
+		"name":       t.Name,
+		"created_at": t.CreatedAt,
+		"quota":      t.Quota,
+	}
+	if usage, err := tenantRegistry.Usage(t.ID); err == nil {
+		resp["usage"] = usage
+	}
+	return resp
+}
+
+func handleListTenants(w http.ResponseWriter, r *http.Request) {
+	if err := authorizeTenantAdmin(r.Context(), "", false); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	bound := credentialTenant(r.Context())
+	tenants := []map[string]interface{}{}
+	for _, t := range tenantRegistry.List() {
+		if bound == "" || t.ID == bound {
+			tenants = append(tenants, tenantResponse(t))
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tenants)
+}
+
+func handleCreateTenant(w http.ResponseWriter, r *http.Request) {
+	if err := authorizeTenantAdmin(r.Context(), "", true); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	var req struct {
+		ID    string      `json:"id"`
+		Name  string      `json:"name"`
+		Quota TenantQuota `json:"quota"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	t, err := tenantRegistry.Create(req.ID, req.Name, req.Quota)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	audit(r.Context(), "tenant.create", "", map[string]interface{}{"tenant": t.ID})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(tenantResponse(t))
+}
+
+func handleGetTenant(w http.ResponseWriter, r *http.Request) {
+	if err := authorizeTenantAdmin(r.Context(), r.PathValue("tenant"), false); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	t, err := tenantRegistry.Get(r.PathValue("tenant"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tenantResponse(t))
+}
+
+func handleSetTenantQuota(w http.ResponseWriter, r *http.Request) {
+	if err := authorizeTenantAdmin(r.Context(), r.PathValue("tenant"), true); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	var quota TenantQuota
+	if err := json.NewDecoder(r.Body).Decode(&quota); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	t, err := tenantRegistry.SetQuota(r.PathValue("tenant"), quota)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	audit(r.Context(), "tenant.quota_set", "", map[string]interface{}{"tenant": t.ID, "quota": quota})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tenantResponse(t))
+}
+
+func handleListTenantSessions(w http.ResponseWriter, r *http.Request) {
+	if err := authorizeTenantAdmin(r.Context(), r.PathValue("tenant"), false); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	sm, err := tenantRegistry.Sessions(r.PathValue("tenant"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sm.ListSessions())
+}
+
+// tenantsCmd manages tenants
+var tenantsCmd = &cobra.Command{
+	Use:   "tenants",
+	Short: "Manage tenants",
+}
+
+func init() {
+	tenantsCmd.AddCommand(tenantsCreateCmd)
+	tenantsCmd.AddCommand(tenantsListCmd)
+	tenantsCmd.AddCommand(tenantsQuotaCmd)
+
+	for _, cmd := range []*cobra.Command{tenantsCreateCmd, tenantsQuotaCmd} {
+		cmd.Flags().Int("max-sessions", 0, "Maximum sessions (0 = unlimited)")
+		cmd.Flags().Int("max-active-sessions", 0, "Maximum active sessions (0 = unlimited)")
+		cmd.Flags().Int64("max-disk-bytes", 0, "Maximum bytes on disk (0 = unlimited)")
+	}
+	tenantsCreateCmd.Flags().String("name", "", "Display name")
+}
+
+// quotaFromFlags reads the --max-* flags
+func quotaFromFlags(cmd *cobra.Command) TenantQuota {
+	var q TenantQuota
+	q.MaxSessions, _ = cmd.Flags().GetInt("max-sessions")
+	q.MaxActiveSessions, _ = cmd.Flags().GetInt("max-active-sessions")
+	q.MaxDiskBytes, _ = cmd.Flags().GetInt64("max-disk-bytes")
+	return q
+}
+
+var tenantsCreateCmd = &cobra.Command{
+	Use:   "create <id>",
+	Short: "Create a tenant with its own data directory and key",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, _ := cmd.Flags().GetString("name")
+		t, err := tenantRegistry.Create(args[0], name, quotaFromFlags(cmd))
+		if err != nil {
+			return err
+		}
+		audit(cmd.Context(), "tenant.create", "", map[string]interface{}{"tenant": t.ID})
+		fmt.Printf("Created tenant: %s\n", t.ID)
+		return nil
+	},
+}
+
+var tenantsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List tenants and their usage",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		tenants := tenantRegistry.List()
+		if len(tenants) == 0 {
+			fmt.Println("No tenants.")
+			return nil
+		}
+
+		fmt.Printf("%-20s %-10s %-10s %-12s %s\n", "ID", "SESSIONS", "ACTIVE", "DISK", "NAME")
+		for _, t := range tenants {
+			usage, err := tenantRegistry.Usage(t.ID)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%-20s %-10d %-10d %-12d %s\n", t.ID, usage.Sessions, usage.ActiveSessions, usage.DiskBytes, t.Name)
+		}
+		return nil
+	},
+}
+
+var tenantsQuotaCmd = &cobra.Command{
+	Use:   "quota <id>",
+	Short: "Replace a tenant's quota",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		quota := quotaFromFlags(cmd)
+		if _, err := tenantRegistry.SetQuota(args[0], quota); err != nil {
+			return err
+		}
+		audit(cmd.Context(), "tenant.quota_set", "", map[string]interface{}{"tenant": args[0], "quota": quota})
+		fmt.Printf("Updated quota for %s\n", args[0])
+		return nil
+	},
+}
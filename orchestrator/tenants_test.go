@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+func TestTenantQuotaUnderConcurrentCreates(t *testing.T) {
+	tests := []struct {
+		name  string
+		quota TenantQuota
+		want  int
+	}{
+		{name: "max sessions", quota: TenantQuota{MaxSessions: 3}, want: 3},
+		{name: "max active sessions", quota: TenantQuota{MaxActiveSessions: 2}, want: 2},
+		{name: "no quota", quota: TenantQuota{}, want: 20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg, err := NewTenantRegistry(t.TempDir(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := reg.Create("acme", "Acme", tt.quota); err != nil {
+				t.Fatal(err)
+			}
+			sm, err := reg.Sessions("acme")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var wg sync.WaitGroup
+			var mu sync.Mutex
+			created, refused := 0, 0
+			for i := 0; i < 20; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					_, err := sm.CreateSession("python", fmt.Sprint(i), SessionOptions{})
+					var quota *QuotaError
+					mu.Lock()
+					defer mu.Unlock()
+					switch {
+					case err == nil:
+						created++
+					case errors.As(err, &quota):
+						refused++
+					default:
+						t.Errorf("CreateSession: %v", err)
+					}
+				}(i)
+			}
+			wg.Wait()
+
+			if created != tt.want || created+refused != 20 {
+				t.Fatalf("created %d, refused %d; want %d created", created, refused, tt.want)
+			}
+			if n := len(sm.ListSessions()); n != tt.want {
+				t.Fatalf("tenant holds %d sessions, want %d", n, tt.want)
+			}
+		})
+	}
+}
+
+func TestTenantActiveQuotaFreedByClose(t *testing.T) {
+	reg, err := NewTenantRegistry(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg.Create("acme", "Acme", TenantQuota{MaxActiveSessions: 1})
+	sm, err := reg.Sessions("acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := sm.CreateSession("python", "", SessionOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = sm.CreateSession("python", "", SessionOptions{})
+	if createErrorStatus(err) != http.StatusForbidden {
+		t.Fatalf("second active session: %v, want a quota error", err)
+	}
+	if err := sm.CloseSession(first.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sm.CreateSession("python", "", SessionOptions{}); err != nil {
+		t.Fatalf("create after close: %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// TLSOptions configures HTTPS and optional client-certificate (mTLS)
+// verification for j0 serve
+type TLSOptions struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile enables mTLS: client certificates must chain to one of
+	// these CAs
+	ClientCAFile string
+	// ClientAuth is "require" or "optional" (verify only if presented)
+	ClientAuth string
+}
+
+// Enabled reports whether a certificate was configured
+func (o TLSOptions) Enabled() bool {
+	return o.CertFile != "" || o.KeyFile != ""
+}
+
+// Config validates the options and builds the server TLS configuration
+func (o TLSOptions) Config() (*tls.Config, error) {
+	if o.CertFile == "" || o.KeyFile == "" {
+		return nil, fmt.Errorf("--tls-cert and --tls-key must be set together")
+	}
+
+	cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS key pair: %w", err)
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if o.ClientCAFile == "" {
+		return config, nil
+	}
+
+	pem, err := os.ReadFile(o.ClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", o.ClientCAFile)
+	}
+	config.ClientCAs = pool
+
+	switch o.ClientAuth {
+	case "require":
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	case "optional":
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	default:
+		return nil, fmt.Errorf("invalid --tls-client-auth %q (want require or optional)", o.ClientAuth)
+	}
+	return config, nil
+}
+
+// clientCertActor identifies clients by their verified certificate's common
+// name, or returns "" when none was presented
+func clientCertActor(r *http.Request) string {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return ""
+	}
+	cert := r.TLS.VerifiedChains[0][0]
+	if cert.Subject.CommonName != "" {
+		return "cert:" + cert.Subject.CommonName
+	}
+	return "cert:" + cert.SerialNumber.String()
+}
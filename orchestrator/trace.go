@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// traceSentinel separates program output from the trace emitted by the tracer
+const traceSentinel = "__J0_TRACE__"
+
+// defaultTraceLimit caps the number of recorded steps
+const defaultTraceLimit = 500
+
+// TraceStep is a single line event of a traced execution
+type TraceStep struct {
+	Line     int               `json:"line"`
+	Function string            `json:"function,omitempty"`
+	Locals   map[string]string `json:"locals,omitempty"`
+}
+
+// supportsTrace reports whether trace mode is available for a language
+func supportsTrace(language string) bool {
+	return language == "python" || language == "python3"
+}
+
+// wrapPythonTrace runs the user code under sys.settrace and prints a compact
+// line-by-line trace after a sentinel on stdout. The user code is compiled
+// separately so reported line numbers match the submitted code.
+func wrapPythonTrace(code string, limit int) string {
+	if limit <= 0 {
+		limit = defaultTraceLimit
+	}
+
+	return fmt.Sprintf(`import sys as _j0_sys, json as _j0_json
+_j0_steps = []
+def _j0_tracer(frame, event, arg):
+    if frame.f_code.co_filename != "<j0>":
+        return None
+    if event == "line" and len(_j0_steps) < %d:
+        local_vars = {}
+        for k, v in list(frame.f_locals.items())[:20]:
+            if k.startswith("__") or k.startswith("_j0_"):
+                continue
+            try:
+                local_vars[k] = repr(v)[:80]
+            except Exception:
+                local_vars[k] = "<unrepresentable>"
+        step = {"line": frame.f_lineno, "locals": local_vars}
+        if frame.f_code.co_name != "<module>":
+            step["function"] = frame.f_code.co_name
+        _j0_steps.append(step)
+    return _j0_tracer
+_j0_code = compile(%s, "<j0>", "exec")
+_j0_sys.settrace(_j0_tracer)
+try:
+    exec(_j0_code, {"__name__": "__main__"})
+finally:
+    _j0_sys.settrace(None)
+    _j0_sys.stdout.flush()
+    _j0_sys.stdout.write("\n%s" + _j0_json.dumps(_j0_steps) + "\n")
+`, limit, strconv.Quote(code), traceSentinel)
+}
+
+// extractTrace splits the tracer output from stdout
+func extractTrace(stdout string) (string, []TraceStep) {
+	idx := strings.LastIndex(stdout, "\n"+traceSentinel)
+	if idx < 0 {
+		return stdout, nil
+	}
+
+	var steps []TraceStep
+	payload := strings.TrimSpace(stdout[idx+1+len(traceSentinel):])
+	if err := json.Unmarshal([]byte(payload), &steps); err != nil {
+		return stdout, nil
+	}
+
+	return stdout[:idx], steps
+}
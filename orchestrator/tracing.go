@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Minimal OpenTelemetry-compatible tracing: spans are propagated with W3C
+// traceparent headers and exported as OTLP/HTTP JSON. Tracing is disabled
+// (all span operations are no-ops) unless an OTLP endpoint is configured.
+
+// tracer is the process-wide span exporter, nil when tracing is disabled
+var tracer *Tracer
+
+// maxSpanBatch is the number of buffered spans that triggers an export
+const maxSpanBatch = 256
+
+// OTLP span kinds
+const (
+	spanKindInternal = 1
+	spanKindServer   = 2
+	spanKindClient   = 3
+)
+
+// Span is a timed operation within a trace
+type Span struct {
+	TraceID  string
+	SpanID   string
+	ParentID string
+	Name     string
+	Kind     int
+	Start    time.Time
+	End      time.Time
+	Attrs    map[string]interface{}
+	Err      string
+
+	remote bool // parent context received from another service
+	mu     sync.Mutex
+}
+
+type spanContextKey struct{}
+
+// Tracer buffers finished spans and exports them to an OTLP collector
+type Tracer struct {
+	endpoint   string
+	service    string
+	httpClient *http.Client
+
+	mu      sync.Mutex
+	pending []*Span
+}
+
+// NewTracer creates a tracer exporting to an OTLP/HTTP endpoint
+// (e.g. http://collector:4318)
+func NewTracer(endpoint, service string) *Tracer {
+	endpoint = strings.TrimSuffix(endpoint, "/")
+	if !strings.HasSuffix(endpoint, "/v1/traces") {
+		endpoint += "/v1/traces"
+	}
+	return &Tracer{
+		endpoint:   endpoint,
+		service:    service,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Run exports buffered spans periodically until ctx is cancelled
+func (t *Tracer) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			t.Flush()
+			return
+		case <-ticker.C:
+			t.Flush()
+		}
+	}
+}
+
+// Flush exports all buffered spans
+func (t *Tracer) Flush() {
+	t.mu.Lock()
+	spans := t.pending
+	t.pending = nil
+	t.mu.Unlock()
+
+	if len(spans) == 0 {
+		return
+	}
+	if err := t.export(spans); err != nil {
+		log.Printf("Warning: failed to export %d spans: %v", len(spans), err)
+	}
+}
+
+func (t *Tracer) record(span *Span) {
+	t.mu.Lock()
+	t.pending = append(t.pending, span)
+	full := len(t.pending) >= maxSpanBatch
+	t.mu.Unlock()
+
+	if full {
+		go t.Flush()
+	}
+}
+
+// export sends spans as an OTLP ExportTraceServiceRequest in JSON encoding
+func (t *Tracer) export(spans []*Span) error {
+	otlpSpans := make([]map[string]interface{}, 0, len(spans))
+	for _, s := range spans {
+		span := map[string]interface{}{
+			"traceId":           s.TraceID,
+			"spanId":            s.SpanID,
+			"name":              s.Name,
+			"kind":              s.Kind,
+			"startTimeUnixNano": strconv.FormatInt(s.Start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(s.End.UnixNano(), 10),
+			"attributes":        otlpAttributes(s.Attrs),
+		}
+		if s.ParentID != "" {
+			span["parentSpanId"] = s.ParentID
+		}
+		if s.Err != "" {
+			span["status"] = map[string]interface{}{"code": 2, "message": s.Err}
+		}
+		otlpSpans = append(otlpSpans, span)
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{
+				"attributes": otlpAttributes(map[string]interface{}{"service.name": t.service}),
+			},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]string{"name": "j0"},
+				"spans": otlpSpans,
+			}},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := t.httpClient.Post(t.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("collector returned %s", resp.Status)
+	}
+	return nil
+}
+
+// otlpAttributes converts a map to OTLP KeyValue attributes
+func otlpAttributes(attrs map[string]interface{}) []map[string]interface{} {
+	kvs := make([]map[string]interface{}, 0, len(attrs))
+	for k, v := range attrs {
+		var value map[string]interface{}
+		switch v := v.(type) {
+		case bool:
+			value = map[string]interface{}{"boolValue": v}
+		case int:
+			value = map[string]interface{}{"intValue": strconv.Itoa(v)}
+		case int64:
+			value = map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+		case float64:
+			value = map[string]interface{}{"doubleValue": v}
+		default:
+			value = map[string]interface{}{"stringValue": fmt.Sprint(v)}
+		}
+		kvs = append(kvs, map[string]interface{}{"key": k, "value": value})
+	}
+	return kvs
+}
+
+// startSpan starts a child of the span in ctx (or a new trace) and returns
+// a context carrying it. It returns a nil span when tracing is disabled.
+func startSpan(ctx context.Context, name string, kind int) (context.Context, *Span) {
+	if tracer == nil {
+		return ctx, nil
+	}
+
+	span := &Span{
+		SpanID: randomHex(8),
+		Name:   name,
+		Kind:   kind,
+		Start:  time.Now(),
+		Attrs:  make(map[string]interface{}),
+	}
+	if parent, ok := ctx.Value(spanContextKey{}).(*Span); ok {
+		span.TraceID = parent.TraceID
+		span.ParentID = parent.SpanID
+	} else {
+		span.TraceID = randomHex(16)
+	}
+
+	return context.WithValue(ctx, spanContextKey{}, span), span
+}
+
+// SetAttr records an attribute on the span
+func (s *Span) SetAttr(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.Attrs[key] = value
+	s.mu.Unlock()
+}
+
+// SetError marks the span as failed
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.Err = err.Error()
+	s.mu.Unlock()
+}
+
+// Finish ends the span and queues it for export
+func (s *Span) Finish() {
+	if s == nil || s.remote || tracer == nil {
+		return
+	}
+	s.mu.Lock()
+	s.End = time.Now()
+	s.mu.Unlock()
+	tracer.record(s)
+}
+
+// traceparent formats the span context as a W3C traceparent header
+func (s *Span) traceparent() string {
+	return "00-" + s.TraceID + "-" + s.SpanID + "-01"
+}
+
+// spanFromContext returns the local span in ctx, or nil
+func spanFromContext(ctx context.Context) *Span {
+	if span, ok := ctx.Value(spanContextKey{}).(*Span); ok && !span.remote {
+		return span
+	}
+	return nil
+}
+
+// injectTraceparent adds the traceparent header for the span in ctx
+func injectTraceparent(ctx context.Context, req *http.Request) {
+	if span, ok := ctx.Value(spanContextKey{}).(*Span); ok {
+		req.Header.Set("traceparent", span.traceparent())
+	}
+}
+
+// extractTraceparent returns ctx with the remote parent from a traceparent
+// header, or ctx unchanged when the header is missing or malformed
+func extractTraceparent(ctx context.Context, header string) context.Context {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return ctx
+	}
+	if _, err := hex.DecodeString(parts[1] + parts[2]); err != nil {
+		return ctx
+	}
+	if parts[1] == strings.Repeat("0", 32) || parts[2] == strings.Repeat("0", 16) {
+		return ctx
+	}
+	return context.WithValue(ctx, spanContextKey{}, &Span{TraceID: parts[1], SpanID: parts[2], remote: true})
+}
+
+// envOr returns the environment variable or a fallback when it is unset
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// traceHTTP wraps a handler with a server span per request, continuing any
+// trace started by the caller
+func traceHTTP(next http.Handler) http.Handler {
+	if tracer == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := extractTraceparent(r.Context(), r.Header.Get("traceparent"))
+		ctx, span := startSpan(ctx, r.Method+" "+r.URL.Path, spanKindServer)
+		span.SetAttr("http.method", r.Method)
+		span.SetAttr("http.request_id", requestIDFromContext(r.Context()))
+		span.SetAttr("http.target", r.URL.RequestURI())
+
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		span.SetAttr("http.status_code", rec.status)
+		if rec.status >= 500 {
+			span.SetError(fmt.Errorf("HTTP %d", rec.status))
+		}
+		span.Finish()
+	})
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// Raw transcripts keep byte-exact copies of the stdin given to and stdout
+// received from each execution, independent of the display forms stored in
+// history and logs. They back exact-match grading and disputes. Secrets and
+// credential patterns are masked when they are served.
+
+// rawTranscriptPath returns the file holding one stream of an execution
+func (sm *SessionManager) rawTranscriptPath(sessionID, execID, stream string) string {
+	return filepath.Join(sm.dataDir, "raw", filepath.Base(sessionID), filepath.Base(execID)+"."+stream)
+}
+
+// SaveRawTranscript stores the exact stdin and stdout bytes of an execution
+func (sm *SessionManager) SaveRawTranscript(sessionID, execID string, stdin, stdout []byte) error {
+	dir := filepath.Dir(sm.rawTranscriptPath(sessionID, execID, "stdin"))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(sm.rawTranscriptPath(sessionID, execID, "stdin"), stdin, 0644); err != nil {
+		return err
+	}
+	return os.WriteFile(sm.rawTranscriptPath(sessionID, execID, "stdout"), stdout, 0644)
+}
+
+// SaveRawCode keeps an execution's code when history only has a redacted
+// copy, so it can be rerun
+func (sm *SessionManager) SaveRawCode(sessionID, execID string, code []byte) error {
+	path := sm.rawTranscriptPath(sessionID, execID, "code")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, code, 0600)
+}
+
+// RawTranscript returns the stored bytes of one stream ("stdin", "stdout"
+// or "code")
+func (sm *SessionManager) RawTranscript(sessionID, execID, stream string) ([]byte, error) {
+	return os.ReadFile(sm.rawTranscriptPath(sessionID, execID, stream))
+}
+
+// handleGetExecution returns a single execution. With ?raw=true the response
+// includes base64 copies of the exact stdin/stdout bytes, with secrets and
+// credential patterns masked; adding &stream=stdin|stdout returns that
+// stream's bytes otherwise unmodified. Executions started with
+// execute?wait= are long-polled with ?wait= until they finish.
+func handleGetExecution(w http.ResponseWriter, r *http.Request) {
+	sessionID, execID := r.PathValue("id"), r.PathValue("exec_id")
+	if resumeLongPoll(w, r, sessionID, execID) {
+		return
+	}
+	sm := sessionsFor(r.Context())
+	exec, err := sm.GetExecution(sessionID, execID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	query := r.URL.Query()
+	if query.Get("raw") != "true" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(exec)
+		return
+	}
+
+	session, err := sm.GetSession(sessionID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	redact := sm.redactor(session).redact
+
+	if stream := query.Get("stream"); stream != "" {
+		if stream != "stdin" && stream != "stdout" {
+			http.Error(w, "stream must be stdin or stdout", http.StatusBadRequest)
+			return
+		}
+		data, err := sm.RawTranscript(sessionID, execID, stream)
+		if err != nil {
+			http.Error(w, "raw transcript not available", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write([]byte(redact(string(data))))
+		return
+	}
+
+	stdin, errIn := sm.RawTranscript(sessionID, execID, "stdin")
+	stdout, errOut := sm.RawTranscript(sessionID, execID, "stdout")
+	if errIn != nil || errOut != nil {
+		http.Error(w, "raw transcript not available", http.StatusNotFound)
+		return
+	}
+
+	// The digest is of the stored bytes, so exact-match grading still works
+	stdoutSum := sha256.Sum256(stdout)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"execution": exec,
+		"raw": map[string]string{
+			"stdin":         base64.StdEncoding.EncodeToString([]byte(redact(string(stdin)))),
+			"stdout":        base64.StdEncoding.EncodeToString([]byte(redact(string(stdout)))),
+			"stdout_sha256": hex.EncodeToString(stdoutSum[:]),
+		},
+	})
+}
@@ -0,0 +1,46 @@
+package main
+
+import "fmt"
+
+// LimitWarning flags an execution that came close to a resource limit
+type LimitWarning struct {
+	Resource string  `json:"resource"` // "cpu_time" or "memory"
+	Used     float64 `json:"used"`
+	Limit    float64 `json:"limit"`
+	Percent  float64 `json:"percent"`
+	Message  string  `json:"message"`
+}
+
+// limitWarnings returns warnings for resources used at or above
+// softLimitPercent of the submission's limits
+func limitWarnings(sub Judge0Submission, cpuSeconds float64, memoryKB int) []LimitWarning {
+	if softLimitPercent <= 0 {
+		return nil
+	}
+
+	var warnings []LimitWarning
+	check := func(resource string, used, limit float64, format func(float64) string) {
+		if limit <= 0 {
+			return
+		}
+		percent := used / limit * 100
+		if percent < softLimitPercent {
+			return
+		}
+		warnings = append(warnings, LimitWarning{
+			Resource: resource,
+			Used:     used,
+			Limit:    limit,
+			Percent:  percent,
+			Message:  fmt.Sprintf("used %s of %s %s (%.0f%%)", format(used), format(limit), resource, percent),
+		})
+	}
+
+	check("cpu_time", cpuSeconds, float64(sub.CPUTimeLimit), func(v float64) string {
+		return fmt.Sprintf("%.2fs", v)
+	})
+	check("memory", float64(memoryKB), float64(sub.MemoryLimit), func(v float64) string {
+		return fmt.Sprintf("%.1fMB", v/1024)
+	})
+	return warnings
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// SessionUsage tracks resources a session has consumed on the orchestrator side
+type SessionUsage struct {
+	MemorySeconds float64 `json:"memory_mb_seconds"`
+	DiskBytes     int64   `json:"disk_bytes"`
+}
+
+// Budgets are per-session resource ceilings enforced by the watchdog
+type Budgets struct {
+	MemorySeconds float64 // cumulative MB·s of peak memory × CPU time, 0 disables
+	DiskBytes     int64   // log and sidecar bytes on disk, 0 disables
+	Action        string  // "pause" or "close"
+}
+
+// Enabled reports whether any budget is configured
+func (b Budgets) Enabled() bool {
+	return b.MemorySeconds > 0 || b.DiskBytes > 0
+}
+
+// Validate checks the configured action
+func (b Budgets) Validate() error {
+	if b.Action != "pause" && b.Action != "close" {
+		return fmt.Errorf("invalid budget action: %s (want pause or close)", b.Action)
+	}
+	return nil
+}
+
+// sessionDiskUsage sums the on-disk footprint of a session's files
+func (sm *SessionManager) sessionDiskUsage(session *Session) int64 {
+	var total int64
+	for _, path := range []string{session.LogFile, sm.ExecutionsFile(session)} {
+		if info, err := os.Stat(path); err == nil {
+			total += info.Size()
+		}
+	}
+	return total
+}
+
+// EnforceBudget refreshes a session's usage and pauses or closes it when a
+// budget is exceeded, emitting a budget_exceeded event
+func (sm *SessionManager) EnforceBudget(sessionID string, budgets Budgets) {
+	sm.mu.Lock()
+	defer sm.unlockAndDispatch()
+
+	session, ok := sm.sessions[sessionID]
+	if !ok || session.Status != "active" {
+		return
+	}
+
+	session.Usage.DiskBytes = sm.sessionDiskUsage(session)
+
+	detail := map[string]interface{}{}
+	if budgets.MemorySeconds > 0 && session.Usage.MemorySeconds > budgets.MemorySeconds {
+		detail["budget"] = "memory_seconds"
+		detail["used"] = session.Usage.MemorySeconds
+		detail["limit"] = budgets.MemorySeconds
+	} else if budgets.DiskBytes > 0 && session.Usage.DiskBytes > budgets.DiskBytes {
+		detail["budget"] = "disk_bytes"
+		detail["used"] = session.Usage.DiskBytes
+		detail["limit"] = budgets.DiskBytes
+	} else {
+		return
+	}
+
+	status := "paused"
+	if budgets.Action == "close" {
+		status = "closed"
+	}
+	detail["action"] = budgets.Action
+
+	log.Printf("Session %s exceeded %s budget, marking %s", session.ID, detail["budget"], status)
+	sm.emit(EventBudgetExceeded, session, detail)
+	if err := sm.setStatus(session, status); err != nil {
+		log.Printf("Warning: failed to update session %s: %v", session.ID, err)
+		return
+	}
+	audit(systemContext, "session."+budgets.Action, session.ID, detail)
+}
+
+// RunWatchdog enforces budgets on all active sessions until ctx is cancelled
+func (sm *SessionManager) RunWatchdog(ctx context.Context, budgets Budgets, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for _, s := range sm.ListSessions() {
+			sm.EnforceBudget(s.ID, budgets)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// WebhookNotifier delivers session lifecycle events to external systems
+// (LMS, billing, inventory) so they can provision related resources.
+type WebhookNotifier struct {
+	urls       []string
+	template   *template.Template
+	retries    int
+	httpClient *http.Client
+	wg         sync.WaitGroup
+}
+
+// NewWebhookNotifier creates a notifier for the given URLs.
+// If templatePath is set, the file is parsed as a text/template rendered with
+// the SessionEvent to produce the request body; otherwise the event is sent as JSON.
+func NewWebhookNotifier(urls []string, templatePath string, retries int) (*WebhookNotifier, error) {
+	n := &WebhookNotifier{
+		urls:    urls,
+		retries: retries,
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+
+	if templatePath != "" {
+		data, err := os.ReadFile(templatePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read webhook template: %w", err)
+		}
+		tmpl, err := template.New("webhook").Funcs(template.FuncMap{
+			"json": func(v interface{}) (string, error) {
+				b, err := json.Marshal(v)
+				return string(b), err
+			},
+		}).Parse(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse webhook template: %w", err)
+		}
+		n.template = tmpl
+	}
+
+	return n, nil
+}
+
+// Notify renders the event payload and delivers it asynchronously to every URL
+func (n *WebhookNotifier) Notify(event SessionEvent) {
+	payload, err := n.render(event)
+	if err != nil {
+		log.Printf("Warning: failed to render webhook payload: %v", err)
+		return
+	}
+
+	for _, url := range n.urls {
+		n.wg.Add(1)
+		go func(url string) {
+			defer n.wg.Done()
+			if err := n.deliver(url, event.Type, payload); err != nil {
+				log.Printf("Warning: webhook %s failed for %s: %v", url, event.Type, err)
+			}
+		}(url)
+	}
+}
+
+// Wait blocks until all pending deliveries have finished
+func (n *WebhookNotifier) Wait() {
+	n.wg.Wait()
+}
+
+// render produces the request body for an event
+func (n *WebhookNotifier) render(event SessionEvent) ([]byte, error) {
+	if n.template == nil {
+		return json.Marshal(event)
+	}
+
+	var buf bytes.Buffer
+	if err := n.template.Execute(&buf, event); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// deliver posts the payload, retrying with exponential backoff on failure
+func (n *WebhookNotifier) deliver(url, eventType string, payload []byte) error {
+	var lastErr error
+	backoff := 500 * time.Millisecond
+
+	for attempt := 0; attempt <= n.retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+
+		req, err := http.NewRequest("POST", url, bytes.NewReader(payload))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-J0-Event", eventType)
+
+		resp, err := n.httpClient.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return nil
+		}
+		lastErr = fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	return lastErr
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// webhookReceiver records the deliveries it accepts after refusing the
+// first failures with a 503
+type webhookReceiver struct {
+	mu       sync.Mutex
+	failures int
+	events   []string
+	bodies   []string
+}
+
+func (rec *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if rec.failures > 0 {
+		rec.failures--
+		http.Error(w, "busy", http.StatusServiceUnavailable)
+		return
+	}
+	rec.events = append(rec.events, r.Header.Get("X-J0-Event"))
+	rec.bodies = append(rec.bodies, string(body))
+}
+
+func TestWebhookLifecycleEvents(t *testing.T) {
+	rec := &webhookReceiver{}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	notifier, err := NewWebhookNotifier([]string{srv.URL}, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sm, err := NewSessionManager(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Subscribers run unlocked, so they may call back into the manager
+	sm.Subscribe(func(event SessionEvent) {
+		sm.ListSessions()
+		notifier.Notify(event)
+	})
+
+	session, err := sm.CreateSession("python", "", SessionOptions{Owner: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sm.SetEnv(session.ID, "API_TOKEN", "hunter22"); err != nil {
+		t.Fatal(err)
+	}
+	notifier.Wait()
+	if err := sm.CloseSession(session.ID); err != nil {
+		t.Fatal(err)
+	}
+	notifier.Wait()
+
+	if strings.Join(rec.events, ",") != EventSessionCreated+","+EventSessionClosed {
+		t.Fatalf("events = %v", rec.events)
+	}
+	var closed SessionEvent
+	if err := json.Unmarshal([]byte(rec.bodies[1]), &closed); err != nil {
+		t.Fatal(err)
+	}
+	if closed.Type != EventSessionClosed || closed.Session.ID != session.ID || closed.Session.Owner != "alice" {
+		t.Fatalf("closed event = %+v", closed)
+	}
+	for _, body := range rec.bodies {
+		if strings.Contains(body, "hunter22") {
+			t.Fatalf("event leaks the session env: %s", body)
+		}
+	}
+}
+
+func TestWebhookTemplateAndRetries(t *testing.T) {
+	rec := &webhookReceiver{failures: 1}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "webhook.tmpl")
+	os.WriteFile(path, []byte(`{"text": {{json (printf "%s %s" .Type .Session.ID)}}}`), 0644)
+
+	notifier, err := NewWebhookNotifier([]string{srv.URL}, path, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	notifier.Notify(SessionEvent{Type: EventSessionCreated, Session: SessionSummary{ID: "sess-1"}})
+	notifier.Wait()
+
+	if len(rec.bodies) != 1 || rec.bodies[0] != `{"text": "session.created sess-1"}` {
+		t.Fatalf("deliveries = %q, want the rendered template once after a retry", rec.bodies)
+	}
+
+	if _, err := NewWebhookNotifier(nil, filepath.Join(t.TempDir(), "missing"), 0); err == nil {
+		t.Fatal("missing template accepted")
+	}
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Minimal RFC 6455 server-side WebSocket support, enough for a JSON
+// message channel without pulling in a dependency.
+
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocket opcodes
+const (
+	wsOpContinuation = 0x0
+	wsOpText         = 0x1
+	wsOpBinary       = 0x2
+	wsOpClose        = 0x8
+	wsOpPing         = 0x9
+	wsOpPong         = 0xA
+)
+
+// maxWebSocketMessage bounds the size of a single client message
+const maxWebSocketMessage = 16 << 20
+
+// wsAllowedOrigins lists origins besides the server's own that may open
+// WebSockets (--ws-allowed-origins)
+var wsAllowedOrigins []string
+
+// checkWebSocketOrigin guards against cross-site WebSocket hijacking.
+// Browsers always send Origin; requests without one come from other clients.
+func checkWebSocketOrigin(r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err == nil && u.Host != "" && strings.EqualFold(u.Host, r.Host) {
+		return nil
+	}
+	for _, allowed := range wsAllowedOrigins {
+		if strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return nil
+		}
+	}
+	return fmt.Errorf("origin %s is not allowed", origin)
+}
+
+// wsConn is an upgraded WebSocket connection
+type wsConn struct {
+	conn net.Conn
+	rw   *bufio.ReadWriter
+	mu   sync.Mutex // serializes writes
+}
+
+// upgradeWebSocket performs the opening handshake and hijacks the connection
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") ||
+		!strings.Contains(strings.ToLower(r.Header.Get("Connection")), "upgrade") {
+		return nil, fmt.Errorf("not a websocket upgrade request")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		return nil, fmt.Errorf("unsupported websocket version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		return nil, fmt.Errorf("missing Sec-WebSocket-Key")
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, fmt.Errorf("connection does not support hijacking")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	accept := base64.StdEncoding.EncodeToString(sum[:])
+
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\n")
+	fmt.Fprintf(rw, "Upgrade: websocket\r\nConnection: Upgrade\r\n")
+	fmt.Fprintf(rw, "Sec-WebSocket-Accept: %s\r\n\r\n", accept)
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &wsConn{conn: conn, rw: rw}, nil
+}
+
+// ReadMessage returns the next complete text or binary message,
+// answering pings transparently
+func (c *wsConn) ReadMessage() ([]byte, error) {
+	var message []byte
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+
+		switch opcode {
+		case wsOpPing:
+			if err := c.writeFrame(wsOpPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case wsOpPong:
+			continue
+		case wsOpClose:
+			c.writeFrame(wsOpClose, payload)
+			return nil, io.EOF
+		case wsOpText, wsOpBinary, wsOpContinuation:
+			message = append(message, payload...)
+			if len(message) > maxWebSocketMessage {
+				return nil, fmt.Errorf("message too large")
+			}
+			if fin {
+				return message, nil
+			}
+		default:
+			return nil, fmt.Errorf("unknown opcode: %d", opcode)
+		}
+	}
+}
+
+// readFrame reads a single frame; client frames must be masked
+func (c *wsConn) readFrame() (bool, byte, []byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.rw, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	fin := header[0]&0x80 != 0
+	opcode := header[0] & 0x0F
+	masked := header[1]&0x80 != 0
+	length := uint64(header[1] & 0x7F)
+
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.rw, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.rw, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+
+	if !masked {
+		return false, 0, nil, errors.New("client frames must be masked")
+	}
+	if length > maxWebSocketMessage {
+		return false, 0, nil, fmt.Errorf("frame too large")
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.rw, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.rw, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+
+	return fin, opcode, payload, nil
+}
+
+// writeFrame writes a single unmasked frame
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	header := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n < 126:
+		header = append(header, byte(n))
+	case n <= 0xFFFF:
+		header = append(header, 126, byte(n>>8), byte(n))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+
+	if _, err := c.rw.Write(header); err != nil {
+		return err
+	}
+	if _, err := c.rw.Write(payload); err != nil {
+		return err
+	}
+	return c.rw.Flush()
+}
+
+// WriteJSON sends v as a text message
+func (c *wsConn) WriteJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.writeFrame(wsOpText, data)
+}
+
+// Close closes the underlying connection
+func (c *wsConn) Close() error {
+	return c.conn.Close()
+}
+
+// handleSessionWebSocket serves an interactive execution channel for a session.
+// It takes the same credentials as POST /sessions/{id}/execute and only
+// accepts browser connections from this server's origin or an allowed one.
+// Clients send {"code": "...", "stdin": "...", "ref": "..."} messages (or
+// "stdin_template"/"stdin_params" instead of "stdin") and
+// receive "started", "status", "result" or "error" messages tagged with ref.
+func handleSessionWebSocket(w http.ResponseWriter, r *http.Request) {
+	if err := checkWebSocketOrigin(r); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	// Messages execute code, so callers need what POST /execute needs
+	if !hasRole(r.Context(), RoleOperator) {
+		http.Error(w, "read-only credentials can't execute code", http.StatusForbidden)
+		return
+	}
+
+	id := r.PathValue("id")
+	session, err := sessionsFor(r.Context()).GetSession(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	conn, err := upgradeWebSocket(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer conn.Close()
+
+	// Replay the session's MOTD before any execution
+	if session.Init != nil {
+		if err := conn.WriteJSON(motdMessage(session.Init)); err != nil {
+			return
+		}
+	}
+
+	// Browsers send Accept-Language with the upgrade request
+	locale := requestLocale(r)
+	for {
+		data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var msg struct {
+			Ref   string `json:"ref,omitempty"`
+			Code  string `json:"code"`
+			Stdin string `json:"stdin,omitempty"`
+
+			StdinTemplate string            `json:"stdin_template,omitempty"`
+			StdinParams   map[string]string `json:"stdin_params,omitempty"`
+
+			QueueTimeout float64 `json:"queue_timeout,omitempty"`
+			Timeout      float64 `json:"timeout,omitempty"`
+
+			ExpectedExitCode *int  `json:"expected_exit_code,omitempty"`
+			Format           *bool `json:"format,omitempty"`
+
+			CaptureEnv map[string]string `json:"capture_env,omitempty"`
+
+			SubmissionParams
+		}
+		if err := json.Unmarshal(data, &msg); err != nil {
+			conn.WriteJSON(map[string]string{"type": "error", "error": "invalid message: " + err.Error()})
+			continue
+		}
+		if msg.Code == "" {
+			conn.WriteJSON(map[string]string{"type": "error", "ref": msg.Ref, "error": "code is required"})
+			continue
+		}
+
+		req := ExecRequest{
+			Code:          msg.Code,
+			Stdin:         msg.Stdin,
+			StdinTemplate: msg.StdinTemplate,
+			StdinParams:   msg.StdinParams,
+			QueueTimeout:  seconds(msg.QueueTimeout),
+			Timeout:       seconds(msg.Timeout),
+
+			ExpectedExitCode: msg.ExpectedExitCode,
+			Format:           msg.Format,
+			CaptureEnv:       msg.CaptureEnv,
+			Params:           msg.SubmissionParams,
+			OnStart: func(execID string) {
+				conn.WriteJSON(map[string]string{"type": "started", "ref": msg.Ref, "execution_id": execID})
+			},
+			OnStatus: func(status Status) {
+				conn.WriteJSON(map[string]interface{}{"type": "status", "ref": msg.Ref, "status": status.localized(locale)})
+			},
+		}
+
+		if err := executeLimiter.AllowClient(r); err != nil {
+			conn.WriteJSON(map[string]string{"type": "error", "ref": msg.Ref, "error": err.Error(), "code": "rate_limited"})
+			continue
+		}
+
+		exec, err := runExecution(r.Context(), id, req)
+		if err != nil {
+			conn.WriteJSON(map[string]string{"type": "error", "ref": msg.Ref, "error": err.Error(), "code": errorCode(err)})
+			continue
+		}
+
+		result := executionResponse(exec, locale)
+		result["type"] = "result"
+		result["ref"] = msg.Ref
+		if err := conn.WriteJSON(result); err != nil {
+			return
+		}
+	}
+}
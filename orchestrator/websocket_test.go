@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// clientFrame encodes a client frame; masked frames use a fixed key
+func clientFrame(fin, masked bool, opcode byte, payload []byte) []byte {
+	b0 := opcode
+	if fin {
+		b0 |= 0x80
+	}
+	frame := []byte{b0}
+
+	var maskBit byte
+	if masked {
+		maskBit = 0x80
+	}
+	switch n := len(payload); {
+	case n < 126:
+		frame = append(frame, maskBit|byte(n))
+	case n <= 0xFFFF:
+		frame = append(frame, maskBit|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, maskBit|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+
+	if !masked {
+		return append(frame, payload...)
+	}
+	mask := []byte{0x12, 0x34, 0x56, 0x78}
+	frame = append(frame, mask...)
+	for i, c := range payload {
+		frame = append(frame, c^mask[i%4])
+	}
+	return frame
+}
+
+// pipeConn returns a server-side wsConn and the client end it reads from
+func pipeConn(t *testing.T) (*wsConn, net.Conn) {
+	t.Helper()
+	server, client := net.Pipe()
+	t.Cleanup(func() {
+		server.Close()
+		client.Close()
+	})
+	rw := bufio.NewReadWriter(bufio.NewReader(server), bufio.NewWriter(server))
+	return &wsConn{conn: server, rw: rw}, client
+}
+
+func TestWebSocketReadMessage(t *testing.T) {
+	large := bytes.Repeat([]byte("x"), 70000)
+	oversizeHeader := []byte{0x80 | wsOpText, 0x80 | 127}
+	oversizeHeader = binary.BigEndian.AppendUint64(oversizeHeader, maxWebSocketMessage+1)
+	half := bytes.Repeat([]byte("y"), maxWebSocketMessage/2+1)
+
+	tests := []struct {
+		name  string
+		input [][]byte
+		want  string // message, or error substring when err is set
+		err   bool
+	}{
+		{
+			name:  "masked text",
+			input: [][]byte{clientFrame(true, true, wsOpText, []byte(`{"code":"echo hi"}`))},
+			want:  `{"code":"echo hi"}`,
+		},
+		{
+			name:  "16-bit length",
+			input: [][]byte{clientFrame(true, true, wsOpBinary, large[:300])},
+			want:  string(large[:300]),
+		},
+		{
+			name:  "64-bit length",
+			input: [][]byte{clientFrame(true, true, wsOpBinary, large)},
+			want:  string(large),
+		},
+		{
+			name: "fragmented",
+			input: [][]byte{
+				clientFrame(false, true, wsOpText, []byte("hel")),
+				clientFrame(true, true, wsOpContinuation, []byte("lo")),
+			},
+			want: "hello",
+		},
+		{
+			name: "ping between fragments",
+			input: [][]byte{
+				clientFrame(false, true, wsOpText, []byte("hel")),
+				clientFrame(true, true, wsOpPing, []byte("p")),
+				clientFrame(true, true, wsOpContinuation, []byte("lo")),
+			},
+			want: "hello",
+		},
+		{
+			name:  "unmasked frame",
+			input: [][]byte{clientFrame(true, false, wsOpText, []byte("hi"))},
+			want:  "client frames must be masked",
+			err:   true,
+		},
+		{
+			name:  "oversize frame",
+			input: [][]byte{oversizeHeader},
+			want:  "frame too large",
+			err:   true,
+		},
+		{
+			name: "oversize fragmented message",
+			input: [][]byte{
+				clientFrame(false, true, wsOpText, half),
+				clientFrame(true, true, wsOpContinuation, half),
+			},
+			want: "message too large",
+			err:  true,
+		},
+		{
+			name:  "unknown opcode",
+			input: [][]byte{clientFrame(true, true, 0x3, nil)},
+			want:  "unknown opcode",
+			err:   true,
+		},
+		{
+			name:  "close",
+			input: [][]byte{clientFrame(true, true, wsOpClose, nil)},
+			want:  io.EOF.Error(),
+			err:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, client := pipeConn(t)
+			go func() {
+				for _, frame := range tt.input {
+					if _, err := client.Write(frame); err != nil {
+						return
+					}
+				}
+			}()
+			// Pongs and close replies have to be read for writes not to block
+			go io.Copy(io.Discard, client)
+
+			message, err := conn.ReadMessage()
+			if tt.err {
+				if err == nil || !strings.Contains(err.Error(), tt.want) {
+					t.Fatalf("ReadMessage error = %v, want %q", err, tt.want)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReadMessage: %v", err)
+			}
+			if string(message) != tt.want {
+				t.Fatalf("message = %.40q (%d bytes), want %.40q (%d bytes)", message, len(message), tt.want, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestWebSocketPingIsAnswered(t *testing.T) {
+	conn, client := pipeConn(t)
+	go func() {
+		client.Write(clientFrame(true, true, wsOpPing, []byte("are you there")))
+		client.Write(clientFrame(true, true, wsOpText, []byte("done")))
+	}()
+
+	pong := make(chan []byte, 1)
+	go func() {
+		buf := make([]byte, 2+len("are you there"))
+		io.ReadFull(client, buf)
+		pong <- buf
+	}()
+
+	if _, err := conn.ReadMessage(); err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	frame := <-pong
+	if frame[0] != 0x80|wsOpPong || string(frame[2:]) != "are you there" {
+		t.Fatalf("pong frame = %q", frame)
+	}
+}
+
+func TestCheckWebSocketOrigin(t *testing.T) {
+	defer func(saved []string) { wsAllowedOrigins = saved }(wsAllowedOrigins)
+	wsAllowedOrigins = []string{"https://ide.example.com/"}
+
+	tests := []struct {
+		name   string
+		origin string
+		ok     bool
+	}{
+		{name: "no origin", origin: "", ok: true},
+		{name: "same host", origin: "http://j0.example.com:8080", ok: true},
+		{name: "same host other scheme", origin: "https://J0.example.com:8080", ok: true},
+		{name: "allowed origin", origin: "https://ide.example.com", ok: true},
+		{name: "other site", origin: "https://evil.example.com"},
+		{name: "allowed host over another scheme", origin: "http://ide.example.com"},
+		{name: "same host other port", origin: "http://j0.example.com"},
+		{name: "null", origin: "null"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "http://j0.example.com:8080/sessions/s/ws", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			err := checkWebSocketOrigin(r)
+			if tt.ok && err != nil {
+				t.Fatalf("origin %q rejected: %v", tt.origin, err)
+			}
+			if !tt.ok && err == nil {
+				t.Fatalf("origin %q accepted", tt.origin)
+			}
+		})
+	}
+}
+
+func TestHandleSessionWebSocketRejectsBeforeUpgrade(t *testing.T) {
+	tests := []struct {
+		name   string
+		origin string
+		role   string
+		want   int
+	}{
+		{name: "cross-site origin", origin: "https://evil.example.com", role: RoleOperator, want: http.StatusForbidden},
+		{name: "read-only credentials", role: RoleReadOnly, want: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "http://j0.example.com/sessions/s/ws", nil)
+			r.Header.Set("Upgrade", "websocket")
+			r.Header.Set("Connection", "Upgrade")
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			r = r.WithContext(withRole(r.Context(), tt.role))
+			r.SetPathValue("id", "s")
+
+			w := httptest.NewRecorder()
+			handleSessionWebSocket(w, r)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxWorkspaceBytes bounds the size of a session workspace sent to Judge0
+const maxWorkspaceBytes = 16 << 20
+
+// WorkspaceFile describes a file in a session workspace
+type WorkspaceFile struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// WorkspaceDir returns the directory holding a session's workspace files
+func (sm *SessionManager) WorkspaceDir(sessionID string) string {
+	return filepath.Join(sm.dataDir, "workspaces", sessionID)
+}
+
+// workspacePath resolves a relative path inside a session workspace,
+// rejecting paths that escape it
+func (sm *SessionManager) workspacePath(sessionID, rel string) (string, error) {
+	if _, err := sm.GetSession(sessionID); err != nil {
+		return "", err
+	}
+
+	clean := filepath.Clean("/" + filepath.FromSlash(rel))
+	if clean == string(filepath.Separator) {
+		return "", fmt.Errorf("file path is required")
+	}
+	return filepath.Join(sm.WorkspaceDir(sessionID), clean), nil
+}
+
+// WriteFile stores a file in the session workspace
+func (sm *SessionManager) WriteFile(sessionID, rel string, data []byte) error {
+	defer sm.lockWorkspace(sessionID)()
+
+	path, err := sm.workspacePath(sessionID, rel)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// ReadFile reads a file from the session workspace
+func (sm *SessionManager) ReadFile(sessionID, rel string) ([]byte, error) {
+	path, err := sm.workspacePath(sessionID, rel)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("file not found: %s", rel)
+	}
+	return data, err
+}
+
+// DeleteFile removes a file from the session workspace
+func (sm *SessionManager) DeleteFile(sessionID, rel string) error {
+	defer sm.lockWorkspace(sessionID)()
+
+	path, err := sm.workspacePath(sessionID, rel)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("file not found: %s", rel)
+		}
+		return err
+	}
+	return nil
+}
+
+// ListFiles returns all files in the session workspace, sorted by path
+func (sm *SessionManager) ListFiles(sessionID string) ([]WorkspaceFile, error) {
+	if _, err := sm.GetSession(sessionID); err != nil {
+		return nil, err
+	}
+	return listDirFiles(sm.WorkspaceDir(sessionID))
+}
+
+// listDirFiles walks a directory and returns its regular files with slash paths
+func listDirFiles(root string) ([]WorkspaceFile, error) {
+	files := []WorkspaceFile{}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == root {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		files = append(files, WorkspaceFile{
+			Path:    filepath.ToSlash(rel),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		})
+		return nil
+	})
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, err
+}
+
+// WorkspaceArchive returns the workspace as a base64 zip for Judge0's
+// additional_files, or "" when the workspace is empty
+func (sm *SessionManager) WorkspaceArchive(sessionID string) (string, error) {
+	data, err := workspaceZip(sm.WorkspaceDir(sessionID), nil)
+	if err != nil || data == nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+// workspaceZip zips the files under root, replacing or adding the files in
+// extra (keyed by slash path). It returns nil when there is nothing to zip.
+func workspaceZip(root string, extra map[string][]byte) ([]byte, error) {
+	files, err := listDirFiles(root)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 && len(extra) == 0 {
+		return nil, nil
+	}
+
+	var total int64
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		if _, replaced := extra[f.Path]; replaced {
+			continue
+		}
+		total += f.Size
+		if total > maxWorkspaceBytes {
+			return nil, fmt.Errorf("workspace exceeds %d bytes", maxWorkspaceBytes)
+		}
+
+		w, err := zw.Create(f.Path)
+		if err != nil {
+			return nil, err
+		}
+		src, err := os.Open(filepath.Join(root, filepath.FromSlash(f.Path)))
+		if err != nil {
+			return nil, err
+		}
+		_, err = io.Copy(w, src)
+		src.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	names := make([]string, 0, len(extra))
+	for name := range extra {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		total += int64(len(extra[name]))
+		if total > maxWorkspaceBytes {
+			return nil, fmt.Errorf("workspace exceeds %d bytes", maxWorkspaceBytes)
+		}
+		w, err := zw.Create(name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(extra[name]); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// HTTP handlers
+
+func handleListFiles(w http.ResponseWriter, r *http.Request) {
+	files, err := sessionsFor(r.Context()).ListFiles(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(files)
+}
+
+func handleReadFile(w http.ResponseWriter, r *http.Request) {
+	data, err := sessionsFor(r.Context()).ReadFile(r.PathValue("id"), r.PathValue("path"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(data)
+}
+
+func handleWriteFile(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxWorkspaceBytes+1))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(data) > maxWorkspaceBytes {
+		http.Error(w, "file too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	if err := sessionsFor(r.Context()).WriteFile(r.PathValue("id"), r.PathValue("path"), data); err != nil {
+		status := http.StatusInternalServerError
+		if strings.Contains(err.Error(), "not found") || strings.Contains(err.Error(), "required") {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	audit(r.Context(), "session.file_write", r.PathValue("id"), map[string]interface{}{
+		"path":   r.PathValue("path"),
+		"bytes":  len(data),
+		"sha256": sha256Hex(data),
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleDeleteFile(w http.ResponseWriter, r *http.Request) {
+	if err := sessionsFor(r.Context()).DeleteFile(r.PathValue("id"), r.PathValue("path")); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	audit(r.Context(), "session.file_delete", r.PathValue("id"), map[string]interface{}{"path": r.PathValue("path")})
+
+	w.WriteHeader(http.StatusNoContent)
+}